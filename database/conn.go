@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// ErrUnavailable is returned when the database cannot be reached, even after a retry.
+var ErrUnavailable = errors.New("database unavailable")
+
+// DB wraps a connection pool and retries a statement once when the connection to the server was
+// lost, e.g. because MySQL restarted; the pool replaces the broken connection for the retry.
+// Reads are retried after any connection error. Writes are only retried on driver.ErrBadConn,
+// which guarantees that the statement never reached the server, since the server may already have
+// applied a write whose connection broke while waiting for the result. Connection errors that
+// remain after the retry are reported as ErrUnavailable. Statements are rewritten for the Dialect
+// of the server before they run.
+//
+// When QueryTimeout is positive, each statement, and each transaction as a whole, is cancelled
+// after that long even if its context has no deadline, e.g. for the scheduler, so that queries
+// against a struggling server cannot pile up. A statement cancelled that way also fails with
+// ErrUnavailable.
+type DB struct {
+	*sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
+}
+
+// Wrap returns db, a pool of connections to a server of the given dialect, with the retry
+// behaviour of DB.
+func Wrap(db *sql.DB, dialect Dialect) *DB {
+	return &DB{DB: db, Dialect: dialect}
+}
+
+// isConnError reports whether err means the connection to the server failed, rather than the
+// statement. Errors caused by the context are not connection errors.
+func isConnError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// retry runs fn and runs it once more if it failed with an error that retryable accepts and
+// the context is still live. Connection errors of the final attempt are wrapped in ErrUnavailable.
+func (db *DB) retry(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	err := fn()
+	if err != nil && retryable(err) && ctx.Err() == nil {
+		slog.Warn("Database connection lost, retrying", slog.Any("error", err))
+		err = fn()
+	}
+	return unavailable(err)
+}
+
+// unavailable wraps connection errors in ErrUnavailable and returns other errors unchanged.
+func unavailable(err error) error {
+	if err != nil && isConnError(err) {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
+
+// withTimeout returns ctx bounded by the query timeout, and the function releasing it.
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, db.QueryTimeout)
+}
+
+// timedOut reports err as ErrUnavailable when it was caused by the query timeout of queryCtx
+// rather than by ctx, the context of the caller, which is still live.
+func timedOut(ctx, queryCtx context.Context, timeout time.Duration, err error) error {
+	if err != nil && ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: query timed out after %s", ErrUnavailable, timeout)
+	}
+	return err
+}
+
+// isBadConn reports whether err guarantees that a statement was not sent to the server.
+func isBadConn(err error) bool {
+	return errors.Is(err, driver.ErrBadConn)
+}
+
+// ExecContext executes a statement, retrying it if it could not be sent.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = db.Dialect.Rebind(query), db.Dialect.bindArgs(args)
+	var result sql.Result
+	err := db.retry(ctx, isBadConn, func() error {
+		queryCtx, cancel := db.withTimeout(ctx)
+		defer cancel()
+		var err error
+		result, err = db.DB.ExecContext(queryCtx, query, args...)
+		return timedOut(ctx, queryCtx, db.QueryTimeout, err)
+	})
+	return result, err
+}
+
+// QueryContext runs a query, retrying it if the connection was lost. The query timeout also
+// bounds reading the rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	query, args = db.Dialect.Rebind(query), db.Dialect.bindArgs(args)
+	rows := &Rows{ctx: ctx, timeout: db.QueryTimeout}
+	err := db.retry(ctx, isConnError, func() error {
+		rows.queryCtx, rows.cancel = db.withTimeout(ctx)
+		var err error
+		if rows.Rows, err = db.DB.QueryContext(rows.queryCtx, query, args...); err != nil {
+			rows.cancel()
+			return timedOut(ctx, rows.queryCtx, db.QueryTimeout, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Rows is the result of a query, like sql.Rows. Rows of DB.QueryContext must be closed to release
+// the query timeout, and report it as ErrUnavailable when it cut them short.
+type Rows struct {
+	*sql.Rows
+	ctx, queryCtx context.Context
+	cancel        context.CancelFunc
+	timeout       time.Duration
+}
+
+// Close closes the rows, like sql.Rows.Close.
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	return err
+}
+
+// Err returns the error that ended the iteration, like sql.Rows.Err.
+func (r *Rows) Err() error {
+	return timedOut(r.ctx, r.queryCtx, r.timeout, r.Rows.Err())
+}
+
+// QueryRowContext prepares a query expected to return at most one row. The query runs when the
+// row is scanned, so that it can be retried if the connection was lost.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	return &Row{db: db, ctx: ctx, query: db.Dialect.Rebind(query), args: db.Dialect.bindArgs(args)}
+}
+
+// InsertID runs an INSERT into a table with an id column and returns the id of the new row, or
+// sql.ErrNoRows when the statement inserted none, e.g. an INSERT ... SELECT selecting nothing.
+func (db *DB) InsertID(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if db.Dialect == Postgres {
+		// Postgres has no last insert id; the row reports its own
+		var id int64
+		err := db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return insertedID(result)
+}
+
+// insertedID returns the id of the row inserted by a statement with result, or sql.ErrNoRows.
+func insertedID(result sql.Result) (int64, error) {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return result.LastInsertId()
+}
+
+// Row is the result of DB.QueryRowContext.
+type Row struct {
+	db    *DB
+	ctx   context.Context
+	query string
+	args  []interface{}
+}
+
+// Scan runs the query and copies the columns of the first row into dest, like sql.Row.Scan.
+func (r *Row) Scan(dest ...interface{}) error {
+	return r.db.retry(r.ctx, isConnError, func() error {
+		queryCtx, cancel := r.db.withTimeout(r.ctx)
+		defer cancel()
+		err := r.db.DB.QueryRowContext(queryCtx, r.query, r.args...).Scan(dest...)
+		return timedOut(r.ctx, queryCtx, r.db.QueryTimeout, err)
+	})
+}
+
+// Tx is a transaction started by WithTx. Its statements are rewritten for the dialect like those of
+// DB, but not retried, since a lost connection ends the transaction. The query timeout bounds the
+// transaction as a whole: its statements share the deadline of ctx, the context it began with.
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+	ctx     context.Context
+}
+
+// withDeadline returns ctx bounded by the deadline of the transaction, and the function releasing
+// it. A statement still running when the transaction is cancelled would keep it from rolling back.
+func (tx *Tx) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := tx.ctx.Deadline(); ok {
+		return context.WithDeadline(ctx, deadline)
+	}
+	return ctx, func() {}
+}
+
+// ExecContext executes a statement within the transaction.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := tx.withDeadline(ctx)
+	defer cancel()
+	return tx.Tx.ExecContext(ctx, tx.Dialect.Rebind(query), tx.Dialect.bindArgs(args)...)
+}
+
+// QueryContext runs a query within the transaction.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	queryCtx, cancel := tx.withDeadline(ctx)
+	rows, err := tx.Tx.QueryContext(queryCtx, tx.Dialect.Rebind(query), tx.Dialect.bindArgs(args)...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Rows{Rows: rows, ctx: ctx, queryCtx: ctx, cancel: cancel}, nil
+}
+
+// QueryRowContext runs a query expected to return at most one row within the transaction.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *TxRow {
+	return &TxRow{tx: tx, ctx: ctx, query: tx.Dialect.Rebind(query), args: tx.Dialect.bindArgs(args)}
+}
+
+// TxRow is the result of Tx.QueryRowContext.
+type TxRow struct {
+	tx    *Tx
+	ctx   context.Context
+	query string
+	args  []interface{}
+}
+
+// Scan runs the query and copies the columns of the first row into dest, like sql.Row.Scan.
+func (r *TxRow) Scan(dest ...interface{}) error {
+	ctx, cancel := r.tx.withDeadline(r.ctx)
+	defer cancel()
+	return r.tx.Tx.QueryRowContext(ctx, r.query, r.args...).Scan(dest...)
+}
+
+// PrepareContext prepares a statement for use within the transaction. The arguments of the
+// statement are passed to the driver as they are, so they must not be times.
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, cancel := tx.withDeadline(ctx)
+	defer cancel()
+	return tx.Tx.PrepareContext(ctx, tx.Dialect.Rebind(query))
+}
+
+// InsertID runs an INSERT within the transaction, like DB.InsertID.
+func (tx *Tx) InsertID(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if tx.Dialect == Postgres {
+		var id int64
+		err := tx.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return insertedID(result)
+}
+
+// Savepoint runs fn within a savepoint, rolling back only the statements of fn when it fails, so
+// the transaction can go on. Postgres would otherwise refuse every further statement of a
+// transaction in which one failed.
+func (tx *Tx) Savepoint(ctx context.Context, fn func() error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT attempt"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT attempt"); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT attempt")
+	return err
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeConnector is a driver opening connections to a fake server that fails the next failures
+// statements with failure, and counts the statements it receives.
+type fakeConnector struct {
+	mu       sync.Mutex
+	failures int
+	failure  error
+	queries  int
+	execs    int
+}
+
+func (f *fakeConnector) Open(string) (driver.Conn, error) { return &fakeConn{f}, nil }
+
+func (f *fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+
+func (f *fakeConnector) Driver() driver.Driver { return f }
+
+// statement counts a statement in count and returns the error it fails with, if any.
+func (f *fakeConnector) statement(count *int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*count++
+	if f.failures > 0 {
+		f.failures--
+		return f.failure
+	}
+	return nil
+}
+
+// fakeConn is a connection of fakeConnector. Queries return a single row holding 1.
+type fakeConn struct {
+	server *fakeConnector
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.server.statement(&c.server.queries); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.server.statement(&c.server.execs); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// fakeRows holds a single row with a single column.
+type fakeRows struct {
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+// openFakeDB returns a DB on a fake server failing the first failures statements with failure.
+func openFakeDB(t *testing.T, failures int, failure error) (*DB, *fakeConnector) {
+	server := &fakeConnector{failures: failures, failure: failure}
+	pool := sql.OpenDB(server)
+	t.Cleanup(func() { pool.Close() })
+	return Wrap(pool, MySQL), server
+}
+
+func TestQueryRetriedAfterBadConn(t *testing.T) {
+	db, server := openFakeDB(t, 1, driver.ErrBadConn)
+
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("got %d, %v, want 1", n, err)
+	}
+	if server.queries != 2 {
+		t.Fatalf("server received %d queries, want 2", server.queries)
+	}
+}
+
+func TestQueryRetriedAfterLostConnection(t *testing.T) {
+	// database/sql only retries driver.ErrBadConn itself, so this retry is the wrapper's
+	db, server := openFakeDB(t, 1, mysql.ErrInvalidConn)
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatalf("QueryContext: %v", err)
+	}
+	rows.Close()
+	if server.queries != 2 {
+		t.Fatalf("server received %d queries, want 2", server.queries)
+	}
+}
+
+func TestQueryUnavailableAfterRetry(t *testing.T) {
+	db, server := openFakeDB(t, 2, mysql.ErrInvalidConn)
+
+	var n int
+	err := db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	if server.queries != 2 {
+		t.Fatalf("server received %d queries, want 2", server.queries)
+	}
+}
+
+func TestExecRetriedOnlyAfterBadConn(t *testing.T) {
+	db, server := openFakeDB(t, 1, driver.ErrBadConn)
+	if _, err := db.ExecContext(context.Background(), "UPDATE events SET name = ?", "moved"); err != nil {
+		t.Fatalf("ExecContext after driver.ErrBadConn: %v", err)
+	}
+	if server.execs != 2 {
+		t.Fatalf("server received %d statements, want 2", server.execs)
+	}
+
+	// The write may have been applied before the connection broke, so it is not sent again
+	db, server = openFakeDB(t, 1, mysql.ErrInvalidConn)
+	if _, err := db.ExecContext(context.Background(), "UPDATE events SET name = ?", "moved"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("ExecContext after a lost connection: got %v, want ErrUnavailable", err)
+	}
+	if server.execs != 1 {
+		t.Fatalf("server received %d statements, want 1", server.execs)
+	}
+}
+
+func TestStatementErrorsNotRetried(t *testing.T) {
+	failure := errors.New("Error 1064: You have an error in your SQL syntax")
+	db, server := openFakeDB(t, 1, failure)
+
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); !errors.Is(err, failure) || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want the error of the statement", err)
+	}
+	if server.queries != 1 {
+		t.Fatalf("server received %d queries, want 1", server.queries)
+	}
+}
@@ -1,64 +1,325 @@
 package database
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
 	"fmt"
 	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"log"
+	"log/slog"
+	_ "modernc.org/sqlite"
 	"os"
+	"strconv"
 	"time"
 )
 
-// initTLS initializes a custom TLS configuration for secure database connections.
-// It loads the CA certificate from the environment variable and registers a custom TLS config with MySQL driver.
-func initTLS() error {
-	rootCertPool := x509.NewCertPool()
-	// Append Aiven CA certificate to the root certificate pool
-	if ok := rootCertPool.AppendCertsFromPEM([]byte(AivenCA)); !ok {
-		return fmt.Errorf("failed to append Aiven CA certificate")
+// TLS modes for the database connection, selected with the DB_TLS_MODE environment variable
+const (
+	TLSRequire    = "require"     // verify the server against the Aiven CA certificate (default)
+	TLSSkipVerify = "skip-verify" // encrypt but accept any server certificate (local development only)
+	TLSDisable    = "disable"     // plain, unencrypted connection (local development only)
+)
+
+// parseTLSMode validates a DB_TLS_MODE value, defaulting to the secure require mode when empty.
+func parseTLSMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return TLSRequire, nil
+	case TLSRequire, TLSSkipVerify, TLSDisable:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown DB_TLS_MODE %q, expected %s, %s or %s", mode, TLSRequire, TLSSkipVerify, TLSDisable)
+	}
+}
+
+// loadCA returns the PEM-encoded CA certificate of the database server. It is read from the file
+// named by CERTIFICATE_FILE when set, which suits deployments that mount the certificate, and
+// otherwise taken inline from CERTIFICATE.
+func loadCA() ([]byte, error) {
+	if path := os.Getenv("CERTIFICATE_FILE"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CERTIFICATE_FILE: %w", err)
+		}
+		return pem, nil
+	}
+	if AivenCA != "" {
+		return []byte(AivenCA), nil
 	}
+	return nil, fmt.Errorf("no CA certificate configured: set CERTIFICATE_FILE or CERTIFICATE, or change DB_TLS_MODE")
+}
+
+// newTLSConfig returns the TLS configuration for secure database connections. In require mode it
+// loads the CA certificate with loadCA; in skip-verify mode server certificates are not checked.
+func newTLSConfig(mode string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if mode == TLSSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	} else {
+		ca, err := loadCA()
+		if err != nil {
+			return nil, err
+		}
 
-	tlsConfig := &tls.Config{
-		RootCAs: rootCertPool,
+		rootCertPool := x509.NewCertPool()
+		// Append Aiven CA certificate to the root certificate pool
+		if ok := rootCertPool.AppendCertsFromPEM(ca); !ok {
+			return nil, fmt.Errorf("failed to append Aiven CA certificate")
+		}
+		tlsConfig.RootCAs = rootCertPool
+	}
+
+	return tlsConfig, nil
+}
+
+// initTLS initializes a custom TLS configuration for secure MySQL connections, see newTLSConfig.
+// The config is registered with the MySQL driver as "custom".
+func initTLS(mode string) error {
+	tlsConfig, err := newTLSConfig(mode)
+	if err != nil {
+		return err
 	}
 
 	// Register the TLS configuration with the MySQL driver
 	return mysql.RegisterTLSConfig("custom", tlsConfig)
 }
 
-// AivenCA holds the database's CA certificate loaded from an environment variable
-const AivenCA = os.Getenv("CERTIFICATE")
+// Character set and collation of the connection and of every table. MySQL's utf8 charset holds
+// at most three bytes per character, which excludes emoji.
+const (
+	Charset   = "utf8mb4"
+	Collation = "utf8mb4_unicode_ci"
+)
+
+// Upper bound for the delay between two connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// loadConnectRetry reads DB_CONNECT_ATTEMPTS (default 10) and DB_CONNECT_BACKOFF, the delay before
+// the second attempt (default 1s), which doubles after every failed attempt.
+func loadConnectRetry() (int, time.Duration, error) {
+	attempts, backoff := 10, time.Second
 
-// Connect establishes a connection to the MySQL database, configures connection settings,
-// and ensures the required tables are created.
-func Connect() (*sql.DB, error) {
-	// Initialize TLS for secure database connections
-	if err := initTLS(); err != nil {
-		log.Fatalf("Failed to initialize TLS: %v", err)
+	if raw := os.Getenv("DB_CONNECT_ATTEMPTS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("DB_CONNECT_ATTEMPTS must be a positive integer, got %q", raw)
+		}
+		attempts = n
 	}
 
-	// Retrieve database credentials from environment variables
-	dsn := os.Getenv("DB_CREDS")
-	db, err := sql.Open("mysql", dsn)
+	if raw := os.Getenv("DB_CONNECT_BACKOFF"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("DB_CONNECT_BACKOFF must be a positive duration such as 1s, got %q", raw)
+		}
+		backoff = d
+	}
+
+	return attempts, backoff, nil
+}
+
+// loadQueryTimeout reads DB_QUERY_TIMEOUT, the longest a statement or transaction may run
+// (default 10s). 0 lets them run until their context ends.
+func loadQueryTimeout() (time.Duration, error) {
+	raw := os.Getenv("DB_QUERY_TIMEOUT")
+	if raw == "" {
+		return 10 * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("DB_QUERY_TIMEOUT must be a duration such as 10s, or 0 to disable it, got %q", raw)
+	}
+	return d, nil
+}
+
+// pingWithRetry calls ping until it succeeds or attempts run out, sleeping between attempts with
+// exponential backoff starting at backoff and capped at maxConnectBackoff. Each failure is logged.
+func pingWithRetry(ctx context.Context, ping func(context.Context) error, attempts int, backoff time.Duration) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ping(ctx); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		slog.Warn("Database not reachable, retrying", slog.Int("attempt", attempt), slog.Int("attempts", attempts),
+			slog.Duration("backoff", backoff), slog.Any("error", err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+	return fmt.Errorf("database not reachable after %d attempts: %w", attempts, err)
+}
+
+// AivenCA holds the database's CA certificate given inline in the CERTIFICATE environment variable
+var AivenCA = os.Getenv("CERTIFICATE")
+
+// Connect establishes a connection to the database selected with DB_DRIVER, MySQL by default,
+// configures connection settings, and ensures the required tables are created.
+func Connect() (*DB, error) {
+	dialect, err := parseDialect(os.Getenv("DB_DRIVER"))
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+
+	// A SQLite database is a local file, so there is no connection to secure
+	tlsMode := TLSDisable
+	if dialect != SQLite {
+		tlsMode, err = parseTLSMode(os.Getenv("DB_TLS_MODE"))
+		if err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		if tlsMode != TLSRequire {
+			slog.Warn("Database connection is not secure", slog.String("tls_mode", tlsMode))
+		}
+	}
+
+	attempts, backoff, err := loadConnectRetry()
+	if err != nil {
+		log.Fatalf("Invalid connection retry configuration: %v", err)
+	}
+
+	queryTimeout, err := loadQueryTimeout()
+	if err != nil {
+		log.Fatalf("Invalid query timeout configuration: %v", err)
+	}
+
+	var pool *sql.DB
+	switch dialect {
+	case Postgres:
+		pool = openPostgres(tlsMode)
+	case SQLite:
+		pool, err = openSQLite()
+	default:
+		pool, err = openMySQL(tlsMode)
+	}
 	if err != nil {
 		log.Fatalf("Error opening database: %v", err)
 		return nil, err
 	}
 
+	// The database may still be starting up, e.g. when launched alongside the app in a container
+	if err := pingWithRetry(context.Background(), pool.PingContext, attempts, backoff); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
 	// Configure database connection settings
-	db.SetConnMaxLifetime(time.Minute * 3) // Maximum connection lifetime
-	db.SetMaxOpenConns(10)                 // Maximum number of open connections
-	db.SetMaxIdleConns(10)                 // Maximum number of idle connections
+	pool.SetConnMaxLifetime(time.Minute * 3) // Maximum connection lifetime
+	pool.SetMaxOpenConns(10)                 // Maximum number of open connections
+	pool.SetMaxIdleConns(10)                 // Maximum number of idle connections
+
+	if dialect == MySQL {
+		createMySQLTables(pool)
+	}
+
+	// Apply schema changes made since the base tables were introduced
+	db := Wrap(pool, dialect)
+	if err := migrate(db); err != nil {
+		log.Fatal("Error migrating database: ", err)
+	}
+
+	// Migrations may rewrite large tables, so only later statements are bounded
+	db.QueryTimeout = queryTimeout
+
+	return db, nil
+}
 
+// openMySQL opens a MySQL connection pool for the DB_CREDS data source name.
+func openMySQL(tlsMode string) (*sql.DB, error) {
+	// Initialize TLS for secure database connections unless it is disabled
+	if tlsMode != TLSDisable {
+		if err := initTLS(tlsMode); err != nil {
+			log.Fatalf("Failed to initialize TLS: %v", err)
+		}
+	}
+
+	// Retrieve database credentials from environment variables
+	cfg, err := mysql.ParseDSN(os.Getenv("DB_CREDS"))
+	if err != nil {
+		log.Fatalf("Invalid DB_CREDS: %v", err)
+	}
+
+	// DATETIME columns are scanned into time.Time values
+	cfg.ParseTime = true
+
+	// Text is exchanged as utf8mb4 whatever the DSN or server default says, so emoji and other
+	// characters outside the Basic Multilingual Plane are not mangled
+	if err := cfg.Apply(mysql.Charset(Charset, Collation)); err != nil {
+		log.Fatalf("Invalid DB_CREDS: %v", err)
+	}
+
+	// The TLS mode decides whether the custom TLS config is used, regardless of the DSN
+	cfg.TLSConfig = "custom"
+	if tlsMode == TLSDisable {
+		cfg.TLSConfig = ""
+	}
+
+	return sql.Open("mysql", cfg.FormatDSN())
+}
+
+// openPostgres opens a PostgreSQL connection pool for DB_CREDS, a postgres:// URL or a list of
+// key=value settings.
+func openPostgres(tlsMode string) *sql.DB {
+	cfg, err := pgx.ParseConfig(os.Getenv("DB_CREDS"))
+	if err != nil {
+		log.Fatalf("Invalid DB_CREDS: %v", err)
+	}
+
+	// The TLS mode decides how the connection is secured, regardless of sslmode in the DSN
+	cfg.TLSConfig, cfg.Fallbacks = nil, nil
+	if tlsMode != TLSDisable {
+		tlsConfig, err := newTLSConfig(tlsMode)
+		if err != nil {
+			log.Fatalf("Failed to initialize TLS: %v", err)
+		}
+		tlsConfig.ServerName = cfg.Host
+		cfg.TLSConfig = tlsConfig
+	}
+
+	return stdlib.OpenDB(*cfg)
+}
+
+// Database file used by the SQLite driver when DB_CREDS is empty
+const defaultSQLiteFile = "reminders.db"
+
+// openSQLite opens the SQLite database in the file named by DB_CREDS, creating it if needed.
+func openSQLite() (*sql.DB, error) {
+	path := os.Getenv("DB_CREDS")
+	if path == "" {
+		path = defaultSQLiteFile
+	}
+
+	// SQLite leaves foreign keys unchecked unless asked. In WAL mode reads go on during a write, and
+	// write transactions take the database lock when they begin, waiting for it rather than failing
+	// when another connection writes.
+	return sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(10000)&_txlock=immediate")
+}
+
+// createMySQLTables creates the base tables of the MySQL schema, the users and events tables that
+// the migrations extend.
+func createMySQLTables(db *sql.DB) {
 	// Create the users table if it does not exist
 	createUserSQL := `CREATE TABLE IF NOT EXISTS users (
 		id INT AUTO_INCREMENT PRIMARY KEY,
 		username VARCHAR(255) NOT NULL UNIQUE,
 		password VARCHAR(255) NOT NULL
-	);`
-	_, err = db.Exec(createUserSQL)
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation + `;`
+	_, err := db.Exec(createUserSQL)
 	if err != nil {
 		log.Fatal("Error creating users table: ", err)
 	}
@@ -72,11 +333,45 @@ func Connect() (*sql.DB, error) {
 		user_id INT NOT NULL,
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 		UNIQUE (name, user_id)
-	);`
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation + `;`
 	_, err = db.Exec(createTableSQL)
 	if err != nil {
 		log.Fatal("Error creating events table: ", err)
 	}
+}
 
-	return db, nil
+// WithTx runs fn inside a database transaction. The transaction is committed when fn returns nil
+// and rolled back when it returns an error or panics; a panic is re-raised after the rollback.
+// Starting the transaction is retried like a read, but fn is not run again if the connection is
+// lost while it runs; that failure is reported as ErrUnavailable, like the query timeout expiring
+// before the transaction is committed, which rolls it back.
+func WithTx(ctx context.Context, db *DB, fn func(*Tx) error) error {
+	txCtx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var sqlTx *sql.Tx
+	err := db.retry(ctx, isConnError, func() error {
+		var err error
+		sqlTx, err = db.BeginTx(txCtx, nil)
+		return err
+	})
+	if err != nil {
+		return timedOut(ctx, txCtx, db.QueryTimeout, err)
+	}
+	tx := &Tx{Tx: sqlTx, Dialect: db.Dialect, ctx: txCtx}
+
+	// Roll back and propagate the panic if fn does not return normally
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return timedOut(ctx, txCtx, db.QueryTimeout, unavailable(err))
+	}
+
+	return timedOut(ctx, txCtx, db.QueryTimeout, unavailable(tx.Commit()))
 }
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestDB returns a SQLite database in a temporary file holding a table of names.
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	pool, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	db := Wrap(pool, SQLite)
+	if _, err := db.ExecContext(context.Background(), "CREATE TABLE names (name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// countNames returns how many rows the table of names holds.
+func countNames(t *testing.T, db *DB) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM names").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestWithTxCommits(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	err := WithTx(ctx, db, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO names (name) VALUES (?)", "first"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO names (name) VALUES (?)", "second")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if count := countNames(t, db); count != 2 {
+		t.Fatalf("got %d rows after commit, want 2", count)
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	failure := errors.New("failure")
+
+	err := WithTx(ctx, db, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO names (name) VALUES (?)", "first"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got error %v, want %v", err, failure)
+	}
+	if count := countNames(t, db); count != 0 {
+		t.Fatalf("got %d rows after rollback, want 0", count)
+	}
+}
+
+func TestWithTxRollsBackAndRepanics(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("got panic %v, want boom", p)
+			}
+		}()
+		WithTx(ctx, db, func(tx *Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO names (name) VALUES (?)", "first"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+		t.Fatal("WithTx returned instead of panicking")
+	}()
+
+	if count := countNames(t, db); count != 0 {
+		t.Fatalf("got %d rows after panic, want 0", count)
+	}
+
+	// The connection went back to the pool without the transaction, so it can be used again
+	if err := WithTx(ctx, db, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO names (name) VALUES (?)", "second")
+		return err
+	}); err != nil {
+		t.Fatalf("WithTx after panic: %v", err)
+	}
+	if count := countNames(t, db); count != 1 {
+		t.Fatalf("got %d rows, want 1", count)
+	}
+}
+
+func TestParseTLSMode(t *testing.T) {
+	tests := []struct {
+		raw  string
+		mode string
+		ok   bool
+	}{
+		{"", TLSRequire, true},
+		{"require", TLSRequire, true},
+		{"skip-verify", TLSSkipVerify, true},
+		{"disable", TLSDisable, true},
+		{"Require", "", false},
+		{"insecure", "", false},
+	}
+	for _, test := range tests {
+		mode, err := parseTLSMode(test.raw)
+		if (err == nil) != test.ok || mode != test.mode {
+			t.Errorf("DB_TLS_MODE=%q: got %q, %v", test.raw, mode, err)
+		}
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Setenv("CERTIFICATE_FILE", "")
+	saved := AivenCA
+	t.Cleanup(func() { AivenCA = saved })
+	AivenCA = ""
+
+	// Only skip-verify accepts any server certificate, and it needs no CA
+	config, err := newTLSConfig(TLSSkipVerify)
+	if err != nil || !config.InsecureSkipVerify {
+		t.Fatalf("skip-verify: got %+v, %v", config, err)
+	}
+	if config, err := newTLSConfig(TLSRequire); err == nil {
+		t.Fatalf("require without a CA: got %+v, want an error", config)
+	}
+}
+
+// testCA returns a self-signed CA certificate in PEM encoding.
+func testCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestLoadCA(t *testing.T) {
+	saved := AivenCA
+	t.Cleanup(func() { AivenCA = saved })
+	inline, mounted := testCA(t), testCA(t)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte(mounted), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, inline, file, want string
+	}{
+		{"inline", inline, "", inline},
+		{"file", "", path, mounted},
+		{"file preferred", inline, path, mounted},
+		{"missing file", inline, filepath.Join(t.TempDir(), "missing.pem"), ""},
+		{"neither", "", "", ""},
+	}
+	for _, test := range tests {
+		AivenCA = test.inline
+		t.Setenv("CERTIFICATE_FILE", test.file)
+		ca, err := loadCA()
+		if string(ca) != test.want || (err == nil) != (test.want != "") {
+			t.Errorf("%s: got %q, %v, want %q", test.name, ca, err, test.want)
+		}
+		if test.want == "" && err != nil && !strings.Contains(err.Error(), "CERTIFICATE_FILE") {
+			t.Errorf("%s: got error %q, want it to name CERTIFICATE_FILE", test.name, err)
+		}
+
+		// The certificate loaded is the one require mode trusts
+		config, err := newTLSConfig(TLSRequire)
+		if (err == nil) != (test.want != "") || err == nil && config.RootCAs == nil {
+			t.Errorf("%s: require mode got %+v, %v", test.name, config, err)
+		}
+	}
+}
+
+// flakyPing fails its first failures calls and then succeeds, counting every call.
+type flakyPing struct {
+	failures int
+	calls    int
+}
+
+func (p *flakyPing) ping(context.Context) error {
+	p.calls++
+	if p.calls <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestPingWithRetry(t *testing.T) {
+	ctx := context.Background()
+
+	p := &flakyPing{failures: 2}
+	if err := pingWithRetry(ctx, p.ping, 3, time.Millisecond); err != nil || p.calls != 3 {
+		t.Fatalf("database up at the last attempt: got %v after %d pings, want success after 3", err, p.calls)
+	}
+
+	p = &flakyPing{failures: 3}
+	err := pingWithRetry(ctx, p.ping, 3, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") || p.calls != 3 {
+		t.Fatalf("database down: got %v after %d pings, want the last error after 3", err, p.calls)
+	}
+
+	// A cancelled context stops the retries
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	p = &flakyPing{failures: 3}
+	if err := pingWithRetry(cancelled, p.ping, 3, time.Hour); !errors.Is(err, context.Canceled) || p.calls != 1 {
+		t.Fatalf("cancelled: got %v after %d pings, want context.Canceled after 1", err, p.calls)
+	}
+}
+
+func TestLoadConnectRetry(t *testing.T) {
+	tests := []struct {
+		attempts, backoff string
+		wantAttempts      int
+		wantBackoff       time.Duration
+		ok                bool
+	}{
+		{"", "", 10, time.Second, true},
+		{"3", "250ms", 3, 250 * time.Millisecond, true},
+		{"0", "", 0, 0, false},
+		{"three", "", 0, 0, false},
+		{"", "0s", 0, 0, false},
+		{"", "soon", 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Setenv("DB_CONNECT_ATTEMPTS", test.attempts)
+		t.Setenv("DB_CONNECT_BACKOFF", test.backoff)
+		attempts, backoff, err := loadConnectRetry()
+		if (err == nil) != test.ok || attempts != test.wantAttempts || backoff != test.wantBackoff {
+			t.Errorf("DB_CONNECT_ATTEMPTS=%q DB_CONNECT_BACKOFF=%q: got %d, %v, %v", test.attempts, test.backoff, attempts, backoff, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect is the SQL flavour of the database server. The stores write their statements for MySQL
+// with ? placeholders; DB rewrites them for the server with Rebind, and the methods of Dialect build
+// the fragments that differ too much to be rewritten.
+type Dialect string
+
+// Supported database servers, selected with the DB_DRIVER environment variable
+const (
+	MySQL    Dialect = "mysql"    // default; the production database
+	Postgres Dialect = "postgres" // PostgreSQL through pgx
+	SQLite   Dialect = "sqlite"   // a local file, for development without a database server
+)
+
+// parseDialect validates a DB_DRIVER value, defaulting to MySQL when empty.
+func parseDialect(driver string) (Dialect, error) {
+	switch Dialect(driver) {
+	case "":
+		return MySQL, nil
+	case MySQL, Postgres, SQLite:
+		return Dialect(driver), nil
+	default:
+		return "", fmt.Errorf("unknown DB_DRIVER %q, expected %s, %s or %s", driver, MySQL, Postgres, SQLite)
+	}
+}
+
+// Layout of times stored by SQLite, which has no date type; text in this layout sorts and
+// compares in time order.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000"
+
+// Rebind rewrites a statement written for MySQL for d. Postgres numbers its placeholders and
+// spells the null-safe comparison <=> as IS NOT DISTINCT FROM; SQLite spells it IS and locks the
+// whole database for a write transaction, so it has no FOR UPDATE. JSON_ARRAYAGG is json_agg and
+// json_group_array respectively. Question marks inside string literals are left alone.
+func (d Dialect) Rebind(query string) string {
+	switch d {
+	case Postgres:
+		query = strings.NewReplacer("<=>", "IS NOT DISTINCT FROM", "JSON_ARRAYAGG(", "json_agg(").Replace(query)
+		var b strings.Builder
+		n, quoted := 0, false
+		for _, r := range query {
+			switch {
+			case r == '\'':
+				quoted = !quoted
+			case r == '?' && !quoted:
+				n++
+				b.WriteString("$" + strconv.Itoa(n))
+				continue
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	case SQLite:
+		return strings.NewReplacer("<=>", "IS", "JSON_ARRAYAGG(", "json_group_array(",
+			" FOR UPDATE SKIP LOCKED", "", " FOR UPDATE", "").Replace(query)
+	default:
+		return query
+	}
+}
+
+// bindArgs converts the arguments of a statement for d. Times are passed in UTC, since the columns
+// hold UTC without a zone; pgx would otherwise keep the clock time and drop the zone. SQLite gets
+// them as text in sqliteTimeLayout.
+func (d Dialect) bindArgs(args []interface{}) []interface{} {
+	var bound []interface{}
+	for i, arg := range args {
+		var t time.Time
+		switch v := arg.(type) {
+		case time.Time:
+			t = v
+		case *time.Time:
+			if v == nil {
+				continue
+			}
+			t = *v
+		default:
+			continue
+		}
+
+		// Copy the arguments before the first change, so the caller's slice is left untouched
+		if bound == nil {
+			bound = append([]interface{}(nil), args...)
+		}
+		if d == SQLite {
+			bound[i] = t.UTC().Format(sqliteTimeLayout)
+		} else {
+			bound[i] = t.UTC()
+		}
+	}
+	if bound == nil {
+		return args
+	}
+	return bound
+}
+
+// SubtractSeconds returns an expression for the time t less the number of seconds given by the
+// expression seconds; either may be a placeholder.
+func (d Dialect) SubtractSeconds(t, seconds string) string {
+	switch d {
+	case Postgres:
+		return "(CAST(" + t + " AS TIMESTAMP) - CAST(" + seconds + " AS INTEGER) * INTERVAL '1 second')"
+	case SQLite:
+		// %f has milliseconds; the trailing zeros keep the result in sqliteTimeLayout
+		return "strftime('%Y-%m-%d %H:%M:%f000', " + t + ", '-' || (" + seconds + ") || ' seconds')"
+	default:
+		return "DATE_SUB(" + t + ", INTERVAL " + seconds + " SECOND)"
+	}
+}
+
+// FormatRFC3339 returns an expression formatting the UTC time column as RFC3339, e.g. for a LIKE
+// on dates as the API shows them.
+func (d Dialect) FormatRFC3339(column string) string {
+	switch d {
+	case Postgres:
+		return "to_char(" + column + `, 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`
+	case SQLite:
+		return "strftime('%Y-%m-%dT%H:%M:%SZ', " + column + ")"
+	default:
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%dT%H:%i:%sZ')"
+	}
+}
+
+// Like returns a case-insensitive LIKE condition on column with a placeholder for the pattern, in
+// which a backslash escapes the wildcards. MySQL's collation already ignores case; SQLite's LIKE
+// ignores the case of ASCII letters only.
+func (d Dialect) Like(column string) string {
+	switch d {
+	case Postgres:
+		return column + " ILIKE ?"
+	case SQLite:
+		return column + ` LIKE ? ESCAPE '\'`
+	default:
+		return column + " LIKE ?"
+	}
+}
+
+// Upsert returns the clause appended to an INSERT that overwrites columns of the row already
+// holding the unique key instead of failing.
+func (d Dialect) Upsert(key string, columns ...string) string {
+	sets := make([]string, len(columns))
+	for i, column := range columns {
+		if d == MySQL {
+			sets[i] = column + " = VALUES(" + column + ")"
+		} else {
+			sets[i] = column + " = excluded." + column
+		}
+	}
+	if d == MySQL {
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	return " ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// FirstRows returns the WHERE clause of an UPDATE or DELETE on table restricted to the rows with
+// the lowest ids that satisfy where, as many as the last placeholder gives. Only MySQL limits
+// UPDATE and DELETE directly; Postgres skips rows that another transaction has locked, so that
+// concurrent claims take different rows instead of waiting.
+func (d Dialect) FirstRows(table, where string) string {
+	switch d {
+	case Postgres:
+		return "id IN (SELECT id FROM " + table + " WHERE " + where + " ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED)"
+	case SQLite:
+		return "id IN (SELECT id FROM " + table + " WHERE " + where + " ORDER BY id LIMIT ?)"
+	default:
+		return where + " ORDER BY id LIMIT ?"
+	}
+}
+
+// IsDuplicate reports whether err is the violation of a UNIQUE constraint or primary key.
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// MySQL error number for a duplicate entry
+		return mysqlErr.Number == 1062
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// SQLSTATE of unique_violation
+		return pgErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
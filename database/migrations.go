@@ -0,0 +1,371 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// migrations holds the MySQL schema changes applied on top of the base tables created in Connect.
+// Each statement runs exactly once, in order; append new entries and never edit existing ones.
+// Entries after baseVersion need their Postgres and SQLite variants in laterMigrations.
+var migrations = []string{
+	// 1: event priorities
+	`ALTER TABLE events ADD COLUMN priority VARCHAR(16) NOT NULL DEFAULT 'normal'`,
+	// 2: user contact and timezone settings
+	`ALTER TABLE users ADD COLUMN email VARCHAR(255) NOT NULL DEFAULT '', ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC'`,
+	// 3: links attached to events
+	`ALTER TABLE events ADD COLUMN url VARCHAR(2048) NOT NULL DEFAULT ''`,
+	// 4: long-lived API keys, stored as SHA-256 hashes
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		prefix VARCHAR(16) NOT NULL,
+		key_hash CHAR(64) NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME NULL,
+		revoked_at DATETIME NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 5: audit log of account activity; user_id is NULL for attempts on unknown usernames
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NULL,
+		username VARCHAR(255) NOT NULL,
+		action VARCHAR(32) NOT NULL,
+		ip VARCHAR(45) NOT NULL,
+		created_at DATETIME NOT NULL,
+		INDEX (user_id, id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 6: completion of one-off reminders
+	`ALTER TABLE events ADD COLUMN completed_at DATETIME NULL`,
+	// 7: notification delivery attempts and their retry state
+	`CREATE TABLE IF NOT EXISTS deliveries (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		event_id INT NOT NULL,
+		event_name VARCHAR(255) NOT NULL,
+		channel VARCHAR(32) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL,
+		next_attempt_at DATETIME NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		INDEX (status, next_attempt_at),
+		INDEX (user_id, id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`,
+	// 8: per-event selection of notification channels, comma-separated; empty selects all
+	`ALTER TABLE events ADD COLUMN channels VARCHAR(255) NOT NULL DEFAULT ''`,
+	// 9: scheduler state, so that several instances can share due events without firing them twice
+	`ALTER TABLE events ADD COLUMN fired_at DATETIME NULL, ADD COLUMN locked_by VARCHAR(64) NULL, ADD COLUMN locked_at DATETIME NULL,
+		ADD INDEX (fired_at, date)`,
+	// 10: claims on delivery retries, for the same reason
+	`ALTER TABLE deliveries ADD COLUMN claimed_by VARCHAR(64) NULL`,
+	// 11: creation and modification times of events, set by the application; created_at pins list pages
+	`ALTER TABLE events ADD COLUMN created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+		ADD COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)`,
+	// 12: lookup of the deliveries of one event for its detailed view
+	`ALTER TABLE deliveries ADD INDEX (event_id)`,
+	// 13: per-user opt-out from the purge of old events
+	`ALTER TABLE users ADD COLUMN keep_events BOOLEAN NOT NULL DEFAULT FALSE`,
+	// 14: organizations whose members share their events
+	`CREATE TABLE IF NOT EXISTS organizations (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		invite_code VARCHAR(64) NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL
+	)`,
+	// 15: membership of users in an organization
+	`ALTER TABLE users ADD COLUMN org_id INT NULL, ADD FOREIGN KEY (org_id) REFERENCES organizations(id)`,
+	// 16: events shared with an organization; names are unique within it
+	`ALTER TABLE events ADD COLUMN org_id INT NULL, ADD UNIQUE INDEX (org_id, name),
+		ADD FOREIGN KEY (org_id) REFERENCES organizations(id)`,
+	// 17: additional email recipients of events
+	`CREATE TABLE IF NOT EXISTS event_recipients (
+		event_id INT NOT NULL,
+		email VARCHAR(255) NOT NULL,
+		PRIMARY KEY (event_id, email),
+		FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+	)`,
+	// 18: deliveries to additional recipients; empty for the owner
+	`ALTER TABLE deliveries ADD COLUMN recipient VARCHAR(255) NOT NULL DEFAULT ''`,
+	// 19: event templates of users
+	`CREATE TABLE IF NOT EXISTS templates (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		name_pattern VARCHAR(255) NOT NULL,
+		message TEXT NOT NULL,
+		priority VARCHAR(16) NOT NULL,
+		url VARCHAR(2048) NOT NULL DEFAULT '',
+		channels VARCHAR(255) NOT NULL DEFAULT '',
+		recipients TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		INDEX (user_id)
+	)`,
+	// 20: tables created from now on default to utf8mb4
+	`ALTER DATABASE CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 21: users text in utf8mb4
+	`ALTER TABLE users CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 22: events text in utf8mb4
+	`ALTER TABLE events CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 23: api_keys text in utf8mb4
+	`ALTER TABLE api_keys CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 24: audit_log text in utf8mb4
+	`ALTER TABLE audit_log CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 25: deliveries text in utf8mb4
+	`ALTER TABLE deliveries CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 26: organizations text in utf8mb4
+	`ALTER TABLE organizations CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 27: event_recipients text in utf8mb4
+	`ALTER TABLE event_recipients CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 28: templates text in utf8mb4
+	`ALTER TABLE templates CONVERT TO CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 29: deliveries of notifications resent on request
+	`ALTER TABLE deliveries ADD COLUMN manual BOOLEAN NOT NULL DEFAULT FALSE`,
+	// 30: per-event switch for email reminders
+	`ALTER TABLE events ADD COLUMN email_notifications BOOLEAN NOT NULL DEFAULT TRUE`,
+	// 31: recurrence rules of repeating events and the start of their series
+	`ALTER TABLE events ADD COLUMN recurrence VARCHAR(255) NOT NULL DEFAULT '',
+		ADD COLUMN series_start VARCHAR(255) NOT NULL DEFAULT ''`,
+	// 32: event dates rewritten from RFC3339 UTC to the DATETIME literal format; dates that were
+	// never validated and cannot be read fall back to the creation time of their event
+	`UPDATE events SET date = CASE
+		WHEN date REGEXP '^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}Z$' THEN CONCAT(LEFT(date, 10), ' ', SUBSTRING(date, 12, 8))
+		WHEN date REGEXP '^[0-9]{4}-[0-9]{2}-[0-9]{2}$' THEN CONCAT(date, ' 00:00:00')
+		ELSE DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s')
+	END`,
+	// 33: series starts rewritten the same way, starting at the event's date when unset
+	`UPDATE events SET series_start = CASE
+		WHEN series_start REGEXP '^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}Z$' THEN CONCAT(LEFT(series_start, 10), ' ', SUBSTRING(series_start, 12, 8))
+		ELSE date
+	END`,
+	// 34: event dates stored as DATETIME in UTC
+	`ALTER TABLE events MODIFY date DATETIME NOT NULL, MODIFY series_start DATETIME NOT NULL`,
+	// 35: refresh tokens renewing short-lived access tokens, stored as SHA-256 hashes
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 36: webhooks users registered for their reminders, with the secret signing the calls
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		url VARCHAR(2048) NOT NULL,
+		secret VARCHAR(64) NOT NULL,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 37: log of webhook calls, looked up by delivery to skip webhooks that already accepted it
+	`CREATE TABLE IF NOT EXISTS webhook_attempts (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		webhook_id INT NOT NULL,
+		delivery_id INT NOT NULL,
+		event_id INT NOT NULL,
+		status_code INT NOT NULL DEFAULT 0,
+		error VARCHAR(1024) NOT NULL DEFAULT '',
+		created_at DATETIME(6) NOT NULL,
+		INDEX (delivery_id),
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 38: devices registered for push notifications, unique by the hash of their endpoint
+	`CREATE TABLE IF NOT EXISTS devices (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		platform VARCHAR(16) NOT NULL,
+		endpoint VARCHAR(2048) NOT NULL,
+		endpoint_hash CHAR(64) NOT NULL,
+		p256dh VARCHAR(128) NOT NULL DEFAULT '',
+		auth VARCHAR(64) NOT NULL DEFAULT '',
+		expires_at DATETIME NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE KEY (endpoint_hash),
+		INDEX (expires_at),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 39: snoozed reminders fire again when snoozed_until passes; dismissed ones stay quiet
+	`ALTER TABLE events ADD COLUMN snoozed_until DATETIME NULL, ADD COLUMN dismissed_at DATETIME NULL, ADD INDEX (snoozed_until)`,
+	// 40: additional reminders ahead of an event's date; fire_at follows the date, armed_at is when it last changed
+	`CREATE TABLE IF NOT EXISTS event_reminders (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		event_id INT NOT NULL,
+		offset_seconds INT NOT NULL,
+		fire_at DATETIME NOT NULL,
+		armed_at DATETIME NOT NULL,
+		fired_at DATETIME NULL,
+		locked_by VARCHAR(64) NULL,
+		locked_at DATETIME NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE KEY (event_id, offset_seconds),
+		INDEX (fired_at, fire_at),
+		FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 41: read-only iCalendar feeds, one per user, identified by the hash of their token
+	`CREATE TABLE IF NOT EXISTS calendar_feeds (
+		user_id INT PRIMARY KEY,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		last_fetched_at DATETIME NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 42: emailed password reset tokens, identified by their hash; used_at is set once one is redeemed
+	`CREATE TABLE IF NOT EXISTS password_resets (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 43: tags organizing events, lowercase; an event carries each tag once
+	`CREATE TABLE IF NOT EXISTS event_tags (
+		event_id INT NOT NULL,
+		tag VARCHAR(32) NOT NULL,
+		PRIMARY KEY (event_id, tag),
+		FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 44: events looked up by tag, for lists filtered by tag and renaming tags
+	`CREATE INDEX event_tags_tag ON event_tags (tag)`,
+	// 45: deleted events stay in the trash until deleted_at is long enough ago
+	`ALTER TABLE events ADD COLUMN deleted_at DATETIME NULL`,
+	// 46: the trash looked up by deletion time, for emptying it
+	`CREATE INDEX events_deleted_at ON events (deleted_at)`,
+	// 47: events shared with other users, who may read them or also edit them
+	`CREATE TABLE IF NOT EXISTS event_shares (
+		event_id INT NOT NULL,
+		user_id INT NOT NULL,
+		permission VARCHAR(8) NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (event_id, user_id),
+		FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 48: the events shared with a user, for listing them and checking access
+	`CREATE INDEX event_shares_user_id ON event_shares (user_id)`,
+	// 49: Telegram chats users linked for reminders and creating events, one per user and user per chat
+	`CREATE TABLE IF NOT EXISTS telegram_chats (
+		user_id INT PRIMARY KEY,
+		chat_id BIGINT NOT NULL UNIQUE,
+		username VARCHAR(64) NOT NULL DEFAULT '',
+		linked_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 50: one-time codes linking a Telegram chat, one per user, identified by their hash
+	`CREATE TABLE IF NOT EXISTS telegram_link_codes (
+		user_id INT PRIMARY KEY,
+		code_hash CHAR(64) NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 51: where reminders are posted in Slack, one configuration per user: an incoming webhook, or a
+	// Slack app's bot token and channel
+	`CREATE TABLE IF NOT EXISTS slack_configs (
+		user_id INT PRIMARY KEY,
+		webhook_url VARCHAR(2048) NOT NULL DEFAULT '',
+		bot_token VARCHAR(255) NOT NULL DEFAULT '',
+		channel VARCHAR(255) NOT NULL DEFAULT '',
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 52: events opt in to SMS reminders, which cost money to send
+	`ALTER TABLE events ADD COLUMN sms_notifications BOOLEAN NOT NULL DEFAULT FALSE`,
+	// 53: verified phone numbers SMS reminders are sent to, in E.164 form, one per user
+	`CREATE TABLE IF NOT EXISTS phone_numbers (
+		user_id INT PRIMARY KEY,
+		number VARCHAR(16) NOT NULL,
+		verified_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 54: pending verifications of phone numbers, one per user, with the hash of the code texted to
+	// the number and the wrong codes tried
+	`CREATE TABLE IF NOT EXISTS phone_verifications (
+		user_id INT PRIMARY KEY,
+		number VARCHAR(16) NOT NULL,
+		code_hash CHAR(64) NOT NULL,
+		expires_at DATETIME NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 55: notification channels users turned off for their reminders, comma-separated like
+	// events.channels
+	`ALTER TABLE users ADD COLUMN disabled_channels VARCHAR(255) NOT NULL DEFAULT ''`,
+	// 56: log of every attempt of a delivery, with its outcome, for the notification history of events
+	`CREATE TABLE IF NOT EXISTS delivery_attempts (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		delivery_id INT NOT NULL,
+		event_id INT NOT NULL,
+		channel VARCHAR(32) NOT NULL,
+		recipient VARCHAR(255) NOT NULL DEFAULT '',
+		status VARCHAR(16) NOT NULL,
+		error TEXT NOT NULL,
+		attempted_at DATETIME(6) NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	) DEFAULT CHARACTER SET ` + Charset + ` COLLATE ` + Collation,
+	// 57: the attempts of one event looked up for its notification history
+	`CREATE INDEX delivery_attempts_event_id ON delivery_attempts (event_id, id)`,
+	// 58: version signed into access tokens, raised to invalidate the tokens issued before
+	`ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0`,
+	// 59: roles of users, admin for those operating the service
+	`ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user'`,
+	// 60: accounts disabled by an admin
+	`ALTER TABLE users ADD COLUMN disabled_at DATETIME NULL`,
+	// 61: admins list the events of all users by date
+	`CREATE INDEX events_date ON events (date)`,
+}
+
+// migrate applies any pending migrations and records each applied version in schema_migrations.
+// A new Postgres or SQLite database starts out with baseSchema instead of the early migrations.
+func migrate(db *DB) error {
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	if current == 0 && db.Dialect != MySQL {
+		if err := createBaseSchema(db); err != nil {
+			return err
+		}
+		current = baseVersion
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		statement := migrations[version-1]
+		if db.Dialect != MySQL {
+			var ok bool
+			if statement, ok = laterMigrations[version][db.Dialect]; !ok {
+				return fmt.Errorf("migration %d has no %s variant", version, db.Dialect)
+			}
+		}
+
+		// MySQL commits DDL implicitly, so each migration is recorded right after it succeeds
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("applying migration %d: %w", version, err)
+		}
+		if _, err := db.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
+			return fmt.Errorf("recording migration %d: %w", version, err)
+		}
+		slog.Info("Applied database migration", slog.Int("version", version))
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Migration version that baseSchema matches. Postgres and SQLite databases start out at this
+// version with all tables created at once; migrations after it need a variant for them in
+// laterMigrations.
+const baseVersion = 41
+
+// baseSchema creates the tables of a new Postgres or SQLite database as the migrations up to
+// baseVersion left them in MySQL. {id} is replaced with the dialect's auto-incrementing primary
+// key, {time} with its date type, and {nocase} makes names compare without case in SQLite, like
+// the MySQL collation; Postgres compares them with case.
+var baseSchema = []string{
+	`CREATE TABLE organizations (
+		id {id},
+		name VARCHAR(255) NOT NULL,
+		invite_code VARCHAR(64) NOT NULL UNIQUE,
+		created_at {time} NOT NULL
+	)`,
+	`CREATE TABLE users (
+		id {id},
+		username VARCHAR(255) {nocase} NOT NULL UNIQUE,
+		password VARCHAR(255) NOT NULL,
+		email VARCHAR(255) NOT NULL DEFAULT '',
+		timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+		keep_events BOOLEAN NOT NULL DEFAULT FALSE,
+		org_id INT NULL REFERENCES organizations(id)
+	)`,
+	`CREATE TABLE events (
+		id {id},
+		name VARCHAR(255) {nocase} NOT NULL,
+		message TEXT NOT NULL,
+		date {time} NOT NULL,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		priority VARCHAR(16) NOT NULL DEFAULT 'normal',
+		url VARCHAR(2048) NOT NULL DEFAULT '',
+		completed_at {time} NULL,
+		channels VARCHAR(255) NOT NULL DEFAULT '',
+		fired_at {time} NULL,
+		locked_by VARCHAR(64) NULL,
+		locked_at {time} NULL,
+		created_at {time} NOT NULL,
+		updated_at {time} NOT NULL,
+		org_id INT NULL REFERENCES organizations(id),
+		email_notifications BOOLEAN NOT NULL DEFAULT TRUE,
+		recurrence VARCHAR(255) NOT NULL DEFAULT '',
+		series_start {time} NOT NULL,
+		snoozed_until {time} NULL,
+		dismissed_at {time} NULL,
+		UNIQUE (name, user_id),
+		UNIQUE (org_id, name)
+	)`,
+	`CREATE INDEX events_fired_at ON events (fired_at, date)`,
+	`CREATE INDEX events_snoozed_until ON events (snoozed_until)`,
+	`CREATE TABLE api_keys (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		prefix VARCHAR(16) NOT NULL,
+		key_hash CHAR(64) NOT NULL UNIQUE,
+		created_at {time} NOT NULL,
+		last_used_at {time} NULL,
+		revoked_at {time} NULL
+	)`,
+	`CREATE TABLE audit_log (
+		id {id},
+		user_id INT NULL REFERENCES users(id) ON DELETE CASCADE,
+		username VARCHAR(255) NOT NULL,
+		action VARCHAR(32) NOT NULL,
+		ip VARCHAR(45) NOT NULL,
+		created_at {time} NOT NULL
+	)`,
+	`CREATE INDEX audit_log_user_id ON audit_log (user_id, id)`,
+	`CREATE TABLE deliveries (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		event_id INT NOT NULL,
+		event_name VARCHAR(255) NOT NULL,
+		channel VARCHAR(32) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL,
+		next_attempt_at {time} NULL,
+		created_at {time} NOT NULL,
+		updated_at {time} NOT NULL,
+		claimed_by VARCHAR(64) NULL,
+		recipient VARCHAR(255) NOT NULL DEFAULT '',
+		manual BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE INDEX deliveries_status ON deliveries (status, next_attempt_at)`,
+	`CREATE INDEX deliveries_user_id ON deliveries (user_id, id)`,
+	`CREATE INDEX deliveries_event_id ON deliveries (event_id)`,
+	`CREATE TABLE event_recipients (
+		event_id INT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		email VARCHAR(255) NOT NULL,
+		PRIMARY KEY (event_id, email)
+	)`,
+	`CREATE TABLE templates (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id),
+		name_pattern VARCHAR(255) NOT NULL,
+		message TEXT NOT NULL,
+		priority VARCHAR(16) NOT NULL,
+		url VARCHAR(2048) NOT NULL DEFAULT '',
+		channels VARCHAR(255) NOT NULL DEFAULT '',
+		recipients TEXT NOT NULL,
+		created_at {time} NOT NULL
+	)`,
+	`CREATE INDEX templates_user_id ON templates (user_id)`,
+	`CREATE TABLE refresh_tokens (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at {time} NOT NULL,
+		expires_at {time} NOT NULL,
+		revoked_at {time} NULL
+	)`,
+	`CREATE TABLE webhooks (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		url VARCHAR(2048) NOT NULL,
+		secret VARCHAR(64) NOT NULL,
+		created_at {time} NOT NULL
+	)`,
+	`CREATE TABLE webhook_attempts (
+		id {id},
+		webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		delivery_id INT NOT NULL,
+		event_id INT NOT NULL,
+		status_code INT NOT NULL DEFAULT 0,
+		error VARCHAR(1024) NOT NULL DEFAULT '',
+		created_at {time} NOT NULL
+	)`,
+	`CREATE INDEX webhook_attempts_delivery_id ON webhook_attempts (delivery_id)`,
+	`CREATE TABLE devices (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		platform VARCHAR(16) NOT NULL,
+		endpoint VARCHAR(2048) NOT NULL,
+		endpoint_hash CHAR(64) NOT NULL UNIQUE,
+		p256dh VARCHAR(128) NOT NULL DEFAULT '',
+		auth VARCHAR(64) NOT NULL DEFAULT '',
+		expires_at {time} NULL,
+		created_at {time} NOT NULL
+	)`,
+	`CREATE INDEX devices_expires_at ON devices (expires_at)`,
+	`CREATE TABLE event_reminders (
+		id {id},
+		event_id INT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		offset_seconds INT NOT NULL,
+		fire_at {time} NOT NULL,
+		armed_at {time} NOT NULL,
+		fired_at {time} NULL,
+		locked_by VARCHAR(64) NULL,
+		locked_at {time} NULL,
+		created_at {time} NOT NULL,
+		UNIQUE (event_id, offset_seconds)
+	)`,
+	`CREATE INDEX event_reminders_fired_at ON event_reminders (fired_at, fire_at)`,
+	`CREATE TABLE calendar_feeds (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at {time} NOT NULL,
+		last_fetched_at {time} NULL
+	)`,
+}
+
+// laterMigrations holds the Postgres and SQLite variants of the migrations after baseVersion,
+// keyed by version.
+var laterMigrations = map[int]map[Dialect]string{
+	42: portable(`CREATE TABLE password_resets (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at {time} NOT NULL,
+		expires_at {time} NOT NULL,
+		used_at {time} NULL
+	)`),
+	43: portable(`CREATE TABLE event_tags (
+		event_id INT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		tag VARCHAR(32) NOT NULL,
+		PRIMARY KEY (event_id, tag)
+	)`),
+	44: portable(`CREATE INDEX event_tags_tag ON event_tags (tag)`),
+	45: portable(`ALTER TABLE events ADD COLUMN deleted_at {time} NULL`),
+	46: portable(`CREATE INDEX events_deleted_at ON events (deleted_at)`),
+	47: portable(`CREATE TABLE event_shares (
+		event_id INT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		permission VARCHAR(8) NOT NULL,
+		created_at {time} NOT NULL,
+		PRIMARY KEY (event_id, user_id)
+	)`),
+	48: portable(`CREATE INDEX event_shares_user_id ON event_shares (user_id)`),
+	49: portable(`CREATE TABLE telegram_chats (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		chat_id BIGINT NOT NULL UNIQUE,
+		username VARCHAR(64) NOT NULL DEFAULT '',
+		linked_at {time} NOT NULL
+	)`),
+	50: portable(`CREATE TABLE telegram_link_codes (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		code_hash CHAR(64) NOT NULL UNIQUE,
+		expires_at {time} NOT NULL
+	)`),
+	51: portable(`CREATE TABLE slack_configs (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		webhook_url VARCHAR(2048) NOT NULL DEFAULT '',
+		bot_token VARCHAR(255) NOT NULL DEFAULT '',
+		channel VARCHAR(255) NOT NULL DEFAULT '',
+		updated_at {time} NOT NULL
+	)`),
+	52: portable(`ALTER TABLE events ADD COLUMN sms_notifications BOOLEAN NOT NULL DEFAULT FALSE`),
+	53: portable(`CREATE TABLE phone_numbers (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		number VARCHAR(16) NOT NULL,
+		verified_at {time} NOT NULL
+	)`),
+	54: portable(`CREATE TABLE phone_verifications (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		number VARCHAR(16) NOT NULL,
+		code_hash CHAR(64) NOT NULL,
+		expires_at {time} NOT NULL,
+		attempts INT NOT NULL DEFAULT 0
+	)`),
+	55: portable(`ALTER TABLE users ADD COLUMN disabled_channels VARCHAR(255) NOT NULL DEFAULT ''`),
+	56: portable(`CREATE TABLE delivery_attempts (
+		id {id},
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		delivery_id INT NOT NULL,
+		event_id INT NOT NULL,
+		channel VARCHAR(32) NOT NULL,
+		recipient VARCHAR(255) NOT NULL DEFAULT '',
+		status VARCHAR(16) NOT NULL,
+		error TEXT NOT NULL,
+		attempted_at {time} NOT NULL
+	)`),
+	57: portable(`CREATE INDEX delivery_attempts_event_id ON delivery_attempts (event_id, id)`),
+	58: portable(`ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0`),
+	59: portable(`ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user'`),
+	60: portable(`ALTER TABLE users ADD COLUMN disabled_at {time} NULL`),
+	61: portable(`CREATE INDEX events_date ON events (date)`),
+}
+
+// portable returns the Postgres and SQLite variants of a statement written with the placeholders
+// of baseSchema.
+func portable(statement string) map[Dialect]string {
+	return map[Dialect]string{
+		Postgres: schemaReplacer(Postgres).Replace(statement),
+		SQLite:   schemaReplacer(SQLite).Replace(statement),
+	}
+}
+
+// schemaReplacer returns the replacements of the placeholders in baseSchema for a dialect.
+func schemaReplacer(dialect Dialect) *strings.Replacer {
+	if dialect == Postgres {
+		return strings.NewReplacer("{id}", "SERIAL PRIMARY KEY", "{time}", "TIMESTAMP", "{nocase} ", "")
+	}
+	// AUTOINCREMENT keeps SQLite from reusing the ids of deleted rows, which pages rely on
+	return strings.NewReplacer("{id}", "INTEGER PRIMARY KEY AUTOINCREMENT", "{time}", "DATETIME", "{nocase}", "COLLATE NOCASE")
+}
+
+// createBaseSchema creates the tables of baseSchema in a new Postgres or SQLite database and
+// records it at baseVersion. Both run DDL in transactions, so a failure leaves the database empty.
+func createBaseSchema(db *DB) error {
+	ctx := context.Background()
+	replacer := schemaReplacer(db.Dialect)
+
+	err := WithTx(ctx, db, func(tx *Tx) error {
+		for _, statement := range baseSchema {
+			if _, err := tx.ExecContext(ctx, replacer.Replace(statement)); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", baseVersion)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("creating %s schema: %w", db.Dialect, err)
+	}
+
+	slog.Info("Created database schema", slog.String("dialect", string(db.Dialect)), slog.Int("version", baseVersion))
+	return nil
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How far back the delivery counts of the system stats go
+const statsWindow = 24 * time.Hour
+
+// AdminUser struct is the view of a user in the admin API: the profile, and the state of the
+// account.
+type AdminUser struct {
+	Profile
+	OrgID      int        `json:"org_id,omitempty"`
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+// RoleRequest struct defines the body of a change to the role of a user.
+type RoleRequest struct {
+	Role string `json:"role" form:"role" validate:"required,oneof=user admin"`
+}
+
+// newAdminUser returns the admin view of a user.
+func newAdminUser(user *store.User) AdminUser {
+	return AdminUser{Profile: *NewProfile(user), OrgID: user.OrgID, DisabledAt: user.DisabledAt}
+}
+
+// RequireAdmin returns middleware letting only users with the admin role through. It runs after
+// authentication, so the role is checked for every request, and one taken away applies at once.
+func RequireAdmin(s *store.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := s.Users.ByID(c.UserContext(), getUserID(c))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return ServerError(c, err)
+		}
+		if err != nil || user.Role != store.RoleAdmin {
+			return c.Status(403).JSON(fiber.Map{
+				"status":  "error",
+				"code":    "admin_required",
+				"message": "This endpoint is only available to admins",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// targetUser returns the user addressed by the id in the path of an admin request, or a nil user
+// and the result of writing the error response. Admins cannot apply the change to their own
+// account.
+func targetUser(c *fiber.Ctx, s *store.Store, adminID int, change string) (*store.User, error) {
+	id, _ := strconv.Atoi(c.Params("id"))
+	if id == adminID {
+		return nil, c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Admins cannot " + change + " their own account",
+		})
+	}
+
+	user, err := s.Users.ByID(c.UserContext(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "User not found",
+			})
+		}
+		return nil, ServerError(c, err)
+	}
+	return user, nil
+}
+
+// ListUsers retrieves all users one page at a time in id order, optionally only those whose
+// username contains the search query parameter.
+func ListUsers(c *fiber.Ctx, s *store.Store) error {
+	p, err := parsePage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	search := c.Query("search")
+
+	users, hasMore, err := s.Users.List(c.UserContext(), search, p)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	total, err := s.Users.Count(c.UserContext(), search)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	views := make([]AdminUser, 0, len(users))
+	for i := range users {
+		views = append(views, newAdminUser(&users[i]))
+	}
+
+	response := fiber.Map{
+		"status":  "fetched",
+		"count":   len(views),
+		"users":   views,
+		"limit":   p.Limit,
+		"message": "Users fetched successfully",
+	}
+	var nextCursor int
+	if p.Keyset {
+		// next_cursor is only present when more rows exist; pass it back as after_id
+		if hasMore {
+			nextCursor = users[len(users)-1].ID
+			response["next_cursor"] = nextCursor
+		}
+	} else {
+		response["offset"] = p.Offset
+		response["has_more"] = hasMore
+	}
+
+	setPageHeaders(c, p, total, hasMore, nextCursor, nil)
+	return c.Status(200).JSON(response)
+}
+
+// queryRecurrence parses the optional recurrence filter of the admin event list: none, any, or a
+// frequency such as weekly, returning it as store.AdminEventFilter expects it.
+func queryRecurrence(c *fiber.Ctx, key string) (string, error) {
+	raw := strings.ToLower(c.Query(key))
+	switch raw {
+	case "", store.RecurrenceNone, store.RecurrenceAny:
+		return raw, nil
+	case "daily", "weekly", "monthly", "yearly":
+		return strings.ToUpper(raw), nil
+	}
+	return "", fmt.Errorf("%s must be one of none, any, daily, weekly, monthly, or yearly", key)
+}
+
+// ListAllEvents retrieves the events of all users outside the trash one page at a time in id
+// order, each with the username of its owner. The username, from, to, and recurrence query
+// parameters narrow the list.
+func ListAllEvents(c *fiber.Ctx, s *store.Store) error {
+	p, err := parsePage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	filter := store.AdminEventFilter{Owner: NormalizeUsername(c.Query("username"))}
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "from must not be after to",
+		})
+	}
+	if filter.Recurrence, err = queryRecurrence(c, "recurrence"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	events, hasMore, err := s.Events.ListAll(c.UserContext(), filter, p)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	total, err := s.Events.CountAll(c.UserContext(), filter)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	response := fiber.Map{
+		"status":  "fetched",
+		"count":   len(events),
+		"events":  events,
+		"limit":   p.Limit,
+		"message": "Events fetched successfully",
+	}
+	var nextCursor int
+	if p.Keyset {
+		// next_cursor is only present when more rows exist; pass it back as after_id
+		if hasMore {
+			nextCursor = events[len(events)-1].ID
+			response["next_cursor"] = nextCursor
+		}
+	} else {
+		response["offset"] = p.Offset
+		response["has_more"] = hasMore
+	}
+
+	setPageHeaders(c, p, total, hasMore, nextCursor, nil)
+	return c.Status(200).JSON(response)
+}
+
+// SetUserRole makes a user an admin or a regular user again.
+func SetUserRole(c *fiber.Ctx, s *store.Store) error {
+	req := new(RoleRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid role", problems)
+	}
+
+	var adminID = getUserID(c)
+
+	// Admins keep their own role, so at least one admin is always left
+	user, err := targetUser(c, s, adminID, "change the role of")
+	if user == nil {
+		return err
+	}
+
+	if err := s.Users.SetRole(c.UserContext(), user.ID, req.Role); err != nil {
+		return ServerError(c, err)
+	}
+	user.Role = req.Role
+	RecordAudit(c, s, user.ID, user.Username, AuditRoleChange)
+	Logger(c).Info("Changed role of user", slog.Int("target_user_id", user.ID), slog.String("role", req.Role))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "updated",
+		"user":    newAdminUser(user),
+		"message": "Role updated successfully",
+	})
+}
+
+// DisableUser disables an account: it can no longer log in, its tokens and API keys stop working,
+// and its reminders are no longer delivered. Its data is kept until the account is enabled again
+// or deleted.
+func DisableUser(c *fiber.Ctx, s *store.Store) error {
+	var adminID = getUserID(c)
+
+	user, err := targetUser(c, s, adminID, "disable")
+	if user == nil {
+		return err
+	}
+
+	// Disabling twice keeps the time of the first
+	if user.DisabledAt == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		if err := s.Users.SetDisabled(c.UserContext(), user.ID, &now); err != nil {
+			return ServerError(c, err)
+		}
+		user.DisabledAt = &now
+	}
+
+	// End the sessions of the user; API keys are refused while the account is disabled
+	if err := s.RefreshTokens.RevokeAll(c.UserContext(), user.ID); err != nil {
+		return ServerError(c, err)
+	}
+	if err := s.Users.RevokeTokens(c.UserContext(), user.ID); err != nil {
+		return ServerError(c, err)
+	}
+	ForgetTokenVersion(user.ID)
+
+	RecordAudit(c, s, user.ID, user.Username, AuditAccountDisable)
+	Logger(c).Info("Disabled user", slog.Int("target_user_id", user.ID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "disabled",
+		"user":    newAdminUser(user),
+		"message": "User disabled successfully",
+	})
+}
+
+// EnableUser enables a disabled account again. The user has to log in anew.
+func EnableUser(c *fiber.Ctx, s *store.Store) error {
+	var adminID = getUserID(c)
+
+	user, err := targetUser(c, s, adminID, "enable")
+	if user == nil {
+		return err
+	}
+
+	if err := s.Users.SetDisabled(c.UserContext(), user.ID, nil); err != nil {
+		return ServerError(c, err)
+	}
+	user.DisabledAt = nil
+	RecordAudit(c, s, user.ID, user.Username, AuditAccountEnable)
+	Logger(c).Info("Enabled user", slog.Int("target_user_id", user.ID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "enabled",
+		"user":    newAdminUser(user),
+		"message": "User enabled successfully",
+	})
+}
+
+// DeleteUser deletes an account along with everything it owns, as when the user deletes it
+// themselves.
+func DeleteUser(c *fiber.Ctx, s *store.Store) error {
+	var adminID = getUserID(c)
+
+	user, err := targetUser(c, s, adminID, "delete")
+	if user == nil {
+		return err
+	}
+
+	// Events shared with the organization of the user go with the account
+	scope := listScope(c, s.Users, user.ID)
+	if err := s.Users.Delete(c.UserContext(), user.ID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "User not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(scope)
+	ForgetTokenVersion(user.ID)
+
+	// The audit log goes with the account, so the deletion is only logged
+	Logger(c).Info("Deleted user", slog.Int("target_user_id", user.ID), slog.String("username", user.Username))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"user_id": user.ID,
+		"message": "User deleted successfully",
+	})
+}
+
+// AdminDeleteEvent deletes any user's event for good by id, skipping the trash, to remove abusive
+// content. The deletion is recorded in the audit log of the owner.
+func AdminDeleteEvent(c *fiber.Ctx, s *store.Store) error {
+	eventID, _ := strconv.Atoi(c.Params("id"))
+
+	ownerID, err := s.Events.Erase(c.UserContext(), eventID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, ownerID))
+
+	if owner, err := s.Users.ByID(c.UserContext(), ownerID); err == nil {
+		RecordAudit(c, s, owner.ID, owner.Username, AuditEventErase)
+	}
+	Logger(c).Info("Erased event", slog.Int("event_id", eventID), slog.Int("owner_id", ownerID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "deleted",
+		"event_id": eventID,
+		"owner_id": ownerID,
+		"message":  "Event deleted successfully",
+	})
+}
+
+// GetStats returns statistics over the whole system: users, events, and deliveries.
+func GetStats(c *fiber.Ctx, s *store.Store) error {
+	since := time.Now().Add(-statsWindow).UTC()
+
+	stats, err := s.Stats.Stats(c.UserContext(), since)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"stats":   stats,
+		"since":   since.Truncate(time.Second),
+		"message": "Stats fetched successfully",
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"testing"
+)
+
+// adminEvents is the body of a listing of the events of all users.
+type adminEvents struct {
+	Events []struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Owner string `json:"owner"`
+	} `json:"events"`
+}
+
+func TestListAllEvents(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	admin, _ := s.Users.Create(ctx, "admin", "hash")
+	if err := s.Users.SetRole(ctx, admin, store.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := s.Users.Create(ctx, "alice", "hash")
+	bob, _ := s.Users.Create(ctx, "bob", "hash")
+
+	weekly := "FREQ=WEEKLY;BYDAY=MO"
+	for _, event := range []struct {
+		userID int
+		event  store.Event
+	}{
+		{alice, store.Event{Name: "standup", Message: "Daily standup", Date: "2030-01-01T09:00:00Z"}},
+		{alice, store.Event{Name: "review", Message: "Weekly review", Date: "2030-01-07T09:00:00Z", Recurrence: &weekly}},
+		{bob, store.Event{Name: "dentist", Message: "Dentist", Date: "2030-02-01T09:00:00Z"}},
+	} {
+		if err := s.Events.Create(ctx, event.userID, &event.event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := newTestApp()
+	app.Get("/admin/v1/events", RequireAdmin(s), func(c *fiber.Ctx) error { return ListAllEvents(c, s) })
+
+	// list lists the events of all users as admin and returns their names and owners
+	list := func(query string) map[string]string {
+		t.Helper()
+		resp := request(t, app, admin, "GET", "/admin/v1/events"+query, "")
+		if resp.StatusCode != 200 {
+			t.Fatalf("%q: got status %d, want 200", query, resp.StatusCode)
+		}
+		var body adminEvents
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		owners := map[string]string{}
+		for _, event := range body.Events {
+			owners[event.Name] = event.Owner
+		}
+		return owners
+	}
+
+	owners := list("")
+	if len(owners) != 3 || owners["standup"] != "alice" || owners["review"] != "alice" || owners["dentist"] != "bob" {
+		t.Fatalf("got %v, want the events of alice and bob", owners)
+	}
+	if owners := list("?username=bob"); len(owners) != 1 || owners["dentist"] != "bob" {
+		t.Fatalf("username=bob: got %v", owners)
+	}
+	if owners := list("?from=2030-01-05T00:00:00Z&to=2030-01-31T00:00:00Z"); len(owners) != 1 || owners["review"] != "alice" {
+		t.Fatalf("date range: got %v", owners)
+	}
+	if owners := list("?recurrence=weekly"); len(owners) != 1 || owners["review"] != "alice" {
+		t.Fatalf("recurrence=weekly: got %v", owners)
+	}
+	if owners := list("?recurrence=none"); len(owners) != 2 || owners["review"] != "" {
+		t.Fatalf("recurrence=none: got %v", owners)
+	}
+
+	if resp := request(t, app, admin, "GET", "/admin/v1/events?recurrence=hourly", ""); resp.StatusCode != 400 {
+		t.Fatalf("unknown recurrence: got status %d, want 400", resp.StatusCode)
+	}
+
+	resp := request(t, app, alice, "GET", "/admin/v1/events", "")
+	if resp.StatusCode != 403 {
+		t.Fatalf("non-admin: got status %d, want 403", resp.StatusCode)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["code"] != "admin_required" {
+		t.Fatalf("non-admin: got body %v, %v", body, err)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+// Prefix of every generated API key, which makes leaked keys easy to recognise
+const apiKeyPrefix = "rk_"
+
+// Locals key holding the user id of a request authenticated with an API key
+const apiKeyUserLocal = "api_key_user_id"
+
+// APIKeyRequest struct defines the body of an API key creation request.
+type APIKeyRequest struct {
+	Name string `json:"name" form:"name"`
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 hash under which a key is stored.
+// Keys carry 256 bits of randomness, so a fast hash is sufficient.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKey generates a random API key.
+func newAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// APIKeyAuth authenticates requests that carry an X-API-Key header and no bearer token.
+// Requests with a bearer token, or without any credentials, are left to the JWT middleware.
+func APIKeyAuth(s *store.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-API-Key")
+		if key == "" || c.Get(fiber.HeaderAuthorization) != "" {
+			return c.Next()
+		}
+
+		userID, err := s.APIKeys.UserIDByHash(c.UserContext(), hashAPIKey(key))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(401).JSON(fiber.Map{
+					"status":  "error",
+					"code":    "api_key_invalid",
+					"message": "Invalid or revoked API key",
+				})
+			}
+			return ServerError(c, err)
+		}
+
+		c.Locals(apiKeyUserLocal, userID)
+		return c.Next()
+	}
+}
+
+// AuthenticatedByAPIKey reports whether APIKeyAuth already authenticated the request.
+func AuthenticatedByAPIKey(c *fiber.Ctx) bool {
+	_, ok := c.Locals(apiKeyUserLocal).(int)
+	return ok
+}
+
+// CreateAPIKey generates a new API key for the authenticated user.
+// The key itself is only returned in this response; afterwards only its prefix is shown.
+func CreateAPIKey(c *fiber.Ctx, s *store.Store) error {
+	req := new(APIKeyRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "name is required",
+		})
+	}
+
+	var userID = getUserID(c)
+
+	key, err := newAPIKey()
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	created, err := s.APIKeys.Create(c.UserContext(), userID, req.Name, key[:len(apiKeyPrefix)+6], hashAPIKey(key))
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "created",
+		"key":     key,
+		"details": created,
+		"message": "API key created, store it now as it won't be shown again",
+	})
+}
+
+// ListAPIKeys returns the active API keys of the authenticated user without their secrets.
+func ListAPIKeys(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	keys, err := s.APIKeys.List(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "fetched",
+		"api_keys": keys,
+		"message":  "API keys fetched successfully",
+	})
+}
+
+// RevokeAPIKey deactivates one of the authenticated user's API keys.
+func RevokeAPIKey(c *fiber.Ctx, s *store.Store) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "API key id must be an integer",
+		})
+	}
+
+	var userID = getUserID(c)
+
+	if err := s.APIKeys.Revoke(c.UserContext(), userID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "revoked",
+		"message": "API key revoked successfully",
+	})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	app := newTestApp()
+	app.Use(APIKeyAuth(s))
+	app.Get("/whoami", func(c *fiber.Ctx) error { return c.SendString(strconv.Itoa(getUserID(c))) })
+	app.Post("/api-keys", func(c *fiber.Ctx) error { return CreateAPIKey(c, s) })
+	app.Get("/api-keys", func(c *fiber.Ctx) error { return ListAPIKeys(c, s) })
+	app.Delete("/api-keys/:id", func(c *fiber.Ctx) error { return RevokeAPIKey(c, s) })
+
+	// whoami requests /whoami with key alone and returns the status and the user it was made as
+	whoami := func(key string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	resp := request(t, app, alice, "POST", "/api-keys", `{"name":"cron"}`)
+	expectStatus(t, resp, 201)
+	var created struct {
+		Key     string       `json:"key"`
+		Details store.APIKey `json:"details"`
+	}
+	decode(t, resp, &created)
+	if !strings.HasPrefix(created.Key, apiKeyPrefix) || !strings.HasPrefix(created.Key, created.Details.Prefix) {
+		t.Fatalf("got key %q with prefix %q", created.Key, created.Details.Prefix)
+	}
+
+	if status, user := whoami(created.Key); status != 200 || user != strconv.Itoa(alice) {
+		t.Fatalf("valid key: got %d as user %s, want 200 as %d", status, user, alice)
+	}
+	if status, _ := whoami(apiKeyPrefix + "unknown"); status != 401 {
+		t.Fatalf("unknown key: got %d, want 401", status)
+	}
+
+	// Listed keys never carry the key itself
+	resp = request(t, app, alice, "GET", "/api-keys", "")
+	expectStatus(t, resp, 200)
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed struct {
+		Keys []store.APIKey `json:"api_keys"`
+	}
+	if err := json.Unmarshal(raw, &listed); err != nil || len(listed.Keys) != 1 || strings.Contains(string(raw), created.Key) {
+		t.Fatalf("got %s, %v, want one key without its secret", raw, err)
+	}
+
+	// Only the owner can revoke a key, after which it stops working
+	path := "/api-keys/" + strconv.Itoa(created.Details.ID)
+	expectStatus(t, request(t, app, bob, "DELETE", path, ""), 404)
+	expectStatus(t, request(t, app, alice, "DELETE", path, ""), 200)
+	if status, _ := whoami(created.Key); status != 401 {
+		t.Fatalf("revoked key: got %d, want 401", status)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditLoginSuccess   = "login_success"
+	AuditLoginFailure   = "login_failure"
+	AuditEventCreate    = "event_create"
+	AuditEventDelete    = "event_delete"
+	AuditUsernameChange = "username_change"
+	AuditLogout         = "logout"
+	AuditPasswordReset  = "password_reset"
+	AuditPasswordChange = "password_change"
+	AuditRoleChange     = "role_change"
+	AuditAccountDisable = "account_disable"
+	AuditAccountEnable  = "account_enable"
+	AuditEventErase     = "event_erase"
+)
+
+// RecordAudit appends an action to the audit log with the client's IP. A failure to record
+// is logged and does not fail the request.
+func RecordAudit(c *fiber.Ctx, s *store.Store, userID int, username, action string) {
+	entry := &store.AuditEntry{
+		UserID:   userID,
+		Username: username,
+		Action:   action,
+		IP:       c.IP(),
+	}
+	if err := s.Audit.Record(c.UserContext(), entry); err != nil {
+		Logger(c).Error("Failed to record audit action", slog.String("action", action), slog.String("username", username), slog.Any("error", err))
+	}
+}
+
+// Audit returns middleware that records action for the authenticated user once the
+// route's handler has completed successfully. Failed requests are not recorded.
+func Audit(s *store.Store, action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Response().StatusCode() >= 400 {
+			return nil
+		}
+
+		var userID = getUserID(c)
+
+		var username string
+		if user, err := s.Users.ByID(c.UserContext(), userID); err == nil {
+			username = user.Username
+		}
+
+		RecordAudit(c, s, userID, username, action)
+		return nil
+	}
+}
+
+// ListAudit retrieves the audit log of the authenticated user one page at a time, newest first.
+// Keyset pages (after_id) are sorted by id instead.
+func ListAudit(c *fiber.Ctx, s *store.Store) error {
+	p, err := parsePage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	var userID = getUserID(c)
+
+	entries, hasMore, err := s.Audit.List(c.UserContext(), userID, p)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	total, err := s.Audit.Count(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	response := fiber.Map{
+		"status":  "fetched",
+		"count":   len(entries),
+		"entries": entries,
+		"limit":   p.Limit,
+		"message": "Audit log fetched successfully",
+	}
+	var nextCursor int
+	if p.Keyset {
+		// next_cursor is only present when more rows exist; pass it back as after_id
+		if hasMore {
+			nextCursor = entries[len(entries)-1].ID
+			response["next_cursor"] = nextCursor
+		}
+	} else {
+		response["offset"] = p.Offset
+		response["has_more"] = hasMore
+	}
+
+	setPageHeaders(c, p, total, hasMore, nextCursor, nil)
+	return c.Status(200).JSON(response)
+}
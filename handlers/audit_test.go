@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"testing"
+)
+
+// auditActions returns the actions in the audit log of a user, newest first.
+func auditActions(t *testing.T, s *store.Store, userID int) []string {
+	t.Helper()
+	entries, _, err := s.Audit.List(context.Background(), userID, store.Page{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+func TestEventActionsAudited(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", Audit(s, AuditEventCreate), func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Delete("/event/:name", Audit(s, AuditEventDelete), func(c *fiber.Ctx) error { return DeleteEvent(c, s) })
+	app.Get("/audit", func(c *fiber.Ctx) error { return ListAudit(c, s) })
+
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-01T09:00:00Z"}`), 201)
+	entries, _, err := s.Audit.List(context.Background(), alice, store.Page{Limit: 10})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got entries %+v, %v, want one", entries, err)
+	}
+	if entry := entries[0]; entry.Action != AuditEventCreate || entry.Username != "alice" || entry.IP == "" || entry.CreatedAt.IsZero() {
+		t.Fatalf("got entry %+v, want the creation by alice", entry)
+	}
+
+	// Failed requests are not recorded
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Again","date":"2030-01-01T09:00:00Z"}`), 409)
+	expectStatus(t, request(t, app, alice, "DELETE", "/event/missing", ""), 404)
+	expectStatus(t, request(t, app, alice, "DELETE", "/event/standup", ""), 200)
+
+	resp := request(t, app, alice, "GET", "/audit", "")
+	expectStatus(t, resp, 200)
+	var body struct {
+		Entries []store.AuditEntry `json:"entries"`
+	}
+	decode(t, resp, &body)
+	if len(body.Entries) != 2 || body.Entries[0].Action != AuditEventDelete || body.Entries[1].Action != AuditEventCreate {
+		t.Fatalf("got %+v, want the deletion and then the creation", body.Entries)
+	}
+	if actions := auditActions(t, s, createUser(t, s, "bob")); len(actions) != 0 {
+		t.Fatalf("bob's audit log: got %v, want none", actions)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"log/slog"
+	"strings"
+)
+
+// Returned by ParseBody when the body is malformed or has an unsupported content type
+var errInvalidBody = errors.New("Invalid request body")
+
+// ParseBody decodes the request body into out. JSON bodies are decoded strictly, so a key that
+// matches no field (such as a misspelled "mesage") is rejected instead of silently dropped.
+// Other content types, such as x-www-form-urlencoded, go through Fiber's BodyParser.
+// The returned error's message is safe to send to clients.
+func ParseBody(c *fiber.Ctx, out interface{}) error {
+	if !isJSONBody(c) {
+		if err := c.BodyParser(out); err != nil {
+			return errInvalidBody
+		}
+		return nil
+	}
+
+	return decodeJSON(c.Body(), out)
+}
+
+// isJSONBody reports whether the request body is JSON, by its content type.
+func isJSONBody(c *fiber.Ctx) bool {
+	ctype := utils.ToLower(utils.UnsafeString(c.Request().Header.ContentType()))
+	ctype = utils.ParseVendorSpecificContentType(ctype)
+	if i := strings.IndexByte(ctype, ';'); i >= 0 {
+		ctype = ctype[:i]
+	}
+	return strings.HasSuffix(ctype, "json")
+}
+
+// decodeJSON decodes a JSON document into out, rejecting keys out does not have.
+func decodeJSON(data []byte, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		// encoding/json reports unknown keys as `json: unknown field "name"`
+		const unknownField = "json: unknown field "
+		if msg := err.Error(); strings.HasPrefix(msg, unknownField) {
+			return fmt.Errorf("Unknown field %s in request body", strings.TrimPrefix(msg, unknownField))
+		}
+		return errInvalidBody
+	}
+	return nil
+}
+
+// ServerError returns the response for an unexpected error while handling a request: 503 when
+// the database cannot be reached, so that clients know to try again, and 500 otherwise. The
+// error itself may name tables, columns, or driver internals, so it is only logged; clients get
+// a fixed message and an error_id that finds the log line.
+func ServerError(c *fiber.Ctx, err error) error {
+	errorID := newErrorID()
+	Logger(c).Error("Request failed", slog.String("error_id", errorID), slog.String("method", c.Method()),
+		slog.String("path", c.Path()), slog.Any("error", err))
+
+	code, message := fiber.StatusInternalServerError, "Internal server error"
+	if errors.Is(err, store.ErrUnavailable) {
+		code, message = fiber.StatusServiceUnavailable, "Database unavailable, try again later"
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status":   "error",
+		"message":  message,
+		"error_id": errorID,
+	})
+}
+
+// newErrorID returns a random id correlating an error response with its log line.
+func newErrorID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// invalidBody returns the uniform 400 response used when a request body cannot be parsed.
+func invalidBody(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"status":  "error",
+		"message": err.Error(),
+	})
+}
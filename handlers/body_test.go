@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestUnknownFieldsRejected(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	createEvent(t, s, alice, "standup")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+
+	for _, test := range []struct{ method, path, body string }{
+		{"POST", "/event", `{"name":"review","mesage":"Weekly review","date":"2030-01-07T09:00:00Z"}`},
+		{"PUT", "/event/standup", `{"mesage":"Moved"}`},
+	} {
+		resp := request(t, app, alice, test.method, test.path, test.body)
+		expectStatus(t, resp, 400)
+		var body map[string]string
+		decode(t, resp, &body)
+		if body["message"] != `Unknown field "mesage" in request body` {
+			t.Fatalf("%s %s: got message %q, want the misspelled field named", test.method, test.path, body["message"])
+		}
+	}
+
+	if _, err := s.Events.Get(context.Background(), alice, "review"); err == nil {
+		t.Fatal("an event was created from a body with an unknown field")
+	}
+	if standup := getEvent(t, s, alice, "standup"); standup.Message != "Message of standup" {
+		t.Fatalf("an event was updated from a body with an unknown field: got %+v", standup)
+	}
+}
+
+// failingEvents is an EventStore whose reads fail with err.
+type failingEvents struct {
+	store.EventStore
+	err error
+}
+
+func (e failingEvents) Get(ctx context.Context, userID int, name string) (*store.Event, error) {
+	return nil, e.err
+}
+
+func TestServerErrorHidesDetails(t *testing.T) {
+	var logged bytes.Buffer
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logged, nil)))
+
+	detail := "SELECT password_hash FROM users: near \"FORM\": syntax error"
+	for _, test := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{errors.New(detail), 500, "Internal server error"},
+		{fmt.Errorf("%w: %s", store.ErrUnavailable, detail), 503, "Database unavailable, try again later"},
+	} {
+		logged.Reset()
+		s := store.NewMemory()
+		alice := createUser(t, s, "alice")
+		s.Events = failingEvents{EventStore: s.Events, err: test.err}
+		app := newTestApp()
+		app.Get("/event/:name", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+
+		resp := request(t, app, alice, "GET", "/event/standup", "")
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.status || body["message"] != test.message || len(body["error_id"]) != 16 {
+			t.Fatalf("%v: got status %d, body %s, want %d with an error id", test.err, resp.StatusCode, raw, test.status)
+		}
+		if strings.Contains(string(raw), "password_hash") || strings.Contains(string(raw), "syntax") {
+			t.Fatalf("%v: body %s leaks the error", test.err, raw)
+		}
+
+		// The error is logged under the id the client got
+		if !strings.Contains(logged.String(), body["error_id"]) || !strings.Contains(logged.String(), "password_hash") {
+			t.Fatalf("%v: got log %s, want the error under id %s", test.err, logged.String(), body["error_id"])
+		}
+	}
+}
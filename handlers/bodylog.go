@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Placeholder written instead of a sensitive value
+const redacted = "[REDACTED]"
+
+// Logged bodies are cut off after this many bytes
+const maxLoggedBody = 2048
+
+// Body fields whose values are never logged, compared case-insensitively. "key" holds the secret
+// of a new API key, "secret" the one of a new webhook, and the feed URLs the token of a calendar feed.
+var sensitiveFields = map[string]bool{
+	"password":         true,
+	"current_password": true,
+	"new_password":     true,
+	"token":            true,
+	"refresh_token":    true,
+	"key":              true,
+	"secret":           true,
+	"feed_url":         true,
+	"webcal_url":       true,
+}
+
+// LogBodies returns middleware logging the request and response body of every request, with the
+// values of sensitive fields redacted. The Authorization and X-API-Key headers are only logged
+// masked. Bodies that are neither JSON nor form data are logged by size alone, since they cannot
+// be redacted field by field.
+func LogBodies() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Render errors right away, as the logger middleware does, so the logged response is the one sent
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		// Reading a streamed response, such as the export, would consume the stream
+		response := "(streamed)"
+		if !c.Response().IsBodyStream() {
+			response = redactBody(string(c.Response().Header.ContentType()), c.Response().Body())
+		}
+
+		Logger(c).Info("Request body",
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("authorization", maskCredential(c.Get(fiber.HeaderAuthorization))),
+			slog.String("api_key", maskCredential(c.Get("X-API-Key"))),
+			slog.String("request", redactBody(c.Get(fiber.HeaderContentType), c.Body())),
+			slog.Int("status", c.Response().StatusCode()),
+			slog.String("response", response),
+		)
+		return nil
+	}
+}
+
+// maskCredential hides a credential header value, keeping only its scheme such as "Bearer".
+func maskCredential(value string) string {
+	if value == "" {
+		return ""
+	}
+	if scheme, _, ok := strings.Cut(value, " "); ok {
+		return scheme + " " + redacted
+	}
+	return redacted
+}
+
+// redactBody returns a body for logging with the values of sensitive fields replaced.
+func redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return "(empty)"
+	}
+
+	var logged string
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationJSON):
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return fmt.Sprintf("(%d bytes of invalid JSON)", len(body))
+		}
+		encoded, _ := json.Marshal(redactValue(value))
+		logged = string(encoded)
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationForm):
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Sprintf("(%d bytes of invalid form data)", len(body))
+		}
+		for field := range form {
+			if sensitiveFields[strings.ToLower(field)] {
+				form[field] = []string{redacted}
+			}
+		}
+		logged = form.Encode()
+	case contentType == "":
+		return fmt.Sprintf("(%d bytes)", len(body))
+	default:
+		return fmt.Sprintf("(%d bytes of %s)", len(body), contentType)
+	}
+
+	if len(logged) > maxLoggedBody {
+		logged = logged[:maxLoggedBody] + "...(truncated)"
+	}
+	return logged
+}
+
+// redactValue replaces the values of sensitive fields in decoded JSON, at any depth.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, inner := range v {
+			if sensitiveFields[strings.ToLower(field)] {
+				v[field] = redacted
+			} else {
+				v[field] = redactValue(inner)
+			}
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redactValue(inner)
+		}
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Most events a single bulk creation may hold
+const maxBulkEvents = 100
+
+// BulkResult struct reports what became of one event of a bulk creation or import. Index is the
+// event's position in the request, and UID the iCalendar UID of an imported one; Event is set when
+// it was created, Errors when it was invalid.
+type BulkResult struct {
+	Index   int          `json:"index"`
+	UID     string       `json:"uid,omitempty"`
+	Status  string       `json:"status"`
+	Event   *store.Event `json:"event,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	Message string       `json:"message"`
+}
+
+// CreateEvents creates several events of the authenticated user at once from a JSON array. Each
+// event is validated like a single one. The valid events are stored together in one transaction;
+// invalid ones, and ones whose name is taken, are reported without affecting the others.
+func CreateEvents(c *fiber.Ctx, s *store.Store) error {
+	var items []json.RawMessage
+	// Parse the request body as a JSON array of events
+	if err := ParseBody(c, &items); err != nil {
+		return invalidBody(c, err)
+	}
+	if len(items) == 0 || len(items) > maxBulkEvents {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("Provide between 1 and %d events", maxBulkEvents),
+		})
+	}
+
+	var userID = getUserID(c)
+	loc := userLocation(c.UserContext(), s.Users, userID)
+
+	results := make([]BulkResult, len(items))
+	events := []*store.Event{}
+	indexes := []int{}
+	for i, item := range items {
+		results[i] = BulkResult{Index: i, Status: "error"}
+
+		event := new(store.Event)
+		if err := decodeJSON(item, event); err != nil {
+			results[i].Message = err.Error()
+			continue
+		}
+		event.Tags = normalizeTags(event.Tags)
+		if problems := ValidateStruct(event); problems != nil {
+			results[i].Errors = problems
+			results[i].Message = "Invalid event"
+			continue
+		}
+
+		prepareEvent(event, loc)
+		events = append(events, event)
+		indexes = append(indexes, i)
+	}
+
+	created, err := createEvents(c, s, userID, events, indexes, results)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "processed",
+		"created": created,
+		"failed":  len(items) - created,
+		"results": results,
+		"message": fmt.Sprintf("%d of %d events created", created, len(items)),
+	})
+}
+
+// createEvents stores events of a user in one transaction and records the outcome of each in the
+// result at its index, returning how many were created.
+func createEvents(c *fiber.Ctx, s *store.Store, userID int, events []*store.Event, indexes []int, results []BulkResult) (int, error) {
+	failures, err := s.Events.CreateMany(c.UserContext(), userID, events)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for j, event := range events {
+		result := &results[indexes[j]]
+		if errors.Is(failures[j], store.ErrDuplicate) {
+			result.Message = "An event with this name already exists"
+			continue
+		}
+		result.Status = "created"
+		result.Event = event
+		result.Message = "Event created successfully"
+		created++
+	}
+	if created > 0 {
+		eventLists.invalidate(listScope(c, s.Users, userID))
+	}
+	return created, nil
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"sync"
+	"time"
+)
+
+// listCache keeps recent ListEvents results per user, so clients polling the list do not query
+// the database every time. A user's entries are dropped whenever one of their events is written
+// through the API; other changes, such as purged events or writes on another instance, show up
+// once the entries expire.
+type listCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[int]map[string]listResult
+	lastSweep time.Time
+	// versions counts the invalidations per user, so a result read before a write is not cached after it
+	versions map[int]uint64
+	// now reads the clock; tests replace it to control time
+	now func() time.Time
+}
+
+// listResult is one cached page of a user's events.
+type listResult struct {
+	events  []store.Event
+	hasMore bool
+	total   int
+	asOf    time.Time
+	expires time.Time
+}
+
+// Cache of ListEvents results, configured at startup with SetListCacheTTL; disabled until then
+var eventLists = newListCache(0)
+
+// newListCache returns a cache keeping results for ttl; a zero ttl disables it.
+func newListCache(ttl time.Duration) *listCache {
+	return &listCache{ttl: ttl, entries: map[int]map[string]listResult{}, versions: map[int]uint64{}, now: time.Now}
+}
+
+// SetListCacheTTL sets how long ListEvents results are cached per user; zero disables the cache.
+// It must be called before the server starts handling requests.
+func SetListCacheTTL(ttl time.Duration) {
+	eventLists = newListCache(ttl)
+}
+
+// get returns the cached result for key, if it has not expired, and otherwise the version to
+// pass to put along with the result read from the database.
+func (lc *listCache) get(userID int, key string) (listResult, uint64, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	result, ok := lc.entries[userID][key]
+	if !ok || !lc.now().Before(result.expires) {
+		return listResult{}, lc.versions[userID], false
+	}
+	return result, 0, true
+}
+
+// put caches result under key until the TTL passes, unless the user's events changed since
+// version was returned by get.
+func (lc *listCache) put(userID int, version uint64, key string, result listResult) {
+	if lc.ttl <= 0 {
+		return
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.versions[userID] != version {
+		return
+	}
+
+	now := lc.now()
+	lc.sweep(now)
+	if lc.entries[userID] == nil {
+		lc.entries[userID] = map[string]listResult{}
+	}
+	result.expires = now.Add(lc.ttl)
+	lc.entries[userID][key] = result
+}
+
+// sweep drops expired entries, at most once per TTL, so users who stop polling do not keep
+// memory. The lock must be held.
+func (lc *listCache) sweep(now time.Time) {
+	if now.Sub(lc.lastSweep) < lc.ttl {
+		return
+	}
+	lc.lastSweep = now
+
+	for userID, results := range lc.entries {
+		for key, result := range results {
+			if !now.Before(result.expires) {
+				delete(results, key)
+			}
+		}
+		if len(results) == 0 {
+			delete(lc.entries, userID)
+		}
+	}
+}
+
+// invalidate drops every cached result of a user, after one of their events changed.
+func (lc *listCache) invalidate(userID int) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	delete(lc.entries, userID)
+	lc.versions[userID]++
+}
+
+// listScope returns the id under which the lists of a user are cached. Members of an organization
+// see the same events, so they share one scope: the negated organization id.
+func listScope(c *fiber.Ctx, users store.UserStore, userID int) int {
+	return userListScope(c.UserContext(), users, userID)
+}
+
+// userListScope is listScope outside of a request, such as for messages to the Telegram bot.
+func userListScope(ctx context.Context, users store.UserStore, userID int) int {
+	user, err := users.ByID(ctx, userID)
+	if err != nil || user.OrgID == 0 {
+		return userID
+	}
+	return -user.OrgID
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingEvents is an EventStore counting the lists it reads.
+type countingEvents struct {
+	store.EventStore
+
+	mu    sync.Mutex
+	lists int
+}
+
+func (e *countingEvents) List(ctx context.Context, userID int, filter store.EventFilter, page store.Page) ([]store.Event, bool, error) {
+	e.mu.Lock()
+	e.lists++
+	e.mu.Unlock()
+	return e.EventStore.List(ctx, userID, filter, page)
+}
+
+// useListCache replaces the cache of event lists for the duration of a test with one keeping
+// results for ttl, whose clock stands still unless the returned function moves it.
+func useListCache(t *testing.T, ttl time.Duration) (advance func(time.Duration)) {
+	previous := eventLists
+	t.Cleanup(func() { eventLists = previous })
+
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	eventLists = newListCache(ttl)
+	eventLists.now = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestListEventsCache(t *testing.T) {
+	advance := useListCache(t, time.Minute)
+	s := store.NewMemory()
+	events := &countingEvents{EventStore: s.Events}
+	s.Events = events
+	alice, _ := s.Users.Create(context.Background(), "alice", "hash")
+	bob, _ := s.Users.Create(context.Background(), "bob", "hash")
+
+	app := newTestApp()
+	app.Get("/events", func(c *fiber.Ctx) error { return ListEvents(c, s) })
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+
+	// list requests the list of a user and checks how many lists the store has read since
+	list := func(userID int, query string, wantReads int) {
+		t.Helper()
+		if resp := request(t, app, userID, "GET", "/events"+query, ""); resp.StatusCode != 200 {
+			t.Fatalf("list: got status %d", resp.StatusCode)
+		}
+		if events.lists != wantReads {
+			t.Fatalf("store read %d lists, want %d", events.lists, wantReads)
+		}
+	}
+
+	list(alice, "", 1)
+	// Within the TTL the same page comes from the cache
+	advance(30 * time.Second)
+	list(alice, "", 1)
+	// Other pages and other users are cached separately
+	list(alice, "?limit=5", 2)
+	list(bob, "", 3)
+	// no_cache bypasses the cache
+	list(alice, "?no_cache=1", 4)
+
+	// Creating an event drops the cached pages of its owner only
+	resp := request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-02T09:00:00Z"}`)
+	if resp.StatusCode != 201 {
+		t.Fatalf("create: got status %d", resp.StatusCode)
+	}
+	list(alice, "", 5)
+	list(bob, "", 5)
+
+	// Pages expire after the TTL
+	advance(time.Minute)
+	list(alice, "", 6)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"time"
+)
+
+// GetCalendar returns the authenticated user's events for one month (?month=YYYY-MM),
+// grouped by day. Events whose stored date cannot be parsed are skipped and logged.
+func GetCalendar(c *fiber.Ctx, s *store.Store) error {
+	month, err := time.Parse("2006-01", c.Query("month"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "month must be given as YYYY-MM",
+		})
+	}
+	monthKey := month.Format("2006-01")
+
+	var userID = getUserID(c)
+
+	// Every accepted layout starts with YYYY-MM, so a prefix match narrows the events to parse
+	events, err := s.Events.ListByDatePrefix(c.UserContext(), userID, monthKey)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	days := map[string][]store.Event{}
+	for _, event := range events {
+		// New dates are stored in UTC; older ones without an offset are read as UTC too
+		date, err := parseEventDate(event.Date, time.UTC)
+		if err != nil {
+			Logger(c).Warn("Skipping event with unparseable date", slog.String("event", event.Name), slog.String("date", event.Date))
+			continue
+		}
+		if date.Format("2006-01") != monthKey {
+			continue
+		}
+
+		day := date.Format("2006-01-02")
+		days[day] = append(days[day], event)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"month":   monthKey,
+		"days":    days,
+		"message": "Calendar fetched successfully",
+	})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"testing"
+)
+
+func TestGetCalendar(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	for _, event := range []struct {
+		userID     int
+		name, date string
+	}{
+		{alice, "breakfast", "2030-05-03T08:00:00Z"},
+		{alice, "standup", "2030-05-03T09:00:00Z"},
+		{alice, "review", "2030-05-20T15:00:00Z"},
+		{alice, "before", "2030-04-30T23:00:00Z"},
+		{alice, "after", "2030-06-01T00:00:00Z"},
+		// Dates stored before validation existed may not parse; they are left out
+		{alice, "garbled", "2030-05-xx"},
+		{bob, "other", "2030-05-03T09:00:00Z"},
+	} {
+		if err := s.Events.Create(context.Background(), event.userID, &store.Event{Name: event.name, Message: "Message of " + event.name, Date: event.date}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := newTestApp()
+	app.Get("/events/calendar", func(c *fiber.Ctx) error { return GetCalendar(c, s) })
+
+	resp := request(t, app, alice, "GET", "/events/calendar?month=2030-05", "")
+	expectStatus(t, resp, 200)
+	var body struct {
+		Month string                   `json:"month"`
+		Days  map[string][]store.Event `json:"days"`
+	}
+	decode(t, resp, &body)
+	if body.Month != "2030-05" || len(body.Days) != 2 {
+		t.Fatalf("got month %s with days %v, want 2030-05 with 2 days", body.Month, body.Days)
+	}
+	if day := body.Days["2030-05-03"]; len(day) != 2 || day[0].Name+","+day[1].Name != "breakfast,standup" && day[0].Name+","+day[1].Name != "standup,breakfast" {
+		t.Fatalf("2030-05-03: got %+v, want breakfast and standup", day)
+	}
+	if day := body.Days["2030-05-20"]; len(day) != 1 || day[0].Name != "review" {
+		t.Fatalf("2030-05-20: got %+v, want review", day)
+	}
+
+	for _, month := range []string{"", "2030-13", "2030-5", "May", "2030-05-01"} {
+		expectStatus(t, request(t, app, alice, "GET", "/events/calendar?month="+month, ""), 400)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"sort"
+)
+
+// ChannelsRequest struct defines the body of a change to the notification channels of a user. Each
+// channel named is turned on (true) or off (false); channels left out keep their setting.
+type ChannelsRequest struct {
+	Channels map[string]bool `json:"channels" validate:"required,min=1,dive,keys,channel,endkeys"`
+}
+
+// channelPreferences returns whether user receives reminders through each registered channel.
+func channelPreferences(user *store.User) map[string]bool {
+	recipient := store.Recipient{DisabledChannels: user.DisabledChannels}
+	preferences := make(map[string]bool, len(notificationChannels))
+	for channel := range notificationChannels {
+		preferences[channel] = recipient.Receives(channel)
+	}
+	return preferences
+}
+
+// GetChannels returns which notification channels the authenticated user receives reminders
+// through.
+func GetChannels(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	user, err := s.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "fetched",
+		"channels": channelPreferences(user),
+		"message":  "Notification channels fetched successfully",
+	})
+}
+
+// UpdateChannels turns notification channels on or off for the reminders of the authenticated
+// user, both of their own events and of those shared with them.
+func UpdateChannels(c *fiber.Ctx, s *store.Store) error {
+	req := new(ChannelsRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid notification channels", problems)
+	}
+
+	var userID = getUserID(c)
+
+	user, err := s.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	// Channels that are no longer registered keep their setting, in case they come back
+	disabled := map[string]bool{}
+	for _, channel := range user.DisabledChannels {
+		disabled[channel] = true
+	}
+	for channel, enabled := range req.Channels {
+		disabled[channel] = !enabled
+	}
+	user.DisabledChannels = []string{}
+	for channel, off := range disabled {
+		if off {
+			user.DisabledChannels = append(user.DisabledChannels, channel)
+		}
+	}
+	sort.Strings(user.DisabledChannels)
+
+	if err := s.Users.UpdateChannels(c.UserContext(), userID, user.DisabledChannels); err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "updated",
+		"channels": channelPreferences(user),
+		"message":  "Notification channels updated successfully",
+	})
+}
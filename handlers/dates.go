@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"time"
+)
+
+// Layouts accepted for event dates, tried in order. Layouts without a UTC offset are read
+// in the user's timezone.
+var eventDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// errInvalidDate is returned when a date matches none of eventDateLayouts.
+var errInvalidDate = errors.New("date must be RFC3339, YYYY-MM-DD HH:MM, or YYYY-MM-DD")
+
+// parseEventDate parses an event date using the first layout that matches. Dates without
+// a UTC offset are interpreted in loc; date-only values fall on midnight.
+func parseEventDate(date string, loc *time.Location) (time.Time, error) {
+	for _, layout := range eventDateLayouts {
+		if t, err := time.ParseInLocation(layout, date, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errInvalidDate
+}
+
+// normalizeEventDate converts an event date in any accepted layout to the RFC3339 UTC form it is stored in.
+func normalizeEventDate(date string, loc *time.Location) (string, error) {
+	t, err := parseEventDate(date, loc)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+// userLocation returns the time zone configured for a user, falling back to UTC.
+func userLocation(ctx context.Context, users store.UserStore, userID int) *time.Location {
+	user, err := users.ByID(ctx, userID)
+	if err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"testing"
+	"time"
+)
+
+func TestNormalizeEventDate(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		date, want string
+	}{
+		{"2030-01-01T09:00:00Z", "2030-01-01T09:00:00Z"},
+		{"2030-01-01T09:00:00-05:00", "2030-01-01T14:00:00Z"},
+		// Dates without an offset are in the time zone of the user
+		{"2030-01-01 09:00", "2030-01-01T08:00:00Z"},
+		{"2030-07-01 09:00", "2030-07-01T07:00:00Z"},
+		// Date-only values fall on midnight
+		{"2030-01-01", "2029-12-31T23:00:00Z"},
+	}
+	for _, test := range tests {
+		if got, err := normalizeEventDate(test.date, paris); err != nil || got != test.want {
+			t.Errorf("%q: got %q, %v, want %q", test.date, got, err, test.want)
+		}
+	}
+
+	for _, date := range []string{"", "tomorrow", "01/02/2030", "2030-01-01T09:00", "2030-13-01", "2030-01-01 9am"} {
+		if got, err := normalizeEventDate(date, paris); err != errInvalidDate {
+			t.Errorf("%q: got %q, %v, want errInvalidDate", date, got, err)
+		}
+	}
+}
+
+func TestEventDatesInUserTimezone(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	if err := s.Users.UpdateSettings(context.Background(), alice, "", "Europe/Paris", nil); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-01"}`), 201)
+	if standup := getEvent(t, s, alice, "standup"); standup.Date != "2029-12-31T23:00:00Z" {
+		t.Fatalf("date-only date: got %s, want midnight in Paris", standup.Date)
+	}
+	expectStatus(t, request(t, app, alice, "PUT", "/event/standup", `{"date":"2030-01-02 09:30"}`), 200)
+	if standup := getEvent(t, s, alice, "standup"); standup.Date != "2030-01-02T08:30:00Z" {
+		t.Fatalf("date and time: got %s, want 09:30 in Paris", standup.Date)
+	}
+	expectStatus(t, request(t, app, alice, "PUT", "/event/standup", `{"date":"02/01/2030"}`), 422)
+}
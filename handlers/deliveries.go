@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+// Values of the detail query parameter of GetEvent
+const (
+	detailMinimal = "minimal"
+	detailFull    = "full"
+)
+
+// NotificationState struct describes where an event stands in the notification process.
+// NextFireAt is set while the event is still waiting to fire; Sent reports whether any
+// delivery has succeeded.
+type NotificationState struct {
+	FiredAt    *time.Time       `json:"fired_at"`
+	NextFireAt *time.Time       `json:"next_fire_at"`
+	Sent       bool             `json:"sent"`
+	Deliveries []store.Delivery `json:"deliveries"`
+}
+
+// Resender delivers the notification of an event again on request; the scheduler implements it.
+type Resender interface {
+	Resend(ctx context.Context, due store.DueEvent) []store.Delivery
+}
+
+// loadNotificationState collects the scheduler and delivery state of one of a user's events.
+func loadNotificationState(ctx context.Context, s *store.Store, userID int, event *store.Event) (*NotificationState, error) {
+	firedAt, err := s.Events.FiredAt(ctx, userID, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.Deliveries.ForEvent(ctx, userID, event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &NotificationState{FiredAt: firedAt, Deliveries: deliveries}
+	for _, delivery := range deliveries {
+		if delivery.Status == store.DeliverySent {
+			state.Sent = true
+		}
+	}
+
+	// Completed events never fire, a snoozed event fires when its snooze ends, and the scheduler
+	// skips dates that passed while it was not running
+	if event.CompletedAt == nil && event.SnoozedUntil != nil {
+		state.NextFireAt = event.SnoozedUntil
+	} else if firedAt == nil && event.CompletedAt == nil {
+		if date, err := parseEventDate(event.Date, time.UTC); err == nil && date.After(time.Now()) {
+			state.NextFireAt = &date
+		}
+	}
+	return state, nil
+}
+
+// ListDeliveries retrieves the notification delivery history of the authenticated user one page
+// at a time, newest first. ?status=pending, sent, or failed narrows it to one status.
+func ListDeliveries(c *fiber.Ctx, s *store.Store) error {
+	p, err := parsePage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", store.DeliveryPending, store.DeliverySent, store.DeliveryFailed:
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("status must be one of %s, %s, or %s", store.DeliveryPending, store.DeliverySent, store.DeliveryFailed),
+		})
+	}
+
+	var userID = getUserID(c)
+
+	deliveries, hasMore, err := s.Deliveries.List(c.UserContext(), userID, status, p)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	total, err := s.Deliveries.Count(c.UserContext(), userID, status)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	response := fiber.Map{
+		"status":     "fetched",
+		"count":      len(deliveries),
+		"deliveries": deliveries,
+		"limit":      p.Limit,
+		"message":    "Deliveries fetched successfully",
+	}
+	var nextCursor int
+	if p.Keyset {
+		// next_cursor is only present when more rows exist; pass it back as after_id
+		if hasMore {
+			nextCursor = deliveries[len(deliveries)-1].ID
+			response["next_cursor"] = nextCursor
+		}
+	} else {
+		response["offset"] = p.Offset
+		response["has_more"] = hasMore
+	}
+
+	setPageHeaders(c, p, total, hasMore, nextCursor, nil)
+	return c.Status(200).JSON(response)
+}
+
+// ListEventNotifications retrieves the log of every attempt to deliver the reminders of one of the
+// authenticated user's events one page at a time, newest first, so that it can be told whether and
+// when each reminder actually reached its recipients.
+func ListEventNotifications(c *fiber.Ctx, s *store.Store) error {
+	p, err := parsePage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	var userID = getUserID(c)
+
+	// The history lists the addresses of the event's recipients, so only its owner sees it
+	ownerID, _, err := resolveEvent(c, s, userID, "")
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+	eventID, _ := strconv.Atoi(c.Params("id"))
+
+	attempts, hasMore, err := s.Deliveries.Attempts(c.UserContext(), ownerID, eventID, p)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	total, err := s.Deliveries.CountAttempts(c.UserContext(), ownerID, eventID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	response := fiber.Map{
+		"status":        "fetched",
+		"event_id":      eventID,
+		"count":         len(attempts),
+		"notifications": attempts,
+		"limit":         p.Limit,
+		"message":       "Notifications fetched successfully",
+	}
+	var nextCursor int
+	if p.Keyset {
+		// next_cursor is only present when more rows exist; pass it back as after_id
+		if hasMore {
+			nextCursor = attempts[len(attempts)-1].ID
+			response["next_cursor"] = nextCursor
+		}
+	} else {
+		response["offset"] = p.Offset
+		response["has_more"] = hasMore
+	}
+
+	setPageHeaders(c, p, total, hasMore, nextCursor, nil)
+	return c.Status(200).JSON(response)
+}
+
+// ResendEvent delivers the notification of one of the user's events right away through the
+// event's channels, independent of its schedule. The deliveries are recorded in the history
+// marked as manual.
+func ResendEvent(c *fiber.Ctx, s *store.Store, resender Resender) error {
+	eventName := c.Params("name") // Get the event name from URL params
+
+	var userID = getUserID(c)
+
+	event, err := s.Events.Get(c.UserContext(), userID, eventName)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	// Load the event with the recipient it is delivered to, as when it fires
+	due, err := s.Events.DueByIDs(c.UserContext(), []int{event.ID})
+	if err != nil {
+		return ServerError(c, err)
+	}
+	if len(due) == 0 {
+		// Deleted since it was read
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Record not found",
+		})
+	}
+
+	deliveries := resender.Resend(c.UserContext(), due[0])
+	if len(deliveries) == 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "None of the event's notification channels is configured",
+		})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":     "resent",
+		"event_id":   event.ID,
+		"deliveries": deliveries,
+		"message":    "Notification resent",
+	})
+}
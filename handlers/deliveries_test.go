@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// eventDetail is the body of a single event, with the notification state in full detail mode.
+type eventDetail struct {
+	Details      store.Event        `json:"details"`
+	Notification *NotificationState `json:"notification"`
+}
+
+func TestGetEventDetail(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	event := createEvent(t, s, alice, "standup")
+	app := newTestApp()
+	app.Get("/event/:name", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+
+	// get fetches the standup event with query and returns the body
+	get := func(query string) eventDetail {
+		t.Helper()
+		resp := request(t, app, alice, "GET", "/event/standup"+query, "")
+		expectStatus(t, resp, 200)
+		var body eventDetail
+		decode(t, resp, &body)
+		return body
+	}
+
+	// The default response is the event alone
+	for _, query := range []string{"", "?detail=minimal"} {
+		if body := get(query); body.Details.Name != "standup" || body.Notification != nil {
+			t.Fatalf("%q: got %+v, want the event without its notification state", query, body)
+		}
+	}
+
+	// Before it fires the event waits for its date
+	state := get("?detail=full").Notification
+	date := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	if state == nil || state.FiredAt != nil || state.NextFireAt == nil || !state.NextFireAt.Equal(date) || state.Sent || len(state.Deliveries) != 0 {
+		t.Fatalf("before firing: got %+v, want the next fire time %v only", state, date)
+	}
+
+	// Once it fired and was delivered, it reports when and how
+	now := date.Add(time.Minute)
+	if _, err := s.Events.ClaimDue(ctx, "test", time.Time{}, now, time.Minute, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Events.MarkFired(ctx, "test", []int{event.ID}, now); err != nil {
+		t.Fatal(err)
+	}
+	delivery := &store.Delivery{UserID: alice, EventID: event.ID, EventName: event.Name, Channel: store.EmailChannel, Status: store.DeliverySent, Attempts: 1}
+	if err := s.Deliveries.Create(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+	state = get("?detail=full").Notification
+	if state == nil || state.FiredAt == nil || !state.FiredAt.Equal(now) || state.NextFireAt != nil || !state.Sent ||
+		len(state.Deliveries) != 1 || state.Deliveries[0].ID != delivery.ID {
+		t.Fatalf("after firing: got %+v, want it fired at %v and sent", state, now)
+	}
+
+	expectStatus(t, request(t, app, alice, "GET", "/event/standup?detail=everything", ""), 400)
+}
+
+// recordingResender records a manual delivery through each of its channels, counting its calls.
+type recordingResender struct {
+	s        *store.Store
+	channels []string
+	calls    int
+}
+
+func (r *recordingResender) Resend(ctx context.Context, due store.DueEvent) []store.Delivery {
+	r.calls++
+	deliveries := []store.Delivery{}
+	for _, channel := range r.channels {
+		delivery := store.Delivery{UserID: due.UserID, EventID: due.Event.ID, EventName: due.Event.Name, Channel: channel,
+			Manual: true, Status: store.DeliverySent, Attempts: 1}
+		if err := r.s.Deliveries.Create(ctx, &delivery); err != nil {
+			panic(err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+func TestResendEvent(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	event := createEvent(t, s, alice, "standup")
+	resender := &recordingResender{s: s, channels: []string{store.EmailChannel}}
+	app := newTestApp()
+	app.Post("/events/:name/resend", RateLimit(3, time.Minute), func(c *fiber.Ctx) error { return ResendEvent(c, s, resender) })
+
+	resp := request(t, app, alice, "POST", "/events/standup/resend", "")
+	expectStatus(t, resp, 200)
+	var body struct {
+		Deliveries []store.Delivery `json:"deliveries"`
+	}
+	decode(t, resp, &body)
+	if resender.calls != 1 || len(body.Deliveries) != 1 || !body.Deliveries[0].Manual {
+		t.Fatalf("got %d calls answered with %+v, want one manual delivery", resender.calls, body.Deliveries)
+	}
+	history, err := s.Deliveries.ForEvent(context.Background(), alice, event.ID)
+	if err != nil || len(history) != 1 || history[0].ID != body.Deliveries[0].ID {
+		t.Fatalf("got history %+v, %v, want the delivery answered", history, err)
+	}
+
+	// Only the user's own events are resent
+	expectStatus(t, request(t, app, bob, "POST", "/events/standup/resend", ""), 404)
+	if resender.calls != 1 {
+		t.Fatalf("got %d calls, want the event of alice not resent for bob", resender.calls)
+	}
+
+	// The limit counts every request of the client, bob's too; beyond it the client is told when to
+	// try again
+	expectStatus(t, request(t, app, alice, "POST", "/events/standup/resend", ""), 200)
+	resp = request(t, app, alice, "POST", "/events/standup/resend", "")
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("got status %d, want 429", resp.StatusCode)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter)); err != nil || seconds <= 0 || seconds > 60 {
+		t.Fatalf("got Retry-After %q, want the seconds left of the minute", resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+	if resender.calls != 2 {
+		t.Fatalf("got %d calls, want the limited request not resent", resender.calls)
+	}
+}
+
+func TestResendEventWithoutChannels(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	createEvent(t, s, alice, "standup")
+	app := newTestApp()
+	app.Post("/events/:name/resend", func(c *fiber.Ctx) error { return ResendEvent(c, s, &recordingResender{s: s}) })
+
+	expectStatus(t, request(t, app, alice, "POST", "/events/standup/resend", ""), 409)
+	expectStatus(t, request(t, app, alice, "POST", "/events/missing/resend", ""), 404)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/notify"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Most devices a user may register for push notifications
+const maxDevices = 20
+
+// PushService describes the push services devices can register for; the push notifier implements it.
+type PushService interface {
+	Platforms() []string
+	VAPIDPublicKey() string
+}
+
+// WebPushSubscription struct is a browser's PushSubscription as serialized by its toJSON method.
+// ExpirationTime is in milliseconds since the epoch.
+type WebPushSubscription struct {
+	Endpoint       string `json:"endpoint" validate:"required,max=2048,http_url"`
+	ExpirationTime *int64 `json:"expirationTime"`
+	Keys           struct {
+		P256dh string `json:"p256dh" validate:"required,max=128"`
+		Auth   string `json:"auth" validate:"required,max=64"`
+	} `json:"keys"`
+}
+
+// DeviceRequest struct defines the body of a device registration: a Web Push subscription for
+// browsers, or the registration token of an FCM app.
+type DeviceRequest struct {
+	Platform     string               `json:"platform" form:"platform" validate:"required,oneof=webpush fcm"`
+	Subscription *WebPushSubscription `json:"subscription" form:"-" validate:"required_if=Platform webpush"`
+	Token        string               `json:"token" form:"token" validate:"required_if=Platform fcm,max=4096"`
+}
+
+// supportsPlatform reports whether push offers platform.
+func supportsPlatform(push PushService, platform string) bool {
+	for _, supported := range push.Platforms() {
+		if supported == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPushConfig returns the platforms devices may register for and the VAPID public key browsers
+// subscribe with.
+func GetPushConfig(c *fiber.Ctx, push PushService) error {
+	platforms := push.Platforms()
+	if platforms == nil {
+		platforms = []string{}
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":           "fetched",
+		"platforms":        platforms,
+		"vapid_public_key": push.VAPIDPublicKey(),
+		"message":          "Push configuration fetched successfully",
+	})
+}
+
+// RegisterDevice registers a browser or app of the authenticated user for push notifications.
+// Registering a device again updates its keys and expiry.
+func RegisterDevice(c *fiber.Ctx, s *store.Store, push PushService) error {
+	req := new(DeviceRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid device", problems)
+	}
+	if !supportsPlatform(push, req.Platform) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("push notifications via %s are not available", req.Platform),
+		})
+	}
+
+	device := &store.Device{Platform: req.Platform, Endpoint: req.Token}
+	if req.Platform == store.PlatformWebPush {
+		sub := req.Subscription
+		if !strings.HasPrefix(sub.Endpoint, "https://") {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "subscription.endpoint must be an https URL",
+			})
+		}
+		if err := notify.CheckSubscription(sub.Keys.P256dh, sub.Keys.Auth); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "subscription." + err.Error(),
+			})
+		}
+
+		device.Endpoint = sub.Endpoint
+		device.P256dh = sub.Keys.P256dh
+		device.Auth = sub.Keys.Auth
+		if sub.ExpirationTime != nil {
+			expiresAt := time.UnixMilli(*sub.ExpirationTime).UTC()
+			device.ExpiresAt = &expiresAt
+		}
+	}
+
+	var userID = getUserID(c)
+
+	devices, err := s.Devices.List(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+	known := false
+	for _, registered := range devices {
+		if registered.Endpoint == device.Endpoint {
+			known = true
+		}
+	}
+	if !known && len(devices) >= maxDevices {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("at most %d devices may be registered", maxDevices),
+		})
+	}
+
+	if err := s.Devices.Register(c.UserContext(), userID, device); err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "created",
+		"device":  device,
+		"message": "Device registered successfully",
+	})
+}
+
+// ListDevices returns the devices the authenticated user registered for push notifications.
+func ListDevices(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	devices, err := s.Devices.List(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"devices": devices,
+		"message": "Devices fetched successfully",
+	})
+}
+
+// DeleteDevice unregisters one of the authenticated user's devices, e.g. when the user signs out
+// on it.
+func DeleteDevice(c *fiber.Ctx, s *store.Store) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "device id must be an integer",
+		})
+	}
+
+	var userID = getUserID(c)
+
+	if err := s.Devices.Delete(c.UserContext(), userID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Device deleted successfully",
+	})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"strings"
+)
+
+// errStale is returned by a precondition check when the event changed since the client read it
+var errStale = errors.New("event was modified")
+
+// eventETag returns the entity tag of an event's current state. Every write through the store
+// sets a new UpdatedAt, so the tag changes with each modification.
+func eventETag(event *store.Event) string {
+	return fmt.Sprintf(`"%d-%d"`, event.ID, event.UpdatedAt.UnixMicro())
+}
+
+// matchesETag reports whether an If-Match header value matches etag. The value is "*" or a
+// comma-separated list of tags; weak tags (W/"...") never match, as If-Match compares strongly.
+func matchesETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
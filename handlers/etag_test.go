@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"1-100"`, true},
+		{`*`, true},
+		{`"1-99", "1-100"`, true},
+		{`"1-99"`, false},
+		{`W/"1-100"`, false},
+		{`1-100`, false},
+	}
+	for _, test := range tests {
+		if got := matchesETag(test.header, `"1-100"`); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.header, got, test.want)
+		}
+	}
+}
+
+func TestDeleteEventIfMatch(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Get("/event/:name", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+	app.Delete("/event/:name", func(c *fiber.Ctx) error { return DeleteEvent(c, s) })
+
+	// read returns the ETag of one of alice's events
+	read := func(name string) string {
+		t.Helper()
+		resp := request(t, app, alice, "GET", "/event/"+name, "")
+		expectStatus(t, resp, 200)
+		resp.Body.Close()
+		etag := resp.Header.Get(fiber.HeaderETag)
+		if etag == "" {
+			t.Fatalf("%s: got no ETag", name)
+		}
+		return etag
+	}
+	// remove deletes one of alice's events with the given If-Match header, if any
+	remove := func(name, ifMatch string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("DELETE", "/event/"+name, nil)
+		req.Header.Set(testUserHeader, strconv.Itoa(alice))
+		if ifMatch != "" {
+			req.Header.Set(fiber.HeaderIfMatch, ifMatch)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	// exists reports whether alice still has the named event
+	exists := func(name string) bool {
+		t.Helper()
+		_, err := s.Events.Get(context.Background(), alice, name)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	// A tag read before the last change is stale, and the event stays
+	createEvent(t, s, alice, "standup")
+	stale := read("standup")
+	expectStatus(t, request(t, app, alice, "PUT", "/event/standup", `{"message":"Standup moved"}`), 200)
+	current := read("standup")
+	if stale == current {
+		t.Fatalf("got the same ETag %s before and after the update", stale)
+	}
+	expectStatus(t, remove("standup", stale), 412)
+	if !exists("standup") {
+		t.Fatal("stale If-Match: the event was deleted")
+	}
+
+	// The current tag, alone or in a list, deletes it
+	expectStatus(t, remove("standup", stale+", "+current), 200)
+	if exists("standup") {
+		t.Fatal("current If-Match: the event was not deleted")
+	}
+	expectStatus(t, remove("standup", current), 404)
+
+	// "*" matches any state, and without the header the delete is unconditional
+	createEvent(t, s, alice, "review")
+	expectStatus(t, remove("review", "*"), 200)
+	createEvent(t, s, alice, "retro")
+	expectStatus(t, remove("retro", ""), 200)
+	if exists("review") || exists("retro") {
+		t.Fatal("the events deleted with * or without If-Match remain")
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"strconv"
+)
+
+// Number of events written between flushes of the export stream
+const exportFlushEvery = 100
+
+// ExportData returns everything stored about the authenticated user as a downloadable JSON document.
+// Events are streamed one by one so accounts with many reminders are never held in memory at once.
+func ExportData(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown user",
+		})
+	}
+
+	profile, err := loadProfile(c.UserContext(), s.Users, userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	// The stream is written after the handler returns, so nothing may read from c inside it
+	ctx, logger := c.UserContext(), Logger(c)
+
+	c.Attachment("reminder-export.json")
+	c.Status(200)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		w.WriteString(`{"user":`)
+		w.Write(profileJSON)
+		w.WriteString(`,"events":[`)
+
+		count := 0
+		err := s.Events.Each(ctx, userID, func(event *store.Event) error {
+			eventJSON, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				w.WriteByte(',')
+			}
+			w.Write(eventJSON)
+			count++
+
+			if count%exportFlushEvery == 0 {
+				// A failed flush means the client went away; stop reading events
+				return w.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			// Headers are already sent, so a truncated document is the only signal left
+			logger.Warn("Export aborted", slog.Any("error", err))
+			return
+		}
+
+		w.WriteString(`],"event_count":`)
+		w.WriteString(strconv.Itoa(count))
+		w.WriteString(`}`)
+	})
+
+	return nil
+}
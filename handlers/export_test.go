@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"testing"
+)
+
+func TestExportData(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	// More events than are written between two flushes of the stream
+	for i := 0; i < exportFlushEvery+5; i++ {
+		createEvent(t, s, alice, fmt.Sprintf("event-%d", i))
+	}
+	createEvent(t, s, bob, "other")
+	app := newTestApp()
+	app.Get("/export", func(c *fiber.Ctx) error { return ExportData(c, s) })
+
+	resp := request(t, app, alice, "GET", "/export", "")
+	expectStatus(t, resp, 200)
+	if disposition := resp.Header.Get(fiber.HeaderContentDisposition); !strings.HasPrefix(disposition, "attachment") {
+		t.Fatalf("got Content-Disposition %q, want an attachment", disposition)
+	}
+	var export struct {
+		User       map[string]interface{} `json:"user"`
+		Events     []store.Event          `json:"events"`
+		EventCount int                    `json:"event_count"`
+	}
+	decode(t, resp, &export)
+	if export.User["id"] != float64(alice) || export.User["username"] != "alice" {
+		t.Fatalf("got user %v, want the profile of alice", export.User)
+	}
+	for field := range export.User {
+		if strings.Contains(strings.ToLower(field), "password") {
+			t.Fatalf("export exposes %s", field)
+		}
+	}
+	if want := exportFlushEvery + 5; len(export.Events) != want || export.EventCount != want {
+		t.Fatalf("got %d events and event_count %d, want %d", len(export.Events), export.EventCount, want)
+	}
+	for _, event := range export.Events {
+		if event.Name == "other" {
+			t.Fatal("export holds an event of another user")
+		}
+	}
+
+	expectStatus(t, request(t, app, 0, "GET", "/export", ""), 401)
+}
@@ -1,227 +1,764 @@
 package handlers
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-// Events struct defines the structure of an event.
-type Events struct {
-	Name    string `json:"name"`
-	Date    string `json:"date"`
-	Message string `json:"message"`
+// getUserID retrieves the user ID of the authenticated request. Requests authenticated with an
+// API key carry the id directly, and JWTs in the user_id claim, which survives a change of
+// username and which CheckTokenVersion already verified, so no query is needed.
+func getUserID(c *fiber.Ctx) int {
+	if userID, ok := c.Locals(apiKeyUserLocal).(int); ok {
+		return userID
+	}
+	userID, _, _ := tokenClaims(c)
+	return userID
 }
 
-// getUserID retrieves the user ID from the database based on the username extracted from JWT claims.
-func getUserID(c *fiber.Ctx, db *sql.DB) int {
-	user := c.Locals("user") // Extract the decoded JWT claims
-	var username string
+// UserID returns the id of the authenticated user for routes outside this package, or 0 when the
+// user is unknown.
+func UserID(c *fiber.Ctx) int {
+	return getUserID(c)
+}
 
-	// Assert and extract claims from the JWT token
-	if token, ok := user.(*jwt.Token); ok {
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return 0
-		}
+// Path under which a single event is served; its id is appended
+const eventPath = "/api/v1/events/"
 
-		username = claims["username"].(string)
-		log.Println("Authenticated user:", username) // Log the username for debugging purposes
-	} else {
-		return 0
-	}
+// Deprecated marks the responses of a deprecated route with a Deprecation header, so clients
+// learn to move to its replacement.
+func Deprecated(c *fiber.Ctx) error {
+	c.Set("Deprecation", "true")
+	return c.Next()
+}
 
-	var userID int
+// resolveEventName returns the name of the event a request addresses: the event with the :id
+// parameter, or the :name parameter on the deprecated name-based routes. It returns
+// store.ErrNotFound if the user has no event with the id.
+func resolveEventName(c *fiber.Ctx, s *store.Store, userID int) (string, error) {
+	raw := c.Params("id")
+	if raw == "" {
+		return c.Params("name"), nil
+	}
 
-	// Query the database to fetch user ID for the given username
-	err := db.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&userID)
+	id, err := strconv.Atoi(raw)
 	if err != nil {
-		return 0
+		return "", store.ErrNotFound
 	}
-
-	return userID
+	return s.Events.NameByID(c.UserContext(), userID, id)
 }
 
-// CreateEvent handles the creation of a new event in the database.
-func CreateEvent(c *fiber.Ctx, db *sql.DB) error {
-	event := new(Events)
-	// Parse the request body into the event struct
-	if err := json.Unmarshal(c.Body(), &event); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": string(err.Error()),
-		})
+// errNotPermitted aborts a request for an event shared with the user without the permission it needs.
+var errNotPermitted = errors.New("event is shared without the permission needed")
+
+// resolveEvent is resolveEventName for routes that also serve the events shared with the user. It
+// returns the user whose events hold the event, the user themselves or the owner of an event
+// shared with them, and the event's name. permission is the share permission the request needs,
+// store.PermissionRead or store.PermissionEdit, or empty when only the user's own events qualify.
+// It returns errNotPermitted for a shared event the user lacks the permission for.
+func resolveEvent(c *fiber.Ctx, s *store.Store, userID int, permission string) (int, string, error) {
+	name, err := resolveEventName(c, s, userID)
+	// Events are only shared by id
+	if !errors.Is(err, store.ErrNotFound) || c.Params("id") == "" {
+		return userID, name, err
 	}
 
-	var userID = getUserID(c, db)
+	id, _ := strconv.Atoi(c.Params("id"))
+	return resolveSharedEvent(c.UserContext(), s, userID, id, permission)
+}
 
-	// Prepare and execute the SQL query to insert the event
-	insertQuery, err := db.Prepare("INSERT INTO events (name, message, date, user_id) VALUES(?,?,?,?)")
+// resolveSharedEvent returns the owner and the name of the event with the given id shared with a
+// user, who needs permission as for resolveEvent. It returns store.ErrNotFound if the event is not
+// shared with the user, and errNotPermitted if the user lacks the permission.
+func resolveSharedEvent(ctx context.Context, s *store.Store, userID, id int, permission string) (int, string, error) {
+	ownerID, granted, err := s.Shares.Access(ctx, userID, id)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
+		return 0, "", err
+	}
+	if permission == "" || (permission == store.PermissionEdit && granted != store.PermissionEdit) {
+		return 0, "", errNotPermitted
+	}
+	name, err := s.Events.NameByID(ctx, ownerID, id)
+	return ownerID, name, err
+}
+
+// eventLookupFailed answers a request whose event resolveEvent could not resolve.
+func eventLookupFailed(c *fiber.Ctx, err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{
 			"status":  "error",
-			"message": string(err.Error()),
+			"message": "Record not found",
 		})
 	}
-	defer insertQuery.Close()
-
-	_, err = insertQuery.Exec(event.Name, event.Message, event.Date, userID)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
+	if errors.Is(err, errNotPermitted) {
+		return c.Status(403).JSON(fiber.Map{
 			"status":  "error",
-			"message": string(err.Error()),
+			"message": "The event is shared with you without permission to do this",
 		})
 	}
+	return ServerError(c, err)
+}
 
-	return c.Status(200).JSON(fiber.Map{
+// sortedList returns a sorted copy of a list such as the recipients or tags of an event, or an
+// empty list for none.
+func sortedList(list []string) []string {
+	sorted := append([]string{}, list...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// prepareEvent fills in the defaults of a new, validated event and normalizes its fields for
+// storage. Dates without an offset are in loc.
+func prepareEvent(event *store.Event, loc *time.Location) {
+	// Events without an explicit priority are normal priority
+	if event.Priority == "" {
+		event.Priority = defaultPriority
+	}
+	// New events always start out uncompleted, neither snoozed nor dismissed, and outside the trash
+	event.CompletedAt, event.SnoozedUntil, event.DismissedAt, event.DeletedAt = nil, nil, nil, nil
+	// Events without a channel selection are delivered through every channel
+	if event.Channels == nil {
+		event.Channels = []string{}
+	}
+	event.Recipients = sortedList(event.Recipients)
+	event.Tags = sortedList(event.Tags)
+	event.Recurrence = canonicalRecurrence(event.Recurrence)
+
+	// Store the date in RFC3339 UTC. The eventdate rule already checked the layout, so this cannot fail.
+	event.Date, _ = normalizeEventDate(event.Date, loc)
+}
+
+// CreateEvent handles the creation of a new event in the database.
+func CreateEvent(c *fiber.Ctx, s *store.Store) error {
+	event := new(store.Event)
+	// Parse the request body (JSON or form-encoded) into the event struct
+	if err := ParseBody(c, event); err != nil {
+		return invalidBody(c, err)
+	}
+
+	event.Tags = normalizeTags(event.Tags)
+	if problems := ValidateStruct(event); problems != nil {
+		return ValidationFailed(c, "Invalid event", problems)
+	}
+
+	var userID = getUserID(c)
+
+	prepareEvent(event, userLocation(c.UserContext(), s.Users, userID))
+
+	// Insert the event for the authenticated user
+	if err := s.Events.Create(c.UserContext(), userID, event); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An event with this name already exists",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	c.Location(eventPath + strconv.Itoa(event.ID))
+	c.Set(fiber.HeaderETag, eventETag(event))
+	return c.Status(201).JSON(fiber.Map{
 		"status":     "created",
 		"event_name": event.Name,
+		"event":      event,
 		"message":    "Event created successfully",
 	})
 }
 
-// UpdateEvent updates the details of an existing event.
-func UpdateEvent(c *fiber.Ctx, db *sql.DB) error {
-	eventName := c.Params("name") // Get the event name from URL params
+// DuplicateRequest struct defines the body of an event duplication request.
+type DuplicateRequest struct {
+	Name string `json:"name" form:"name" validate:"required,eventname"`
+	Date string `json:"date" form:"date" validate:"omitempty,eventdate"`
+}
 
-	newEvent := new(Events)
+// DuplicateEvent copies an existing event by id, or by name on the deprecated route, under a new
+// name, optionally moving it to a new date. Every other field is carried over from the source event,
+// and so are its reminders. Events shared with the user can be copied too; the copy is the user's own.
+func DuplicateEvent(c *fiber.Ctx, s *store.Store) error {
+	req := new(DuplicateRequest)
+	// Parse the request body (JSON or form-encoded) into the request struct
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
 
-	// Parse the request body into the newEvent struct
-	if err := json.Unmarshal(c.Body(), &newEvent); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": string(err.Error()),
-		})
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid duplicate request", problems)
 	}
 
-	oldEvent := new(Events)
+	var userID = getUserID(c)
 
-	var id int
-	var userID = getUserID(c, db)
+	// The eventdate rule already checked the layout, so this cannot fail
+	if req.Date != "" {
+		req.Date, _ = normalizeEventDate(req.Date, userLocation(c.UserContext(), s.Users, userID))
+	}
 
-	// Fetch the current details of the event
-	err := db.QueryRow("SELECT id, name, message, date FROM events WHERE name = ? and user_id = ?", eventName, userID).Scan(&id, &oldEvent.Name, &oldEvent.Message, &oldEvent.Date)
+	ownerID, eventName, err := resolveEvent(c, s, userID, store.PermissionRead)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		return eventLookupFailed(c, err)
+	}
+
+	// Fetch the event being copied
+	event, err := s.Events.Get(c.UserContext(), ownerID, eventName)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
 			return c.Status(404).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Record not found",
 			})
 		}
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": string(err.Error()),
-		})
+		return ServerError(c, err)
 	}
 
-	// Update fields if new values are provided
-	if newEvent.Name != "" {
-		oldEvent.Name = newEvent.Name
+	// The copy is a fresh, uncompleted event
+	event.Name = req.Name
+	event.CompletedAt, event.SnoozedUntil, event.DismissedAt = nil, nil, nil
+	if req.Date != "" {
+		event.Date, event.SeriesStart = req.Date, req.Date
 	}
-	if newEvent.Message != "" {
-		oldEvent.Message = newEvent.Message
+
+	// Insert the copy as a new event with the reminders of the source; the uniqueness constraint
+	// rejects a taken name
+	sourceID := event.ID
+	if err := s.Events.Duplicate(c.UserContext(), userID, sourceID, event); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An event with this name already exists",
+			})
+		}
+		return ServerError(c, err)
 	}
-	if newEvent.Date != "" {
-		oldEvent.Date = newEvent.Date
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	c.Location(eventPath + strconv.Itoa(event.ID))
+	c.Set(fiber.HeaderETag, eventETag(event))
+	return c.Status(201).JSON(fiber.Map{
+		"status":     "created",
+		"event_id":   event.ID,
+		"event_name": event.Name,
+		"message":    "Event duplicated successfully",
+	})
+}
+
+// ValidateEvent checks an event payload exactly as CreateEvent would, without touching the database.
+func ValidateEvent(c *fiber.Ctx) error {
+	event := new(store.Event)
+	// Parse the request body (JSON or form-encoded) into the event struct
+	if err := ParseBody(c, event); err != nil {
+		return invalidBody(c, err)
 	}
 
-	// Prepare and execute the SQL query to update the event
-	updateQuery, err := db.Prepare("UPDATE events SET name = ?, message = ?, date = ? WHERE id = ?")
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
+	event.Tags = normalizeTags(event.Tags)
+	if problems := ValidateStruct(event); problems != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
 			"status":  "error",
-			"message": string(err.Error()),
+			"valid":   false,
+			"errors":  problems,
+			"message": "Invalid event",
 		})
 	}
-	defer updateQuery.Close()
 
-	_, err = updateQuery.Exec(oldEvent.Name, oldEvent.Message, oldEvent.Date, id)
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "valid",
+		"valid":   true,
+		"message": "Event is valid",
+	})
+}
+
+// UpdateEvent updates the details of an existing event.
+func UpdateEvent(c *fiber.Ctx, s *store.Store) error {
+	newEvent := new(store.Event)
+
+	// Parse the request body (JSON or form-encoded) into the newEvent struct
+	if err := ParseBody(c, newEvent); err != nil {
+		return invalidBody(c, err)
+	}
+
+	// Only the given fields are checked; a rename must still produce a name usable as a URL path param
+	newEvent.Tags = normalizeTags(newEvent.Tags)
+	if problems := validatePatch(newEvent); problems != nil {
+		return ValidationFailed(c, "Invalid event", problems)
+	}
+
+	var userID = getUserID(c)
+
+	ownerID, eventName, err := resolveEvent(c, s, userID, store.PermissionEdit)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": string(err.Error()),
-		})
+		return eventLookupFailed(c, err)
+	}
+
+	// The eventdate rule already checked the layout, so this cannot fail
+	if newEvent.Date != "" {
+		newEvent.Date, _ = normalizeEventDate(newEvent.Date, userLocation(c.UserContext(), s.Users, userID))
+	}
+
+	updated, err := s.Events.Update(c.UserContext(), ownerID, eventName, func(oldEvent *store.Event) error {
+		// Update fields if new values are provided
+		if newEvent.Name != "" {
+			oldEvent.Name = newEvent.Name
+		}
+		if newEvent.Message != "" {
+			oldEvent.Message = newEvent.Message
+		}
+		if newEvent.Date != "" {
+			oldEvent.Date = newEvent.Date
+		}
+		if newEvent.Priority != "" {
+			oldEvent.Priority = newEvent.Priority
+		}
+		if newEvent.URL != "" {
+			oldEvent.URL = newEvent.URL
+		}
+		// An empty list resets the event to every channel; an absent one leaves it unchanged
+		if newEvent.Channels != nil {
+			oldEvent.Channels = newEvent.Channels
+		}
+		// Likewise an empty list removes every recipient
+		if newEvent.Recipients != nil {
+			oldEvent.Recipients = sortedList(newEvent.Recipients)
+		}
+		// Likewise an empty list removes every tag
+		if newEvent.Tags != nil {
+			oldEvent.Tags = sortedList(newEvent.Tags)
+		}
+		if newEvent.EmailNotifications != nil {
+			oldEvent.EmailNotifications = newEvent.EmailNotifications
+		}
+		if newEvent.SMSNotifications != nil {
+			oldEvent.SMSNotifications = newEvent.SMSNotifications
+		}
+		// An empty rule makes the event a one-off; an absent one leaves the rule unchanged
+		if newEvent.Recurrence != nil {
+			oldEvent.Recurrence = canonicalRecurrence(newEvent.Recurrence)
+		}
+		// A new date or rule starts the series over
+		if newEvent.Date != "" || newEvent.Recurrence != nil {
+			oldEvent.SeriesStart = oldEvent.Date
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An event with this name already exists",
+			})
+		}
+		return ServerError(c, err)
 	}
+	eventLists.invalidate(listScope(c, s.Users, ownerID))
 
+	c.Set(fiber.HeaderETag, eventETag(updated))
 	return c.Status(200).JSON(fiber.Map{
 		"status":   "updated",
-		"event_id": id,
+		"event_id": updated.ID,
 		"message":  "Event updated successfully",
 	})
 }
 
-// GetEvent retrieves the details of a specific event by name.
-func GetEvent(c *fiber.Ctx, db *sql.DB) error {
-	eventName := c.Params("name") // Get the event name from URL params
-
-	event := new(Events)
+// CompleteEvent marks an event as done, by id, or by name on the deprecated route. Completed events
+// no longer fire and are hidden from the event list unless requested; ListCompleted lists them.
+// Completing an event twice keeps the original completion time.
+func CompleteEvent(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
 
-	var id int
-	var userID = getUserID(c, db)
+	ownerID, eventName, err := resolveEvent(c, s, userID, store.PermissionEdit)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
 
-	// Query the database to fetch event details
-	err := db.QueryRow("SELECT id, name, message, date FROM events WHERE name = ? and user_id = ?", eventName, userID).Scan(&id, &event.Name, &event.Message, &event.Date)
+	completed, err := s.Events.Update(c.UserContext(), ownerID, eventName, func(event *store.Event) error {
+		if event.CompletedAt == nil {
+			// Whole seconds, matching the precision of the stored DATETIME
+			now := time.Now().UTC().Truncate(time.Second)
+			event.CompletedAt = &now
+		}
+		return nil
+	})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.Status(404).JSON(fiber.Map{
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
 				"status":  "error",
-				"message": string(err.Error()),
+				"message": "Record not found",
 			})
 		}
-		return c.Status(500).JSON(fiber.Map{
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, ownerID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":       "completed",
+		"event_id":     completed.ID,
+		"completed_at": completed.CompletedAt,
+		"message":      "Event marked as completed",
+	})
+}
+
+// GetEvent retrieves the details of a specific event by id, or by name on the deprecated route. With ?detail=full the response
+// also describes the event's notification state.
+func GetEvent(c *fiber.Ctx, s *store.Store) error {
+	detail := c.Query("detail", detailMinimal)
+	if detail != detailMinimal && detail != detailFull {
+		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": string(err.Error()),
+			"message": fmt.Sprintf("detail must be %s or %s", detailMinimal, detailFull),
 		})
 	}
 
-	return c.Status(200).JSON(fiber.Map{
+	var userID = getUserID(c)
+
+	ownerID, eventName, err := resolveEvent(c, s, userID, store.PermissionRead)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	// Fetch the event details
+	event, err := s.Events.Get(c.UserContext(), ownerID, eventName)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, eventETag(event))
+	response := fiber.Map{
 		"status":   "fetched",
-		"event_id": id,
+		"event_id": event.ID,
 		"details":  event,
 		"message":  "Event fetched successfully",
-	})
+	}
+	if detail == detailFull {
+		notification, err := loadNotificationState(c.UserContext(), s, ownerID, event)
+		if err != nil {
+			return ServerError(c, err)
+		}
+		response["notification"] = notification
+	}
+
+	return c.Status(200).JSON(response)
 }
 
-// DeleteEvent removes an event from the database by name.
-func DeleteEvent(c *fiber.Ctx, db *sql.DB) error {
-	var userID = getUserID(c, db)
+// Values of the sort query parameter of ListEvents
+const (
+	sortPriority = "priority"
+	sortDate     = "date"
+)
 
-	eventName := c.Params("name") // Get the event name from URL params
+// Values of the status query parameter of SearchEvents
+const (
+	statusUpcoming = "upcoming"
+	statusPast     = "past"
+)
 
-	// Prepare and execute the SQL query to delete the event
-	deleteQuery, err := db.Prepare("DELETE FROM events WHERE name = ? and user_id = ?")
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
+// Longest text SearchEvents searches for, as for event names
+const maxSearchLength = 255
+
+// ListEvents retrieves the events of the authenticated user one page at a time.
+// Offset pages are sorted by priority, most urgent first, and then date, or by date alone with sort=date; keyset pages (after_id) are sorted by id
+// and avoid scanning skipped rows, which makes them the better choice for large lists.
+// Completed events are left out unless include_completed=true is given, and events created
+// after as_of, the start of the listing echoed in every response, are always left out.
+// from and to restrict the list to events dated within the inclusive range, tag to the events
+// carrying that tag, and priority to the events of the listed priorities, such as high,urgent.
+// Pages are cached per user for a short time unless no_cache=1 is given.
+func ListEvents(c *fiber.Ctx, s *store.Store) error {
+	return listEvents(c, s, store.EventFilter{}, "")
+}
+
+// SearchEvents lists the events of the authenticated user like ListEvents, narrowed down by
+// ?q=, text contained in the name or message ignoring case, and ?status=upcoming or past, which
+// leaves out events dated before or after now.
+func SearchEvents(c *fiber.Ctx, s *store.Store) error {
+	var filter store.EventFilter
+	filter.Search = strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(filter.Search) > maxSearchLength {
+		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": string(err.Error()),
+			"message": fmt.Sprintf("q must be at most %d characters", maxSearchLength),
 		})
 	}
-	defer deleteQuery.Close()
 
-	result, err := deleteQuery.Exec(eventName, userID)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
+	status := c.Query("status")
+	switch status {
+	case "", statusUpcoming, statusPast:
+	default:
+		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": string(err.Error()),
+			"message": fmt.Sprintf("status must be %s or %s", statusUpcoming, statusPast),
 		})
 	}
+	return listEvents(c, s, filter, status)
+}
 
-	rowsAffected, err := result.RowsAffected()
+// ListCompleted lists the history of the user's completed events, most recently completed first.
+// It takes the paging and filters of ListEvents except sort.
+func ListCompleted(c *fiber.Ctx, s *store.Store) error {
+	if c.Query("sort") != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "sort is not supported, completed events are sorted by completed_at",
+		})
+	}
+	return listEvents(c, s, store.EventFilter{CompletedOnly: true}, "")
+}
+
+// listEvents answers ListEvents and SearchEvents: it adds the paging, sorting, and date range
+// parameters of the request to filter and returns one page of the matching events. status is
+// statusUpcoming, statusPast, or empty for events at any date.
+func listEvents(c *fiber.Ctx, s *store.Store, filter store.EventFilter, status string) error {
+	p, err := parsePage(c)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
+		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": string(err.Error()),
+			"message": err.Error(),
 		})
 	}
 
-	if rowsAffected == 0 {
-		return c.Status(404).JSON(fiber.Map{
+	if filter.IncludeCompleted, err = queryBool(c, "include_completed"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Record not found",
+			"message": err.Error(),
+		})
+	}
+	if filter.AsOf, err = queryTime(c, "as_of"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "from must not be after to",
+		})
+	}
+	if tag := c.Query("tag"); tag != "" {
+		filter.Tag = normalizeTag(tag)
+		if err := validateTag(filter.Tag); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+	}
+	if filter.Priorities, err = queryPriorities(c, "priority"); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	switch c.Query("sort", sortPriority) {
+	case sortPriority:
+	case sortDate:
+		if p.Keyset {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "sort cannot be combined with after_id, keyset pages are sorted by id",
+			})
+		}
+		filter.ByDate = true
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("sort must be %s or %s", sortPriority, sortDate),
+		})
+	}
+
+	// Without as_of the listing starts now; the page links carry the time so that later pages
+	// leave out events created in the meantime
+	var pinned map[string]string
+	if filter.AsOf.IsZero() {
+		filter.AsOf = time.Now().UTC()
+		pinned = map[string]string{"as_of": filter.AsOf.Format(time.RFC3339Nano)}
+	}
+
+	// The status narrows the date range given by from and to at the current time
+	now := time.Now()
+	switch status {
+	case statusUpcoming:
+		if filter.From.Before(now) {
+			filter.From = now
+		}
+	case statusPast:
+		if filter.To.IsZero() || filter.To.After(now) {
+			filter.To = now
+		}
+	}
+
+	noCache, err := queryBool(c, "no_cache")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	var userID = getUserID(c)
+
+	// A cached page is as recent as a new one: writes drop the user's cached pages, so no event
+	// was created since the page's as_of. as_of is only part of the key when the client sent it.
+	key := fmt.Sprintf("%d/%d/%d/%t/%t/%t/%s/%s/%s/%t/%q/%s/%s/%s", p.Limit, p.Offset, p.AfterID, p.Keyset, filter.IncludeCompleted, filter.CompletedOnly,
+		c.Query("as_of"), c.Query("from"), c.Query("to"), filter.ByDate, filter.Search, status, filter.Tag, strings.Join(filter.Priorities, ","))
+	scope := listScope(c, s.Users, userID)
+	list, version, cached := eventLists.get(scope, key)
+	if noCache || !cached {
+		list = listResult{asOf: filter.AsOf}
+		if list.events, list.hasMore, err = s.Events.List(c.UserContext(), userID, filter, p); err != nil {
+			return ServerError(c, err)
+		}
+		if list.total, err = s.Events.Count(c.UserContext(), userID, filter); err != nil {
+			return ServerError(c, err)
+		}
+		eventLists.put(scope, version, key, list)
+	}
+	events, hasMore, total := list.events, list.hasMore, list.total
+	if pinned != nil {
+		pinned["as_of"] = list.asOf.Format(time.RFC3339Nano)
+	}
+
+	response := fiber.Map{
+		"status":  "fetched",
+		"count":   len(events),
+		"total":   total,
+		"events":  events,
+		"limit":   p.Limit,
+		"as_of":   list.asOf.UTC().Format(time.RFC3339Nano),
+		"message": "Events fetched successfully",
+	}
+	var nextCursor int
+	if p.Keyset {
+		// next_cursor is only present when more rows exist; pass it back as after_id
+		if hasMore {
+			nextCursor = events[len(events)-1].ID
+			response["next_cursor"] = nextCursor
+		}
+	} else {
+		response["offset"] = p.Offset
+		response["has_more"] = hasMore
+	}
+
+	setPageHeaders(c, p, total, hasMore, nextCursor, pinned)
+	return c.Status(200).JSON(response)
+}
+
+// Most events a single batch get may ask for, as enforced by the max rule on BatchGetRequest.Names
+const maxBatchGetNames = 100
+
+// BatchGetRequest struct defines the body of a batch get: the names of the events to fetch.
+type BatchGetRequest struct {
+	Names []string `json:"names" form:"names" validate:"required,min=1,max=100,dive,required"`
+}
+
+// BatchGetEvents fetches several events by name in one query. The events are returned keyed by
+// name, and names that do not match one of the user's events are listed in not_found.
+func BatchGetEvents(c *fiber.Ctx, s *store.Store) error {
+	req := new(BatchGetRequest)
+	// Parse the request body (JSON or form-encoded) into the request struct
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, fmt.Sprintf("Provide between 1 and %d event names", maxBatchGetNames), problems)
+	}
+
+	var userID = getUserID(c)
+
+	events, err := s.Events.GetMany(c.UserContext(), userID, req.Names)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	found := make(map[string]store.Event, len(events))
+	for _, event := range events {
+		found[event.Name] = event
+	}
+	// Report each missing name once, in request order
+	notFound := []string{}
+	seen := make(map[string]bool, len(req.Names))
+	for _, name := range req.Names {
+		if _, ok := found[name]; !ok && !seen[name] {
+			notFound = append(notFound, name)
+		}
+		seen[name] = true
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":    "fetched",
+		"events":    found,
+		"not_found": notFound,
+		"message":   "Events fetched successfully",
+	})
+}
+
+// DeleteEvent removes an event from the database by id, or by name on the deprecated route.
+func DeleteEvent(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	// Only the user's own events may be deleted, not those shared with them
+	_, eventName, err := resolveEvent(c, s, userID, "")
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	// With If-Match the event is only deleted in the state the client last read
+	if ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch != "" {
+		err = s.Events.DeleteIf(c.UserContext(), userID, eventName, func(event *store.Event) error {
+			if !matchesETag(ifMatch, eventETag(event)) {
+				return errStale
+			}
+			return nil
 		})
+	} else {
+		err = s.Events.Delete(c.UserContext(), userID, eventName)
 	}
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		if errors.Is(err, errStale) {
+			return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Event was modified since it was read, fetch it again",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
 
 	return c.Status(200).JSON(fiber.Map{
 		"status":     "deleted",
@@ -229,3 +766,35 @@ func DeleteEvent(c *fiber.Ctx, db *sql.DB) error {
 		"message":    "Event deleted successfully",
 	})
 }
+
+// DeleteEvents removes several events by name in a single transaction.
+// Names that do not match one of the user's events are reported back instead of failing the request.
+func DeleteEvents(c *fiber.Ctx, s *store.Store) error {
+	var names []string
+	// Parse the request body as a JSON array of event names
+	if err := ParseBody(c, &names); err != nil {
+		return invalidBody(c, err)
+	}
+
+	if len(names) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No event names provided",
+		})
+	}
+
+	var userID = getUserID(c)
+
+	deleted, notFound, err := s.Events.DeleteMany(c.UserContext(), userID, names)
+	if err != nil {
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":    "deleted",
+		"deleted":   deleted,
+		"not_found": notFound,
+		"message":   "Events deleted successfully",
+	})
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Vansh3140/Reminder-App/database"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Header naming the user a test request is made by
+const testUserHeader = "X-Test-User"
+
+// testAuth is middleware authenticating every request as the user named by testUserHeader, as
+// the JWT middleware does for a valid token of that user.
+func testAuth(c *fiber.Ctx) error {
+	userID, _ := strconv.Atoi(c.Get(testUserHeader))
+	c.Locals("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": float64(userID)}})
+	return c.Next()
+}
+
+// newTestApp returns an app authenticating requests with testAuth. Its strings are immutable, since
+// the memory store keeps the strings it is given, which Fiber would otherwise reuse for later requests.
+func newTestApp() *fiber.App {
+	app := fiber.New(fiber.Config{Immutable: true})
+	app.Use(testAuth)
+	return app
+}
+
+// request makes a request to app as a user, with a JSON body unless body is empty.
+func request(t *testing.T, app *fiber.App, userID int, method, path, body string) *http.Response {
+	t.Helper()
+	contentType := ""
+	if body != "" {
+		contentType = fiber.MIMEApplicationJSON
+	}
+	return send(t, app, userID, method, path, contentType, body)
+}
+
+// send makes a request to app as a user, with a body of the given content type.
+func send(t *testing.T, app *fiber.App, userID int, method, path, contentType, body string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(testUserHeader, strconv.Itoa(userID))
+	if contentType != "" {
+		req.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// decode decodes the JSON body of resp into v.
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+	t.Helper()
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+}
+
+// expectStatus fails the test unless resp has the wanted status.
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		t.Fatalf("got status %d, want %d; body %v", resp.StatusCode, want, body)
+	}
+}
+
+// createUser stores a user and returns its id.
+func createUser(t *testing.T, s *store.Store, username string) int {
+	t.Helper()
+	id, err := s.Users.Create(context.Background(), username, "hash")
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return id
+}
+
+// createEvent stores an event of a user dated in 2030, as CreateEvent would.
+func createEvent(t *testing.T, s *store.Store, userID int, name string) *store.Event {
+	t.Helper()
+	event := &store.Event{Name: name, Message: "Message of " + name, Date: "2030-01-01T09:00:00Z", Priority: defaultPriority}
+	prepareEvent(event, time.UTC)
+	if err := s.Events.Create(context.Background(), userID, event); err != nil {
+		t.Fatalf("create event %s: %v", name, err)
+	}
+	return event
+}
+
+// getEvent loads one of a user's events from the store.
+func getEvent(t *testing.T, s *store.Store, userID int, name string) *store.Event {
+	t.Helper()
+	event, err := s.Events.Get(context.Background(), userID, name)
+	if err != nil {
+		t.Fatalf("get event %s: %v", name, err)
+	}
+	return event
+}
+
+func TestEventBodiesAsJSONOrForm(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-02T09:00:00Z","tags":["work"]}`), 201)
+	expectStatus(t, send(t, app, alice, "POST", "/event", fiber.MIMEApplicationForm,
+		"name=review&message=Weekly+review&date=2030-01-07T09%3A00%3A00Z&tags=work&tags=team"), 201)
+
+	standup, review := getEvent(t, s, alice, "standup"), getEvent(t, s, alice, "review")
+	if standup.Message != "Daily standup" || standup.Date != "2030-01-02T09:00:00Z" || strings.Join(standup.Tags, ",") != "work" {
+		t.Fatalf("event created from JSON: got %+v", standup)
+	}
+	if review.Message != "Weekly review" || review.Date != "2030-01-07T09:00:00Z" || strings.Join(review.Tags, ",") != "team,work" {
+		t.Fatalf("event created from a form: got %+v", review)
+	}
+
+	expectStatus(t, send(t, app, alice, "PUT", "/event/review", fiber.MIMEApplicationForm, "message=Monthly+review"), 200)
+	if review := getEvent(t, s, alice, "review"); review.Message != "Monthly review" || review.Date != "2030-01-07T09:00:00Z" || strings.Join(review.Tags, ",") != "team,work" {
+		t.Fatalf("event updated from a form: got %+v", review)
+	}
+
+	for _, body := range []struct{ contentType, body string }{
+		{fiber.MIMEApplicationJSON, `{"name":`},
+		{fiber.MIMEApplicationJSON, `["standup"]`},
+		{fiber.MIMETextPlain, "name=standup"},
+	} {
+		resp := send(t, app, alice, "POST", "/event", body.contentType, body.body)
+		expectStatus(t, resp, 400)
+		var answer map[string]string
+		decode(t, resp, &answer)
+		if answer["status"] != "error" || answer["message"] != "Invalid request body" {
+			t.Fatalf("%s body %q: got %v", body.contentType, body.body, answer)
+		}
+	}
+}
+
+func TestUnicodeMessagesRoundTrip(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_CREDS", filepath.Join(t.TempDir(), "reminders.db"))
+	db, err := database.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := store.NewSQL(db)
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Get("/event/:name", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+
+	message := "Mum's birthday 🎂🎉 — 誕生日おめでとう, День рождения, 👩‍👩‍👧 ✅"
+	encoded, _ := json.Marshal(message)
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"birthday","message":`+string(encoded)+`,"date":"2030-01-02T09:00:00Z"}`), 201)
+
+	resp := request(t, app, alice, "GET", "/event/birthday", "")
+	expectStatus(t, resp, 200)
+	var body struct {
+		Details store.Event `json:"details"`
+	}
+	decode(t, resp, &body)
+	if body.Details.Message != message {
+		t.Fatalf("got message %q, want %q", body.Details.Message, message)
+	}
+}
+
+func TestDeleteEvents(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	createEvent(t, s, alice, "standup")
+	createEvent(t, s, alice, "review")
+	app := newTestApp()
+	app.Delete("/events", func(c *fiber.Ctx) error { return DeleteEvents(c, s) })
+
+	resp := request(t, app, alice, "DELETE", "/events", `["standup","missing"]`)
+	expectStatus(t, resp, 200)
+	var body struct {
+		Deleted  int      `json:"deleted"`
+		NotFound []string `json:"not_found"`
+	}
+	decode(t, resp, &body)
+	if body.Deleted != 1 || len(body.NotFound) != 1 || body.NotFound[0] != "missing" {
+		t.Fatalf("got %+v, want standup deleted and missing not found", body)
+	}
+	if _, err := s.Events.Get(context.Background(), alice, "standup"); err == nil {
+		t.Fatal("standup was not deleted")
+	}
+	getEvent(t, s, alice, "review")
+
+	expectStatus(t, request(t, app, alice, "DELETE", "/events", `[]`), 400)
+	expectStatus(t, request(t, app, alice, "DELETE", "/events", `{"names":["review"]}`), 400)
+}
+
+// listNames lists the events of a user with the given query and returns their names in order.
+func listNames(t *testing.T, app *fiber.App, userID int, query string) []string {
+	t.Helper()
+	resp := request(t, app, userID, "GET", "/events"+query, "")
+	expectStatus(t, resp, 200)
+	var body struct {
+		Events []store.Event `json:"events"`
+	}
+	decode(t, resp, &body)
+	var names []string
+	for _, event := range body.Events {
+		names = append(names, event.Name)
+	}
+	return names
+}
+
+func TestCreatedEventsLocated(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Post("/event/:name/duplicate", func(c *fiber.Ctx) error { return DuplicateEvent(c, s) })
+	app.Get("/api/v1/events/:id", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+
+	// expectCreated checks that resp answers the creation of the named event with 201 and its
+	// location, which serves the event
+	expectCreated := func(resp *http.Response, name string) {
+		t.Helper()
+		expectStatus(t, resp, 201)
+		resp.Body.Close()
+		event := getEvent(t, s, alice, name)
+		want := "/api/v1/events/" + strconv.Itoa(event.ID)
+		if location := resp.Header.Get(fiber.HeaderLocation); location != want {
+			t.Fatalf("%s: got Location %q, want %q", name, location, want)
+		}
+		found := request(t, app, alice, "GET", want, "")
+		expectStatus(t, found, 200)
+		var body struct {
+			Details store.Event `json:"details"`
+		}
+		decode(t, found, &body)
+		if body.Details.ID != event.ID || body.Details.Name != name {
+			t.Fatalf("%s: location served %+v", name, body.Details)
+		}
+	}
+
+	resp := request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-02T09:00:00Z"}`)
+	expectCreated(resp, "standup")
+	expectCreated(request(t, app, alice, "POST", "/event/standup/duplicate", `{"name":"standup-copy"}`), "standup-copy")
+
+	// Failed creations point nowhere
+	resp = request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-02T09:00:00Z"}`)
+	if resp.StatusCode != 409 || resp.Header.Get(fiber.HeaderLocation) != "" {
+		t.Fatalf("duplicate name: got status %d and Location %q, want 409 without one", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+}
+
+func TestEventPriorities(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Get("/events", func(c *fiber.Ctx) error { return ListEvents(c, s) })
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+
+	for _, body := range []string{
+		`{"name":"later","message":"Later","date":"2030-01-05T09:00:00Z","priority":"high"}`,
+		`{"name":"chores","message":"Chores","date":"2030-01-01T09:00:00Z","priority":"low"}`,
+		`{"name":"standup","message":"Daily standup","date":"2030-01-03T09:00:00Z"}`,
+		`{"name":"deadline","message":"Deadline","date":"2030-01-04T09:00:00Z","priority":"urgent"}`,
+		`{"name":"sooner","message":"Sooner","date":"2030-01-02T09:00:00Z","priority":"high"}`,
+	} {
+		expectStatus(t, request(t, app, alice, "POST", "/event", body), 201)
+	}
+	if standup := getEvent(t, s, alice, "standup"); standup.Priority != "normal" {
+		t.Fatalf("event created without a priority: got priority %q, want normal", standup.Priority)
+	}
+
+	// Events are listed from the most urgent, and by date within a priority
+	if names := strings.Join(listNames(t, app, alice, ""), ","); names != "deadline,sooner,later,standup,chores" {
+		t.Fatalf("sorted by priority: got %s", names)
+	}
+	if names := strings.Join(listNames(t, app, alice, "?sort=date"), ","); names != "chores,sooner,standup,deadline,later" {
+		t.Fatalf("sorted by date: got %s", names)
+	}
+
+	expectStatus(t, request(t, app, alice, "PUT", "/event/chores", `{"priority":"urgent"}`), 200)
+	if names := strings.Join(listNames(t, app, alice, ""), ","); names != "chores,deadline,sooner,later,standup" {
+		t.Fatalf("after raising the priority of chores: got %s", names)
+	}
+
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"party","message":"Party","date":"2030-01-06T09:00:00Z","priority":"critical"}`), 422)
+	expectStatus(t, request(t, app, alice, "PUT", "/event/standup", `{"priority":"critical"}`), 422)
+	expectStatus(t, request(t, app, alice, "GET", "/events?sort=name", ""), 400)
+}
+
+func TestEventURL(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+	app.Get("/event/:name", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"meeting","message":"Planning","date":"2030-01-01T09:00:00Z","url":"https://meet.example.com/planning?id=1"}`), 201)
+	resp := request(t, app, alice, "GET", "/event/meeting", "")
+	expectStatus(t, resp, 200)
+	var body struct {
+		Details store.Event `json:"details"`
+	}
+	decode(t, resp, &body)
+	if body.Details.URL != "https://meet.example.com/planning?id=1" {
+		t.Fatalf("got url %q", body.Details.URL)
+	}
+
+	// The URL is optional
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-01T09:00:00Z","url":""}`), 201)
+	if standup := getEvent(t, s, alice, "standup"); standup.URL != "" {
+		t.Fatalf("got url %q, want none", standup.URL)
+	}
+
+	for _, url := range []string{"ftp://files.example.com/agenda", "javascript:alert(1)", "/relative/path", "not a url"} {
+		expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"review","message":"Review","date":"2030-01-01T09:00:00Z","url":"`+url+`"}`), 422)
+		expectStatus(t, request(t, app, alice, "PUT", "/event/meeting", `{"url":"`+url+`"}`), 422)
+	}
+	if meeting := getEvent(t, s, alice, "meeting"); meeting.URL != "https://meet.example.com/planning?id=1" {
+		t.Fatalf("rejected updates changed the url to %q", meeting.URL)
+	}
+}
+
+func TestDuplicateEvent(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	weekly := "FREQ=WEEKLY"
+	source := &store.Event{Name: "review", Message: "Weekly review", Date: "2030-01-07T09:00:00Z", Priority: "high", Recurrence: &weekly, Tags: []string{"team", "work"}}
+	prepareEvent(source, time.UTC)
+	if err := s.Events.Create(ctx, alice, source); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reminders.Add(ctx, alice, source.ID, 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	createEvent(t, s, alice, "standup")
+	app := newTestApp()
+	app.Post("/event/:name/duplicate", func(c *fiber.Ctx) error { return DuplicateEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+
+	resp := request(t, app, alice, "POST", "/event/review/duplicate", `{"name":"review-copy","date":"2030-02-04T09:00:00Z"}`)
+	expectStatus(t, resp, 201)
+	var body struct {
+		EventID int `json:"event_id"`
+	}
+	decode(t, resp, &body)
+
+	duplicate := getEvent(t, s, alice, "review-copy")
+	if body.EventID != duplicate.ID || duplicate.ID == source.ID {
+		t.Fatalf("got event_id %d for copy %d of event %d", body.EventID, duplicate.ID, source.ID)
+	}
+	if duplicate.Message != "Weekly review" || duplicate.Priority != "high" || duplicate.Date != "2030-02-04T09:00:00Z" ||
+		*duplicate.Recurrence != weekly || strings.Join(duplicate.Tags, ",") != "team,work" {
+		t.Fatalf("got copy %+v", duplicate)
+	}
+	if reminders, err := s.Reminders.List(ctx, alice, duplicate.ID); err != nil || len(reminders) != 1 || !reminders[0].FireAt.Equal(time.Date(2030, 2, 4, 8, 50, 0, 0, time.UTC)) {
+		t.Fatalf("reminders of the copy: got %+v, %v", reminders, err)
+	}
+
+	// The copy is independent of its source
+	expectStatus(t, request(t, app, alice, "PUT", "/event/review-copy", `{"message":"Monthly review"}`), 200)
+	if review := getEvent(t, s, alice, "review"); review.Message != "Weekly review" || review.Date != "2030-01-07T09:00:00Z" {
+		t.Fatalf("updating the copy changed the source: got %+v", review)
+	}
+
+	expectStatus(t, request(t, app, alice, "POST", "/event/review/duplicate", `{"name":"standup"}`), 409)
+	expectStatus(t, request(t, app, alice, "POST", "/event/missing/duplicate", `{"name":"copy"}`), 404)
+	expectStatus(t, request(t, app, alice, "POST", "/event/review/duplicate", `{"name":"bad name"}`), 422)
+}
+
+func TestCompleteEvent(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	createEvent(t, s, alice, "standup")
+	createEvent(t, s, alice, "review")
+	app := newTestApp()
+	app.Get("/events", func(c *fiber.Ctx) error { return ListEvents(c, s) })
+	app.Post("/event/:name/complete", func(c *fiber.Ctx) error { return CompleteEvent(c, s) })
+
+	expectStatus(t, request(t, app, alice, "POST", "/event/standup/complete", ""), 200)
+	completedAt := getEvent(t, s, alice, "standup").CompletedAt
+	if completedAt == nil {
+		t.Fatal("standup is not completed")
+	}
+
+	// Completed events leave the listing unless asked for
+	if names := strings.Join(listNames(t, app, alice, ""), ","); names != "review" {
+		t.Fatalf("default listing: got %s, want review", names)
+	}
+	if names := listNames(t, app, alice, "?include_completed=true"); len(names) != 2 {
+		t.Fatalf("listing with include_completed: got %v, want both events", names)
+	}
+
+	// Completing again keeps the original time
+	expectStatus(t, request(t, app, alice, "POST", "/event/standup/complete", ""), 200)
+	if again := getEvent(t, s, alice, "standup").CompletedAt; again == nil || !again.Equal(*completedAt) {
+		t.Fatalf("completed again: got %v, want %v", again, completedAt)
+	}
+	expectStatus(t, request(t, app, alice, "POST", "/event/missing/complete", ""), 404)
+}
+
+func TestBatchGetEvents(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	createEvent(t, s, alice, "standup")
+	createEvent(t, s, alice, "review")
+	createEvent(t, s, bob, "dentist")
+	app := newTestApp()
+	app.Post("/events/batch-get", func(c *fiber.Ctx) error { return BatchGetEvents(c, s) })
+
+	// Missing names, including the events of other users, are listed once each in request order
+	resp := request(t, app, alice, "POST", "/events/batch-get", `{"names":["review","missing","dentist","standup","missing"]}`)
+	expectStatus(t, resp, 200)
+	var body struct {
+		Events   map[string]store.Event `json:"events"`
+		NotFound []string               `json:"not_found"`
+	}
+	decode(t, resp, &body)
+	if len(body.Events) != 2 || body.Events["standup"].Name != "standup" || body.Events["review"].Message != "Message of review" {
+		t.Fatalf("got events %+v, want standup and review", body.Events)
+	}
+	if strings.Join(body.NotFound, ",") != "missing,dentist" {
+		t.Fatalf("got not_found %v, want missing and dentist", body.NotFound)
+	}
+
+	// More names than the cap, or none, fail validation
+	names := make([]string, maxBatchGetNames+1)
+	for i := range names {
+		names[i] = "event-" + strconv.Itoa(i)
+	}
+	tooMany, _ := json.Marshal(map[string][]string{"names": names})
+	for _, body := range []string{string(tooMany), `{"names":[]}`, `{}`} {
+		expectStatus(t, request(t, app, alice, "POST", "/events/batch-get", body), 422)
+	}
+	names = names[:maxBatchGetNames]
+	atCap, _ := json.Marshal(map[string][]string{"names": names})
+	expectStatus(t, request(t, app, alice, "POST", "/events/batch-get", string(atCap)), 200)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"time"
+)
+
+// HealthChecker reports whether a background component is working; the scheduler implements it.
+type HealthChecker interface {
+	Health() error
+}
+
+// Longest the readiness check waits for the database to answer a ping
+const readinessTimeout = time.Second
+
+// Liveness answers the liveness probe, GET /healthz, with a 200 as long as the server handles
+// requests at all. It checks nothing else, so that an unreachable database, which restarting the
+// server would not fix, does not get it restarted.
+func Liveness(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+}
+
+// Readiness returns the handler of the readiness probe, GET /readyz, which pings the database
+// through ping and asks scheduler for its health. It answers 200 when both are fine and 503
+// otherwise, reporting each check as "ok" or with the reason it failed. Database errors are only
+// logged, since they may name the server.
+func Readiness(ping func(ctx context.Context) error, scheduler HealthChecker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ready := true
+		checks := fiber.Map{"database": "ok", "scheduler": "ok"}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), readinessTimeout)
+		defer cancel()
+		if err := ping(ctx); err != nil {
+			Logger(c).Warn("Readiness check failed to reach the database", slog.Any("error", err))
+			checks["database"] = "unavailable"
+			ready = false
+		}
+		if err := scheduler.Health(); err != nil {
+			checks["scheduler"] = err.Error()
+			ready = false
+		}
+
+		if !ready {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "error", "checks": checks})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok", "checks": checks})
+	}
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/rrule"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Path under which calendar feeds are served; the token and ".ics" are appended
+const calendarFeedPath = "/calendar/"
+
+// Longest content line of an iCalendar document in octets, excluding the line break
+const icsLineLength = 75
+
+// Layouts of iCalendar DATE-TIME values in UTC and in local time
+const (
+	icsUTCLayout   = "20060102T150405Z"
+	icsLocalLayout = "20060102T150405"
+)
+
+// PRIORITY values of the event priorities; 1 is the highest, 9 the lowest
+var icsPriorities = map[string]string{"urgent": "1", "high": "2", "normal": "5", "low": "9"}
+
+// icsEscape escapes a TEXT value: backslashes, semicolons, commas, and line breaks.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// writeICSLine writes a content line, folded into lines of at most icsLineLength octets without
+// splitting a character, each ended by CRLF.
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := icsLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards their length
+		limit = icsLineLength - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+// icsRule returns the recurrence rule of an event for an RRULE property, or "" for a one-off event.
+// UNTIL is given as a UTC time, since the start is a DATE-TIME; completed events end at completion.
+func icsRule(event *store.Event, loc *time.Location) string {
+	if event.Recurrence == nil || *event.Recurrence == "" {
+		return ""
+	}
+	rule, err := rrule.Parse(*event.Recurrence)
+	if err != nil {
+		return ""
+	}
+
+	if rule.UntilDate {
+		rule.Until = time.Date(rule.Until.Year(), rule.Until.Month(), rule.Until.Day(), 23, 59, 59, 0, loc)
+		rule.UntilDate = false
+	}
+	if event.CompletedAt != nil && (rule.Until.IsZero() || event.CompletedAt.Before(rule.Until)) {
+		// COUNT and UNTIL are mutually exclusive
+		rule.Until, rule.Count = *event.CompletedAt, 0
+	}
+	return rule.String()
+}
+
+// writeICSEvent writes an event as a VEVENT. A recurring event starts at the start of its series,
+// in the owner's timezone loc so its occurrences keep their local clock time.
+func writeICSEvent(buf *bytes.Buffer, event *store.Event, loc *time.Location) {
+	date, err := time.Parse(time.RFC3339, event.Date)
+	if err != nil {
+		return
+	}
+
+	writeICSLine(buf, "BEGIN:VEVENT")
+	writeICSLine(buf, fmt.Sprintf("UID:event-%d@reminder-app", event.ID))
+	writeICSLine(buf, "DTSTAMP:"+event.UpdatedAt.UTC().Format(icsUTCLayout))
+	writeICSLine(buf, "CREATED:"+event.CreatedAt.UTC().Format(icsUTCLayout))
+	writeICSLine(buf, "LAST-MODIFIED:"+event.UpdatedAt.UTC().Format(icsUTCLayout))
+
+	if rule := icsRule(event, loc); rule != "" {
+		start := date
+		if seriesStart, err := time.Parse(time.RFC3339, event.SeriesStart); err == nil {
+			start = seriesStart
+		}
+		if loc == time.UTC {
+			writeICSLine(buf, "DTSTART:"+start.UTC().Format(icsUTCLayout))
+		} else {
+			writeICSLine(buf, "DTSTART;TZID="+loc.String()+":"+start.In(loc).Format(icsLocalLayout))
+		}
+		writeICSLine(buf, "RRULE:"+rule)
+	} else {
+		writeICSLine(buf, "DTSTART:"+date.UTC().Format(icsUTCLayout))
+	}
+
+	writeICSLine(buf, "SUMMARY:"+icsEscape(event.Name))
+	writeICSLine(buf, "DESCRIPTION:"+icsEscape(event.Message))
+	if event.URL != "" {
+		writeICSLine(buf, "URL:"+event.URL)
+	}
+	if priority, ok := icsPriorities[event.Priority]; ok {
+		writeICSLine(buf, "PRIORITY:"+priority)
+	}
+	// Reminders mark a moment rather than block time
+	writeICSLine(buf, "TRANSP:TRANSPARENT")
+	writeICSLine(buf, "END:VEVENT")
+}
+
+// renderICS returns all events of a user as an iCalendar document.
+func renderICS(c *fiber.Ctx, s *store.Store, userID int) ([]byte, error) {
+	user, err := s.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:-//Reminder-App//Events//EN")
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	writeICSLine(&buf, "METHOD:PUBLISH")
+	writeICSLine(&buf, "X-WR-CALNAME:"+icsEscape("Reminders of "+user.Username))
+	writeICSLine(&buf, "X-WR-TIMEZONE:"+loc.String())
+
+	err = s.Events.Each(c.UserContext(), userID, func(event *store.Event) error {
+		writeICSEvent(&buf, event, loc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	writeICSLine(&buf, "END:VCALENDAR")
+	return buf.Bytes(), nil
+}
+
+// ExportICS returns the authenticated user's events as an iCalendar (.ics) file, recurring events
+// with their recurrence rule, for importing into calendar apps.
+func ExportICS(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	calendar, err := renderICS(c, s, userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	c.Attachment("reminders.ics")
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.Status(200).Send(calendar)
+}
+
+// CalendarFeed serves the events of the user owning the token in the URL as an iCalendar feed,
+// for calendar apps that subscribe to a URL and cannot send credentials. Unknown tokens get a 404.
+func CalendarFeed(c *fiber.Ctx, s *store.Store) error {
+	userID, err := s.CalendarFeeds.UserIDByHash(c.UserContext(), hashAPIKey(c.Params("token")))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	calendar, err := renderICS(c, s, userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.Status(200).Send(calendar)
+}
+
+// feedURLs returns the https and webcal URLs of the calendar feed with token, on the host of the request.
+func feedURLs(c *fiber.Ctx, token string) (string, string) {
+	url := c.BaseURL() + calendarFeedPath + token + ".ics"
+	return url, "webcal://" + strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+}
+
+// GetCalendarFeed reports whether the authenticated user has a calendar feed. Its URL is only
+// shown when the feed is created.
+func GetCalendarFeed(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	feed, err := s.CalendarFeeds.Get(c.UserContext(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"feed":    feed,
+		"message": "Calendar feed fetched successfully",
+	})
+}
+
+// CreateCalendarFeed gives the authenticated user a calendar feed URL with a new secret token.
+// A previous feed URL stops working. The URL is only returned in this response.
+func CreateCalendarFeed(c *fiber.Ctx, s *store.Store) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return ServerError(c, err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	var userID = getUserID(c)
+
+	feed, err := s.CalendarFeeds.Rotate(c.UserContext(), userID, hashAPIKey(token))
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	url, webcal := feedURLs(c, token)
+	return c.Status(201).JSON(fiber.Map{
+		"status":     "created",
+		"feed":       feed,
+		"feed_url":   url,
+		"webcal_url": webcal,
+		"message":    "Calendar feed created; store the URL now, it will not be shown again",
+	})
+}
+
+// DeleteCalendarFeed turns off the authenticated user's calendar feed; its URL stops working.
+func DeleteCalendarFeed(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	if err := s.CalendarFeeds.Delete(c.UserContext(), userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Calendar feed deleted successfully",
+	})
+}
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Most events a single calendar import may hold
+const maxImportEvents = 500
+
+// Largest calendar accepted for an import, uploaded or fetched
+const maxImportSize = 4 << 20
+
+// How long fetching a calendar from a URL may take
+const importTimeout = 10 * time.Second
+
+// errNotICS is returned for files that are not an iCalendar document.
+var errNotICS = errors.New("file is not an iCalendar (.ics) document")
+
+// errPrivateAddress is returned for calendar URLs resolving to an address that is not public.
+var errPrivateAddress = errors.New("calendar URL must point to a public address")
+
+// importClient fetches calendars from URLs. It only connects to public addresses, so an import
+// cannot be used to reach services inside the server's network.
+var importClient = &http.Client{
+	Timeout: importTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: importTimeout, Control: publicAddressOnly}).DialContext,
+	},
+}
+
+// publicAddressOnly refuses connections to loopback, private, link-local, and other non-public addresses.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// ImportRequest struct defines the body of an import from a URL; webcal:// URLs are fetched over https.
+type ImportRequest struct {
+	URL string `json:"url" form:"url" validate:"required,max=2048"`
+}
+
+// icsProperty is a property of an iCalendar component, with its parameters keyed by upper-case name.
+type icsProperty struct {
+	Params map[string]string
+	Value  string
+}
+
+// icsEvent holds the properties of a VEVENT by upper-case name; only the first of repeated properties is kept.
+type icsEvent map[string]icsProperty
+
+// splitICSLine splits a content line into its name, parameters, and value. Colons and semicolons
+// within quoted parameter values do not split.
+func splitICSLine(line string) (string, map[string]string, string, bool) {
+	quoted := false
+	parts := []string{}
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				params := map[string]string{}
+				for _, param := range parts[1:] {
+					if key, value, ok := strings.Cut(param, "="); ok {
+						params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+					}
+				}
+				return strings.ToUpper(parts[0]), params, line[i+1:], true
+			}
+		}
+	}
+	return "", nil, "", false
+}
+
+// parseICS returns the VEVENTs of an iCalendar document. Folded lines are unfolded; properties of
+// components nested in a VEVENT, such as its VALARMs, are left out.
+func parseICS(data []byte) ([]icsEvent, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	text = strings.NewReplacer("\n ", "", "\n\t", "").Replace(text)
+
+	events := []icsEvent{}
+	var stack []string
+	var current icsEvent
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		name, params, value, ok := splitICSLine(line)
+		if !ok {
+			return nil, errNotICS
+		}
+		if len(stack) == 0 && (name != "BEGIN" || !strings.EqualFold(value, "VCALENDAR")) {
+			return nil, errNotICS
+		}
+
+		switch name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(value))
+			if stack[len(stack)-1] == "VEVENT" {
+				current = icsEvent{}
+			}
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(value) {
+				return nil, errNotICS
+			}
+			if stack[len(stack)-1] == "VEVENT" {
+				events = append(events, current)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if stack[len(stack)-1] == "VEVENT" {
+				if _, seen := current[name]; !seen {
+					current[name] = icsProperty{Params: params, Value: value}
+				}
+			}
+		}
+	}
+	if len(stack) != 0 {
+		return nil, errNotICS
+	}
+	return events, nil
+}
+
+// icsUnescape decodes a TEXT value escaped as on export.
+func icsUnescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// icsDate converts a DTSTART to an event date. All-day dates fall on midnight and times without a
+// UTC offset are read in their TZID, both in loc when none is given or it is not an IANA name.
+func icsDate(prop icsProperty, loc *time.Location) (string, error) {
+	value := strings.TrimSpace(prop.Value)
+	if prop.Params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return "", fmt.Errorf("DTSTART %q is not a valid date", value)
+		}
+		return t.Format("2006-01-02"), nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsUTCLayout, value)
+		if err != nil {
+			return "", fmt.Errorf("DTSTART %q is not a valid date-time", value)
+		}
+		return t.Format(time.RFC3339), nil
+	}
+	if tzid, ok := prop.Params["TZID"]; ok {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+	t, err := time.ParseInLocation(icsLocalLayout, value, loc)
+	if err != nil {
+		return "", fmt.Errorf("DTSTART %q is not a valid date-time", value)
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+// icsPriority converts a PRIORITY to an event priority: 1 is urgent, 2 to 4 are high, 5 normal, and
+// 6 to 9 low.
+// 0 and invalid values leave the priority to its default.
+func icsPriority(value string) string {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	switch {
+	case err != nil || n < 1 || n > 9:
+		return ""
+	case n == 1:
+		return "urgent"
+	case n < 5:
+		return "high"
+	case n == 5:
+		return "normal"
+	default:
+		return "low"
+	}
+}
+
+// importName derives an event name from a SUMMARY, replacing runs of characters event names may not
+// hold with a dash. taken holds the lowercased names used so far; a number is added to names already
+// taken, and the returned name is added to taken.
+func importName(summary string, taken map[string]bool) string {
+	var b strings.Builder
+	for _, r := range summary {
+		if r == '-' || r == '_' || (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') {
+			b.WriteRune(r)
+		} else if b.Len() > 0 && !strings.HasSuffix(b.String(), "-") {
+			b.WriteByte('-')
+		}
+	}
+	base := strings.Trim(b.String(), "-")
+	if base == "" {
+		base = "event"
+	}
+	if len(base) > maxEventNameLength {
+		base = base[:maxEventNameLength]
+	}
+
+	name := base
+	for n := 2; taken[strings.ToLower(name)]; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		name = base[:min(len(base), maxEventNameLength-len(suffix))] + suffix
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
+
+// importEvent converts a VEVENT to an event, or returns why it cannot be imported.
+func importEvent(vevent icsEvent, loc *time.Location, taken map[string]bool) (*store.Event, error) {
+	start, ok := vevent["DTSTART"]
+	if !ok {
+		return nil, errors.New("event has no DTSTART")
+	}
+	date, err := icsDate(start, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := icsUnescape(vevent["SUMMARY"].Value)
+	event := &store.Event{
+		Name:     importName(summary, taken),
+		Date:     date,
+		Message:  icsUnescape(vevent["DESCRIPTION"].Value),
+		Priority: icsPriority(vevent["PRIORITY"].Value),
+	}
+	// Events need a message, which calendar entries often leave to their summary
+	if strings.TrimSpace(event.Message) == "" {
+		event.Message = summary
+	}
+	if strings.TrimSpace(event.Message) == "" {
+		event.Message = event.Name
+	}
+	if link := vevent["URL"].Value; strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		event.URL = link
+	}
+	if rule, ok := vevent["RRULE"]; ok {
+		event.Recurrence = &rule.Value
+	}
+	return event, nil
+}
+
+// readCalendar returns the calendar to import: an uploaded file, a text/calendar body, or the
+// document at the URL of an ImportRequest.
+func readCalendar(c *fiber.Ctx) ([]byte, error) {
+	ctype := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(ctype, fiber.MIMEMultipartForm):
+		if file, err := c.FormFile("file"); err == nil {
+			if file.Size > maxImportSize {
+				return nil, fmt.Errorf("calendar must be at most %d MB", maxImportSize>>20)
+			}
+			f, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return io.ReadAll(f)
+		}
+	case strings.HasPrefix(ctype, "text/calendar"):
+		return c.Body(), nil
+	}
+
+	req := new(ImportRequest)
+	if err := ParseBody(c, req); err != nil {
+		return nil, err
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return nil, errors.New("provide an .ics file as file, or the URL of a calendar as url")
+	}
+	return fetchCalendar(c, req.URL)
+}
+
+// fetchCalendar downloads the calendar at an http, https, or webcal URL.
+func fetchCalendar(c *fiber.Ctx, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err == nil && u.Scheme == "webcal" {
+		u.Scheme = "https"
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("url must be an http, https, or webcal URL")
+	}
+
+	request, err := http.NewRequestWithContext(c.UserContext(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(fiber.HeaderAccept, "text/calendar")
+	resp, err := importClient.Do(request)
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return nil, errPrivateAddress
+		}
+		return nil, errors.New("calendar could not be fetched from url")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar could not be fetched from url: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+	if err != nil {
+		return nil, errors.New("calendar could not be fetched from url")
+	}
+	if len(data) > maxImportSize {
+		return nil, fmt.Errorf("calendar must be at most %d MB", maxImportSize>>20)
+	}
+	return data, nil
+}
+
+// ImportEvents creates events of the authenticated user from the VEVENTs of an iCalendar file,
+// uploaded as the file field of a multipart form, sent as a text/calendar body, or fetched from the
+// url of an ImportRequest. Events are named after their SUMMARY and keep their recurrence rule;
+// recurring events starting in the past move on to their next occurrence. Cancelled events and
+// changed occurrences of recurring events are skipped. With dry_run=true nothing is stored, and the
+// results report what would be created.
+func ImportEvents(c *fiber.Ctx, s *store.Store) error {
+	dryRun, err := queryBool(c, "dry_run")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	data, err := readCalendar(c)
+	if err != nil {
+		return invalidBody(c, err)
+	}
+	vevents, err := parseICS(data)
+	if err != nil {
+		return invalidBody(c, err)
+	}
+	if len(vevents) == 0 || len(vevents) > maxImportEvents {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("calendar must hold between 1 and %d events", maxImportEvents),
+		})
+	}
+
+	var userID = getUserID(c)
+	loc := userLocation(c.UserContext(), s.Users, userID)
+	now := time.Now()
+
+	// Names of existing events are only needed to report duplicates without storing anything
+	existing := map[string]bool{}
+	if dryRun {
+		err := s.Events.Each(c.UserContext(), userID, func(event *store.Event) error {
+			existing[strings.ToLower(event.Name)] = true
+			return nil
+		})
+		if err != nil {
+			return ServerError(c, err)
+		}
+	}
+
+	results := make([]BulkResult, len(vevents))
+	events := []*store.Event{}
+	indexes := []int{}
+	taken := map[string]bool{}
+	skipped := 0
+	for i, vevent := range vevents {
+		results[i] = BulkResult{Index: i, UID: vevent["UID"].Value, Status: "error"}
+
+		if strings.EqualFold(vevent["STATUS"].Value, "CANCELLED") {
+			results[i].Status, results[i].Message = "skipped", "Cancelled event"
+			skipped++
+			continue
+		}
+		if _, ok := vevent["RECURRENCE-ID"]; ok {
+			results[i].Status, results[i].Message = "skipped", "Changed occurrence of a recurring event"
+			skipped++
+			continue
+		}
+
+		event, err := importEvent(vevent, loc, taken)
+		if err != nil {
+			results[i].Message = err.Error()
+			continue
+		}
+		if problems := ValidateStruct(event); problems != nil {
+			results[i].Errors = problems
+			results[i].Message = "Invalid event"
+			continue
+		}
+
+		prepareEvent(event, loc)
+		if event.Recurrence != nil && *event.Recurrence != "" {
+			// The series keeps its start, while the event waits for its next occurrence to fire
+			event.SeriesStart = event.Date
+			if next := store.Occurrences(event, loc, now, 1); len(next) > 0 {
+				event.Date = next[0].Format(time.RFC3339)
+			}
+		}
+
+		if dryRun {
+			if existing[strings.ToLower(event.Name)] {
+				results[i].Message = "An event with this name already exists"
+				continue
+			}
+			results[i].Status, results[i].Event, results[i].Message = "valid", event, "Event would be created"
+			events = append(events, event)
+			continue
+		}
+		events = append(events, event)
+		indexes = append(indexes, i)
+	}
+
+	if dryRun {
+		return c.Status(200).JSON(fiber.Map{
+			"status":  "validated",
+			"dry_run": true,
+			"valid":   len(events),
+			"skipped": skipped,
+			"failed":  len(vevents) - len(events) - skipped,
+			"results": results,
+			"message": fmt.Sprintf("%d of %d events would be created", len(events), len(vevents)),
+		})
+	}
+
+	created, err := createEvents(c, s, userID, events, indexes, results)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "processed",
+		"dry_run": false,
+		"created": created,
+		"skipped": skipped,
+		"failed":  len(vevents) - created - skipped,
+		"results": results,
+		"message": fmt.Sprintf("%d of %d events created", created, len(vevents)),
+	})
+}
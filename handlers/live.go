@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Locals key holding the user id of a WebSocket connection while it is upgraded
+const liveUserLocal = "live_user_id"
+
+// How long a push may block on a slow client before the connection is dropped
+const liveWriteTimeout = 10 * time.Second
+
+// LiveMessage struct defines the JSON message pushed to WebSocket clients when a reminder fires.
+type LiveMessage struct {
+	Type  string      `json:"type"`
+	Event store.Event `json:"event"`
+}
+
+// Hub tracks the open WebSocket connections of each user so fired reminders can be pushed to them.
+type Hub struct {
+	mu    sync.Mutex
+	conns map[int]map[*websocket.Conn]struct{}
+}
+
+// NewHub returns an empty Hub.
+func NewHub() *Hub {
+	return &Hub{conns: map[int]map[*websocket.Conn]struct{}{}}
+}
+
+// register adds a connection of a user.
+func (h *Hub) register(userID int, conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[userID] == nil {
+		h.conns[userID] = map[*websocket.Conn]struct{}{}
+	}
+	h.conns[userID][conn] = struct{}{}
+}
+
+// unregister removes a connection of a user.
+func (h *Hub) unregister(userID int, conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns[userID], conn)
+	if len(h.conns[userID]) == 0 {
+		delete(h.conns, userID)
+	}
+}
+
+// Push sends a fired event to every open connection of its user. Connections that cannot be
+// written to are closed; their read loop then unregisters them.
+func (h *Hub) Push(userID int, event store.Event) {
+	// Writes happen under the lock, which also keeps them from overlapping on a connection
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	message := LiveMessage{Type: "reminder", Event: event}
+	for conn := range h.conns[userID] {
+		conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		if err := conn.WriteJSON(message); err != nil {
+			slog.Warn("Dropping live connection", slog.Int("user_id", userID), slog.Any("error", err))
+			conn.Close()
+		}
+	}
+}
+
+// LiveEvents upgrades the request to a WebSocket connection on which the authenticated user
+// receives their reminders as they fire. Requests that are not WebSocket upgrades get a 426.
+func LiveEvents(hub *Hub, s *store.Store) fiber.Handler {
+	upgrade := websocket.New(func(conn *websocket.Conn) {
+		userID, _ := conn.Locals(liveUserLocal).(int)
+
+		hub.register(userID, conn)
+		defer hub.unregister(userID, conn)
+
+		// Messages from the client are ignored; reading only detects the disconnect
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		c.Locals(liveUserLocal, getUserID(c))
+		return upgrade(c)
+	}
+}
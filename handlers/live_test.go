@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// liveConnections returns how many connections of a user hub holds.
+func liveConnections(hub *Hub, userID int) int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.conns[userID])
+}
+
+// waitForConnections waits until hub holds want connections of a user.
+func waitForConnections(t *testing.T, hub *Hub, userID, want int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); liveConnections(hub, userID) != want; {
+		if time.Now().After(deadline) {
+			t.Fatalf("user %d has %d live connections, want %d", userID, liveConnections(hub, userID), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLiveEvents(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	hub := NewHub()
+	app := fiber.New(fiber.Config{Immutable: true, DisableStartupMessage: true})
+	app.Use(testAuth)
+	app.Get("/ws", LiveEvents(hub, s))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	header := http.Header{testUserHeader: {strconv.Itoa(alice)}}
+	conn, _, err := fastws.DefaultDialer.Dial("ws://"+listener.Addr().String()+"/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitForConnections(t, hub, alice, 1)
+
+	// Only the reminders of the connected user reach the connection
+	hub.Push(bob, store.Event{ID: 1, Name: "other"})
+	hub.Push(alice, store.Event{ID: 2, Name: "standup"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message LiveMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Type != "reminder" || message.Event.Name != "standup" {
+		t.Fatalf("got %+v, want the reminder of standup", message)
+	}
+
+	// Closed connections are unregistered
+	conn.Close()
+	waitForConnections(t, hub, alice, 0)
+
+	expectStatus(t, request(t, app, alice, "GET", "/ws", ""), 426)
+}
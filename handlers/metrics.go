@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"github.com/Vansh3140/Reminder-App/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordMetrics returns middleware counting requests and observing their latency in the metrics
+// package, by method, route pattern and status. It must come before RequestLogger, which renders
+// errors, so that the recorded status is the one sent.
+func RecordMetrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		route := c.Route().Path
+		metrics.HTTPRequests.WithLabelValues(c.Method(), route, strconv.Itoa(c.Response().StatusCode())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Method(), route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Metrics returns the handler of /metrics, serving the metrics in the Prometheus text format.
+// When token is set, scrapers must send it as a bearer token; other requests get a 401.
+func Metrics(token string) fiber.Handler {
+	serve := adaptor.HTTPHandler(metrics.Handler())
+	return func(c *fiber.Ctx) error {
+		if token != "" {
+			sent, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Missing or invalid metrics token",
+				})
+			}
+		}
+		return serve(c)
+	}
+}
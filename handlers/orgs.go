@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+)
+
+// OrgRequest struct defines the body of an organization creation request.
+type OrgRequest struct {
+	Name string `json:"name" form:"name" validate:"required,max=255"`
+}
+
+// JoinOrgRequest struct defines the body of a request to join an organization.
+type JoinOrgRequest struct {
+	InviteCode string `json:"invite_code" form:"invite_code" validate:"required,max=64"`
+}
+
+// newInviteCode generates a random code with which users join an organization.
+func newInviteCode() (string, error) {
+	code := make([]byte, 18)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+// inOrganization answers a request of a user who already belongs to an organization.
+func inOrganization(c *fiber.Ctx) error {
+	return c.Status(409).JSON(fiber.Map{
+		"status":  "error",
+		"message": "You already belong to an organization",
+	})
+}
+
+// CreateOrg creates an organization with the authenticated user as its first member. The user's
+// events move into the organization, where every member can see and change them.
+func CreateOrg(c *fiber.Ctx, s *store.Store) error {
+	req := new(OrgRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid organization", problems)
+	}
+
+	var userID = getUserID(c)
+
+	code, err := newInviteCode()
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	org, err := s.Orgs.Create(c.UserContext(), userID, req.Name, code)
+	if err != nil {
+		if errors.Is(err, store.ErrInOrganization) {
+			return inOrganization(c)
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(userID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":       "created",
+		"organization": org,
+		"message":      "Organization created, share the invite code to add members",
+	})
+}
+
+// GetOrg returns the organization of the authenticated user with its members.
+func GetOrg(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	org, err := s.Orgs.ForUser(c.UserContext(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "You do not belong to an organization",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":       "fetched",
+		"organization": org,
+		"message":      "Organization fetched successfully",
+	})
+}
+
+// JoinOrg adds the authenticated user to the organization with the invite code. The user's events
+// move into the organization, so none of their names may already be used there.
+func JoinOrg(c *fiber.Ctx, s *store.Store) error {
+	req := new(JoinOrgRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid invite code", problems)
+	}
+
+	var userID = getUserID(c)
+
+	org, err := s.Orgs.Join(c.UserContext(), userID, req.InviteCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unknown invite code",
+			})
+		case errors.Is(err, store.ErrInOrganization):
+			return inOrganization(c)
+		case errors.Is(err, store.ErrDuplicate):
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An event with one of your event names already exists in the organization",
+			})
+		}
+		return ServerError(c, err)
+	}
+	// The user's events moved from their own lists into the organization's
+	eventLists.invalidate(userID)
+	eventLists.invalidate(-org.ID)
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":       "joined",
+		"organization": org,
+		"message":      "Joined organization successfully",
+	})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// createOrg creates an organization named name as a user and returns its invite code.
+func createOrg(t *testing.T, app *fiber.App, userID int, name string) string {
+	t.Helper()
+	resp := request(t, app, userID, "POST", "/org", `{"name":"`+name+`"}`)
+	expectStatus(t, resp, 201)
+	var body struct {
+		Organization store.Organization `json:"organization"`
+	}
+	decode(t, resp, &body)
+	return body.Organization.InviteCode
+}
+
+func TestOrganizationsIsolateEvents(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+	dave := createUser(t, s, "dave")
+	standup := createEvent(t, s, alice, "standup")
+	createEvent(t, s, carol, "dentist")
+	createEvent(t, s, dave, "retro")
+	app := newTestApp()
+	app.Post("/org", func(c *fiber.Ctx) error { return CreateOrg(c, s) })
+	app.Post("/org/join", func(c *fiber.Ctx) error { return JoinOrg(c, s) })
+	app.Get("/events", func(c *fiber.Ctx) error { return ListEvents(c, s) })
+	app.Get("/event/:name", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+	app.Put("/event/:name", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+	app.Delete("/event/:name", func(c *fiber.Ctx) error { return DeleteEvent(c, s) })
+	app.Get("/events/:id", func(c *fiber.Ctx) error { return GetEvent(c, s) })
+	app.Put("/events/:id", func(c *fiber.Ctx) error { return UpdateEvent(c, s) })
+	app.Delete("/events/:id", func(c *fiber.Ctx) error { return DeleteEvent(c, s) })
+
+	// Alice and bob share one organization, dave has another and carol none
+	code := createOrg(t, app, alice, "Acme")
+	expectStatus(t, request(t, app, bob, "POST", "/org/join", `{"invite_code":"`+code+`"}`), 200)
+	createOrg(t, app, dave, "Globex")
+
+	// Both members see and change the events of their organization
+	if names := listNames(t, app, bob, ""); !reflect.DeepEqual(names, []string{"standup"}) {
+		t.Fatalf("bob: got %v, want the standup of alice", names)
+	}
+	expectStatus(t, request(t, app, bob, "GET", "/event/standup", ""), 200)
+	expectStatus(t, request(t, app, bob, "PUT", "/event/standup", `{"message":"Standup moved"}`), 200)
+	if event := getEvent(t, s, alice, "standup"); event.Message != "Standup moved" {
+		t.Fatalf("alice: got message %q, want the one bob set", event.Message)
+	}
+
+	// Users outside the organization find none of them, by name or by id
+	byID := "/events/" + strconv.Itoa(standup.ID)
+	for user, want := range map[int][]string{carol: {"dentist"}, dave: {"retro"}} {
+		if names := listNames(t, app, user, ""); !reflect.DeepEqual(names, want) {
+			t.Fatalf("user %d: got %v, want %v", user, names, want)
+		}
+		for _, path := range []string{"/event/standup", byID} {
+			expectStatus(t, request(t, app, user, "GET", path, ""), 404)
+			expectStatus(t, request(t, app, user, "PUT", path, `{"message":"Taken over"}`), 404)
+			expectStatus(t, request(t, app, user, "DELETE", path, ""), 404)
+		}
+	}
+	if event := getEvent(t, s, alice, "standup"); event.Message != "Standup moved" {
+		t.Fatalf("after outsiders: got message %q, want it unchanged", event.Message)
+	}
+
+	// Nor do the members see the events of the other organization or of carol
+	for _, path := range []string{"/event/retro", "/event/dentist"} {
+		expectStatus(t, request(t, app, alice, "GET", path, ""), 404)
+		expectStatus(t, request(t, app, alice, "DELETE", path, ""), 404)
+	}
+	getEvent(t, s, dave, "retro")
+	getEvent(t, s, carol, "dentist")
+}
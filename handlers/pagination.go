@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default and maximum number of items returned per page by list endpoints
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// queryInt parses an optional non-negative integer query parameter, returning def when it is absent.
+func queryInt(c *fiber.Ctx, key string, def int) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+// queryBool parses an optional boolean query parameter such as include_completed=true,
+// returning false when it is absent.
+func queryBool(c *fiber.Ctx, key string) (bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", key)
+	}
+	return b, nil
+}
+
+// queryTime parses an optional RFC3339 timestamp query parameter, returning the zero time when it is absent.
+func queryTime(c *fiber.Ctx, key string) (time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC3339 timestamp", key)
+	}
+	return t, nil
+}
+
+// queryPriorities parses an optional comma-separated list of event priorities, such as high,urgent,
+// returning nil when it is absent.
+func queryPriorities(c *fiber.Ctx, key string) ([]string, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var list []string
+	for _, priority := range strings.Split(raw, ",") {
+		priority = strings.TrimSpace(priority)
+		if !isPriority(priority) {
+			return nil, fmt.Errorf("%s must be a comma-separated list of %s", key, strings.Join(priorities, ", "))
+		}
+		list = append(list, priority)
+	}
+	return list, nil
+}
+
+// parsePage reads the limit, offset, and after_id query parameters of a list request.
+// Supplying after_id selects keyset pagination.
+func parsePage(c *fiber.Ctx) (store.Page, error) {
+	var p store.Page
+	var err error
+
+	if p.Limit, err = queryInt(c, "limit", defaultPageSize); err != nil {
+		return p, err
+	}
+	if p.Limit < 1 || p.Limit > maxPageSize {
+		return p, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+
+	if c.Query("after_id") != "" {
+		if c.Query("offset") != "" {
+			return p, fmt.Errorf("offset and after_id cannot be combined")
+		}
+		p.Keyset = true
+		p.AfterID, err = queryInt(c, "after_id", 0)
+		return p, err
+	}
+
+	p.Offset, err = queryInt(c, "offset", 0)
+	return p, err
+}
+
+// pageLink returns the URL of the current request with the given query parameters replaced.
+// Every other query parameter, such as a filter, is kept, and those in pinned are added.
+func pageLink(c *fiber.Ctx, set map[string]int, pinned map[string]string) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	for key, value := range pinned {
+		query.Set(key, value)
+	}
+	for key, value := range set {
+		query.Set(key, strconv.Itoa(value))
+	}
+	return c.BaseURL() + c.Path() + "?" + query.Encode()
+}
+
+// setPageHeaders adds an X-Total-Count header and an RFC 5988 Link header to a list response.
+// Offset pages link to the first, previous, next, and last page where they exist; keyset pages
+// can only link to the first and the next page. Query parameters in pinned, such as a snapshot
+// time chosen by the server, are added to every link.
+func setPageHeaders(c *fiber.Ctx, p store.Page, total int, hasMore bool, nextCursor int, pinned map[string]string) {
+	c.Set("X-Total-Count", strconv.Itoa(total))
+
+	var links []string
+	link := func(rel string, set map[string]int) {
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, pageLink(c, set, pinned), rel))
+	}
+
+	if p.Keyset {
+		link("first", map[string]int{"limit": p.Limit, "after_id": 0})
+		if hasMore {
+			link("next", map[string]int{"limit": p.Limit, "after_id": nextCursor})
+		}
+	} else {
+		last := 0
+		if total > 0 {
+			last = (total - 1) / p.Limit * p.Limit
+		}
+
+		link("first", map[string]int{"limit": p.Limit, "offset": 0})
+		if p.Offset > 0 {
+			prev := p.Offset - p.Limit
+			if prev < 0 {
+				prev = 0
+			}
+			link("prev", map[string]int{"limit": p.Limit, "offset": prev})
+		}
+		if p.Offset+p.Limit < total {
+			link("next", map[string]int{"limit": p.Limit, "offset": p.Offset + p.Limit})
+		}
+		link("last", map[string]int{"limit": p.Limit, "offset": last})
+	}
+
+	c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+}
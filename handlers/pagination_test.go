@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventPage is the body of one page of an event listing.
+type eventPage struct {
+	Events     []store.Event `json:"events"`
+	Total      int           `json:"total"`
+	HasMore    bool          `json:"has_more"`
+	NextCursor *int          `json:"next_cursor"`
+}
+
+// listPage fetches one page of the events of a user.
+func listPage(t *testing.T, app *fiber.App, userID int, query string) eventPage {
+	t.Helper()
+	resp := request(t, app, userID, "GET", "/events"+query, "")
+	expectStatus(t, resp, 200)
+	var page eventPage
+	decode(t, resp, &page)
+	return page
+}
+
+// newListingApp returns an app listing the events of users in s, with seven events of a user.
+func newListingApp(t *testing.T) (*fiber.App, int) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	for i := 1; i <= 7; i++ {
+		createEvent(t, s, alice, fmt.Sprintf("event-%d", i))
+	}
+	createEvent(t, s, bob, "other")
+	app := newTestApp()
+	app.Get("/events", func(c *fiber.Ctx) error { return ListEvents(c, s) })
+	return app, alice
+}
+
+func TestKeysetPagination(t *testing.T) {
+	app, alice := newListingApp(t)
+
+	seen := map[int]bool{}
+	var pages []int
+	query := "?limit=3&after_id=0"
+	for {
+		page := listPage(t, app, alice, query)
+		pages = append(pages, len(page.Events))
+		last := 0
+		for _, event := range page.Events {
+			if seen[event.ID] {
+				t.Fatalf("event %d listed twice", event.ID)
+			}
+			if event.ID <= last {
+				t.Fatalf("event %d listed after event %d, want ascending ids", event.ID, last)
+			}
+			seen[event.ID], last = true, event.ID
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		if *page.NextCursor != last {
+			t.Fatalf("got next_cursor %d, want the last id %d", *page.NextCursor, last)
+		}
+		query = fmt.Sprintf("?limit=3&after_id=%d", *page.NextCursor)
+	}
+	if len(seen) != 7 || fmt.Sprint(pages) != "[3 3 1]" {
+		t.Fatalf("walked pages of %v events, %d distinct, want [3 3 1] and 7", pages, len(seen))
+	}
+}
+
+func TestOffsetPagination(t *testing.T) {
+	app, alice := newListingApp(t)
+
+	seen := map[int]bool{}
+	for offset := 0; ; offset += 3 {
+		page := listPage(t, app, alice, fmt.Sprintf("?limit=3&offset=%d", offset))
+		if page.Total != 7 || page.NextCursor != nil {
+			t.Fatalf("offset %d: got total %d and next_cursor %v, want 7 and none", offset, page.Total, page.NextCursor)
+		}
+		for _, event := range page.Events {
+			if seen[event.ID] {
+				t.Fatalf("event %d listed twice", event.ID)
+			}
+			seen[event.ID] = true
+		}
+		if !page.HasMore {
+			break
+		}
+	}
+	if len(seen) != 7 {
+		t.Fatalf("listed %d distinct events, want 7", len(seen))
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?offset=-1", "?after_id=x", "?offset=3&after_id=3", "?after_id=0&sort=date"} {
+		expectStatus(t, request(t, app, alice, "GET", "/events"+query, ""), 400)
+	}
+}
+
+// linkPattern matches one link of a Link header
+var linkPattern = regexp.MustCompile(`<([^>]*)>; rel="([a-z]+)"`)
+
+// pageLinks returns the query of each link in the Link header of resp, by relation.
+func pageLinks(t *testing.T, resp *http.Response) map[string]url.Values {
+	t.Helper()
+	links := map[string]url.Values{}
+	for _, match := range linkPattern.FindAllStringSubmatch(resp.Header.Get(fiber.HeaderLink), -1) {
+		link, err := url.Parse(match[1])
+		if err != nil {
+			t.Fatalf("link %s: %v", match[1], err)
+		}
+		links[match[2]] = link.Query()
+	}
+	return links
+}
+
+func TestPaginationHeaders(t *testing.T) {
+	app, alice := newListingApp(t)
+
+	tests := []struct {
+		offset int
+		// Offsets of the first, prev, next, and last links, -1 where there is none
+		first, prev, next, last int
+	}{
+		{offset: 0, first: 0, prev: -1, next: 3, last: 6},
+		{offset: 3, first: 0, prev: 0, next: 6, last: 6},
+		{offset: 6, first: 0, prev: 3, next: -1, last: 6},
+	}
+	for _, test := range tests {
+		resp := request(t, app, alice, "GET", fmt.Sprintf("/events?limit=3&offset=%d&priority=normal", test.offset), "")
+		expectStatus(t, resp, 200)
+		if total := resp.Header.Get("X-Total-Count"); total != "7" {
+			t.Fatalf("offset %d: got X-Total-Count %q, want 7", test.offset, total)
+		}
+
+		links := pageLinks(t, resp)
+		for rel, want := range map[string]int{"first": test.first, "prev": test.prev, "next": test.next, "last": test.last} {
+			link, ok := links[rel]
+			if want < 0 {
+				if ok {
+					t.Fatalf("offset %d: got a %s link %v, want none", test.offset, rel, link)
+				}
+				continue
+			}
+			// Links keep the filters and the start of the listing
+			if !ok || link.Get("offset") != strconv.Itoa(want) || link.Get("limit") != "3" || link.Get("priority") != "normal" || link.Get("as_of") == "" {
+				t.Fatalf("offset %d: got %s link %v, want offset %d", test.offset, rel, link, want)
+			}
+		}
+	}
+}
+
+func TestListingPinnedAsOf(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	for i := 1; i <= 4; i++ {
+		createEvent(t, s, alice, fmt.Sprintf("event-%d", i))
+	}
+	app := newTestApp()
+	app.Get("/events", func(c *fiber.Ctx) error { return ListEvents(c, s) })
+
+	// The first page tells when the listing started, and its next link carries that time
+	resp := request(t, app, alice, "GET", "/events?limit=2", "")
+	expectStatus(t, resp, 200)
+	next := pageLinks(t, resp)["next"]
+	var first struct {
+		eventPage
+		AsOf string `json:"as_of"`
+	}
+	decode(t, resp, &first)
+	if first.AsOf == "" || next.Get("as_of") != first.AsOf {
+		t.Fatalf("got as_of %q and next link %v, want the link to carry as_of", first.AsOf, next)
+	}
+	for _, event := range first.Events {
+		if event.CreatedAt.IsZero() || event.UpdatedAt.IsZero() {
+			t.Fatalf("event %s: got created_at %v, updated_at %v, want both set", event.Name, event.CreatedAt, event.UpdatedAt)
+		}
+	}
+
+	// An event created since is not on the later pages of the listing, nor counted
+	time.Sleep(time.Millisecond)
+	createEvent(t, s, alice, "event-0")
+	second := listPage(t, app, alice, "?"+next.Encode())
+	if second.Total != 4 || len(second.Events) != 2 || second.HasMore {
+		t.Fatalf("second page: got %+v, want the 2 remaining of 4 events", second)
+	}
+	for _, event := range second.Events {
+		if event.Name == "event-0" {
+			t.Fatal("second page lists the event created after the listing started")
+		}
+	}
+	if page := listPage(t, app, alice, "?limit=10"); page.Total != 5 {
+		t.Fatalf("new listing: got %d events, want 5", page.Total)
+	}
+}
+
+func TestListDateRange(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	for day := 1; day <= 5; day++ {
+		event := &store.Event{Name: fmt.Sprintf("day-%d", day), Message: "Message", Priority: defaultPriority,
+			Date: time.Date(2030, 1, day, 9, 0, 0, 0, time.UTC).Format(time.RFC3339)}
+		if day%2 == 0 {
+			event.Tags = []string{"work"}
+		}
+		prepareEvent(event, time.UTC)
+		if err := s.Events.Create(context.Background(), alice, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := newTestApp()
+	app.Get("/events", func(c *fiber.Ctx) error { return ListEvents(c, s) })
+
+	// names returns the names of the events on page
+	names := func(page eventPage) string {
+		var names []string
+		for _, event := range page.Events {
+			names = append(names, event.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	for _, test := range []struct {
+		query string
+		want  string
+		total int
+	}{
+		// Both bounds are inclusive, whatever the offset they are given in
+		{"?sort=date&from=2030-01-02T09:00:00Z&to=2030-01-04T09:00:00Z", "day-2,day-3,day-4", 3},
+		{"?sort=date&from=2030-01-02T10:00:00%2B01:00&to=2030-01-04T10:00:00%2B01:00", "day-2,day-3,day-4", 3},
+		{"?sort=date&from=2030-01-03T09:00:00Z&to=2030-01-03T09:00:00Z", "day-3", 1},
+		{"?sort=date&from=2030-01-04T00:00:00Z", "day-4,day-5", 2},
+		{"?sort=date&to=2030-01-01T23:59:59Z", "day-1", 1},
+		// The range combines with the other filters and with paging
+		{"?sort=date&from=2030-01-02T09:00:00Z&to=2030-01-04T09:00:00Z&tag=work", "day-2,day-4", 2},
+		{"?sort=date&from=2030-01-02T09:00:00Z&to=2030-01-04T09:00:00Z&limit=2", "day-2,day-3", 3},
+		{"?sort=date&from=2030-01-02T09:00:00Z&to=2030-01-04T09:00:00Z&limit=2&offset=2", "day-4", 3},
+		// A range without events is an empty list
+		{"?from=2031-01-01T00:00:00Z&to=2031-12-31T00:00:00Z", "", 0},
+		{"?from=2030-01-02T09:00:01Z&to=2030-01-02T23:00:00Z", "", 0},
+	} {
+		page := listPage(t, app, alice, test.query+"&no_cache=1")
+		if got := names(page); got != test.want || page.Total != test.total {
+			t.Errorf("%s: got %q of %d, want %q of %d", test.query, got, page.Total, test.want, test.total)
+		}
+	}
+
+	for _, query := range []string{
+		"?from=2030-01-04T09:00:00Z&to=2030-01-02T09:00:00Z",
+		"?from=2030-01-02",
+		"?to=tomorrow",
+	} {
+		expectStatus(t, request(t, app, alice, "GET", "/events"+query, ""), 400)
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/notify"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// How long a code verifying a phone number stays valid
+const phoneCodeTTL = 10 * time.Minute
+
+// Wrong codes allowed before a phone verification has to be started over
+const maxPhoneCodeAttempts = 5
+
+// Digits of a code verifying a phone number
+const phoneCodeDigits = 6
+
+// Phone numbers in E.164 form: a plus, a country code, and at most 15 digits in all
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// PhoneRequest struct defines the body of a request verifying a phone number.
+type PhoneRequest struct {
+	Number string `json:"number" form:"number" validate:"required,phone"`
+}
+
+// PhoneCodeRequest struct defines the body of a request confirming a phone number with the code
+// texted to it.
+type PhoneCodeRequest struct {
+	Code string `json:"code" form:"code" validate:"required,len=6,numeric"`
+}
+
+// validatePhone checks that number is a phone number in E.164 form, such as +14155550123.
+func validatePhone(number string) error {
+	if !phoneNumberPattern.MatchString(number) {
+		return fmt.Errorf("number must be a phone number in E.164 form, such as +14155550123")
+	}
+	return nil
+}
+
+// newPhoneCode generates a random numeric code verifying a phone number.
+func newPhoneCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < phoneCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", phoneCodeDigits, n), nil
+}
+
+// hashPhoneCode returns the hash of a code texted to a user. Few codes exist, so the user is
+// hashed along, and the same code of two users has different hashes.
+func hashPhoneCode(userID int, code string) string {
+	return hashAPIKey(strconv.Itoa(userID) + ":" + code)
+}
+
+// VerifyPhone starts the verification of the phone number SMS reminders of the authenticated user
+// are sent to, by texting it a code to confirm with ConfirmPhone. A verification started before
+// stops working; the number verified before stays in use until the new one is confirmed.
+func VerifyPhone(c *fiber.Ctx, s *store.Store, sms notify.SMSSender) error {
+	if sms == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "SMS is not available",
+		})
+	}
+
+	req := new(PhoneRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid phone number", problems)
+	}
+
+	code, err := newPhoneCode()
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	var userID = getUserID(c)
+
+	expiresAt := time.Now().Add(phoneCodeTTL).UTC().Truncate(time.Second)
+	if err := s.Phones.StartVerification(c.UserContext(), userID, req.Number, hashPhoneCode(userID, code), expiresAt); err != nil {
+		return ServerError(c, err)
+	}
+
+	text := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(phoneCodeTTL.Minutes()))
+	if err := sms.Send(c.UserContext(), req.Number, text); err != nil {
+		if errors.Is(err, notify.ErrUndeliverable) {
+			return ValidationFailed(c, "Invalid phone number", []FieldError{{
+				Field:   "number",
+				Message: "number cannot receive text messages",
+			}})
+		}
+		Logger(c).Error("Failed to text verification code", slog.Int("user_id", userID), slog.Any("error", err))
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The verification code could not be sent, try again later",
+		})
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"status":     "pending",
+		"number":     req.Number,
+		"expires_at": expiresAt,
+		"message":    "Verification code sent, confirm it to receive SMS reminders at this number",
+	})
+}
+
+// ConfirmPhone confirms the phone number being verified with the code texted to it, after which
+// SMS reminders of the authenticated user are sent to it.
+func ConfirmPhone(c *fiber.Ctx, s *store.Store) error {
+	req := new(PhoneCodeRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid verification code", problems)
+	}
+
+	var userID = getUserID(c)
+
+	phone, err := s.Phones.Confirm(c.UserContext(), userID, hashPhoneCode(userID, req.Code), time.Now(), maxPhoneCodeAttempts)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No phone verification is pending, or it expired",
+			})
+		case errors.Is(err, store.ErrCodeMismatch):
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"code":    "verification_code_invalid",
+				"message": "Incorrect verification code",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "verified",
+		"phone":   phone,
+		"message": "Phone number verified successfully",
+	})
+}
+
+// GetPhone returns the phone number the authenticated user verified.
+func GetPhone(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	phone, err := s.Phones.Phone(c.UserContext(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No phone number is verified",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"phone":   phone,
+		"message": "Phone number fetched successfully",
+	})
+}
+
+// DeletePhone removes the phone number of the authenticated user, along with any verification
+// in progress. SMS reminders are no longer sent.
+func DeletePhone(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	if err := s.Phones.Delete(c.UserContext(), userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No phone number is verified",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Phone number deleted successfully",
+	})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hour of the day an event described in plain English falls on when only its day is given
+const defaultPhraseHour = 9
+
+// Errors of parseReminderPhrase, worded as replies to the user
+var (
+	errPhraseNoTask = errors.New("tell me what to remind you of, e.g. \"remind me to pay rent on the 1st\"")
+	errPhraseNoWhen = errors.New("tell me when to remind you, e.g. \"tomorrow at 9am\", \"on friday\", \"on the 1st\", or \"in 2 hours\"")
+	errPhrasePast   = errors.New("that time has already passed")
+	errPhraseNoDate = errors.New("there is no such date")
+)
+
+// Parts of a reminder phrase, each matched at its end and cut off before the next one is tried
+var (
+	phrasePrefix     = regexp.MustCompile(`(?i)^(?:please\s+)?remind\s+me\s+(?:to\s+|about\s+|of\s+)?`)
+	phraseTime       = regexp.MustCompile(`(?i)\s+at\s+(?:(noon)|(midnight)|(\d{1,2})(?::(\d{2}))?\s*(am|pm)?)$`)
+	phraseRelative   = regexp.MustCompile(`(?i)\s+in\s+(an?|\d{1,4})\s+(minute|min|hour|day|week)s?$`)
+	phraseDay        = regexp.MustCompile(`(?i)\s+(today|tomorrow)$`)
+	phraseWeekday    = regexp.MustCompile(`(?i)\s+(?:on\s+|next\s+)?(monday|tuesday|wednesday|thursday|friday|saturday|sunday)$`)
+	phraseDayOfMonth = regexp.MustCompile(`(?i)\s+on\s+the\s+(\d{1,2})(?:st|nd|rd|th)?$`)
+	phraseISODate    = regexp.MustCompile(`(?i)\s+on\s+(\d{4}-\d{2}-\d{2})$`)
+	phraseDayMonth   = regexp.MustCompile(`(?i)\s+on\s+(?:the\s+)?(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?([a-z]+)$`)
+	phraseMonthDay   = regexp.MustCompile(`(?i)\s+on\s+([a-z]+)\s+(\d{1,2})(?:st|nd|rd|th)?$`)
+)
+
+// phraseWhen collects what a reminder phrase says about its time. Either at is set, or the event
+// falls on day, or on the first day after now matching matches, at hour and minute.
+type phraseWhen struct {
+	at      time.Time
+	day     time.Time
+	matches func(day time.Time) bool
+	hour    int
+	minute  int
+}
+
+// parseReminderPhrase reads an event described in plain English, such as "remind me to pay rent on
+// the 1st" or "call mom tomorrow at 6pm", and returns what to be reminded of and when. now is in
+// the user's timezone, which dates and times are read in. A day without a time falls on
+// defaultPhraseHour; a time without a day on its next occurrence.
+func parseReminderPhrase(text string, now time.Time) (string, time.Time, error) {
+	task := strings.TrimRight(strings.TrimSpace(text), ".!")
+	task = phrasePrefix.ReplaceAllString(task, "")
+
+	when := phraseWhen{hour: -1}
+	for cut := true; cut; {
+		var rest string
+		rest, cut = when.cut(task, now)
+		task = rest
+	}
+	task = strings.TrimSpace(task)
+
+	if task == "" {
+		return "", time.Time{}, errPhraseNoTask
+	}
+	if when.at.IsZero() && when.day.IsZero() && when.matches == nil && when.hour < 0 {
+		return "", time.Time{}, errPhraseNoWhen
+	}
+	date, err := when.resolve(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return task, date, nil
+}
+
+// cut removes the last part of task that says when, if there is one it has not read yet, and
+// reports whether it did.
+func (w *phraseWhen) cut(task string, now time.Time) (string, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	dated := !w.at.IsZero() || !w.day.IsZero() || w.matches != nil
+
+	if m := phraseTime.FindStringSubmatchIndex(task); m != nil && w.hour < 0 && w.at.IsZero() {
+		if hour, minute, ok := phraseClock(submatches(task, m)); ok {
+			w.hour, w.minute = hour, minute
+			return task[:m[0]], true
+		}
+	}
+	if dated {
+		return task, false
+	}
+
+	if m := phraseRelative.FindStringSubmatch(task); m != nil {
+		n := 1
+		if !strings.HasPrefix(strings.ToLower(m[1]), "a") {
+			n, _ = strconv.Atoi(m[1])
+		}
+		switch strings.ToLower(m[2]) {
+		case "minute", "min", "hour":
+			// A number of minutes or hours leaves no room for a time of day
+			if w.hour >= 0 {
+				return task, false
+			}
+			unit := time.Minute
+			if strings.ToLower(m[2]) == "hour" {
+				unit = time.Hour
+			}
+			w.at = now.Add(time.Duration(n) * unit).Truncate(time.Second)
+		case "day":
+			w.day = today.AddDate(0, 0, n)
+		case "week":
+			w.day = today.AddDate(0, 0, 7*n)
+		}
+		return strings.TrimSuffix(task, m[0]), true
+	}
+	if m := phraseDay.FindStringSubmatch(task); m != nil {
+		w.day = today
+		if strings.ToLower(m[1]) == "tomorrow" {
+			w.day = today.AddDate(0, 0, 1)
+		}
+		return strings.TrimSuffix(task, m[0]), true
+	}
+	if m := phraseWeekday.FindStringSubmatch(task); m != nil {
+		weekday := phraseWeekdays[strings.ToLower(m[1])]
+		w.matches = func(day time.Time) bool { return day.Weekday() == weekday }
+		return strings.TrimSuffix(task, m[0]), true
+	}
+	if m := phraseDayOfMonth.FindStringSubmatch(task); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		w.matches = func(day time.Time) bool { return day.Day() == n }
+		return strings.TrimSuffix(task, m[0]), true
+	}
+	if m := phraseISODate.FindStringSubmatch(task); m != nil {
+		if day, err := time.ParseInLocation("2006-01-02", m[1], now.Location()); err == nil {
+			w.day = day
+			return strings.TrimSuffix(task, m[0]), true
+		}
+	}
+	if m := phraseDayMonth.FindStringSubmatch(task); m != nil {
+		if month, ok := phraseMonth(m[2]); ok {
+			n, _ := strconv.Atoi(m[1])
+			w.matches = func(day time.Time) bool { return day.Month() == month && day.Day() == n }
+			return strings.TrimSuffix(task, m[0]), true
+		}
+	}
+	if m := phraseMonthDay.FindStringSubmatch(task); m != nil {
+		if month, ok := phraseMonth(m[1]); ok {
+			n, _ := strconv.Atoi(m[2])
+			w.matches = func(day time.Time) bool { return day.Month() == month && day.Day() == n }
+			return strings.TrimSuffix(task, m[0]), true
+		}
+	}
+	return task, false
+}
+
+// resolve returns the time the phrase described, which must be after now.
+func (w *phraseWhen) resolve(now time.Time) (time.Time, error) {
+	if !w.at.IsZero() {
+		return w.at, nil
+	}
+
+	hour, minute := w.hour, w.minute
+	if hour < 0 {
+		hour, minute = defaultPhraseHour, 0
+	}
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	}
+
+	if !w.day.IsZero() {
+		if !at(w.day).After(now) {
+			return time.Time{}, errPhrasePast
+		}
+		return at(w.day), nil
+	}
+
+	matches := w.matches
+	if matches == nil {
+		matches = func(time.Time) bool { return true }
+	}
+	// Four years cover every date, the 29th of February included
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for i := 0; i < 4*366; i++ {
+		if matches(day) && at(day).After(now) {
+			return at(day), nil
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, errPhraseNoDate
+}
+
+// phraseClock reads the time of day matched by phraseTime, from its submatches.
+func phraseClock(m []string) (int, int, bool) {
+	switch {
+	case m[1] != "":
+		return 12, 0, true
+	case m[2] != "":
+		return 0, 0, true
+	}
+
+	hour, _ := strconv.Atoi(m[3])
+	minute := 0
+	if m[4] != "" {
+		minute, _ = strconv.Atoi(m[4])
+	}
+	if minute > 59 {
+		return 0, 0, false
+	}
+	switch strings.ToLower(m[5]) {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if strings.ToLower(m[5]) == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, false
+		}
+	}
+	return hour, minute, true
+}
+
+// submatches returns the submatches of s at the indexes of a FindStringSubmatchIndex match, empty
+// for those that did not participate.
+func submatches(s string, m []int) []string {
+	parts := make([]string, len(m)/2)
+	for i := range parts {
+		if m[2*i] >= 0 {
+			parts[i] = s[m[2*i]:m[2*i+1]]
+		}
+	}
+	return parts
+}
+
+// Days of the week in reminder phrases
+var phraseWeekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// phraseMonth reads the name of a month, in full or abbreviated to three letters, or "sept".
+func phraseMonth(name string) (time.Month, bool) {
+	name = strings.ToLower(name)
+	if name == "sept" {
+		return time.September, true
+	}
+	for month := time.January; month <= time.December; month++ {
+		full := strings.ToLower(month.String())
+		if name == full || name == full[:3] {
+			return month, true
+		}
+	}
+	return 0, false
+}
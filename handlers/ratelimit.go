@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"time"
+)
+
+// Where rate limits count requests; nil keeps the counts in the memory of this instance
+var rateLimitStorage fiber.Storage
+
+// SetRateLimitStorage sets where rate limits count requests, e.g. a RedisStorage shared by every
+// instance of the server, so that clients cannot multiply their limits by spreading requests
+// across instances. It must be called before the rate limiting middleware is created.
+func SetRateLimitStorage(storage fiber.Storage) {
+	rateLimitStorage = storage
+}
+
+// RateLimit returns middleware allowing each client IP max requests per window on a route,
+// answering 429 with a Retry-After header beyond that. A max of zero disables the limit.
+func RateLimit(max int, window time.Duration) fiber.Handler {
+	return newRateLimiter("ip", max, window, false, func(c *fiber.Ctx) string {
+		return c.IP()
+	})
+}
+
+// RateLimitUsername returns middleware allowing max failed requests per window for each username
+// sent to a route such as /login, answering 429 with a Retry-After header beyond that. Unlike
+// RateLimit it also stops attacks spread across many IPs from guessing the password of one account.
+// Successful requests are not counted, so the owner is only locked out while the account is under
+// attack. Requests without a valid username are left to the handler. A max of zero disables the limit.
+func RateLimitUsername(max int, window time.Duration) fiber.Handler {
+	return newRateLimiter("username", max, window, true, bodyUsername)
+}
+
+// newRateLimiter returns the limiter middleware counting requests by the key of each request on a
+// route, only failed ones if failedOnly is set. Requests with an empty key are not limited.
+func newRateLimiter(kind string, max int, window time.Duration, failedOnly bool, key func(*fiber.Ctx) string) fiber.Handler {
+	if max <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: window,
+		Next: func(c *fiber.Ctx) bool {
+			return key(c) == ""
+		},
+		// Routes have their own counts, also when they share the storage
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return kind + ":" + c.Route().Path + ":" + key(c)
+		},
+		SkipSuccessfulRequests: failedOnly,
+		Storage:                rateLimitStorage,
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Too many requests, try again later",
+			})
+		},
+	})
+}
+
+// bodyUsername returns the normalized username in the body of a login or signup request, or "" if
+// the body has none or it is not a valid username, since such names match no account.
+func bodyUsername(c *fiber.Ctx) string {
+	var body struct {
+		Username string `json:"username" form:"username"`
+	}
+
+	// Unlike ParseBody, other fields such as the password are ignored here
+	if isJSONBody(c) {
+		if json.Unmarshal(c.Body(), &body) != nil {
+			return ""
+		}
+	} else if c.BodyParser(&body) != nil {
+		return ""
+	}
+
+	username := NormalizeUsername(body.Username)
+	if validateUsername(username) != nil {
+		return ""
+	}
+	return username
+}
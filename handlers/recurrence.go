@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// Default and maximum number of occurrences returned by ListOccurrences
+const (
+	defaultOccurrences = 10
+	maxOccurrences     = 100
+)
+
+// ListOccurrences returns the next dates one of the user's events will fire on, expanding its
+// recurrence rule in the user's timezone. ?count= sets how many, up to maxOccurrences; a one-off
+// event has at most one.
+func ListOccurrences(c *fiber.Ctx, s *store.Store) error {
+	count, err := queryInt(c, "count", defaultOccurrences)
+	if err == nil && (count < 1 || count > maxOccurrences) {
+		err = fmt.Errorf("count must be between 1 and %d", maxOccurrences)
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	eventName := c.Params("name") // Get the event name from URL params
+
+	var userID = getUserID(c)
+
+	event, err := s.Events.Get(c.UserContext(), userID, eventName)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	loc := userLocation(c.UserContext(), s.Users, userID)
+	dates := store.Occurrences(event, loc, time.Now(), count)
+
+	occurrences := make([]string, len(dates))
+	for i, date := range dates {
+		occurrences[i] = date.Format(time.RFC3339)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":      "fetched",
+		"event_id":    event.ID,
+		"recurrence":  event.Recurrence,
+		"occurrences": occurrences,
+		"message":     "Occurrences fetched successfully",
+	})
+}
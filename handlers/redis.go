@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"time"
+)
+
+// Prefix of the keys under which RedisStorage stores rate limit counts
+const redisKeyPrefix = "ratelimit:"
+
+// RedisStorage keeps rate limit counts in Redis, so that every instance of the server connected
+// to it shares them. It implements fiber.Storage. The limiter treats a failed read as a fresh
+// count, so while Redis cannot be reached requests are let through and the errors are logged.
+type RedisStorage struct {
+	client *redis.Client
+}
+
+// NewRedisStorage connects to the Redis server at rawURL, such as redis://localhost:6379/0, or
+// rediss:// for TLS.
+func NewRedisStorage(rawURL string) (*RedisStorage, error) {
+	options, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+
+	return &RedisStorage{client: client}, nil
+}
+
+// Get returns the value stored under key, or nil if there is none.
+func (s *RedisStorage) Get(key string) ([]byte, error) {
+	value, err := s.client.Get(context.Background(), redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Failed to read rate limit from Redis", slog.Any("error", err))
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores value under key until exp has passed, or for good if exp is zero.
+func (s *RedisStorage) Set(key string, value []byte, exp time.Duration) error {
+	if key == "" || len(value) == 0 {
+		return nil
+	}
+	err := s.client.Set(context.Background(), redisKeyPrefix+key, value, exp).Err()
+	if err != nil {
+		slog.Error("Failed to store rate limit in Redis", slog.Any("error", err))
+	}
+	return err
+}
+
+// Delete removes the value stored under key.
+func (s *RedisStorage) Delete(key string) error {
+	return s.client.Del(context.Background(), redisKeyPrefix+key).Err()
+}
+
+// Reset removes every value of the storage, leaving other keys of the Redis database alone.
+func (s *RedisStorage) Reset() error {
+	ctx := context.Background()
+	iter := s.client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// Close closes the connections to Redis.
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+// Most reminders an event may have besides its date
+const maxReminders = 10
+
+// Shortest and longest time a reminder may fire ahead of its event
+const (
+	minReminderOffset = time.Minute
+	maxReminderOffset = 365 * 24 * time.Hour
+)
+
+// ReminderRequest struct defines the body of a reminder; Before is a Go duration such as 24h, the
+// time the reminder fires ahead of the event's date.
+type ReminderRequest struct {
+	Before string `json:"before" form:"before" validate:"required,offset"`
+}
+
+// reminderOffset parses how long ahead of its event a reminder fires, in whole seconds, matching
+// the precision of the stored offset.
+func reminderOffset(raw string) (time.Duration, error) {
+	before, err := time.ParseDuration(raw)
+	if err != nil || before < minReminderOffset || before > maxReminderOffset {
+		return 0, fmt.Errorf("before must be between %s and %s, such as 30m or 24h", minReminderOffset, maxReminderOffset)
+	}
+	return before.Truncate(time.Second), nil
+}
+
+// ListReminders returns the reminders of one of the user's events, the earliest first.
+func ListReminders(c *fiber.Ctx, s *store.Store) error {
+	eventID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Record not found",
+		})
+	}
+
+	var userID = getUserID(c)
+
+	// The reminders of an event shared with the user are those of its owner
+	ownerID, _, err := resolveEvent(c, s, userID, store.PermissionRead)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	reminders, err := s.Reminders.List(c.UserContext(), ownerID, eventID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":    "fetched",
+		"reminders": reminders,
+		"message":   "Reminders fetched successfully",
+	})
+}
+
+// AddReminder adds a reminder to one of the user's events, firing it a while ahead of its date in
+// addition to the date itself. Recurring events fire their reminders ahead of every occurrence.
+func AddReminder(c *fiber.Ctx, s *store.Store) error {
+	eventID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Record not found",
+		})
+	}
+
+	req := new(ReminderRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid reminder", problems)
+	}
+	// The offset rule accepted the duration
+	before, _ := reminderOffset(req.Before)
+
+	var userID = getUserID(c)
+
+	// The reminders of an event shared with the user are those of its owner
+	ownerID, _, err := resolveEvent(c, s, userID, store.PermissionEdit)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	reminders, err := s.Reminders.List(c.UserContext(), ownerID, eventID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	if len(reminders) >= maxReminders {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("an event may have at most %d reminders", maxReminders),
+		})
+	}
+
+	reminder, err := s.Reminders.Add(c.UserContext(), ownerID, eventID, before)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "The event already has a reminder at that time",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":   "created",
+		"reminder": reminder,
+		"message":  "Reminder added successfully",
+	})
+}
+
+// UpdateReminder moves one of the reminders of a user's event to another time ahead of its date.
+// A reminder moved to a time still to come fires again, even if it fired already.
+func UpdateReminder(c *fiber.Ctx, s *store.Store) error {
+	eventID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Record not found",
+		})
+	}
+	id, err := strconv.Atoi(c.Params("reminderId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Record not found",
+		})
+	}
+
+	req := new(ReminderRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid reminder", problems)
+	}
+	// The offset rule accepted the duration
+	before, _ := reminderOffset(req.Before)
+
+	var userID = getUserID(c)
+
+	// The reminders of an event shared with the user are those of its owner
+	ownerID, _, err := resolveEvent(c, s, userID, store.PermissionEdit)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	reminder, err := s.Reminders.Update(c.UserContext(), ownerID, eventID, id, before)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "The event already has a reminder at that time",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "updated",
+		"reminder": reminder,
+		"message":  "Reminder updated successfully",
+	})
+}
+
+// DeleteReminder removes one of the reminders of a user's event; the event still fires at its date.
+func DeleteReminder(c *fiber.Ctx, s *store.Store) error {
+	eventID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Record not found",
+		})
+	}
+	id, err := strconv.Atoi(c.Params("reminderId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Record not found",
+		})
+	}
+
+	var userID = getUserID(c)
+
+	// The reminders of an event shared with the user are those of its owner
+	ownerID, _, err := resolveEvent(c, s, userID, store.PermissionEdit)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	if err := s.Reminders.Delete(c.UserContext(), ownerID, eventID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Reminder deleted successfully",
+	})
+}
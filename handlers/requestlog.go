@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"time"
+)
+
+// Header carrying the id of a request. An id sent by the client or a proxy is kept so that its
+// logs can be matched with ours; otherwise one is generated. Responses carry it either way.
+const requestIDHeader = "X-Request-ID"
+
+// Locals holding the id of the request and, once getUserID resolved it, of the authenticated user
+const (
+	requestIDLocal = "request_id"
+	userIDLocal    = "user_id"
+)
+
+// Request ids taken from the header; anything else could forge or garble log lines
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestLogger returns middleware that assigns every request an id and logs one line for it once
+// it has been handled, with its method, route, status, latency, client IP, and the authenticated
+// user if any. Responses of 500 and above are logged as errors. Handlers log through Logger, so
+// that their lines carry the same request id.
+func RequestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		id := c.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newErrorID()
+		}
+		c.Locals(requestIDLocal, id)
+		c.Set(requestIDHeader, id)
+
+		// Render errors right away, so the logged status is the one sent
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		Logger(c).LogAttrs(c.UserContext(), level, "Request handled",
+			slog.String("method", c.Method()),
+			slog.String("route", c.Route().Path),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+		)
+		return nil
+	}
+}
+
+// Logger returns the logger for lines about a request, which carry its id and the id of the
+// authenticated user once it is known.
+func Logger(c *fiber.Ctx) *slog.Logger {
+	logger := slog.Default()
+	if id, ok := c.Locals(requestIDLocal).(string); ok {
+		logger = logger.With(slog.String("request_id", id))
+	}
+	if userID := loggedUserID(c); userID != 0 {
+		logger = logger.With(slog.Int("user_id", userID))
+	}
+	return logger
+}
+
+// loggedUserID returns the id of the user who made a request, as far as it was established while
+// handling it, or 0.
+func loggedUserID(c *fiber.Ctx) int {
+	if userID, ok := c.Locals(apiKeyUserLocal).(int); ok {
+		return userID
+	}
+	userID, _ := c.Locals(userIDLocal).(int)
+	return userID
+}
+
+// LogPanic logs a panic recovered while handling a request, with its stack trace. It is meant as
+// the StackTraceHandler of the recover middleware.
+func LogPanic(c *fiber.Ctx, e interface{}) {
+	Logger(c).Error("Recovered from panic", slog.String("panic", fmt.Sprint(e)), slog.String("stack", string(debug.Stack())))
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// Outcomes reported per event by RescheduleEvents
+const (
+	rescheduleDone        = "rescheduled"
+	rescheduleNotFound    = "not_found"
+	rescheduleInvalidDate = "invalid_date"
+)
+
+// RescheduleItem struct moves one event to a new date.
+type RescheduleItem struct {
+	Name string `json:"name" form:"name" validate:"required,eventname"`
+	Date string `json:"date" form:"date" validate:"required,eventdate"`
+}
+
+// RescheduleRequest struct defines the body of a bulk reschedule. Either Events lists new dates
+// for individual events, or Shift moves events by a duration: the events listed in Names, or
+// every uncompleted event when Names is empty.
+type RescheduleRequest struct {
+	Events []RescheduleItem `json:"events" form:"-" validate:"omitempty,max=500,unique=Name,dive"`
+	Shift  string           `json:"shift" form:"shift" validate:"omitempty,shift"`
+	Names  []string         `json:"names" form:"names" validate:"omitempty,max=500,unique,dive,eventname"`
+}
+
+// RescheduleResult struct reports the outcome of a bulk reschedule for one event.
+type RescheduleResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Date   string `json:"date,omitempty"`
+}
+
+// RescheduleEvents changes the dates of several events in one transaction, either to the given
+// dates or by shifting them by a duration. Names that do not match one of the user's events are
+// reported as not found instead of failing the request.
+func RescheduleEvents(c *fiber.Ctx, s *store.Store) error {
+	req := new(RescheduleRequest)
+	// Parse the request body (JSON or form-encoded) into the request struct
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+
+	if (len(req.Events) == 0) == (req.Shift == "") {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Provide either events with new dates or a shift",
+		})
+	}
+	if len(req.Names) > 0 && req.Shift == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "names can only be used together with shift",
+		})
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid reschedule request", problems)
+	}
+
+	var userID = getUserID(c)
+
+	// Names reported back in order: as requested, or for a shift of all events every event visited
+	// in id order. Events whose stored date cannot be parsed are left unchanged.
+	var requested []string
+	invalidDates := map[string]bool{}
+	var dateFor func(*store.Event) (string, bool)
+
+	if req.Shift != "" {
+		// The shift rule already checked the duration, so this cannot fail
+		shift, _ := time.ParseDuration(req.Shift)
+		named := make(map[string]bool, len(req.Names))
+		for _, name := range req.Names {
+			named[name] = true
+		}
+		requested = append(requested, req.Names...)
+
+		dateFor = func(event *store.Event) (string, bool) {
+			if len(named) > 0 && !named[event.Name] {
+				return "", false
+			}
+			if len(named) == 0 {
+				if event.CompletedAt != nil {
+					return "", false
+				}
+				requested = append(requested, event.Name)
+			}
+
+			date, err := parseEventDate(event.Date, time.UTC)
+			if err != nil {
+				invalidDates[event.Name] = true
+				return "", false
+			}
+			return date.Add(shift).UTC().Format(time.RFC3339), true
+		}
+	} else {
+		// Dates without an offset are in the user's timezone, as when creating an event.
+		// The eventdate rule already checked the layouts, so this cannot fail.
+		loc := userLocation(c.UserContext(), s.Users, userID)
+		dates := make(map[string]string, len(req.Events))
+		for _, item := range req.Events {
+			dates[item.Name], _ = normalizeEventDate(item.Date, loc)
+			requested = append(requested, item.Name)
+		}
+
+		dateFor = func(event *store.Event) (string, bool) {
+			date, ok := dates[event.Name]
+			return date, ok
+		}
+	}
+
+	rescheduled, err := s.Events.Reschedule(c.UserContext(), userID, dateFor)
+	if err != nil {
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	newDates := make(map[string]string, len(rescheduled))
+	for _, event := range rescheduled {
+		newDates[event.Name] = event.Date
+	}
+
+	results := make([]RescheduleResult, len(requested))
+	for i, name := range requested {
+		switch {
+		case newDates[name] != "":
+			results[i] = RescheduleResult{Name: name, Status: rescheduleDone, Date: newDates[name]}
+		case invalidDates[name]:
+			results[i] = RescheduleResult{Name: name, Status: rescheduleInvalidDate}
+		default:
+			results[i] = RescheduleResult{Name: name, Status: rescheduleNotFound}
+		}
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":      "rescheduled",
+		"rescheduled": len(rescheduled),
+		"results":     results,
+		"message":     "Events rescheduled successfully",
+	})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"reflect"
+	"testing"
+)
+
+// rescheduled is the body of a bulk reschedule.
+type rescheduled struct {
+	Rescheduled int                `json:"rescheduled"`
+	Results     []RescheduleResult `json:"results"`
+}
+
+func TestRescheduleEvents(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	for _, name := range []string{"standup", "review", "retro"} {
+		createEvent(t, s, alice, name)
+	}
+	createEvent(t, s, bob, "dentist")
+	app := newTestApp()
+	app.Post("/events/reschedule", func(c *fiber.Ctx) error { return RescheduleEvents(c, s) })
+	app.Post("/event/:name/complete", func(c *fiber.Ctx) error { return CompleteEvent(c, s) })
+	expectStatus(t, request(t, app, alice, "POST", "/event/retro/complete", ""), 200)
+
+	// reschedule posts body as alice and returns the results
+	reschedule := func(body string) rescheduled {
+		t.Helper()
+		resp := request(t, app, alice, "POST", "/events/reschedule", body)
+		expectStatus(t, resp, 200)
+		var answer rescheduled
+		decode(t, resp, &answer)
+		return answer
+	}
+	// dates returns the dates of alice's events and bob's dentist
+	dates := func() map[string]string {
+		t.Helper()
+		return map[string]string{
+			"standup": getEvent(t, s, alice, "standup").Date,
+			"review":  getEvent(t, s, alice, "review").Date,
+			"retro":   getEvent(t, s, alice, "retro").Date,
+			"dentist": getEvent(t, s, bob, "dentist").Date,
+		}
+	}
+
+	// A shift without names moves every uncompleted event of the user
+	answer := reschedule(`{"shift":"24h"}`)
+	want := []RescheduleResult{
+		{Name: "standup", Status: rescheduleDone, Date: "2030-01-02T09:00:00Z"},
+		{Name: "review", Status: rescheduleDone, Date: "2030-01-02T09:00:00Z"},
+	}
+	if answer.Rescheduled != 2 || !reflect.DeepEqual(answer.Results, want) {
+		t.Fatalf("shift all: got %+v, want %+v", answer, want)
+	}
+	if got := dates(); got["retro"] != "2030-01-01T09:00:00Z" || got["dentist"] != "2030-01-01T09:00:00Z" {
+		t.Fatalf("shift all: got dates %v, want retro and the event of bob unchanged", got)
+	}
+
+	// New dates apply to the events named; the event of another user counts as not found
+	answer = reschedule(`{"events":[{"name":"review","date":"2030-02-01T10:00:00Z"},{"name":"dentist","date":"2030-02-01T10:00:00Z"}]}`)
+	want = []RescheduleResult{
+		{Name: "review", Status: rescheduleDone, Date: "2030-02-01T10:00:00Z"},
+		{Name: "dentist", Status: rescheduleNotFound},
+	}
+	if answer.Rescheduled != 1 || !reflect.DeepEqual(answer.Results, want) {
+		t.Fatalf("subset: got %+v, want %+v", answer, want)
+	}
+	if got := dates(); got["standup"] != "2030-01-02T09:00:00Z" || got["dentist"] != "2030-01-01T09:00:00Z" {
+		t.Fatalf("subset: got dates %v, want standup and the event of bob unchanged", got)
+	}
+
+	// A shift of named events moves those only
+	answer = reschedule(`{"shift":"-30m","names":["standup","dentist"]}`)
+	want = []RescheduleResult{
+		{Name: "standup", Status: rescheduleDone, Date: "2030-01-02T08:30:00Z"},
+		{Name: "dentist", Status: rescheduleNotFound},
+	}
+	if answer.Rescheduled != 1 || !reflect.DeepEqual(answer.Results, want) {
+		t.Fatalf("shift of names: got %+v, want %+v", answer, want)
+	}
+	if got := dates(); got["review"] != "2030-02-01T10:00:00Z" || got["dentist"] != "2030-01-01T09:00:00Z" {
+		t.Fatalf("shift of names: got dates %v", got)
+	}
+
+	for _, test := range []struct {
+		body   string
+		status int
+	}{
+		{`{}`, 400},
+		{`{"shift":"24h","events":[{"name":"review","date":"2030-02-01T10:00:00Z"}]}`, 400},
+		{`{"names":["review"],"events":[{"name":"review","date":"2030-02-01T10:00:00Z"}]}`, 400},
+		{`{"shift":"0s"}`, 422},
+		{`{"shift":"tomorrow"}`, 422},
+		{`{"events":[{"name":"review","date":"someday"}]}`, 422},
+	} {
+		expectStatus(t, request(t, app, alice, "POST", "/events/reschedule", test.body), test.status)
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+// ShareRequest struct defines the body of a share: the user to share the event with and what they
+// may do with it, read (the default) or edit.
+type ShareRequest struct {
+	Username   string `json:"username" form:"username" validate:"required"`
+	Permission string `json:"permission" form:"permission" validate:"omitempty,oneof=read edit"`
+}
+
+// ShareEvent shares one of the user's events with another user by username, read-only or with
+// permission to edit it. Sharing it with a user again changes their permission. The event keeps
+// firing for its owner and now also notifies the users it is shared with.
+func ShareEvent(c *fiber.Ctx, s *store.Store) error {
+	req := new(ShareRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	req.Username = NormalizeUsername(req.Username)
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid share", problems)
+	}
+	if req.Permission == "" {
+		req.Permission = store.PermissionRead
+	}
+
+	var userID = getUserID(c)
+
+	// Only users who can see the event share it, not those it is shared with
+	ownerID, _, err := resolveEvent(c, s, userID, "")
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+	// The route only matches integer ids
+	eventID, _ := strconv.Atoi(c.Params("id"))
+
+	user, err := s.Users.ByUsername(c.UserContext(), req.Username)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "User not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	// That includes the user themselves and the members of the event's organization
+	if _, err := s.Events.NameByID(c.UserContext(), user.ID, eventID); !errors.Is(err, store.ErrNotFound) {
+		if err != nil {
+			return ServerError(c, err)
+		}
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The user can already see this event",
+		})
+	}
+
+	share, err := s.Shares.Share(c.UserContext(), ownerID, eventID, user.ID, req.Permission)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "shared",
+		"event_id": eventID,
+		"share":    share,
+		"message":  "Event shared successfully",
+	})
+}
+
+// ListShares lists the users one of the user's events is shared with, in username order.
+func ListShares(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	ownerID, _, err := resolveEvent(c, s, userID, "")
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+	eventID, _ := strconv.Atoi(c.Params("id"))
+
+	shares, err := s.Shares.List(c.UserContext(), ownerID, eventID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "fetched",
+		"event_id": eventID,
+		"shares":   shares,
+		"message":  "Shares fetched successfully",
+	})
+}
+
+// UnshareEvent stops sharing one of the user's events with another user, who can no longer see it
+// and is no longer notified of it.
+func UnshareEvent(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	ownerID, _, err := resolveEvent(c, s, userID, "")
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+	eventID, _ := strconv.Atoi(c.Params("id"))
+
+	user, err := s.Users.ByUsername(c.UserContext(), NormalizeUsername(c.Params("username")))
+	if err == nil {
+		err = s.Shares.Unshare(c.UserContext(), ownerID, eventID, user.ID)
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "unshared",
+		"event_id": eventID,
+		"username": user.Username,
+		"message":  "Event unshared successfully",
+	})
+}
+
+// ListSharedEvents lists the events other users shared with the user, with the username of each
+// event's owner and the permission the user was given. They are addressed by id like the user's
+// own events.
+func ListSharedEvents(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	events, err := s.Shares.SharedWith(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"count":   len(events),
+		"events":  events,
+		"message": "Shared events fetched successfully",
+	})
+}
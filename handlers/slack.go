@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/notify"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Prefix of the incoming webhook URLs Slack issues, and of the response URLs of interactions
+const slackHooksPrefix = "https://hooks.slack.com/"
+
+// Longest a reply to an interaction may take to reach Slack
+const slackRespondTimeout = 10 * time.Second
+
+// SlackApp describes the Slack app interaction callbacks come from; the Slack notifier implements it.
+type SlackApp interface {
+	Verify(timestamp, signature string, body []byte, now time.Time) error
+	ParseActionValue(value string) (int, int, error)
+	Respond(ctx context.Context, responseURL, text string) error
+}
+
+// SlackRequest struct defines the body of a Slack configuration: either the URL of an incoming
+// webhook, or the bot token of a Slack app and the channel it posts to.
+type SlackRequest struct {
+	WebhookURL string `json:"webhook_url" form:"webhook_url" validate:"omitempty,max=2048,slackwebhook"`
+	BotToken   string `json:"bot_token" form:"bot_token" validate:"omitempty,max=255,slacktoken"`
+	Channel    string `json:"channel" form:"channel" validate:"required_with=BotToken,max=255"`
+}
+
+// validateSlackWebhook checks that url is an incoming webhook URL issued by Slack.
+func validateSlackWebhook(url string) error {
+	if !strings.HasPrefix(url, slackHooksPrefix) {
+		return fmt.Errorf("webhook_url must be a Slack incoming webhook URL starting with %s", slackHooksPrefix)
+	}
+	return nil
+}
+
+// validateSlackToken checks that token is the bot token of a Slack app.
+func validateSlackToken(token string) error {
+	if !strings.HasPrefix(token, "xoxb-") {
+		return fmt.Errorf("bot_token must be a Slack bot token starting with xoxb-")
+	}
+	return nil
+}
+
+// SetSlack configures where the authenticated user's reminders are posted in Slack, replacing the
+// previous configuration.
+func SetSlack(c *fiber.Ctx, s *store.Store) error {
+	req := new(SlackRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid Slack configuration", problems)
+	}
+	if (req.WebhookURL == "") == (req.BotToken == "") {
+		return ValidationFailed(c, "Invalid Slack configuration", []FieldError{{
+			Field:   "webhook_url",
+			Message: "either webhook_url, or bot_token and channel, is required",
+		}})
+	}
+
+	var userID = getUserID(c)
+
+	config := &store.SlackConfig{WebhookURL: req.WebhookURL}
+	if req.BotToken != "" {
+		config.BotToken, config.Channel = req.BotToken, req.Channel
+	}
+	if err := s.Slack.Set(c.UserContext(), userID, config); err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "updated",
+		"mode":    config.Mode(),
+		"slack":   config,
+		"message": "Slack configured successfully",
+	})
+}
+
+// GetSlack returns the Slack configuration of the authenticated user without its secrets.
+func GetSlack(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	config, err := s.Slack.Get(c.UserContext(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Slack is not configured",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"mode":    config.Mode(),
+		"slack":   config,
+		"message": "Slack configuration fetched successfully",
+	})
+}
+
+// DeleteSlack removes the Slack configuration of the authenticated user, whose reminders are no
+// longer posted to Slack.
+func DeleteSlack(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	if err := s.Slack.Delete(c.UserContext(), userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Slack is not configured",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Slack configuration deleted successfully",
+	})
+}
+
+// slackInteraction is the part of an interaction payload the buttons of a reminder need.
+type slackInteraction struct {
+	Type        string `json:"type"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// SlackInteraction handles the interaction callbacks of the Slack app, sent when someone presses
+// a button of a reminder. The request must carry a valid Slack signature. The button snoozes or
+// dismisses the reminder of the user it was posted for, and the message is replaced with the
+// outcome.
+func SlackInteraction(c *fiber.Ctx, s *store.Store, slack SlackApp) error {
+	if err := slack.Verify(c.Get(notify.SlackHeaderTimestamp), c.Get(notify.SlackHeaderSignature), c.Body(), time.Now()); err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid signature",
+		})
+	}
+
+	var payload slackInteraction
+	if err := json.Unmarshal([]byte(c.FormValue("payload")), &payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid interaction payload",
+		})
+	}
+	// Slack retries interactions that are not acknowledged, including kinds the app does not handle
+	if payload.Type != "block_actions" || len(payload.Actions) == 0 {
+		return c.SendStatus(200)
+	}
+
+	action := payload.Actions[0]
+	userID, eventID, err := slack.ParseActionValue(action.Value)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid action",
+		})
+	}
+
+	reply := slackAction(c.UserContext(), s, userID, eventID, action.ActionID)
+	if strings.HasPrefix(payload.ResponseURL, slackHooksPrefix) {
+		// Slack gives up on interactions that are not acknowledged within 3 seconds, so the message
+		// is replaced afterwards
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), slackRespondTimeout)
+			defer cancel()
+			if err := slack.Respond(ctx, payload.ResponseURL, reply); err != nil {
+				slog.Error("Replying to a Slack interaction failed", slog.Int("event_id", eventID), slog.Any("error", err))
+			}
+		}()
+	}
+	return c.SendStatus(200)
+}
+
+// slackAction snoozes or dismisses the reminder of an event on behalf of a user, who owns it or
+// may edit it, and returns the text replacing the message of the reminder.
+func slackAction(ctx context.Context, s *store.Store, userID, eventID int, actionID string) string {
+	ownerID := userID
+	name, err := s.Events.NameByID(ctx, userID, eventID)
+	if errors.Is(err, store.ErrNotFound) {
+		ownerID, name, err = resolveSharedEvent(ctx, s, userID, eventID, store.PermissionEdit)
+	}
+
+	var event *store.Event
+	var outcome string
+	if err == nil {
+		switch {
+		case actionID == notify.SlackActionDismiss:
+			event, err = dismiss(ctx, s, ownerID, name)
+			outcome = "dismissed"
+		case strings.HasPrefix(actionID, notify.SlackActionSnooze):
+			// The durations of the buttons are valid snoozes
+			duration, _ := time.ParseDuration(strings.TrimPrefix(actionID, notify.SlackActionSnooze))
+			event, err = snooze(ctx, s, ownerID, name, duration)
+			if err == nil {
+				outcome = fmt.Sprintf("snoozed until <!date^%d^{date_short_pretty} at {time}|%s>",
+					event.SnoozedUntil.Unix(), event.SnoozedUntil.Format(time.RFC3339))
+			}
+		default:
+			return "Unknown action"
+		}
+	}
+
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return "This event no longer exists"
+	case errors.Is(err, errNotPermitted):
+		return "The event is shared with you without permission to do this"
+	case errors.Is(err, errCompleted):
+		return "Completed events cannot be snoozed"
+	case err != nil:
+		slog.Error("Slack action failed", slog.Int("event_id", eventID), slog.String("action", actionID), slog.Any("error", err))
+		return "Something went wrong, please try again later"
+	}
+	eventLists.invalidate(userListScope(ctx, s.Users, ownerID))
+
+	return fmt.Sprintf("Reminder: %s, %s", event.Name, outcome)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// Shortest and longest snooze
+const (
+	minSnooze = time.Minute
+	maxSnooze = 7 * 24 * time.Hour
+)
+
+// validateSnooze checks that duration is a Go duration between minSnooze and maxSnooze.
+func validateSnooze(duration string) error {
+	d, err := time.ParseDuration(duration)
+	if err != nil || d < minSnooze || d > maxSnooze {
+		return fmt.Errorf("duration must be between %s and %s, such as 15m or 2h", minSnooze, maxSnooze)
+	}
+	return nil
+}
+
+// errCompleted aborts snoozing an event that was marked as completed.
+var errCompleted = errors.New("event is completed")
+
+// SnoozeRequest struct defines the body of a snooze request; Duration is a Go duration such as 15m.
+type SnoozeRequest struct {
+	Duration string `json:"duration" form:"duration" validate:"required,snooze"`
+}
+
+// SnoozeEvent makes one of the user's reminders fire again after a duration, whether or not it
+// fired already. Until then it does not fire at its date. Snoozing clears an earlier dismissal.
+func SnoozeEvent(c *fiber.Ctx, s *store.Store) error {
+	req := new(SnoozeRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid snooze", problems)
+	}
+	// The snooze rule accepted the duration
+	duration, _ := time.ParseDuration(req.Duration)
+
+	var userID = getUserID(c)
+
+	ownerID, eventName, err := resolveEvent(c, s, userID, store.PermissionEdit)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	snoozed, err := snooze(c.UserContext(), s, ownerID, eventName, duration)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		if errors.Is(err, errCompleted) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Completed events cannot be snoozed",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, ownerID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":        "snoozed",
+		"event_id":      snoozed.ID,
+		"snoozed_until": snoozed.SnoozedUntil,
+		"message":       "Reminder snoozed",
+	})
+}
+
+// DismissEvent dismisses one of the user's reminders: a pending snooze is cancelled, pending
+// delivery retries stop, and the reminder does not fire for any date up to now. Later dates, such
+// as the next occurrences of a recurring event, still fire.
+func DismissEvent(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	ownerID, eventName, err := resolveEvent(c, s, userID, store.PermissionEdit)
+	if err != nil {
+		return eventLookupFailed(c, err)
+	}
+
+	dismissed, err := dismiss(c.UserContext(), s, ownerID, eventName)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, ownerID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":       "dismissed",
+		"event_id":     dismissed.ID,
+		"dismissed_at": dismissed.DismissedAt,
+		"message":      "Reminder dismissed",
+	})
+}
+
+// snooze makes the reminder of an event of ownerID fire again after duration, or returns
+// errCompleted if the event was completed.
+func snooze(ctx context.Context, s *store.Store, ownerID int, eventName string, duration time.Duration) (*store.Event, error) {
+	return s.Events.Update(ctx, ownerID, eventName, func(event *store.Event) error {
+		if event.CompletedAt != nil {
+			return errCompleted
+		}
+		// Whole seconds, matching the precision of the stored DATETIME
+		until := time.Now().UTC().Add(duration).Truncate(time.Second)
+		event.SnoozedUntil = &until
+		event.DismissedAt = nil
+		return nil
+	})
+}
+
+// dismiss dismisses the reminder of an event of ownerID.
+func dismiss(ctx context.Context, s *store.Store, ownerID int, eventName string) (*store.Event, error) {
+	return s.Events.Update(ctx, ownerID, eventName, func(event *store.Event) error {
+		// Whole seconds, matching the precision of the stored DATETIME
+		now := time.Now().UTC().Truncate(time.Second)
+		event.DismissedAt = &now
+		event.SnoozedUntil = nil
+		return nil
+	})
+}
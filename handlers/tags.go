@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RenameTagRequest struct defines the body of a tag rename: the new name of the tag.
+type RenameTagRequest struct {
+	Name string `json:"name" form:"name" validate:"required,tag"`
+}
+
+// ListTags lists the tags of the user's events, in alphabetical order, with how many events carry
+// each. Tags are created by setting them on events; GET /api/v1/events?tag= lists the events of one.
+func ListTags(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	tags, err := s.Tags.List(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"tags":    tags,
+		"message": "Tags fetched successfully",
+	})
+}
+
+// RenameTag renames a tag on every event of the user carrying it. Renaming a tag to one already
+// in use merges the two.
+func RenameTag(c *fiber.Ctx, s *store.Store) error {
+	tag := normalizeTag(c.Params("tag"))
+
+	req := new(RenameTagRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	req.Name = normalizeTag(req.Name)
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid tag", problems)
+	}
+
+	var userID = getUserID(c)
+
+	events, err := s.Tags.Rename(c.UserContext(), userID, tag, req.Name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "updated",
+		"tag":     req.Name,
+		"events":  events,
+		"message": "Tag renamed successfully",
+	})
+}
+
+// DeleteTag removes a tag from every event of the user carrying it; the events themselves stay.
+func DeleteTag(c *fiber.Ctx, s *store.Store) error {
+	tag := normalizeTag(c.Params("tag"))
+
+	var userID = getUserID(c)
+
+	events, err := s.Tags.Delete(c.UserContext(), userID, tag)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"tag":     tag,
+		"events":  events,
+		"message": "Tag deleted successfully",
+	})
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/notify"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long a code linking a Telegram chat stays valid
+const telegramCodeTTL = 10 * time.Minute
+
+// Most names tried for an event created through the bot whose name is taken
+const maxPhraseNameAttempts = 10
+
+// TelegramBot describes the Telegram bot chats are linked to; the Telegram notifier implements it.
+type TelegramBot interface {
+	BotUsername() string
+}
+
+// Replies of the bot
+const (
+	telegramHelp = "Send me what to remind you of and when, e.g. \"remind me to pay rent on the 1st\", \"call mom tomorrow at 6pm\", " +
+		"or \"check the oven in 20 minutes\". Reminders of your events arrive here too.\n\n/unlink stops sending reminders to this chat."
+	telegramNotLinked = "This chat is not linked to an account yet. Get a code with POST /api/v1/telegram/link and send it to me as /start <code>."
+)
+
+// Characters of a task left out of the name of the event created for it
+var phraseNameUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// newTelegramCode generates a random one-time code linking a Telegram chat. It only uses characters
+// allowed in the start parameter of a t.me link.
+func newTelegramCode() (string, error) {
+	code := make([]byte, 12)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+// LinkTelegram gives the authenticated user a one-time code to send to the bot, which links the
+// chat it is sent in to their account. A code given before stops working. When the bot's username
+// is known, a t.me link sends the code in a single tap.
+func LinkTelegram(c *fiber.Ctx, s *store.Store, bot TelegramBot) error {
+	if bot == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Telegram is not available",
+		})
+	}
+
+	code, err := newTelegramCode()
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	var userID = getUserID(c)
+
+	expiresAt := time.Now().Add(telegramCodeTTL).UTC().Truncate(time.Second)
+	if err := s.Telegram.CreateCode(c.UserContext(), userID, hashAPIKey(code), expiresAt); err != nil {
+		return ServerError(c, err)
+	}
+
+	link := ""
+	if username := bot.BotUsername(); username != "" {
+		link = "https://t.me/" + username + "?start=" + code
+	}
+	return c.Status(201).JSON(fiber.Map{
+		"status":     "created",
+		"code":       code,
+		"link":       link,
+		"expires_at": expiresAt,
+		"message":    "Send /start followed by the code to the bot, or open the link, to link your Telegram chat",
+	})
+}
+
+// GetTelegram returns the Telegram chat the authenticated user linked.
+func GetTelegram(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	chat, err := s.Telegram.Chat(c.UserContext(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No Telegram chat is linked",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"chat":    chat,
+		"message": "Telegram chat fetched successfully",
+	})
+}
+
+// UnlinkTelegram unlinks the Telegram chat of the authenticated user, which no longer receives
+// reminders or creates events.
+func UnlinkTelegram(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	if err := s.Telegram.Unlink(c.UserContext(), userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No Telegram chat is linked",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Telegram chat unlinked successfully",
+	})
+}
+
+// TelegramBotHandler answers the messages sent to the Telegram bot. /start with a code from
+// LinkTelegram links the chat, /unlink unlinks it, and /help explains the bot. Any other message
+// to a linked chat describes an event in plain English, which is created for the linked user.
+func TelegramBotHandler(s *store.Store) notify.BotHandler {
+	return func(ctx context.Context, message notify.BotMessage) string {
+		text := strings.TrimSpace(message.Text)
+		if !strings.HasPrefix(text, "/") {
+			return createPhraseEvent(ctx, s, message.ChatID, text)
+		}
+
+		command, argument, _ := strings.Cut(text, " ")
+		// Commands may be addressed to the bot, as in /help@ReminderBot
+		command, _, _ = strings.Cut(command, "@")
+		switch command {
+		case "/start", "/link":
+			if argument = strings.TrimSpace(argument); argument == "" {
+				if _, err := s.Telegram.UserByChat(ctx, message.ChatID); err != nil {
+					return telegramNotLinked
+				}
+				return telegramHelp
+			}
+			return linkTelegramChat(ctx, s, message, argument)
+		case "/unlink":
+			err := s.Telegram.UnlinkChat(ctx, message.ChatID)
+			if errors.Is(err, store.ErrNotFound) {
+				return telegramNotLinked
+			}
+			if err != nil {
+				slog.Error("Unlinking a Telegram chat failed", slog.Int64("chat_id", message.ChatID), slog.Any("error", err))
+				return "Something went wrong, please try again later."
+			}
+			return "This chat is unlinked; reminders are no longer sent here."
+		}
+		return telegramHelp
+	}
+}
+
+// linkTelegramChat links the chat a message was sent in with a code from LinkTelegram.
+func linkTelegramChat(ctx context.Context, s *store.Store, message notify.BotMessage, code string) string {
+	chat := &store.TelegramChat{ChatID: message.ChatID, Username: message.Username}
+	userID, err := s.Telegram.Link(ctx, hashAPIKey(code), chat, time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		return "This code is unknown or expired. Get a new one with POST /api/v1/telegram/link."
+	}
+	var user *store.User
+	if err == nil {
+		user, err = s.Users.ByID(ctx, userID)
+	}
+	if err != nil {
+		slog.Error("Linking a Telegram chat failed", slog.Int64("chat_id", message.ChatID), slog.Any("error", err))
+		return "Something went wrong, please try again later."
+	}
+
+	slog.Info("Linked Telegram chat", slog.Int("user_id", userID))
+	return fmt.Sprintf("This chat is now linked to %s. %s", user.Username, telegramHelp)
+}
+
+// createPhraseEvent creates the event a message to a linked chat describes in plain English and
+// returns the reply confirming it. The event is named after the task it reminds of; a taken name
+// gets a number appended.
+func createPhraseEvent(ctx context.Context, s *store.Store, chatID int64, text string) string {
+	userID, err := s.Telegram.UserByChat(ctx, chatID)
+	if errors.Is(err, store.ErrNotFound) {
+		return telegramNotLinked
+	}
+	if err != nil {
+		slog.Error("Looking up a Telegram chat failed", slog.Int64("chat_id", chatID), slog.Any("error", err))
+		return "Something went wrong, please try again later."
+	}
+
+	loc := userLocation(ctx, s.Users, userID)
+	task, date, err := parseReminderPhrase(text, time.Now().In(loc))
+	if err != nil {
+		return "Sorry, " + err.Error() + "."
+	}
+
+	event := &store.Event{Date: date.UTC().Format(time.RFC3339), Message: task}
+	name := phraseEventName(task)
+	for attempt := 1; ; attempt++ {
+		event.Name = name
+		if attempt > 1 {
+			event.Name = name + "-" + strconv.Itoa(attempt)
+		}
+		if problems := ValidateStruct(event); problems != nil {
+			return "Sorry, " + problems[0].Message + "."
+		}
+		prepareEvent(event, loc)
+
+		err = s.Events.Create(ctx, userID, event)
+		if !errors.Is(err, store.ErrDuplicate) || attempt == maxPhraseNameAttempts {
+			break
+		}
+	}
+	if errors.Is(err, store.ErrDuplicate) {
+		return "Sorry, an event with this name already exists."
+	}
+	if err != nil {
+		slog.Error("Creating an event from Telegram failed", slog.Int("user_id", userID), slog.Any("error", err))
+		return "Something went wrong, please try again later."
+	}
+	eventLists.invalidate(userListScope(ctx, s.Users, userID))
+
+	return fmt.Sprintf("Got it, I will remind you to %s on %s. The event is called %s.",
+		task, date.Format("Monday, 2 January 2006 at 15:04 MST"), event.Name)
+}
+
+// phraseEventName returns the name of an event created for a task: its letters and digits,
+// lowercased, with dashes in between, short enough for a number to be appended.
+func phraseEventName(task string) string {
+	name := strings.Trim(phraseNameUnsafe.ReplaceAllString(strings.ToLower(task), "-"), "-")
+	if limit := maxEventNameLength - 3; limit > 0 && len(name) > limit {
+		name = strings.TrimRight(name[:limit], "-")
+	}
+	if name == "" {
+		return "reminder"
+	}
+	return name
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+// FromTemplateRequest struct defines the body of a request creating an event from a template.
+// Name defaults to the template's name pattern applied to Date.
+type FromTemplateRequest struct {
+	Name string `json:"name" form:"name" validate:"omitempty,eventname"`
+	Date string `json:"date" form:"date" validate:"required,eventdate"`
+}
+
+// CreateTemplate stores a new event template for the authenticated user.
+func CreateTemplate(c *fiber.Ctx, s *store.Store) error {
+	template := new(store.Template)
+	if err := ParseBody(c, template); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(template); problems != nil {
+		return ValidationFailed(c, "Invalid template", problems)
+	}
+
+	// Defaults as for events created directly
+	if template.Priority == "" {
+		template.Priority = defaultPriority
+	}
+	if template.Channels == nil {
+		template.Channels = []string{}
+	}
+	template.Recipients = sortedList(template.Recipients)
+
+	var userID = getUserID(c)
+
+	if err := s.Templates.Create(c.UserContext(), userID, template); err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":   "created",
+		"template": template,
+		"message":  "Template created successfully",
+	})
+}
+
+// ListTemplates returns the event templates of the authenticated user, oldest first.
+func ListTemplates(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	templates, err := s.Templates.List(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":    "fetched",
+		"templates": templates,
+		"message":   "Templates fetched successfully",
+	})
+}
+
+// CreateEventFromTemplate creates an event on the given date with the message, priority, URL,
+// channels, and recipients of one of the user's templates.
+func CreateEventFromTemplate(c *fiber.Ctx, s *store.Store) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Template id must be an integer",
+		})
+	}
+
+	req := new(FromTemplateRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid event", problems)
+	}
+
+	var userID = getUserID(c)
+
+	template, err := s.Templates.Get(c.UserContext(), userID, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	// Dates without an offset are in the user's timezone, which also decides the date in the name.
+	// The eventdate rule already checked the layout, so this cannot fail.
+	loc := userLocation(c.UserContext(), s.Users, userID)
+	date, _ := parseEventDate(req.Date, loc)
+
+	event := &store.Event{
+		Name:       req.Name,
+		Date:       date.UTC().Format(time.RFC3339),
+		Message:    template.Message,
+		Priority:   template.Priority,
+		URL:        template.URL,
+		Channels:   template.Channels,
+		Recipients: template.Recipients,
+	}
+	if event.Name == "" {
+		event.Name = expandNamePattern(template.NamePattern, date.In(loc))
+	}
+	// The template was valid when it was stored, but limits such as the name length may have changed
+	if problems := ValidateStruct(event); problems != nil {
+		return ValidationFailed(c, "Invalid event", problems)
+	}
+
+	if err := s.Events.Create(c.UserContext(), userID, event); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An event with this name already exists",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	c.Location(eventPath + strconv.Itoa(event.ID))
+	c.Set(fiber.HeaderETag, eventETag(event))
+	return c.Status(201).JSON(fiber.Map{
+		"status":     "created",
+		"event_name": event.Name,
+		"event":      event,
+		"message":    "Event created successfully",
+	})
+}
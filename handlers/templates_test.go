@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestEventsFromTemplates(t *testing.T) {
+	previous := notificationChannels
+	t.Cleanup(func() { notificationChannels = previous })
+	SetNotificationChannels([]string{"email", "webhook"})
+
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	if err := s.Users.UpdateSettings(context.Background(), alice, "", "Asia/Tokyo", nil); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp()
+	app.Post("/templates", func(c *fiber.Ctx) error { return CreateTemplate(c, s) })
+	app.Get("/templates", func(c *fiber.Ctx) error { return ListTemplates(c, s) })
+	app.Post("/events/from-template/:id", func(c *fiber.Ctx) error { return CreateEventFromTemplate(c, s) })
+
+	resp := request(t, app, alice, "POST", "/templates", `{"name_pattern":"standup-{date}","message":"Daily standup","priority":"high",
+		"url":"https://meet.example.com/standup","channels":["email"],"recipients":["carol@example.com","bob@example.com"]}`)
+	expectStatus(t, resp, 201)
+	var created struct {
+		Template store.Template `json:"template"`
+	}
+	decode(t, resp, &created)
+	fromTemplate := "/events/from-template/" + strconv.Itoa(created.Template.ID)
+
+	// Templates are listed for their owner only
+	var listed struct {
+		Templates []store.Template `json:"templates"`
+	}
+	resp = request(t, app, alice, "GET", "/templates", "")
+	expectStatus(t, resp, 200)
+	decode(t, resp, &listed)
+	if len(listed.Templates) != 1 || listed.Templates[0].ID != created.Template.ID {
+		t.Fatalf("alice: got templates %+v, want the one she created", listed.Templates)
+	}
+	resp = request(t, app, bob, "GET", "/templates", "")
+	expectStatus(t, resp, 200)
+	decode(t, resp, &listed)
+	if len(listed.Templates) != 0 {
+		t.Fatalf("bob: got templates %+v, want none", listed.Templates)
+	}
+
+	// An event without a name is named after the date in the user's timezone, and takes every
+	// other field from the template
+	resp = request(t, app, alice, "POST", fromTemplate, `{"date":"2030-01-01T20:00:00Z"}`)
+	expectStatus(t, resp, 201)
+	event := getEvent(t, s, alice, "standup-2030-01-02")
+	if event.Date != "2030-01-01T20:00:00Z" || event.Message != "Daily standup" || event.Priority != "high" ||
+		event.URL != "https://meet.example.com/standup" || !reflect.DeepEqual(event.Channels, []string{"email"}) ||
+		!reflect.DeepEqual(event.Recipients, []string{"bob@example.com", "carol@example.com"}) {
+		t.Fatalf("got event %+v, want the fields of the template", event)
+	}
+	if location := resp.Header.Get(fiber.HeaderLocation); location != eventPath+strconv.Itoa(event.ID) {
+		t.Fatalf("got Location %q, want the new event", location)
+	}
+
+	// A name given overrides the pattern, and a date without an offset is in the user's timezone
+	expectStatus(t, request(t, app, alice, "POST", fromTemplate, `{"name":"kickoff","date":"2030-01-06 09:00"}`), 201)
+	if kickoff := getEvent(t, s, alice, "kickoff"); kickoff.Date != "2030-01-06T00:00:00Z" || kickoff.Message != "Daily standup" {
+		t.Fatalf("got event %+v, want it at 9:00 in Tokyo with the message of the template", kickoff)
+	}
+
+	expectStatus(t, request(t, app, alice, "POST", fromTemplate, `{"date":"2030-01-01T20:00:00Z"}`), 409)
+	expectStatus(t, request(t, app, alice, "POST", fromTemplate, `{"name":"no date"}`), 422)
+	expectStatus(t, request(t, app, bob, "POST", fromTemplate, `{"date":"2030-01-01T20:00:00Z"}`), 404)
+	expectStatus(t, request(t, app, alice, "POST", "/events/from-template/standup", `{"date":"2030-01-01T20:00:00Z"}`), 400)
+	expectStatus(t, request(t, app, alice, "POST", "/templates", `{"name_pattern":"stand up {date}","message":"Daily standup"}`), 422)
+	expectStatus(t, request(t, app, alice, "POST", "/templates", `{"name_pattern":"standup","message":"Daily standup","recipients":["nobody"]}`), 422)
+}
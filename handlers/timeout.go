@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// BaseContext returns middleware that derives the user context of every request from ctx, so that
+// cancelling ctx, e.g. on shutdown, aborts the database queries of requests still running.
+func BaseContext(ctx context.Context) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// RequestTimeout returns middleware that gives every request a deadline. The request's user context
+// is cancelled when the deadline passes, which aborts database queries made with it, and the client
+// receives a 503 instead of whatever the handler produced. Requests for the exempt paths, such as
+// long-lived WebSocket connections and streamed downloads, run without a deadline.
+func RequestTimeout(timeout time.Duration, exempt ...string) fiber.Handler {
+	skip := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		skip[path] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		if skip[c.Path()] {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Request timed out",
+			})
+		}
+		return err
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowRoute returns a handler that waits until its request context ends, or for a second, and
+// records the error of the context on cancelled.
+func slowRoute(cancelled chan<- error) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			cancelled <- c.UserContext().Err()
+		case <-time.After(time.Second):
+			cancelled <- nil
+		}
+		return c.SendString("done")
+	}
+}
+
+func TestRequestTimeoutCancelsSlowRequests(t *testing.T) {
+	cancelled := make(chan error, 1)
+	app := fiber.New()
+	app.Use(RequestTimeout(20*time.Millisecond, "/exempt"))
+	app.Get("/slow", slowRoute(cancelled))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/slow", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want 503", resp.StatusCode)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["message"] != "Request timed out" {
+		t.Fatalf("got body %v, %v", body, err)
+	}
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("request context ended with %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRequestTimeoutLetsFastAndExemptRequestsThrough(t *testing.T) {
+	app := fiber.New()
+	app.Use(RequestTimeout(200*time.Millisecond, "/exempt"))
+	app.Get("/fast", func(c *fiber.Ctx) error { return c.SendString("done") })
+	app.Get("/exempt", func(c *fiber.Ctx) error {
+		if _, ok := c.UserContext().Deadline(); ok {
+			return errors.New("exempt request has a deadline")
+		}
+		return c.SendString("done")
+	})
+
+	for _, path := range []string{"/fast", "/exempt"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"sync"
+	"time"
+)
+
+// versionCache keeps the token versions of recently active users, so that checking an access token
+// does not query the database on every request. A version raised on this instance is forgotten
+// right away; one raised on another instance takes effect here once the entry expires. A token
+// newer than the cached version always triggers a lookup, so fresh tokens work immediately.
+type versionCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[int]cachedVersion
+	lastSweep time.Time
+	// now reads the clock; tests replace it to control time
+	now func() time.Time
+}
+
+// cachedVersion is the token version of one user.
+type cachedVersion struct {
+	version int
+	expires time.Time
+}
+
+// Cache of token versions, configured at startup with SetTokenVersionTTL; disabled until then
+var tokenVersions = newVersionCache(0)
+
+// newVersionCache returns a cache keeping versions for ttl; a zero ttl disables it.
+func newVersionCache(ttl time.Duration) *versionCache {
+	return &versionCache{ttl: ttl, entries: map[int]cachedVersion{}, now: time.Now}
+}
+
+// SetTokenVersionTTL sets how long the token version of a user is cached; zero disables the cache,
+// checking every request against the database. It must be called before the server starts
+// handling requests.
+func SetTokenVersionTTL(ttl time.Duration) {
+	tokenVersions = newVersionCache(ttl)
+}
+
+// get returns the cached token version of a user, if it has not expired.
+func (vc *versionCache) get(userID int) (int, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	entry, ok := vc.entries[userID]
+	if !ok || !vc.now().Before(entry.expires) {
+		return 0, false
+	}
+	return entry.version, true
+}
+
+// put caches the token version of a user until the TTL passes.
+func (vc *versionCache) put(userID, version int) {
+	if vc.ttl <= 0 {
+		return
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	now := vc.now()
+	if now.Sub(vc.lastSweep) >= vc.ttl {
+		// Drop expired entries, at most once per TTL, so users who go quiet do not keep memory
+		vc.lastSweep = now
+		for id, entry := range vc.entries {
+			if !now.Before(entry.expires) {
+				delete(vc.entries, id)
+			}
+		}
+	}
+	vc.entries[userID] = cachedVersion{version: version, expires: now.Add(vc.ttl)}
+}
+
+// forget drops the cached token version of a user, after it was raised.
+func (vc *versionCache) forget(userID int) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	delete(vc.entries, userID)
+}
+
+// ForgetTokenVersion makes the next request of a user look up their token version again. Call it
+// after raising the version with store.UserStore.RevokeTokens.
+func ForgetTokenVersion(userID int) {
+	tokenVersions.forget(userID)
+}
+
+// tokenClaims returns the user id and token version in the claims of the JWT of a request. ok is
+// false for requests without a JWT and for tokens issued without a user_id claim.
+func tokenClaims(c *fiber.Ctx) (userID, version int, ok bool) {
+	token, isToken := c.Locals("user").(*jwt.Token)
+	if !isToken {
+		return 0, 0, false
+	}
+	claims, isMap := token.Claims.(jwt.MapClaims)
+	if !isMap {
+		return 0, 0, false
+	}
+
+	// JSON numbers in the claims decode as float64; tokens issued before versions existed have none
+	id, isNumber := claims["user_id"].(float64)
+	if !isNumber {
+		return 0, 0, false
+	}
+	ver, _ := claims["ver"].(float64)
+	return int(id), int(ver), true
+}
+
+// CheckTokenVersion returns middleware rejecting access tokens whose user was deleted or whose
+// version is older than the user's current one, i.e. tokens revoked by logging out everywhere or
+// changing the password. Tokens issued without a user_id claim cannot be revoked and are rejected
+// too. It runs after the JWT middleware; requests authenticated with an API key pass through.
+func CheckTokenVersion(s *store.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, isToken := c.Locals("user").(*jwt.Token); !isToken {
+			return c.Next()
+		}
+		userID, version, ok := tokenClaims(c)
+		if !ok {
+			return tokenRevoked(c)
+		}
+
+		current, cached := tokenVersions.get(userID)
+		if !cached || version > current {
+			user, err := s.Users.ByID(c.UserContext(), userID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return ServerError(c, err)
+			}
+			if err != nil {
+				return tokenRevoked(c)
+			}
+			current = user.TokenVersion
+			tokenVersions.put(userID, current)
+		}
+		if version != current {
+			return tokenRevoked(c)
+		}
+
+		c.Locals(userIDLocal, userID) // Attributes the log lines of the request to the user
+		return c.Next()
+	}
+}
+
+// tokenRevoked rejects a request whose access token is no longer valid.
+func tokenRevoked(c *fiber.Ctx) error {
+	return c.Status(401).JSON(fiber.Map{
+		"status":  "error",
+		"code":    "token_revoked",
+		"message": "Token has been revoked, log in again",
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+// ListTrash lists the user's deleted events, most recently deleted first. They stay in the trash,
+// restorable with RestoreEvent, until the purge removes them.
+func ListTrash(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	events, err := s.Events.Trash(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"count":   len(events),
+		"events":  events,
+		"message": "Trash fetched successfully",
+	})
+}
+
+// RestoreEvent takes one of the user's deleted events out of the trash by id. The event comes back
+// as it was deleted, with its recipients, tags, and reminders.
+func RestoreEvent(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	// The route only matches integer ids
+	id, _ := strconv.Atoi(c.Params("id"))
+	event, err := s.Events.Restore(c.UserContext(), userID, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+	eventLists.invalidate(listScope(c, s.Users, userID))
+
+	c.Set(fiber.HeaderETag, eventETag(event))
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "restored",
+		"event":   event,
+		"message": "Event restored successfully",
+	})
+}
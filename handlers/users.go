@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// usernameCheckDuration is the minimum time UsernameAvailable takes to answer, so the response
+// time does not depend on whether or how the name was found.
+const usernameCheckDuration = 100 * time.Millisecond
+
+// Profile struct defines the public view of a user account. It never carries the password hash.
+type Profile struct {
+	ID         int    `json:"id"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Timezone   string `json:"timezone"`
+	KeepEvents bool   `json:"keep_events"`
+	Role       string `json:"role"`
+}
+
+// Settings struct defines the user preferences that can be changed. Empty fields are left unchanged.
+// KeepEvents opts out of the purge of old events.
+type Settings struct {
+	Email      string `json:"email" form:"email" validate:"omitempty,max=255,address"`
+	Timezone   string `json:"timezone" form:"timezone" validate:"omitempty,tzname"`
+	KeepEvents *bool  `json:"keep_events" form:"keep_events"`
+}
+
+// NewProfile returns the public view of a user; the password hash is left out.
+func NewProfile(user *store.User) *Profile {
+	return &Profile{
+		ID:         user.ID,
+		Username:   user.Username,
+		Email:      user.Email,
+		Timezone:   user.Timezone,
+		KeepEvents: user.KeepEvents,
+		Role:       user.Role,
+	}
+}
+
+// loadProfile reads the public view of a user.
+func loadProfile(ctx context.Context, users store.UserStore, userID int) (*Profile, error) {
+	user, err := users.ByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return NewProfile(user), nil
+}
+
+// GetProfile returns the profile of the authenticated user.
+func GetProfile(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown user",
+		})
+	}
+
+	profile, err := loadProfile(c.UserContext(), s.Users, userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "fetched",
+		"user":    profile,
+		"message": "Profile fetched successfully",
+	})
+}
+
+// UpdateSettings changes the email, timezone, and/or purge opt-out of the authenticated user.
+func UpdateSettings(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown user",
+		})
+	}
+
+	settings := new(Settings)
+	// Parse the request body (JSON or form-encoded) into the settings struct
+	if err := ParseBody(c, settings); err != nil {
+		return invalidBody(c, err)
+	}
+
+	// Only the provided fields are validated and updated
+	if settings.Email == "" && settings.Timezone == "" && settings.KeepEvents == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No settings provided",
+		})
+	}
+	if problems := ValidateStruct(settings); problems != nil {
+		return ValidationFailed(c, "Invalid settings", problems)
+	}
+
+	if err := s.Users.UpdateSettings(c.UserContext(), userID, settings.Email, settings.Timezone, settings.KeepEvents); err != nil {
+		return ServerError(c, err)
+	}
+
+	// Return the stored settings, including fields that were left unchanged
+	user, err := s.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+	settings.Email = user.Email
+	settings.Timezone = user.Timezone
+	settings.KeepEvents = &user.KeepEvents
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "updated",
+		"settings": settings,
+		"message":  "Settings updated successfully",
+	})
+}
+
+// UsernameQuery struct defines the query of a username availability check.
+type UsernameQuery struct {
+	Username string `json:"username" validate:"required,username"`
+}
+
+// UsernameAvailable reports whether a username, normalized as on signup, can still be registered.
+func UsernameAvailable(c *fiber.Ctx, s *store.Store) error {
+	// Every answer, including errors, waits for the same minimum duration
+	defer func(start time.Time) {
+		time.Sleep(usernameCheckDuration - time.Since(start))
+	}(time.Now())
+
+	query := UsernameQuery{Username: NormalizeUsername(c.Query("username"))}
+	if problems := ValidateStruct(&query); problems != nil {
+		return ValidationFailed(c, "Invalid username", problems)
+	}
+
+	_, err := s.Users.ByUsername(c.UserContext(), query.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"available": errors.Is(err, store.ErrNotFound),
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsernameAvailable(t *testing.T) {
+	s := store.NewMemory()
+	if _, err := s.Users.Create(context.Background(), "alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get("/username-available", func(c *fiber.Ctx) error { return UsernameAvailable(c, s) })
+
+	tests := []struct {
+		username  string
+		status    int
+		available bool
+	}{
+		{"bob", 200, true},
+		{"alice", 200, false},
+		// Normalized as on signup
+		{"  Alice ", 200, false},
+		{"", 422, false},
+		{"no spaces", 422, false},
+		{"ab", 422, false},
+	}
+	for _, test := range tests {
+		start := time.Now()
+		resp, err := app.Test(httptest.NewRequest("GET", "/username-available?username="+url.QueryEscape(test.username), nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < usernameCheckDuration {
+			t.Errorf("%q: answered after %s, before the minimum of %s", test.username, elapsed, usernameCheckDuration)
+		}
+		if resp.StatusCode != test.status {
+			t.Fatalf("%q: got status %d, want %d", test.username, resp.StatusCode, test.status)
+		}
+		if test.status != 200 {
+			continue
+		}
+
+		var body struct {
+			Available bool `json:"available"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Available != test.available {
+			t.Errorf("%q: got available %t, want %t", test.username, body.Available, test.available)
+		}
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Get("/me", func(c *fiber.Ctx) error { return GetProfile(c, s) })
+
+	resp := request(t, app, alice, "GET", "/me", "")
+	expectStatus(t, resp, 200)
+	var body struct {
+		User map[string]interface{} `json:"user"`
+	}
+	decode(t, resp, &body)
+	if body.User["id"] != float64(alice) || body.User["username"] != "alice" || body.User["role"] != store.RoleUser {
+		t.Fatalf("got %v, want the profile of alice", body.User)
+	}
+	for field := range body.User {
+		if strings.Contains(strings.ToLower(field), "password") {
+			t.Fatalf("profile exposes %s", field)
+		}
+	}
+
+	expectStatus(t, request(t, app, 0, "GET", "/me", ""), 401)
+}
+
+func TestUpdateSettings(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Put("/settings", func(c *fiber.Ctx) error { return UpdateSettings(c, s) })
+
+	// update changes the settings of alice and returns the settings the response reports
+	update := func(body string) Settings {
+		t.Helper()
+		resp := request(t, app, alice, "PUT", "/settings", body)
+		expectStatus(t, resp, 200)
+		var answer struct {
+			Settings Settings `json:"settings"`
+		}
+		decode(t, resp, &answer)
+		return answer.Settings
+	}
+
+	if settings := update(`{"email":"alice@example.com"}`); settings.Email != "alice@example.com" || settings.Timezone != "UTC" {
+		t.Fatalf("after updating the email: got %+v", settings)
+	}
+	if settings := update(`{"timezone":"Europe/Paris"}`); settings.Email != "alice@example.com" || settings.Timezone != "Europe/Paris" {
+		t.Fatalf("after updating the timezone: got %+v", settings)
+	}
+	user, err := s.Users.ByID(context.Background(), alice)
+	if err != nil || user.Email != "alice@example.com" || user.Timezone != "Europe/Paris" {
+		t.Fatalf("stored settings: got %+v, %v", user, err)
+	}
+
+	for _, body := range []string{`{"timezone":"Mars/Olympus_Mons"}`, `{"email":"not an address"}`} {
+		expectStatus(t, request(t, app, alice, "PUT", "/settings", body), 422)
+	}
+	expectStatus(t, request(t, app, alice, "PUT", "/settings", `{}`), 400)
+	if user, _ := s.Users.ByID(context.Background(), alice); user.Email != "alice@example.com" || user.Timezone != "Europe/Paris" {
+		t.Fatalf("rejected updates changed the settings: got %+v", user)
+	}
+}
@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/rrule"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"net/mail"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Priority applied to events created without one; the allowed values are listed in the
+// oneof rule of store.Event
+const defaultPriority = "normal"
+
+// Event priorities from the least to the most urgent, as accepted by the priority filter of ListEvents
+var priorities = []string{"low", "normal", "high", "urgent"}
+
+// isPriority reports whether priority is one of priorities.
+func isPriority(priority string) bool {
+	for _, known := range priorities {
+		if priority == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event names are used as URL path params (/event/:name), so only characters that need no escaping are allowed.
+var eventNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Default and upper bound for the event name length; the upper bound matches the events.name column.
+const (
+	defaultEventNameLength = 64
+	maxEventNameColumn     = 255
+)
+
+// Maximum event name length, configurable via the EVENT_NAME_MAX_LENGTH environment variable
+var maxEventNameLength = loadMaxEventNameLength()
+
+// loadMaxEventNameLength reads EVENT_NAME_MAX_LENGTH, falling back to the default when unset or out of range.
+func loadMaxEventNameLength() int {
+	n, err := strconv.Atoi(os.Getenv("EVENT_NAME_MAX_LENGTH"))
+	if err != nil || n <= 0 || n > maxEventNameColumn {
+		return defaultEventNameLength
+	}
+	return n
+}
+
+// validateEventName checks that an event name is non-empty, within the configured length,
+// and only contains letters, digits, dashes, and underscores.
+func validateEventName(name string) error {
+	if name == "" {
+		return fmt.Errorf("event name is required")
+	}
+	if len(name) > maxEventNameLength {
+		return fmt.Errorf("event name must be at most %d characters", maxEventNameLength)
+	}
+	if !eventNamePattern.MatchString(name) {
+		return fmt.Errorf("event name may only contain letters, digits, dashes, and underscores")
+	}
+	return nil
+}
+
+// Placeholder in template name patterns replaced by the date of the event, as YYYY-MM-DD
+const datePlaceholder = "{date}"
+
+// expandNamePattern returns the name of an event created from a template on date.
+func expandNamePattern(pattern string, date time.Time) string {
+	return strings.ReplaceAll(pattern, datePlaceholder, date.Format("2006-01-02"))
+}
+
+// validateNamePattern checks that a template's name pattern yields valid event names.
+func validateNamePattern(pattern string) error {
+	if err := validateEventName(expandNamePattern(pattern, time.Time{})); err != nil {
+		return fmt.Errorf("name pattern must yield a valid event name: %v", err)
+	}
+	return nil
+}
+
+// validateRecurrence checks that a recurrence rule is empty or a rule the scheduler can expand.
+func validateRecurrence(rule string) error {
+	if rule == "" {
+		return nil
+	}
+	if _, err := rrule.Parse(rule); err != nil {
+		return fmt.Errorf("recurrence must be a recurrence rule such as FREQ=WEEKLY;BYDAY=MO: %v", err)
+	}
+	return nil
+}
+
+// canonicalRecurrence rewrites a valid recurrence rule in canonical form, so shorthands such as
+// "weekly" are stored as FREQ=WEEKLY. Empty rules and nil are returned unchanged.
+func canonicalRecurrence(rule *string) *string {
+	if rule == nil || *rule == "" {
+		return rule
+	}
+	parsed, err := rrule.Parse(*rule)
+	if err != nil {
+		return rule
+	}
+	canonical := parsed.String()
+	return &canonical
+}
+
+// Usernames are stored lowercased, so only lowercase letters are allowed after normalization.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]+$`)
+
+// Length bounds for usernames
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 64
+)
+
+// NormalizeUsername trims surrounding whitespace and lowercases a username, so that names
+// differing only in case belong to the same account.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// validateUsername checks that a normalized username is 3 to 64 characters of letters, digits,
+// dots, dashes, and underscores.
+func validateUsername(username string) error {
+	if len(username) < minUsernameLength || len(username) > maxUsernameLength {
+		return fmt.Errorf("username must be between %d and %d characters", minUsernameLength, maxUsernameLength)
+	}
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("username may only contain letters, digits, dots, dashes, and underscores")
+	}
+	return nil
+}
+
+// Minimum length of new passwords in characters
+const minPasswordLength = 8
+
+// Maximum length of new passwords in bytes, the most bcrypt hashes. It counts bytes rather than
+// characters, so a password of multibyte characters reaches it sooner.
+const maxPasswordBytes = 72
+
+// validatePassword checks that a new password is at least 8 characters and at most 72 bytes long
+// and mixes letters with digits or symbols. Existing passwords are not checked, so weaker ones keep
+// working.
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+
+	var letters, others bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letters = true
+		} else {
+			others = true
+		}
+	}
+	if !letters || !others {
+		return fmt.Errorf("password must contain letters and at least one digit or symbol")
+	}
+	return nil
+}
+
+// Tags are stored lowercased and used as URL path params (/tags/:tag), like event names.
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Longest tag, matching the event_tags.tag column
+const maxTagLength = 32
+
+// normalizeTag trims surrounding whitespace and lowercases a tag, so that tags differing only in
+// case are the same tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normalizes every tag of an event. A nil list stays nil, which updates take as
+// leaving the tags unchanged.
+func normalizeTags(tags []string) []string {
+	for i, tag := range tags {
+		tags[i] = normalizeTag(tag)
+	}
+	return tags
+}
+
+// validateTag checks that a normalized tag is 1 to 32 characters of letters, digits, dashes, and
+// underscores.
+func validateTag(tag string) error {
+	if tag == "" || len(tag) > maxTagLength {
+		return fmt.Errorf("tag must be between 1 and %d characters", maxTagLength)
+	}
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("tag may only contain letters, digits, dashes, and underscores")
+	}
+	return nil
+}
+
+// Notification channels events may select, registered at startup with SetNotificationChannels
+var notificationChannels = map[string]bool{}
+
+// SetNotificationChannels sets the channel names events may select, normally those of the
+// scheduler's notifiers. It must be called before the server starts handling requests.
+func SetNotificationChannels(channels []string) {
+	notificationChannels = make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		notificationChannels[channel] = true
+	}
+}
+
+// validateChannel checks that channel is a registered notification channel.
+func validateChannel(channel string) error {
+	if !notificationChannels[channel] {
+		return fmt.Errorf("unknown notification channel %q", channel)
+	}
+	return nil
+}
+
+// validateShift checks that shift is a non-zero Go duration such as 24h or -30m.
+func validateShift(shift string) error {
+	if d, err := time.ParseDuration(shift); err != nil || d == 0 {
+		return fmt.Errorf("shift must be a non-zero duration such as 24h or -30m")
+	}
+	return nil
+}
+
+// validateEmail checks that email is a bare address such as user@example.com, without a display name.
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("email must be a valid address")
+	}
+	return nil
+}
+
+// validateTimezone checks that timezone names an IANA time zone such as Europe/Berlin.
+func validateTimezone(timezone string) error {
+	// "Local" would resolve to the server's zone rather than the user's
+	if timezone == "Local" {
+		return fmt.Errorf("timezone must be an IANA time zone name")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("timezone must be an IANA time zone name")
+	}
+	return nil
+}
+
+// Custom validate tags, each backed by a check whose error message is reported for the field
+var customRules = map[string]func(string) error{
+	"eventname":   validateEventName,
+	"namepattern": validateNamePattern,
+	"eventdate": func(date string) error {
+		_, err := parseEventDate(date, time.UTC)
+		return err
+	},
+	"recurrence": validateRecurrence,
+	"channel":    validateChannel,
+	"shift":      validateShift,
+	"snooze":     validateSnooze,
+	"offset": func(before string) error {
+		_, err := reminderOffset(before)
+		return err
+	},
+	"username":     validateUsername,
+	"password":     validatePassword,
+	"address":      validateEmail,
+	"tzname":       validateTimezone,
+	"tag":          validateTag,
+	"slackwebhook": validateSlackWebhook,
+	"slacktoken":   validateSlackToken,
+	"phone":        validatePhone,
+}
+
+// validate checks request structs against their validate tags
+var validate = newValidator()
+
+// newValidator returns a validator that knows the custom tags and reports fields by their JSON name.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+	for tag, check := range customRules {
+		check := check
+		v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return check(fl.Field().String()) == nil
+		})
+	}
+	return v
+}
+
+// FieldError describes why one field of a request failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationFailed returns the 422 response for a request whose fields failed validation, listing
+// the problems of each field. Malformed bodies get a 400 from ParseBody instead.
+func ValidationFailed(c *fiber.Ctx, message string, problems []FieldError) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"status":  "error",
+		"errors":  problems,
+		"message": message,
+	})
+}
+
+// ValidateStruct checks v, a pointer to a struct, against its validate tags and returns one error
+// per invalid field, or nil if every field is valid.
+func ValidateStruct(v interface{}) []FieldError {
+	return validateFields(v, false)
+}
+
+// validatePatch is ValidateStruct for partial updates, where empty fields are left unchanged:
+// required rules are skipped, all other rules apply to the fields that are given.
+func validatePatch(v interface{}) []FieldError {
+	return validateFields(v, true)
+}
+
+// validateFields runs the validator on v, skipping failed required rules if partial is set.
+func validateFields(v interface{}, partial bool) []FieldError {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		// Only reachable by passing something other than a struct, which is a programming error
+		panic(err)
+	}
+
+	var problems []FieldError
+	for _, fe := range invalid {
+		if partial && fe.Tag() == "required" {
+			continue
+		}
+		problems = append(problems, FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)})
+	}
+	return problems
+}
+
+// fieldPath returns the JSON path of an invalid field, such as "channels[1]".
+func fieldPath(fe validator.FieldError) string {
+	// The namespace starts with the struct's Go name, which clients never see
+	namespace := fe.Namespace()
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
+
+// fieldMessage describes a failed rule in the same words the handlers used before tags existed.
+func fieldMessage(fe validator.FieldError) string {
+	field := fieldPath(fe)
+	if check, ok := customRules[fe.Tag()]; ok {
+		if err := check(fmt.Sprint(fe.Value())); err != nil {
+			return err.Error()
+		}
+	}
+
+	switch fe.Tag() {
+	case "required", "required_if", "required_with":
+		return field + " is required"
+	case "min", "max":
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("%s must have %s %s entries", field, bound, fe.Param())
+		}
+		return fmt.Sprintf("%s must be %s %s characters", field, bound, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(strings.Fields(fe.Param()), ", "))
+	case "http_url":
+		return field + " must be an absolute http or https URL"
+	case "unique":
+		return field + " must not contain duplicates"
+	}
+	return field + " is invalid"
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateEventName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"standup", true},
+		{"Team_review-2030", true},
+		{strings.Repeat("a", maxEventNameLength), true},
+		{"", false},
+		{strings.Repeat("a", maxEventNameLength+1), false},
+		{"team review", false},
+		{"a/b", false},
+		{"../admin", false},
+		{"café", false},
+		{"name?x=1", false},
+	}
+	for _, test := range tests {
+		if err := validateEventName(test.name); (err == nil) != test.valid {
+			t.Errorf("%q: got %v, want valid %t", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"correct-horse", true},
+		{strings.Repeat("a", maxPasswordBytes-1) + "1", true},
+		{strings.Repeat("日", 23) + "1", true},
+		{"short-1", false},
+		{"onlyletters", false},
+		{"12345678", false},
+		{strings.Repeat("a", maxPasswordBytes) + "1", false},
+		// Within 72 characters, but not within 72 bytes
+		{strings.Repeat("日", 29) + "1", false},
+	}
+	for _, test := range tests {
+		if err := validatePassword(test.password); (err == nil) != test.valid {
+			t.Errorf("%q: got %v, want valid %t", test.password, err, test.valid)
+		}
+	}
+}
+
+func TestCreateEventRejectsInvalidNames(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+
+	for _, name := range []string{"team review", "a/b", strings.Repeat("a", maxEventNameLength+1)} {
+		resp := request(t, app, alice, "POST", "/event", `{"name":"`+name+`","message":"Weekly review","date":"2030-01-07T09:00:00Z"}`)
+		expectStatus(t, resp, 422)
+		var body struct {
+			Errors []FieldError `json:"errors"`
+		}
+		decode(t, resp, &body)
+		if len(body.Errors) != 1 || body.Errors[0].Field != "name" || body.Errors[0].Message != validateEventName(name).Error() {
+			t.Fatalf("name %q: got %+v, want one error for the name", name, body.Errors)
+		}
+	}
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"team_review-1","message":"Weekly review","date":"2030-01-07T09:00:00Z"}`), 201)
+}
+
+func TestValidateEvent(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/events/validate", ValidateEvent)
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+
+	resp := request(t, app, alice, "POST", "/events/validate", `{"name":"standup","message":"Daily standup","date":"2030-01-01T09:00:00Z","recurrence":"FREQ=DAILY"}`)
+	expectStatus(t, resp, 200)
+	var valid struct {
+		Valid bool `json:"valid"`
+	}
+	decode(t, resp, &valid)
+	if !valid.Valid {
+		t.Fatal("valid event: got valid false")
+	}
+
+	for _, test := range []struct{ body, field string }{
+		{`{"name":"standup","date":"2030-01-01T09:00:00Z"}`, "message"},
+		{`{"name":"standup","message":"Daily standup","date":"tomorrow"}`, "date"},
+		{`{"name":"daily standup","message":"Daily standup","date":"2030-01-01T09:00:00Z"}`, "name"},
+		{`{"name":"standup","message":"Daily standup","date":"2030-01-01T09:00:00Z","recurrence":"FREQ=SOMETIMES"}`, "recurrence"},
+	} {
+		resp := request(t, app, alice, "POST", "/events/validate", test.body)
+		expectStatus(t, resp, 422)
+		var answer struct {
+			Valid  bool         `json:"valid"`
+			Errors []FieldError `json:"errors"`
+		}
+		decode(t, resp, &answer)
+		if answer.Valid || len(answer.Errors) != 1 || answer.Errors[0].Field != test.field {
+			t.Fatalf("%s: got %+v, want an error for %s", test.body, answer, test.field)
+		}
+
+		// CreateEvent rejects the payload with the same problems
+		resp = request(t, app, alice, "POST", "/event", test.body)
+		expectStatus(t, resp, 422)
+		var created struct {
+			Errors []FieldError `json:"errors"`
+		}
+		decode(t, resp, &created)
+		if len(created.Errors) != 1 || created.Errors[0] != answer.Errors[0] {
+			t.Fatalf("%s: CreateEvent reported %+v, the validation %+v", test.body, created.Errors, answer.Errors)
+		}
+	}
+
+	// Validating stores nothing
+	if events, _, err := s.Events.List(context.Background(), alice, store.EventFilter{}, store.Page{Limit: 10}); err != nil || len(events) != 0 {
+		t.Fatalf("got events %+v, %v, want none", events, err)
+	}
+}
+
+func TestCreateEventValidatesChannels(t *testing.T) {
+	previous := notificationChannels
+	t.Cleanup(func() { notificationChannels = previous })
+	SetNotificationChannels([]string{"email", "webhook"})
+
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+
+	resp := request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-01T09:00:00Z","channels":["email","pager"]}`)
+	expectStatus(t, resp, 422)
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	decode(t, resp, &body)
+	if len(body.Errors) != 1 || body.Errors[0].Field != "channels[1]" {
+		t.Fatalf("got %+v, want one error for channels[1]", body.Errors)
+	}
+
+	expectStatus(t, request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-01T09:00:00Z","channels":["email","webhook"]}`), 201)
+	event, err := s.Events.Get(context.Background(), alice, "standup")
+	if err != nil || !reflect.DeepEqual(event.Channels, []string{"email", "webhook"}) {
+		t.Fatalf("got channels %v, %v, want [email webhook]", event.Channels, err)
+	}
+}
+
+func TestCreateEventValidatesRecipients(t *testing.T) {
+	s := store.NewMemory()
+	alice := createUser(t, s, "alice")
+	app := newTestApp()
+	app.Post("/event", func(c *fiber.Ctx) error { return CreateEvent(c, s) })
+
+	// create posts the standup with the given recipients, a JSON list
+	create := func(recipients string) *http.Response {
+		t.Helper()
+		return request(t, app, alice, "POST", "/event", `{"name":"standup","message":"Daily standup","date":"2030-01-01T09:00:00Z","recipients":`+recipients+`}`)
+	}
+
+	many := make([]string, 11)
+	for i := range many {
+		many[i] = fmt.Sprintf(`"member%d@example.com"`, i)
+	}
+	for _, test := range []struct {
+		recipients string
+		field      string
+	}{
+		{`["bob@example.com","not an address"]`, "recipients[1]"},
+		{`["bob@example.com","bob@example.com"]`, "recipients"},
+		{"[" + strings.Join(many, ",") + "]", "recipients"},
+	} {
+		resp := create(test.recipients)
+		expectStatus(t, resp, 422)
+		var body struct {
+			Errors []FieldError `json:"errors"`
+		}
+		decode(t, resp, &body)
+		if len(body.Errors) != 1 || body.Errors[0].Field != test.field {
+			t.Fatalf("%s: got %+v, want one error for %s", test.recipients, body.Errors, test.field)
+		}
+	}
+	if _, err := s.Events.Get(context.Background(), alice, "standup"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("got %v, want no event stored", err)
+	}
+
+	// Valid recipients are stored in alphabetical order
+	expectStatus(t, create(`["dave@example.com","bob@example.com","carol@example.com"]`), 201)
+	want := []string{"bob@example.com", "carol@example.com", "dave@example.com"}
+	if event := getEvent(t, s, alice, "standup"); !reflect.DeepEqual(event.Recipients, want) {
+		t.Fatalf("got recipients %v, want %v", event.Recipients, want)
+	}
+}
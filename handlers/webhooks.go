@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+// Prefix of every generated webhook secret
+const webhookSecretPrefix = "whsec_"
+
+// Most webhooks a user may register; every one is called for each reminder
+const maxWebhooks = 10
+
+// WebhookRequest struct defines the body of a webhook registration request.
+type WebhookRequest struct {
+	URL string `json:"url" form:"url" validate:"required,max=2048,http_url"`
+}
+
+// newWebhookSecret generates a random secret for signing the calls of a webhook.
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// CreateWebhook registers a URL that is POSTed to whenever one of the user's reminders fires.
+// The secret signing the calls is only returned in this response.
+func CreateWebhook(c *fiber.Ctx, s *store.Store) error {
+	req := new(WebhookRequest)
+	if err := ParseBody(c, req); err != nil {
+		return invalidBody(c, err)
+	}
+	if problems := ValidateStruct(req); problems != nil {
+		return ValidationFailed(c, "Invalid webhook", problems)
+	}
+
+	var userID = getUserID(c)
+
+	webhooks, err := s.Webhooks.List(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+	if len(webhooks) >= maxWebhooks {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": fmt.Sprintf("at most %d webhooks may be registered", maxWebhooks),
+		})
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	created, err := s.Webhooks.Create(c.UserContext(), userID, req.URL, secret)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "created",
+		"webhook": created,
+		"message": "Webhook created, store the secret now as it won't be shown again",
+	})
+}
+
+// ListWebhooks returns the webhooks of the authenticated user without their secrets.
+func ListWebhooks(c *fiber.Ctx, s *store.Store) error {
+	var userID = getUserID(c)
+
+	webhooks, err := s.Webhooks.List(c.UserContext(), userID)
+	if err != nil {
+		return ServerError(c, err)
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":   "fetched",
+		"webhooks": webhooks,
+		"message":  "Webhooks fetched successfully",
+	})
+}
+
+// DeleteWebhook removes one of the authenticated user's webhooks together with its delivery log.
+func DeleteWebhook(c *fiber.Ctx, s *store.Store) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "webhook id must be an integer",
+		})
+	}
+
+	var userID = getUserID(c)
+
+	if err := s.Webhooks.Delete(c.UserContext(), userID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListWebhookDeliveries retrieves the log of calls of one of the user's webhooks one page at a
+// time, newest first, with the status each call was answered with.
+func ListWebhookDeliveries(c *fiber.Ctx, s *store.Store) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "webhook id must be an integer",
+		})
+	}
+
+	p, err := parsePage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	var userID = getUserID(c)
+
+	attempts, hasMore, err := s.Webhooks.Attempts(c.UserContext(), userID, id, p)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Record not found",
+			})
+		}
+		return ServerError(c, err)
+	}
+
+	total, err := s.Webhooks.CountAttempts(c.UserContext(), id)
+	if err != nil {
+		return ServerError(c, err)
+	}
+
+	response := fiber.Map{
+		"status":     "fetched",
+		"count":      len(attempts),
+		"deliveries": attempts,
+		"limit":      p.Limit,
+		"message":    "Webhook deliveries fetched successfully",
+	}
+	var nextCursor int
+	if p.Keyset {
+		// next_cursor is only present when more rows exist; pass it back as after_id
+		if hasMore {
+			nextCursor = attempts[len(attempts)-1].ID
+			response["next_cursor"] = nextCursor
+		}
+	} else {
+		response["offset"] = p.Offset
+		response["has_more"] = hasMore
+	}
+
+	setPageHeaders(c, p, total, hasMore, nextCursor, nil)
+	return c.Status(200).JSON(response)
+}
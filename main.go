@@ -1,17 +1,35 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/Vansh3140/Reminder-App/database"
 	"github.com/Vansh3140/Reminder-App/handlers"
+	"github.com/Vansh3140/Reminder-App/metrics"
+	"github.com/Vansh3140/Reminder-App/notify"
+	"github.com/Vansh3140/Reminder-App/password"
+	"github.com/Vansh3140/Reminder-App/scheduler"
+	"github.com/Vansh3140/Reminder-App/store"
 	jwtware "github.com/gofiber/contrib/jwt"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"log"
+	"log/slog"
+	"net/mail"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -19,16 +37,180 @@ import (
 // Application version
 const version = "1.0.0"
 
+// Application name reported on the root path; APP_NAME overrides it
+var appName = loadAppName()
+
+// Signals that shut the server down gracefully. SIGTSTP (Ctrl-Z) is deliberately left out, so it
+// suspends the process as usual and SIGCONT (e.g. fg) resumes it.
+var shutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+
 // Secret key for signing JWT tokens
 var secretKey = []byte(os.Getenv("SECRET_KEY"))
 
+// Keys accepted when verifying JWT tokens: the current secret key followed by the comma-separated
+// keys in SECRET_KEY_PREVIOUS, so tokens signed before a key rotation stay valid until they expire
+var verificationKeys = loadVerificationKeys(secretKey, os.Getenv("SECRET_KEY_PREVIOUS"))
+
+// Prefixes of every refresh token and password reset token, which make leaked tokens easy to recognise
+const (
+	refreshTokenPrefix       = "rt_"
+	passwordResetTokenPrefix = "pr_"
+)
+
+// Lifetimes of issued tokens, set from ACCESS_TOKEN_TTL, REFRESH_TOKEN_TTL and PASSWORD_RESET_TTL
+var tokens = tokenConfig{AccessTTL: 15 * time.Minute, RefreshTTL: 30 * 24 * time.Hour, ResetTTL: time.Hour}
+
+// Mailer of password reset tokens, set when SMTP_HOST is configured
+var resetMailer *notify.Email
+
+// Page where users choose a new password, from PASSWORD_RESET_URL. Reset emails link to it with the
+// token in the token query parameter; without it they carry the bare token.
+var resetURL *url.URL
+
+// Hasher for new passwords, selected by PASSWORD_HASH. Hashes of the other algorithms still verify.
+var passwordHasher password.Hasher = password.Bcrypt{Cost: bcrypt.DefaultCost}
+
 // Credentials struct to parse login and signup requests
+// Signup checks the validate tags, including the strength of the password; bcrypt hashes at most
+// 72 bytes of a password, and the same limit applies to argon2id so that accounts can move between
+// the algorithms.
 type Credentials struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,username"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// RefreshRequest struct to parse refresh and logout requests
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" validate:"required"`
+}
+
+// authRateLimits struct holds how many requests to /login and /signup are allowed per Window from
+// each client IP, and how many failed ones for each username. Zero disables a limit.
+type authRateLimits struct {
+	PerIP       int
+	PerUsername int
+	Window      time.Duration
+}
+
+// tokenConfig struct holds how long access tokens, refresh tokens and password reset tokens stay valid.
+type tokenConfig struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	ResetTTL   time.Duration
 }
 
 func main() {
+	// Log structured lines from the start, so that configuration errors are logged the same way.
+	// The standard logger writes through it as well.
+	logger, err := loadLogger()
+	if err != nil {
+		log.Fatal("Invalid logging configuration: ", err)
+	}
+	slog.SetDefault(logger)
+
+	// Load the password hashing algorithm before any password is hashed
+	hasher, err := loadPasswordHasher()
+	if err != nil {
+		log.Fatal("Invalid password hashing configuration: ", err)
+	}
+	passwordHasher = hasher
+
+	// Resolve how long access and refresh tokens stay valid
+	if tokens, err = loadTokenConfig(); err != nil {
+		log.Fatal("Invalid token configuration: ", err)
+	}
+
+	// Resolve how often the scheduler looks for due reminders and retries deliveries
+	schedulerConfig, err := loadSchedulerConfig()
+	if err != nil {
+		log.Fatal("Invalid scheduler configuration: ", err)
+	}
+
+	// Resolve how long event lists are cached
+	listCacheTTL, err := loadListCacheTTL()
+	if err != nil {
+		log.Fatal("Invalid list cache configuration: ", err)
+	}
+	handlers.SetListCacheTTL(listCacheTTL)
+
+	// Resolve how long token versions are cached
+	tokenVersionTTL, err := loadTokenVersionTTL()
+	if err != nil {
+		log.Fatal("Invalid token version cache configuration: ", err)
+	}
+	handlers.SetTokenVersionTTL(tokenVersionTTL)
+
+	// Resolve whether and how often old events are purged
+	purgeConfig, err := loadPurgeConfig()
+	if err != nil {
+		log.Fatal("Invalid purge configuration: ", err)
+	}
+
+	// Resolve the deadline for handling a single request
+	requestTimeout, err := loadDuration("REQUEST_TIMEOUT", 30*time.Second)
+	if err != nil {
+		log.Fatal("Invalid request timeout configuration: ", err)
+	}
+
+	// Resolve how long requests in flight may take to finish on shutdown
+	shutdownTimeout, err := loadDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+	if err != nil {
+		log.Fatal("Invalid shutdown timeout configuration: ", err)
+	}
+
+	// Resolve the response compression level
+	compressLevel, err := loadCompressLevel()
+	if err != nil {
+		log.Fatal("Invalid compression configuration: ", err)
+	}
+
+	// Resolve how many login and signup attempts clients may make
+	authLimits, err := loadAuthRateLimits()
+	if err != nil {
+		log.Fatal("Invalid rate limit configuration: ", err)
+	}
+
+	// Resolve the page linked from password reset emails
+	if resetURL, err = loadResetURL(); err != nil {
+		log.Fatal("Invalid password reset configuration: ", err)
+	}
+
+	// Resolve the mail server for email reminders
+	smtpConfig, err := loadSMTPConfig()
+	if err != nil {
+		log.Fatal("Invalid SMTP configuration: ", err)
+	}
+
+	// Resolve the credentials of the push services for push reminders
+	pushConfig, err := loadPushConfig()
+	if err != nil {
+		log.Fatal("Invalid push configuration: ", err)
+	}
+
+	// Resolve the bot for Telegram reminders
+	telegramConfig, err := loadTelegramConfig()
+	if err != nil {
+		log.Fatal("Invalid Telegram configuration: ", err)
+	}
+
+	// Resolve the Slack app whose buttons snooze and dismiss Slack reminders
+	slackConfig, err := loadSlackConfig()
+	if err != nil {
+		log.Fatal("Invalid Slack configuration: ", err)
+	}
+
+	// Resolve the SMS provider for SMS reminders and phone verification codes
+	smsSender, err := loadSMSSender()
+	if err != nil {
+		log.Fatal("Invalid SMS configuration: ", err)
+	}
+
+	// Resolve whether request and response bodies are logged
+	logBodies, err := loadBool("LOG_BODIES")
+	if err != nil {
+		log.Fatal("Invalid logging configuration: ", err)
+	}
+
 	// Connect to the database
 	db, err := database.Connect()
 	if err != nil {
@@ -36,45 +218,514 @@ func main() {
 	}
 	defer db.Close()
 
+	// Repositories used by the handlers
+	st := store.NewSQL(db)
+
+	// Share rate limits between instances through Redis, when configured; otherwise each instance
+	// counts requests on its own
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		storage, err := handlers.NewRedisStorage(redisURL)
+		if err != nil {
+			log.Fatal("Invalid Redis configuration: ", err)
+		}
+		defer storage.Close()
+		handlers.SetRateLimitStorage(storage)
+	}
+
+	// Export the connection pool and the depth of the retry queue with the other metrics
+	metrics.RegisterDB(db.DB)
+	metrics.RegisterPendingDeliveries(st.Deliveries.Pending)
+
+	// Fire due reminders and push them to connected WebSocket clients
+	hub := handlers.NewHub()
+	sched := scheduler.New(st.Events, st.Reminders, st.Deliveries, schedulerConfig)
+	sched.Subscribe(hub.Push)
+
+	// Email reminders to users who registered an address, when a mail server is configured.
+	// Password reset tokens go out the same way.
+	if smtpConfig.Host != "" {
+		email := notify.NewEmail(smtpConfig)
+		sched.AddNotifier(email)
+		resetMailer = email
+	}
+	// Call the webhooks users registered; users without webhooks are skipped
+	sched.AddNotifier(notify.NewWebhook(st.Webhooks))
+
+	// Push reminders to the devices users registered, when a push service is configured
+	push, err := notify.NewPush(st.Devices, pushConfig)
+	if err != nil {
+		log.Fatal("Invalid push configuration: ", err)
+	}
+	if len(push.Platforms()) > 0 {
+		sched.AddNotifier(push)
+	}
+
+	// Message reminders to the Telegram chats users linked, and run the bot that links chats and
+	// creates events, when a bot is configured
+	var telegram *notify.Telegram
+	var telegramBot handlers.TelegramBot
+	if telegramConfig.Token != "" {
+		telegram = notify.NewTelegram(st.Telegram, telegramConfig, handlers.TelegramBotHandler(st))
+		sched.AddNotifier(telegram)
+		telegramBot = telegram
+	}
+	// Post reminders to the Slack workspaces users configured; users without Slack are skipped
+	slack := notify.NewSlack(st.Slack, slackConfig)
+	sched.AddNotifier(slack)
+	// Text reminders of events opting in to the verified phones of users, when an SMS provider is
+	// configured
+	if smsSender != nil {
+		sched.AddNotifier(notify.NewSMS(st.Phones, smsSender))
+	}
+
+	// Events may only select the channels of the registered notifiers
+	handlers.SetNotificationChannels(sched.Channels())
+
+	schedCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go sched.Run(schedCtx)
+
+	// Delete push subscriptions once they expire
+	if len(push.Platforms()) > 0 {
+		go push.Run(schedCtx)
+	}
+
+	// Poll the bot for the messages users send it
+	if telegram != nil {
+		go telegram.Run(schedCtx)
+	}
+
+	// Empty the trash, and delete old events that fired or were completed when a retention period
+	// is configured
+	go scheduler.NewPurger(st.Events, purgeConfig).Run(schedCtx)
+
 	// Initialize the Fiber app with the specified configuration
 	app := fiber.New(fiber.Config{
-		AppName: version,
+		AppName:      version,
+		ErrorHandler: errorHandler,
 	})
 
-	// Middleware for logging HTTP requests
-	app.Use(logger.New())
+	// Middleware tying every request to a context that is cancelled once the shutdown timeout
+	// has passed, aborting the database queries of requests that are still running
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	app.Use(handlers.BaseContext(requestCtx))
+
+	// Middleware counting requests and their latency by route and status for /metrics. It wraps
+	// the request logging, which renders errors, to see the status that was sent.
+	app.Use(handlers.RecordMetrics())
+
+	// Middleware giving every request an id, returned in X-Request-ID, and logging one line per
+	// request with its route, status, latency and user. It comes before the recovery so that
+	// requests that panicked are logged too.
+	app.Use(handlers.RequestLogger())
+
+	// Middleware for recovering from panics in handlers, logging the stack trace
+	app.Use(recover.New(recover.Config{
+		EnableStackTrace:  true,
+		StackTraceHandler: handlers.LogPanic,
+	}))
+
+	// Middleware logging request and response bodies with passwords and tokens redacted, when
+	// LOG_BODIES is set. It runs inside the compression so it sees the uncompressed response.
+	if logBodies {
+		app.Use(handlers.LogBodies())
+	}
+
+	// Middleware for compressing responses for clients that send Accept-Encoding.
+	// Bodies under 200 bytes and non-compressible content types are sent as-is.
+	app.Use(compress.New(compress.Config{
+		Level: compressLevel,
+	}))
+
+	// Middleware cancelling requests that run past REQUEST_TIMEOUT with a 503.
+	// The WebSocket connection and the streamed export are long-lived by design, and /metrics is
+	// scraped on the scraper's own timeout, so a slow scrape is not turned into a 503.
+	app.Use(handlers.RequestTimeout(requestTimeout, "/api/v1/ws", "/api/v1/export", "/metrics"))
+
+	// Application name and version, e.g. for checking which build is deployed
+	app.Get("/", rootInfo)
+
+	// Probes for Kubernetes and load balancers: /healthz answers while the server runs, /readyz
+	// only while the database can be reached and the scheduler keeps up
+	app.Get("/healthz", handlers.Liveness)
+	app.Get("/readyz", handlers.Readiness(db.PingContext, sched))
 
-	// Public routes for login and signup
-	app.Post("/login", func(c *fiber.Ctx) error {
-		return login(c, db)
+	// Prometheus metrics, guarded by METRICS_TOKEN when it is set
+	app.Get("/metrics", handlers.Metrics(os.Getenv("METRICS_TOKEN")))
+
+	// Public routes for login and signup, limited per client and per username against credential
+	// stuffing and password guessing
+	app.Post("/login", handlers.RateLimit(authLimits.PerIP, authLimits.Window),
+		handlers.RateLimitUsername(authLimits.PerUsername, authLimits.Window), func(c *fiber.Ctx) error {
+			return login(c, st)
+		})
+	app.Post("/signup", handlers.RateLimit(authLimits.PerIP, authLimits.Window),
+		handlers.RateLimitUsername(authLimits.PerUsername, authLimits.Window), func(c *fiber.Ctx) error {
+			return signup(c, st)
+		})
+	// Public, since the access token has usually expired by the time it is refreshed
+	app.Post("/refresh", func(c *fiber.Ctx) error {
+		return refresh(c, st)
+	})
+	app.Post("/logout", func(c *fiber.Ctx) error {
+		return logout(c, st)
 	})
-	app.Post("/signup", func(c *fiber.Ctx) error {
-		return signup(c, db)
+	// Limited per client, since each request may send an email
+	app.Post("/forgot-password", handlers.RateLimit(5, time.Minute), func(c *fiber.Ctx) error {
+		return forgotPassword(c, st)
 	})
+	// Limited per client so reset tokens cannot be guessed
+	app.Post("/reset-password", handlers.RateLimit(10, time.Minute), func(c *fiber.Ctx) error {
+		return resetPassword(c, st)
+	})
+	// Limited per client so the availability check cannot be used to enumerate accounts
+	app.Get("/username-available", handlers.RateLimit(10, time.Minute), func(c *fiber.Ctx) error {
+		return handlers.UsernameAvailable(c, st)
+	})
+	// Public, since calendar apps subscribing to the feed cannot log in; the token in the URL authorizes
+	app.Get("/calendar/:token.ics", handlers.RateLimit(60, time.Minute), func(c *fiber.Ctx) error {
+		return handlers.CalendarFeed(c, st)
+	})
+	// Public, since Slack calls it when a button of a reminder is pressed; the Slack signature authorizes
+	if slackConfig.SigningSecret != "" {
+		app.Post("/slack/interactions", handlers.RateLimit(60, time.Minute), func(c *fiber.Ctx) error {
+			return handlers.SlackInteraction(c, st, slack)
+		})
+	}
 
 	// Protected API routes using JWT middleware
 	api := app.Group("/api/v1")
+	api.Use(handlers.APIKeyAuth(st))
 	api.Use(jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: secretKey},
+		Filter:       handlers.AuthenticatedByAPIKey,
+		KeyFunc:      jwtKeyFunc,
+		ErrorHandler: jwtErrorHandler,
 	}))
+	api.Use(handlers.CheckTokenVersion(st))
+
+	// Account routes (protected)
+	api.Get("/me", func(c *fiber.Ctx) error {
+		return handlers.GetProfile(c, st)
+	})
+	api.Post("/logout-all", func(c *fiber.Ctx) error {
+		return logoutAll(c, st)
+	})
+	api.Put("/settings", func(c *fiber.Ctx) error {
+		return handlers.UpdateSettings(c, st)
+	})
+	api.Get("/channels", func(c *fiber.Ctx) error {
+		return handlers.GetChannels(c, st)
+	})
+	api.Put("/channels", func(c *fiber.Ctx) error {
+		return handlers.UpdateChannels(c, st)
+	})
+	api.Put("/username", func(c *fiber.Ctx) error {
+		return changeUsername(c, st)
+	})
+	api.Get("/account", func(c *fiber.Ctx) error {
+		return handlers.GetProfile(c, st)
+	})
+	api.Put("/account", func(c *fiber.Ctx) error {
+		return updateAccount(c, st)
+	})
+	api.Delete("/account", func(c *fiber.Ctx) error {
+		return deleteAccount(c, st)
+	})
+	api.Get("/export", func(c *fiber.Ctx) error {
+		return handlers.ExportData(c, st)
+	})
+
+	// Organization routes (protected)
+	api.Post("/org", func(c *fiber.Ctx) error {
+		return handlers.CreateOrg(c, st)
+	})
+	api.Get("/org", func(c *fiber.Ctx) error {
+		return handlers.GetOrg(c, st)
+	})
+	api.Post("/org/join", func(c *fiber.Ctx) error {
+		return handlers.JoinOrg(c, st)
+	})
+
+	// Event template routes (protected)
+	api.Post("/templates", func(c *fiber.Ctx) error {
+		return handlers.CreateTemplate(c, st)
+	})
+	api.Get("/templates", func(c *fiber.Ctx) error {
+		return handlers.ListTemplates(c, st)
+	})
+
+	// Notification delivery history (protected)
+	api.Get("/deliveries", func(c *fiber.Ctx) error {
+		return handlers.ListDeliveries(c, st)
+	})
+
+	// Audit log route (protected), limited per client since every request scans the log
+	api.Get("/audit", handlers.RateLimit(30, time.Minute), func(c *fiber.Ctx) error {
+		return handlers.ListAudit(c, st)
+	})
+
+	// API key management routes (protected)
+	api.Post("/api-keys", func(c *fiber.Ctx) error {
+		return handlers.CreateAPIKey(c, st)
+	})
+	api.Get("/api-keys", func(c *fiber.Ctx) error {
+		return handlers.ListAPIKeys(c, st)
+	})
+	api.Delete("/api-keys/:id", func(c *fiber.Ctx) error {
+		return handlers.RevokeAPIKey(c, st)
+	})
+
+	// Webhook management routes (protected)
+	api.Post("/webhooks", func(c *fiber.Ctx) error {
+		return handlers.CreateWebhook(c, st)
+	})
+	api.Get("/webhooks", func(c *fiber.Ctx) error {
+		return handlers.ListWebhooks(c, st)
+	})
+	api.Delete("/webhooks/:id", func(c *fiber.Ctx) error {
+		return handlers.DeleteWebhook(c, st)
+	})
+	api.Get("/webhooks/:id/deliveries", func(c *fiber.Ctx) error {
+		return handlers.ListWebhookDeliveries(c, st)
+	})
+
+	// Push device routes (protected)
+	api.Get("/devices/config", func(c *fiber.Ctx) error {
+		return handlers.GetPushConfig(c, push)
+	})
+	api.Post("/devices", func(c *fiber.Ctx) error {
+		return handlers.RegisterDevice(c, st, push)
+	})
+	api.Get("/devices", func(c *fiber.Ctx) error {
+		return handlers.ListDevices(c, st)
+	})
+	api.Delete("/devices/:id", func(c *fiber.Ctx) error {
+		return handlers.DeleteDevice(c, st)
+	})
+
+	// Telegram chat routes (protected)
+	api.Post("/telegram/link", func(c *fiber.Ctx) error {
+		return handlers.LinkTelegram(c, st, telegramBot)
+	})
+	api.Get("/telegram", func(c *fiber.Ctx) error {
+		return handlers.GetTelegram(c, st)
+	})
+	api.Delete("/telegram", func(c *fiber.Ctx) error {
+		return handlers.UnlinkTelegram(c, st)
+	})
+
+	// Slack configuration routes (protected)
+	api.Get("/slack", func(c *fiber.Ctx) error {
+		return handlers.GetSlack(c, st)
+	})
+	api.Put("/slack", func(c *fiber.Ctx) error {
+		return handlers.SetSlack(c, st)
+	})
+	api.Delete("/slack", func(c *fiber.Ctx) error {
+		return handlers.DeleteSlack(c, st)
+	})
+
+	// Phone number routes (protected); every code texted costs money, and few codes exist
+	api.Post("/phone", handlers.RateLimit(5, time.Hour), func(c *fiber.Ctx) error {
+		return handlers.VerifyPhone(c, st, smsSender)
+	})
+	api.Post("/phone/verify", handlers.RateLimit(10, 15*time.Minute), func(c *fiber.Ctx) error {
+		return handlers.ConfirmPhone(c, st)
+	})
+	api.Get("/phone", func(c *fiber.Ctx) error {
+		return handlers.GetPhone(c, st)
+	})
+	api.Delete("/phone", func(c *fiber.Ctx) error {
+		return handlers.DeletePhone(c, st)
+	})
+
+	// Calendar feed routes (protected)
+	api.Get("/calendar/feed", func(c *fiber.Ctx) error {
+		return handlers.GetCalendarFeed(c, st)
+	})
+	api.Post("/calendar/feed", func(c *fiber.Ctx) error {
+		return handlers.CreateCalendarFeed(c, st)
+	})
+	api.Delete("/calendar/feed", func(c *fiber.Ctx) error {
+		return handlers.DeleteCalendarFeed(c, st)
+	})
+
+	// Live reminder push over WebSocket (protected)
+	api.Get("/ws", handlers.LiveEvents(hub, st))
 
 	// Event management routes (protected)
-	api.Post("/event", func(c *fiber.Ctx) error {
-		return handlers.CreateEvent(c, db)
+	api.Post("/event", handlers.Audit(st, handlers.AuditEventCreate), func(c *fiber.Ctx) error {
+		return handlers.CreateEvent(c, st)
+	})
+	api.Get("/events/:id<int>", func(c *fiber.Ctx) error {
+		return handlers.GetEvent(c, st)
+	})
+	api.Put("/events/:id<int>", func(c *fiber.Ctx) error {
+		return handlers.UpdateEvent(c, st)
+	})
+	api.Delete("/events/:id<int>", handlers.Audit(st, handlers.AuditEventDelete), func(c *fiber.Ctx) error {
+		return handlers.DeleteEvent(c, st)
+	})
+	// Deprecated aliases addressing events by name, which changes on renames
+	api.Get("/event/:name", handlers.Deprecated, func(c *fiber.Ctx) error {
+		return handlers.GetEvent(c, st)
+	})
+	api.Put("/event/:name", handlers.Deprecated, func(c *fiber.Ctx) error {
+		return handlers.UpdateEvent(c, st)
+	})
+	api.Delete("/event/:name", handlers.Deprecated, handlers.Audit(st, handlers.AuditEventDelete), func(c *fiber.Ctx) error {
+		return handlers.DeleteEvent(c, st)
+	})
+	api.Post("/events/:id<int>/duplicate", handlers.Audit(st, handlers.AuditEventCreate), func(c *fiber.Ctx) error {
+		return handlers.DuplicateEvent(c, st)
+	})
+	// Deprecated alias duplicating an event by name
+	api.Post("/events/:name/duplicate", handlers.Deprecated, handlers.Audit(st, handlers.AuditEventCreate), func(c *fiber.Ctx) error {
+		return handlers.DuplicateEvent(c, st)
+	})
+	api.Post("/events/:id<int>/share", func(c *fiber.Ctx) error {
+		return handlers.ShareEvent(c, st)
+	})
+	api.Get("/events/:id<int>/share", func(c *fiber.Ctx) error {
+		return handlers.ListShares(c, st)
+	})
+	api.Delete("/events/:id<int>/share/:username", func(c *fiber.Ctx) error {
+		return handlers.UnshareEvent(c, st)
+	})
+	api.Post("/events/:id<int>/restore", func(c *fiber.Ctx) error {
+		return handlers.RestoreEvent(c, st)
+	})
+	api.Post("/events/:id<int>/snooze", func(c *fiber.Ctx) error {
+		return handlers.SnoozeEvent(c, st)
+	})
+	api.Post("/events/:id<int>/dismiss", func(c *fiber.Ctx) error {
+		return handlers.DismissEvent(c, st)
+	})
+	api.Get("/events/:id<int>/notifications", func(c *fiber.Ctx) error {
+		return handlers.ListEventNotifications(c, st)
+	})
+	api.Get("/events/:id<int>/reminders", func(c *fiber.Ctx) error {
+		return handlers.ListReminders(c, st)
+	})
+	api.Post("/events/:id<int>/reminders", func(c *fiber.Ctx) error {
+		return handlers.AddReminder(c, st)
+	})
+	api.Put("/events/:id<int>/reminders/:reminderId<int>", func(c *fiber.Ctx) error {
+		return handlers.UpdateReminder(c, st)
+	})
+	api.Delete("/events/:id<int>/reminders/:reminderId<int>", func(c *fiber.Ctx) error {
+		return handlers.DeleteReminder(c, st)
+	})
+	api.Post("/events/:id<int>/complete", func(c *fiber.Ctx) error {
+		return handlers.CompleteEvent(c, st)
+	})
+	// Deprecated alias completing an event by name
+	api.Post("/events/:name/complete", handlers.Deprecated, func(c *fiber.Ctx) error {
+		return handlers.CompleteEvent(c, st)
+	})
+	// Limited per client, since every resend sends notifications to the event's recipients
+	api.Post("/events/:name/resend", handlers.RateLimit(5, time.Minute), func(c *fiber.Ctx) error {
+		return handlers.ResendEvent(c, st, sched)
+	})
+	api.Get("/events/:name/occurrences", func(c *fiber.Ctx) error {
+		return handlers.ListOccurrences(c, st)
+	})
+	api.Post("/events/bulk", handlers.Audit(st, handlers.AuditEventCreate), func(c *fiber.Ctx) error {
+		return handlers.CreateEvents(c, st)
+	})
+	api.Post("/events/import", handlers.Audit(st, handlers.AuditEventCreate), func(c *fiber.Ctx) error {
+		return handlers.ImportEvents(c, st)
+	})
+	api.Post("/events/reschedule", func(c *fiber.Ctx) error {
+		return handlers.RescheduleEvents(c, st)
+	})
+	api.Post("/events/batch-get", func(c *fiber.Ctx) error {
+		return handlers.BatchGetEvents(c, st)
+	})
+	// Registered after the /events/:name routes, so an event named from-template stays reachable
+	api.Post("/events/from-template/:id", handlers.Audit(st, handlers.AuditEventCreate), func(c *fiber.Ctx) error {
+		return handlers.CreateEventFromTemplate(c, st)
+	})
+	api.Post("/events/validate", handlers.ValidateEvent)
+	api.Get("/events/calendar", func(c *fiber.Ctx) error {
+		return handlers.GetCalendar(c, st)
 	})
-	api.Get("/event/:name", func(c *fiber.Ctx) error {
-		return handlers.GetEvent(c, db)
+	api.Get("/events/export.ics", func(c *fiber.Ctx) error {
+		return handlers.ExportICS(c, st)
 	})
-	api.Put("/event/:name", func(c *fiber.Ctx) error {
-		return handlers.UpdateEvent(c, db)
+	api.Get("/events", func(c *fiber.Ctx) error {
+		return handlers.ListEvents(c, st)
 	})
-	api.Delete("/event/:name", func(c *fiber.Ctx) error {
-		return handlers.DeleteEvent(c, db)
+	api.Get("/events/search", func(c *fiber.Ctx) error {
+		return handlers.SearchEvents(c, st)
 	})
+	api.Get("/events/trash", func(c *fiber.Ctx) error {
+		return handlers.ListTrash(c, st)
+	})
+	api.Get("/events/completed", func(c *fiber.Ctx) error {
+		return handlers.ListCompleted(c, st)
+	})
+	api.Get("/events/shared", func(c *fiber.Ctx) error {
+		return handlers.ListSharedEvents(c, st)
+	})
+	api.Delete("/events", handlers.Audit(st, handlers.AuditEventDelete), func(c *fiber.Ctx) error {
+		return handlers.DeleteEvents(c, st)
+	})
+
+	// Tag routes (protected); tags are set on events, these manage them across all events
+	api.Get("/tags", func(c *fiber.Ctx) error {
+		return handlers.ListTags(c, st)
+	})
+	api.Put("/tags/:tag", func(c *fiber.Ctx) error {
+		return handlers.RenameTag(c, st)
+	})
+	api.Delete("/tags/:tag", func(c *fiber.Ctx) error {
+		return handlers.DeleteTag(c, st)
+	})
+
+	// Admin routes, authenticated like the API and limited to users with the admin role
+	admin := app.Group("/admin/v1")
+	admin.Use(handlers.APIKeyAuth(st))
+	admin.Use(jwtware.New(jwtware.Config{
+		Filter:       handlers.AuthenticatedByAPIKey,
+		KeyFunc:      jwtKeyFunc,
+		ErrorHandler: jwtErrorHandler,
+	}))
+	admin.Use(handlers.CheckTokenVersion(st))
+	admin.Use(handlers.RequireAdmin(st))
+
+	admin.Get("/users", func(c *fiber.Ctx) error {
+		return handlers.ListUsers(c, st)
+	})
+	admin.Put("/users/:id<int>/role", func(c *fiber.Ctx) error {
+		return handlers.SetUserRole(c, st)
+	})
+	admin.Post("/users/:id<int>/disable", func(c *fiber.Ctx) error {
+		return handlers.DisableUser(c, st)
+	})
+	admin.Post("/users/:id<int>/enable", func(c *fiber.Ctx) error {
+		return handlers.EnableUser(c, st)
+	})
+	admin.Delete("/users/:id<int>", func(c *fiber.Ctx) error {
+		return handlers.DeleteUser(c, st)
+	})
+	admin.Get("/events", func(c *fiber.Ctx) error {
+		return handlers.ListAllEvents(c, st)
+	})
+	admin.Delete("/events/:id<int>", func(c *fiber.Ctx) error {
+		return handlers.AdminDeleteEvent(c, st)
+	})
+	admin.Get("/stats", func(c *fiber.Ctx) error {
+		return handlers.GetStats(c, st)
+	})
+
+	// Requests matching no route, registered last so it never shadows one
+	app.Use(notFound)
 
 	// Graceful shutdown setup
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM, syscall.SIGTSTP)
+	signal.Notify(stop, shutdownSignals...)
 
 	// Start the server in a goroutine
 	go func() {
@@ -85,98 +736,1117 @@ func main() {
 
 	// Wait for a termination signal
 	<-stop
-	log.Println("Received shutdown signal, shutting down...")
+	slog.Info("Received shutdown signal, shutting down")
 
-	// Shutdown the server gracefully
-	if err := app.Shutdown(); err != nil {
-		log.Fatalf("Error shutting down server: %v", err)
+	// Stop firing reminders, then shut down the server gracefully, giving requests in flight
+	// SHUTDOWN_TIMEOUT to finish
+	stopScheduler()
+	if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+		slog.Warn("Requests still running after the shutdown timeout, cancelling them", slog.Duration("timeout", shutdownTimeout), slog.Any("error", err))
 	}
 
-	log.Println("Server shutdown successfully")
+	// Cancel what is left, e.g. WebSocket connections, so that their queries are aborted instead
+	// of holding up the deferred close of the database
+	cancelRequests()
+
+	slog.Info("Server shutdown successfully")
+}
+
+// invalidLogin answers a login with an unknown username or a wrong password, alike for both.
+func invalidLogin(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid username or password"})
+}
+
+// Hashes of a random password by each hasher used so far, see dummyHash
+var (
+	dummyHashesMu sync.Mutex
+	dummyHashes   = map[password.Hasher]string{}
+)
+
+// dummyHash returns a hash by hasher of a password nobody knows, for logins of unknown usernames
+// to compare against. It is made once per hasher, so it costs as much to verify as a stored one.
+func dummyHash(hasher password.Hasher) string {
+	dummyHashesMu.Lock()
+	defer dummyHashesMu.Unlock()
+
+	if hash, ok := dummyHashes[hasher]; ok {
+		return hash
+	}
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		slog.Error("Failed to generate dummy password", slog.Any("error", err))
+		return ""
+	}
+	hash, err := hasher.Hash(hex.EncodeToString(secret))
+	if err != nil {
+		slog.Error("Failed to hash dummy password", slog.Any("error", err))
+		return ""
+	}
+	dummyHashes[hasher] = hash
+	return hash
 }
 
 // login handles user authentication and JWT generation
-func login(c *fiber.Ctx, db *sql.DB) error {
+func login(c *fiber.Ctx, st *store.Store) error {
 	var creds Credentials
-	if err := c.BodyParser(&creds); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	if err := handlers.ParseBody(c, &creds); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	var userID int
-	var storedPassword string
-
-	// Query the database for user credentials
-	err := db.QueryRow("SELECT id, password FROM users WHERE username = ?", creds.Username).Scan(&userID, &storedPassword)
+	// Look up the user's credentials. Names that signup would reject simply match no account.
+	username := handlers.NormalizeUsername(creds.Username)
+	user, err := st.Users.ByUsername(c.UserContext(), username)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(400).JSON(fiber.Map{
-				"status":  "error",
-				"message": "No user with the given credentials exists",
-			})
+		if errors.Is(err, store.ErrNotFound) {
+			// Comparing against a hash anyway makes the answer take as long as for a wrong
+			// password, so neither the answer nor its timing tells which usernames exist
+			password.Verify(passwordHasher, dummyHash(passwordHasher), creds.Password)
+			// Attempts on unknown usernames are recorded without an account
+			handlers.RecordAudit(c, st, 0, username, handlers.AuditLoginFailure)
+			return invalidLogin(c)
 		}
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": err.Error(),
-		})
+		return handlers.ServerError(c, err)
 	}
 
 	// Compare the provided password with the stored hash
-	err = bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(creds.Password))
+	rehash, err := password.Verify(passwordHasher, user.PasswordHash, creds.Password)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid username or password"})
+		if !errors.Is(err, password.ErrMismatch) {
+			handlers.Logger(c).Error("Failed to verify password", slog.Int("user_id", user.ID), slog.Any("error", err))
+		}
+		handlers.RecordAudit(c, st, user.ID, user.Username, handlers.AuditLoginFailure)
+		return invalidLogin(c)
+	}
+
+	// Checked after the password, so the state of an account is only told to its owner
+	if user.DisabledAt != nil {
+		return accountDisabled(c)
 	}
 
+	// Move the account to the configured algorithm while the plain password is at hand.
+	// The login succeeds either way; the old hash keeps working until the next attempt.
+	if rehash {
+		if hash, err := passwordHasher.Hash(creds.Password); err != nil {
+			handlers.Logger(c).Error("Failed to rehash password", slog.Int("user_id", user.ID), slog.Any("error", err))
+		} else if err := st.Users.UpdatePassword(c.UserContext(), user.ID, hash); err != nil {
+			handlers.Logger(c).Error("Failed to store rehashed password", slog.Int("user_id", user.ID), slog.Any("error", err))
+		}
+	}
+
+	handlers.RecordAudit(c, st, user.ID, user.Username, handlers.AuditLoginSuccess)
+
 	// Generate and return a JWT token
-	return jwtSigner(c, creds.Username)
+	return jwtSigner(c, st, user)
+}
+
+// passwordTooLong answers a request whose new password, given in field, bcrypt refused to hash
+// for its length with the validation failure the password rule reports for it.
+func passwordTooLong(c *fiber.Ctx, message, field string) error {
+	return handlers.ValidationFailed(c, message, []handlers.FieldError{{Field: field, Message: "password must be at most 72 bytes"}})
 }
 
 // signup handles new user registration
-func signup(c *fiber.Ctx, db *sql.DB) error {
+func signup(c *fiber.Ctx, st *store.Store) error {
 	var creds Credentials
-	if err := c.BodyParser(&creds); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	if err := handlers.ParseBody(c, &creds); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	creds.Username = handlers.NormalizeUsername(creds.Username)
+	if problems := handlers.ValidateStruct(&creds); problems != nil {
+		return handlers.ValidationFailed(c, "Invalid credentials", problems)
 	}
 
 	// Hash the user's password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	hashedPassword, err := passwordHasher.Hash(creds.Password)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return passwordTooLong(c, "Invalid credentials", "password")
+	}
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": err.Error(),
-		})
+		return handlers.ServerError(c, err)
 	}
 
-	// Insert the new user into the database
-	insertQuery, err := db.Prepare("INSERT INTO users (username, password) VALUES (?, ?)")
+	// Store the new user
+	userID, err := st.Users.Create(c.UserContext(), creds.Username, hashedPassword)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Username is already taken",
+			})
+		}
+		return handlers.ServerError(c, err)
 	}
 
-	_, err = insertQuery.Exec(creds.Username, hashedPassword)
+	// Load the new account with the defaults the store filled in, such as the timezone
+	user, err := st.Users.ByID(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": err.Error(),
-		})
+		return handlers.ServerError(c, err)
 	}
 
 	// Generate and return a JWT token
-	return jwtSigner(c, creds.Username)
+	return jwtSigner(c, st, user)
 }
 
-// jwtSigner generates a JWT token for a given username
-func jwtSigner(c *fiber.Ctx, username string) error {
-	// Create and sign a JWT token with user claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": username,
-		"exp":      jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 365)), // Token expires in 1 year
-	})
+// refresh exchanges a refresh token for a new access token and a new refresh token. The refresh
+// token is revoked in the process, so each one can be used once.
+func refresh(c *fiber.Ctx, st *store.Store) error {
+	var req RefreshRequest
+	if err := handlers.ParseBody(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if problems := handlers.ValidateStruct(&req); problems != nil {
+		return handlers.ValidationFailed(c, "Invalid refresh request", problems)
+	}
 
-	signedToken, err := token.SignedString(secretKey)
+	refreshToken, hash, err := newToken(refreshTokenPrefix)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+		return handlers.ServerError(c, err)
 	}
-
-	// Return the signed JWT token
-	return c.JSON(fiber.Map{"token": signedToken})
+	now := time.Now()
+	userID, err := st.RefreshTokens.Rotate(c.UserContext(), hashToken(req.RefreshToken), hash, now, now.Add(tokens.RefreshTTL))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"code":    "refresh_token_invalid",
+				"message": "Invalid, expired, or revoked refresh token",
+			})
+		}
+		return handlers.ServerError(c, err)
+	}
+
+	user, err := st.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return handlers.ServerError(c, err)
+	}
+	// Disabling revokes the refresh tokens; this covers one rotated at the same time
+	if user.DisabledAt != nil {
+		return accountDisabled(c)
+	}
+	return sendTokens(c, user, refreshToken)
+}
+
+// accountDisabled rejects a login or refresh of an account an admin disabled.
+func accountDisabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"status":  "error",
+		"code":    "account_disabled",
+		"message": "This account has been disabled",
+	})
+}
+
+// logout revokes a refresh token. Access tokens issued with it stay valid until they expire.
+func logout(c *fiber.Ctx, st *store.Store) error {
+	var req RefreshRequest
+	if err := handlers.ParseBody(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if problems := handlers.ValidateStruct(&req); problems != nil {
+		return handlers.ValidationFailed(c, "Invalid logout request", problems)
+	}
+
+	userID, err := st.RefreshTokens.Revoke(c.UserContext(), hashToken(req.RefreshToken))
+	if err != nil {
+		// Logging out twice, or with a token that expired, leaves the client logged out all the same
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(200).JSON(fiber.Map{
+				"status":  "logged_out",
+				"message": "Logged out successfully",
+			})
+		}
+		return handlers.ServerError(c, err)
+	}
+
+	if user, err := st.Users.ByID(c.UserContext(), userID); err == nil {
+		handlers.RecordAudit(c, st, user.ID, user.Username, handlers.AuditLogout)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "logged_out",
+		"message": "Logged out successfully",
+	})
+}
+
+// logoutAll ends every session of the authenticated user: all refresh tokens are revoked, and the
+// access tokens issued so far, including the one of this request, stop working.
+func logoutAll(c *fiber.Ctx, st *store.Store) error {
+	userID := handlers.UserID(c)
+
+	if err := st.RefreshTokens.RevokeAll(c.UserContext(), userID); err != nil {
+		return handlers.ServerError(c, err)
+	}
+	if err := st.Users.RevokeTokens(c.UserContext(), userID); err != nil {
+		return handlers.ServerError(c, err)
+	}
+	handlers.ForgetTokenVersion(userID)
+
+	if user, err := st.Users.ByID(c.UserContext(), userID); err == nil {
+		handlers.RecordAudit(c, st, user.ID, user.Username, handlers.AuditLogout)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "logged_out",
+		"message": "Logged out of all sessions successfully",
+	})
+}
+
+// ForgotPasswordRequest struct to parse requests for a password reset token
+type ForgotPasswordRequest struct {
+	Username string `json:"username" form:"username" validate:"required"`
+}
+
+// PasswordReset struct to parse requests redeeming a password reset token. The new password is
+// limited like the one given on signup.
+type PasswordReset struct {
+	Token    string `json:"token" form:"token" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required,password"`
+}
+
+// Minimum duration of a forgot-password request, so that the response does not tell whether the
+// account exists or has an email address
+const forgotPasswordDuration = 500 * time.Millisecond
+
+// forgotPassword emails a single-use password reset token to the address of an account. The
+// response is the same whether or not a token was sent.
+func forgotPassword(c *fiber.Ctx, st *store.Store) error {
+	var req ForgotPasswordRequest
+	if err := handlers.ParseBody(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if problems := handlers.ValidateStruct(&req); problems != nil {
+		return handlers.ValidationFailed(c, "Invalid password reset request", problems)
+	}
+	if resetMailer == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Password reset is not available, no mail server is configured",
+		})
+	}
+
+	// Every answer from here on, including errors, waits for the same minimum duration
+	defer func(start time.Time) {
+		time.Sleep(forgotPasswordDuration - time.Since(start))
+	}(time.Now())
+
+	user, err := st.Users.ByUsername(c.UserContext(), handlers.NormalizeUsername(req.Username))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return handlers.ServerError(c, err)
+	}
+	if err == nil && user.Email != "" {
+		token, hash, err := newToken(passwordResetTokenPrefix)
+		if err != nil {
+			return handlers.ServerError(c, err)
+		}
+		expiresAt := time.Now().Add(tokens.ResetTTL)
+		if err := st.PasswordResets.Create(c.UserContext(), user.ID, hash, expiresAt); err != nil {
+			return handlers.ServerError(c, err)
+		}
+
+		// Sent in the background, since how long the mail server takes would give the account away
+		go sendPasswordReset(handlers.Logger(c), user, token, expiresAt)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "accepted",
+		"message": "If the account has an email address, a password reset token was sent to it",
+	})
+}
+
+// sendPasswordReset emails a password reset token to user, logging a failure to logger.
+func sendPasswordReset(logger *slog.Logger, user *store.User, token string, expiresAt time.Time) {
+	var link string
+	if resetURL != nil {
+		u := *resetURL
+		query := u.Query()
+		query.Set("token", token)
+		u.RawQuery = query.Encode()
+		link = u.String()
+	}
+
+	if err := resetMailer.SendPasswordReset(context.Background(), user.Email, user.Username, token, link, expiresAt); err != nil {
+		logger.Error("Failed to email password reset token", slog.Int("user_id", user.ID), slog.Any("error", err))
+	}
+}
+
+// resetPassword redeems a password reset token, setting a new password for its account. The token
+// and any other reset tokens of the account stop working, and all its sessions are logged out.
+func resetPassword(c *fiber.Ctx, st *store.Store) error {
+	var req PasswordReset
+	if err := handlers.ParseBody(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if problems := handlers.ValidateStruct(&req); problems != nil {
+		return handlers.ValidationFailed(c, "Invalid password reset", problems)
+	}
+
+	hashedPassword, err := passwordHasher.Hash(req.Password)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return passwordTooLong(c, "Invalid password reset", "password")
+	}
+	if err != nil {
+		return handlers.ServerError(c, err)
+	}
+
+	userID, err := st.PasswordResets.Redeem(c.UserContext(), hashToken(req.Token), time.Now(), hashedPassword)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"code":    "reset_token_invalid",
+				"message": "Invalid, expired, or used password reset token",
+			})
+		}
+		return handlers.ServerError(c, err)
+	}
+	handlers.ForgetTokenVersion(userID)
+
+	if user, err := st.Users.ByID(c.UserContext(), userID); err == nil {
+		handlers.RecordAudit(c, st, user.ID, user.Username, handlers.AuditPasswordReset)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "password_reset",
+		"message": "Password reset successfully, log in with the new password",
+	})
+}
+
+// UsernameChange struct to parse requests for a new username
+type UsernameChange struct {
+	Username string `json:"username" form:"username" validate:"required,username"`
+}
+
+// changeUsername renames the authenticated user and returns a fresh JWT carrying the new username.
+// Tokens issued before keep working, since they identify the user by id.
+func changeUsername(c *fiber.Ctx, st *store.Store) error {
+	var req UsernameChange
+	if err := handlers.ParseBody(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	req.Username = handlers.NormalizeUsername(req.Username)
+	if problems := handlers.ValidateStruct(&req); problems != nil {
+		return handlers.ValidationFailed(c, "Invalid username", problems)
+	}
+
+	userID := handlers.UserID(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown user",
+		})
+	}
+	if err := st.Users.UpdateUsername(c.UserContext(), userID, req.Username); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Username is already taken",
+			})
+		}
+		return handlers.ServerError(c, err)
+	}
+
+	user, err := st.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return handlers.ServerError(c, err)
+	}
+	handlers.RecordAudit(c, st, user.ID, user.Username, handlers.AuditUsernameChange)
+
+	// Generate and return a JWT token for the new username
+	return jwtSigner(c, st, user)
+}
+
+// AccountUpdate struct to parse changes to the account of the authenticated user. Empty fields are
+// left unchanged; the email, timezone and purge opt-out are validated as in handlers.Settings. A new
+// password is limited like the one given on signup and requires the current one.
+type AccountUpdate struct {
+	Email           string `json:"email" form:"email" validate:"omitempty,max=255,address"`
+	Timezone        string `json:"timezone" form:"timezone" validate:"omitempty,tzname"`
+	KeepEvents      *bool  `json:"keep_events" form:"keep_events"`
+	CurrentPassword string `json:"current_password" form:"current_password" validate:"required_with=NewPassword"`
+	NewPassword     string `json:"new_password" form:"new_password" validate:"omitempty,password"`
+}
+
+// AccountDeletion struct to parse requests deleting the account of the authenticated user, which
+// the password of the account confirms.
+type AccountDeletion struct {
+	Password string `json:"password" form:"password" validate:"required"`
+}
+
+// checkPassword verifies the password of the authenticated user. It returns the user, or nil and
+// the result of answering the request when the password does not match (403) or the user cannot
+// be loaded.
+func checkPassword(c *fiber.Ctx, st *store.Store, userID int, plain string) (*store.User, error) {
+	user, err := st.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return nil, handlers.ServerError(c, err)
+	}
+
+	if _, err := password.Verify(passwordHasher, user.PasswordHash, plain); err != nil {
+		if !errors.Is(err, password.ErrMismatch) {
+			handlers.Logger(c).Error("Failed to verify password", slog.Any("error", err))
+		}
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"code":    "password_invalid",
+			"message": "Incorrect password",
+		})
+	}
+	return user, nil
+}
+
+// updateAccount changes the settings and/or the password of the authenticated user. Changing the
+// password logs out every other session: the response then carries fresh tokens, as on /login.
+func updateAccount(c *fiber.Ctx, st *store.Store) error {
+	userID := handlers.UserID(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown user",
+		})
+	}
+
+	var req AccountUpdate
+	if err := handlers.ParseBody(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.Email == "" && req.Timezone == "" && req.KeepEvents == nil && req.NewPassword == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No changes provided",
+		})
+	}
+	if problems := handlers.ValidateStruct(&req); problems != nil {
+		return handlers.ValidationFailed(c, "Invalid account changes", problems)
+	}
+
+	// The current password is checked before anything changes
+	if req.NewPassword != "" {
+		if user, err := checkPassword(c, st, userID, req.CurrentPassword); user == nil {
+			return err
+		}
+	}
+
+	if req.Email != "" || req.Timezone != "" || req.KeepEvents != nil {
+		if err := st.Users.UpdateSettings(c.UserContext(), userID, req.Email, req.Timezone, req.KeepEvents); err != nil {
+			return handlers.ServerError(c, err)
+		}
+	}
+
+	if req.NewPassword != "" {
+		hashedPassword, err := passwordHasher.Hash(req.NewPassword)
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return passwordTooLong(c, "Invalid account changes", "new_password")
+		}
+		if err != nil {
+			return handlers.ServerError(c, err)
+		}
+		if err := st.Users.UpdatePassword(c.UserContext(), userID, hashedPassword); err != nil {
+			return handlers.ServerError(c, err)
+		}
+		// Sessions started with the old password end, including this one, which gets new tokens below
+		if err := st.RefreshTokens.RevokeAll(c.UserContext(), userID); err != nil {
+			return handlers.ServerError(c, err)
+		}
+		if err := st.Users.RevokeTokens(c.UserContext(), userID); err != nil {
+			return handlers.ServerError(c, err)
+		}
+		handlers.ForgetTokenVersion(userID)
+	}
+
+	user, err := st.Users.ByID(c.UserContext(), userID)
+	if err != nil {
+		return handlers.ServerError(c, err)
+	}
+	if req.NewPassword != "" {
+		handlers.RecordAudit(c, st, user.ID, user.Username, handlers.AuditPasswordChange)
+		return jwtSigner(c, st, user)
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "updated",
+		"user":    handlers.NewProfile(user),
+		"message": "Account updated successfully",
+	})
+}
+
+// deleteAccount deletes the authenticated user along with everything they own, once their
+// password confirms it. Their tokens and API keys stop working right away.
+func deleteAccount(c *fiber.Ctx, st *store.Store) error {
+	userID := handlers.UserID(c)
+	if userID == 0 {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown user",
+		})
+	}
+
+	var req AccountDeletion
+	if err := handlers.ParseBody(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if problems := handlers.ValidateStruct(&req); problems != nil {
+		return handlers.ValidationFailed(c, "The password is required to delete the account", problems)
+	}
+
+	user, err := checkPassword(c, st, userID, req.Password)
+	if user == nil {
+		return err
+	}
+
+	if err := st.Users.Delete(c.UserContext(), userID); err != nil {
+		return handlers.ServerError(c, err)
+	}
+	handlers.ForgetTokenVersion(userID)
+	// The audit log goes with the account, so the deletion is only logged
+	handlers.Logger(c).Info("Deleted account", slog.String("username", user.Username))
+
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "deleted",
+		"message": "Account deleted successfully",
+	})
+}
+
+// rootInfo answers the root path with the application name and version.
+func rootInfo(c *fiber.Ctx) error {
+	return c.Status(200).JSON(fiber.Map{
+		"status":  "ok",
+		"name":    appName,
+		"version": version,
+	})
+}
+
+// notFound answers requests matching no route with a 404 in the standard JSON envelope.
+func notFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"status":  "error",
+		"message": fmt.Sprintf("No route for %s %s", c.Method(), c.Path()),
+	})
+}
+
+// errorHandler renders errors returned by handlers, including recovered panics, in the standard JSON envelope.
+// Unexpected errors are reported as a generic 500 so internal details are not exposed; an unreachable
+// database is reported as 503.
+func errorHandler(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{
+			"status":  "error",
+			"message": fiberErr.Message,
+		})
+	}
+
+	// Anything else is unexpected and is only described in the log
+	return handlers.ServerError(c, err)
+}
+
+// loadVerificationKeys builds the verification key set from the primary key and a comma-separated
+// list of previous keys. Empty entries are ignored.
+func loadVerificationKeys(primary []byte, previous string) jwt.VerificationKeySet {
+	keys := jwt.VerificationKeySet{Keys: []jwt.VerificationKey{primary}}
+	for _, key := range strings.Split(previous, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys.Keys = append(keys.Keys, []byte(key))
+		}
+	}
+	return keys
+}
+
+// keyID names a signing key in the kid header of the tokens it signs, without revealing the key.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// jwtKeyFunc accepts HS256 tokens signed with the key of the verification key set named by their
+// kid header. Tokens naming an unknown key are rejected; tokens without a kid, signed before key
+// ids were added, are checked against every key in the set.
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method: %v", token.Header["alg"])
+	}
+	kid, ok := token.Header["kid"]
+	if !ok {
+		return verificationKeys, nil
+	}
+	for _, key := range verificationKeys.Keys {
+		if key, ok := key.([]byte); ok && kid == keyID(key) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown jwt key id: %v", kid)
+}
+
+// jwtErrorHandler rejects unauthenticated requests with a 401, telling clients whether
+// the token merely expired (and can be refreshed) or is invalid altogether.
+func jwtErrorHandler(c *fiber.Ctx, err error) error {
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"code":    "token_expired",
+			"message": "Token has expired",
+		})
+	}
+
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"status":  "error",
+		"code":    "token_invalid",
+		"message": "Missing or invalid token",
+	})
+}
+
+// loadPasswordHasher reads PASSWORD_HASH ("bcrypt" or "argon2id", defaulting to bcrypt) and, for
+// bcrypt, the cost from BCRYPT_COST.
+func loadPasswordHasher() (password.Hasher, error) {
+	name := os.Getenv("PASSWORD_HASH")
+	if name == "" {
+		name = "bcrypt"
+	}
+
+	cost, err := loadBcryptCost()
+	if err != nil {
+		return nil, err
+	}
+	return password.New(name, cost)
+}
+
+// loadBcryptCost reads the BCRYPT_COST environment variable, falling back to bcrypt.DefaultCost when unset.
+// Values outside bcrypt's supported range are rejected.
+func loadBcryptCost() (int, error) {
+	raw := os.Getenv("BCRYPT_COST")
+	if raw == "" {
+		return bcrypt.DefaultCost, nil
+	}
+
+	cost, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("BCRYPT_COST must be an integer, got %q", raw)
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return 0, fmt.Errorf("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+
+	return cost, nil
+}
+
+// loadCompressLevel reads the COMPRESS_LEVEL environment variable ("disabled", "default",
+// "best-speed", or "best-compression"), falling back to the default level when unset.
+func loadCompressLevel() (compress.Level, error) {
+	switch raw := os.Getenv("COMPRESS_LEVEL"); raw {
+	case "", "default":
+		return compress.LevelDefault, nil
+	case "disabled":
+		return compress.LevelDisabled, nil
+	case "best-speed":
+		return compress.LevelBestSpeed, nil
+	case "best-compression":
+		return compress.LevelBestCompression, nil
+	default:
+		return 0, fmt.Errorf("unknown COMPRESS_LEVEL %q", raw)
+	}
+}
+
+// Shortest accepted SCHEDULER_INTERVAL
+const minSchedulerInterval = time.Second
+
+// Shortest accepted URGENT_REPEAT_INTERVAL
+const minUrgentRepeat = time.Minute
+
+// Most delivery attempts NOTIFY_MAX_ATTEMPTS may ask for; with the retry delay capped at a day,
+// more would keep failing deliveries pending for months
+const maxNotifyAttempts = 20
+
+// loadSchedulerConfig reads SCHEDULER_INTERVAL (default 30s, at least 1s), SCHEDULER_LEASE_TIMEOUT
+// (default 5m), SCHEDULER_MISSED_GRACE (unset fires missed events however late), NOTIFY_MAX_ATTEMPTS (default 5, at most 20),
+// NOTIFY_RETRY_BACKOFF (default 1m), and URGENT_REPEAT_INTERVAL (default 15m, at least 1m).
+// Durations are given in Go syntax such as "30s".
+func loadSchedulerConfig() (scheduler.Config, error) {
+	config := scheduler.Config{
+		Interval:     30 * time.Second,
+		MaxAttempts:  5,
+		RetryBackoff: time.Minute,
+		BatchSize:    100,
+		LeaseTimeout: 5 * time.Minute,
+		UrgentRepeat: 15 * time.Minute,
+	}
+
+	var err error
+	if config.Interval, err = loadDuration("SCHEDULER_INTERVAL", config.Interval); err != nil {
+		return config, err
+	}
+	// Event dates have second precision, so checking more often gains nothing
+	if config.Interval < minSchedulerInterval {
+		return config, fmt.Errorf("SCHEDULER_INTERVAL must be at least %s, got %s", minSchedulerInterval, config.Interval)
+	}
+	if config.RetryBackoff, err = loadDuration("NOTIFY_RETRY_BACKOFF", config.RetryBackoff); err != nil {
+		return config, err
+	}
+	if config.LeaseTimeout, err = loadDuration("SCHEDULER_LEASE_TIMEOUT", config.LeaseTimeout); err != nil {
+		return config, err
+	}
+	if config.MissedGrace, err = loadDuration("SCHEDULER_MISSED_GRACE", config.MissedGrace); err != nil {
+		return config, err
+	}
+	if config.UrgentRepeat, err = loadDuration("URGENT_REPEAT_INTERVAL", config.UrgentRepeat); err != nil {
+		return config, err
+	}
+	// Repeats come no closer together than the shortest snooze
+	if config.UrgentRepeat < minUrgentRepeat {
+		return config, fmt.Errorf("URGENT_REPEAT_INTERVAL must be at least %s, got %s", minUrgentRepeat, config.UrgentRepeat)
+	}
+
+	if raw := os.Getenv("NOTIFY_MAX_ATTEMPTS"); raw != "" {
+		attempts, err := strconv.Atoi(raw)
+		if err != nil || attempts < 1 || attempts > maxNotifyAttempts {
+			return config, fmt.Errorf("NOTIFY_MAX_ATTEMPTS must be an integer between 1 and %d, got %q", maxNotifyAttempts, raw)
+		}
+		config.MaxAttempts = attempts
+	}
+
+	return config, nil
+}
+
+// loadPurgeConfig reads EVENT_RETENTION, how long events are kept after their date once they fired
+// or were completed, TRASH_RETENTION (default 720h), how long deleted events stay in the trash, and
+// PURGE_INTERVAL (default 1h). Without EVENT_RETENTION only the trash is purged.
+func loadPurgeConfig() (scheduler.PurgeConfig, error) {
+	config := scheduler.PurgeConfig{
+		Interval:       time.Hour,
+		TrashRetention: 30 * 24 * time.Hour,
+		BatchSize:      1000,
+	}
+
+	var err error
+	if config.Retention, err = loadDuration("EVENT_RETENTION", 0); err != nil {
+		return config, err
+	}
+	if config.TrashRetention, err = loadDuration("TRASH_RETENTION", config.TrashRetention); err != nil {
+		return config, err
+	}
+	if config.Interval, err = loadDuration("PURGE_INTERVAL", config.Interval); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// loadSMTPConfig reads SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD, and
+// SMTP_FROM, which is required with a host. Without SMTP_HOST no email is sent.
+func loadSMTPConfig() (notify.SMTPConfig, error) {
+	config := notify.SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     587,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if config.Host == "" {
+		return config, nil
+	}
+
+	if raw := os.Getenv("SMTP_PORT"); raw != "" {
+		port, err := strconv.Atoi(raw)
+		if err != nil || port < 1 || port > 65535 {
+			return config, fmt.Errorf("SMTP_PORT must be a port number, got %q", raw)
+		}
+		config.Port = port
+	}
+
+	// The sender goes into the envelope and the From header as is, so it must be a bare address
+	if addr, err := mail.ParseAddress(config.From); err != nil || addr.Address != config.From {
+		return config, fmt.Errorf("SMTP_FROM must be an email address such as reminders@example.com, got %q", config.From)
+	}
+	return config, nil
+}
+
+// loadPushConfig reads the push service credentials: VAPID_PRIVATE_KEY and VAPID_SUBJECT for
+// Web Push, and the service account key file named by FCM_CREDENTIALS_FILE for FCM.
+func loadPushConfig() (notify.PushConfig, error) {
+	config := notify.PushConfig{
+		VAPIDPrivateKey: os.Getenv("VAPID_PRIVATE_KEY"),
+		VAPIDSubject:    os.Getenv("VAPID_SUBJECT"),
+	}
+
+	// Push services reject VAPID tokens whose subject is not a contact URL
+	if config.VAPIDPrivateKey != "" && !strings.HasPrefix(config.VAPIDSubject, "mailto:") && !strings.HasPrefix(config.VAPIDSubject, "https://") {
+		return config, fmt.Errorf("VAPID_SUBJECT must be a mailto: or https: URL, got %q", config.VAPIDSubject)
+	}
+
+	if path := os.Getenv("FCM_CREDENTIALS_FILE"); path != "" {
+		credentials, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("FCM_CREDENTIALS_FILE could not be read: %w", err)
+		}
+		config.FCMCredentials = credentials
+	}
+	return config, nil
+}
+
+// loadTelegramConfig reads TELEGRAM_BOT_TOKEN, the token BotFather issued for the bot, and
+// TELEGRAM_API_URL, the address of the Bot API (default https://api.telegram.org). Without a token
+// no Telegram message is sent.
+func loadTelegramConfig() (notify.TelegramConfig, error) {
+	config := notify.TelegramConfig{
+		Token:  strings.TrimSpace(os.Getenv("TELEGRAM_BOT_TOKEN")),
+		APIURL: strings.TrimSuffix(os.Getenv("TELEGRAM_API_URL"), "/"),
+	}
+
+	// Tokens look like 123456:ABC-DEF1234ghIkl; anything else would only fail once reminders are due
+	if id, secret, ok := strings.Cut(config.Token, ":"); config.Token != "" && (!ok || secret == "" || strings.Trim(id, "0123456789") != "") {
+		return config, fmt.Errorf("TELEGRAM_BOT_TOKEN must be a bot token such as 123456:ABC-DEF1234ghIkl")
+	}
+	if config.APIURL != "" {
+		if u, err := url.Parse(config.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config, fmt.Errorf("TELEGRAM_API_URL must be an http or https URL, got %q", config.APIURL)
+		}
+	}
+	return config, nil
+}
+
+// loadSlackConfig reads SLACK_SIGNING_SECRET, the signing secret of the Slack app, and
+// SLACK_API_URL, the address of the Slack Web API (default https://slack.com/api). Without a
+// signing secret Slack reminders have no buttons.
+func loadSlackConfig() (notify.SlackConfig, error) {
+	config := notify.SlackConfig{
+		SigningSecret: strings.TrimSpace(os.Getenv("SLACK_SIGNING_SECRET")),
+		APIURL:        strings.TrimSuffix(os.Getenv("SLACK_API_URL"), "/"),
+	}
+
+	if config.APIURL != "" {
+		if u, err := url.Parse(config.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config, fmt.Errorf("SLACK_API_URL must be an http or https URL, got %q", config.APIURL)
+		}
+	}
+	return config, nil
+}
+
+// loadSMSSender returns the SMS provider configured in the environment, or nil when there is none,
+// in which case no text message is sent. Twilio is configured with TWILIO_ACCOUNT_SID,
+// TWILIO_AUTH_TOKEN, and TWILIO_FROM, the number or messaging service messages are sent from, and
+// optionally TWILIO_API_URL, the address of the Twilio REST API (default https://api.twilio.com).
+func loadSMSSender() (notify.SMSSender, error) {
+	config := notify.TwilioConfig{
+		AccountSID: strings.TrimSpace(os.Getenv("TWILIO_ACCOUNT_SID")),
+		AuthToken:  strings.TrimSpace(os.Getenv("TWILIO_AUTH_TOKEN")),
+		From:       strings.TrimSpace(os.Getenv("TWILIO_FROM")),
+		APIURL:     strings.TrimSuffix(os.Getenv("TWILIO_API_URL"), "/"),
+	}
+	if config.AccountSID == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(config.AccountSID, "AC") {
+		return nil, fmt.Errorf("TWILIO_ACCOUNT_SID must be an account SID starting with AC")
+	}
+	if config.AuthToken == "" || config.From == "" {
+		return nil, fmt.Errorf("TWILIO_AUTH_TOKEN and TWILIO_FROM are required with TWILIO_ACCOUNT_SID")
+	}
+	if config.APIURL != "" {
+		if u, err := url.Parse(config.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("TWILIO_API_URL must be an http or https URL, got %q", config.APIURL)
+		}
+	}
+	return notify.NewTwilio(config), nil
+}
+
+// loadAppName reads the application name from APP_NAME, defaulting to Reminder-App.
+func loadAppName() string {
+	if name := strings.TrimSpace(os.Getenv("APP_NAME")); name != "" {
+		return name
+	}
+	return "Reminder-App"
+}
+
+// loadListCacheTTL reads LIST_CACHE_TTL, how long event list pages are cached per user (default 5s).
+// "0" disables the cache.
+func loadListCacheTTL() (time.Duration, error) {
+	if os.Getenv("LIST_CACHE_TTL") == "0" {
+		return 0, nil
+	}
+	return loadDuration("LIST_CACHE_TTL", 5*time.Second)
+}
+
+// loadTokenVersionTTL reads TOKEN_VERSION_CACHE_TTL, how long the token version of a user is
+// cached (default 30s), i.e. how long a revoked access token may keep working on other instances.
+// "0" checks every request against the database.
+func loadTokenVersionTTL() (time.Duration, error) {
+	if os.Getenv("TOKEN_VERSION_CACHE_TTL") == "0" {
+		return 0, nil
+	}
+	return loadDuration("TOKEN_VERSION_CACHE_TTL", 30*time.Second)
+}
+
+// loadLogger reads LOG_FORMAT, json (default) or text, and LOG_LEVEL, the least severe level
+// logged: debug, info (default), warn or error. Lines go to standard error.
+func loadLogger() (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if raw := os.Getenv("LOG_LEVEL"); raw != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(raw)); err != nil {
+			return nil, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", raw)
+		}
+		options.Level = level
+	}
+
+	switch raw := os.Getenv("LOG_FORMAT"); raw {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT must be json or text, got %q", raw)
+	}
+}
+
+// loadBool reads a boolean such as "true" or "0" from the environment variable key, false when unset.
+func loadBool(key string) (bool, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false, got %q", key, raw)
+	}
+	return value, nil
+}
+
+// loadDuration reads a positive duration such as "30s" from the environment variable key,
+// falling back to def when unset.
+func loadDuration(key string, def time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration such as 30s, got %q", key, raw)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %s", key, d)
+	}
+
+	return d, nil
+}
+
+// loadTokenConfig reads ACCESS_TOKEN_TTL (default 15m), REFRESH_TOKEN_TTL (default 720h) and
+// PASSWORD_RESET_TTL (default 1h).
+func loadTokenConfig() (tokenConfig, error) {
+	config := tokens
+	var err error
+	if config.AccessTTL, err = loadDuration("ACCESS_TOKEN_TTL", config.AccessTTL); err != nil {
+		return config, err
+	}
+	if config.RefreshTTL, err = loadDuration("REFRESH_TOKEN_TTL", config.RefreshTTL); err != nil {
+		return config, err
+	}
+	if config.ResetTTL, err = loadDuration("PASSWORD_RESET_TTL", config.ResetTTL); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// loadAuthRateLimits reads AUTH_RATE_LIMIT_IP (default 20), AUTH_RATE_LIMIT_USERNAME (default 5),
+// and AUTH_RATE_LIMIT_WINDOW (default 15m).
+func loadAuthRateLimits() (authRateLimits, error) {
+	limits := authRateLimits{PerIP: 20, PerUsername: 5, Window: 15 * time.Minute}
+	var err error
+	if limits.PerIP, err = loadLimit("AUTH_RATE_LIMIT_IP", limits.PerIP); err != nil {
+		return limits, err
+	}
+	if limits.PerUsername, err = loadLimit("AUTH_RATE_LIMIT_USERNAME", limits.PerUsername); err != nil {
+		return limits, err
+	}
+	if limits.Window, err = loadDuration("AUTH_RATE_LIMIT_WINDOW", limits.Window); err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+// loadLimit reads a request count from the environment variable key, falling back to def when
+// unset. "0" disables the limit.
+func loadLimit(key string, def int) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, raw)
+	}
+	return limit, nil
+}
+
+// loadResetURL reads PASSWORD_RESET_URL, an absolute http or https URL, or returns nil when unset.
+func loadResetURL() (*url.URL, error) {
+	raw := os.Getenv("PASSWORD_RESET_URL")
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("PASSWORD_RESET_URL must be an http or https URL such as https://example.com/reset, got %q", raw)
+	}
+	return u, nil
+}
+
+// hashToken returns the hex-encoded SHA-256 hash under which a refresh or password reset token is
+// stored. Tokens carry 256 bits of randomness, so a fast hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken generates a random token starting with prefix and returns it with its hash.
+func newToken(prefix string) (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token := prefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashToken(token), nil
+}
+
+// jwtSigner starts a session for a given user: it stores a new refresh token and returns it with
+// a JWT access token and the user's public profile
+func jwtSigner(c *fiber.Ctx, st *store.Store, user *store.User) error {
+	refreshToken, hash, err := newToken(refreshTokenPrefix)
+	if err != nil {
+		return handlers.ServerError(c, err)
+	}
+	if err := st.RefreshTokens.Create(c.UserContext(), user.ID, hash, time.Now().Add(tokens.RefreshTTL)); err != nil {
+		return handlers.ServerError(c, err)
+	}
+
+	return sendTokens(c, user, refreshToken)
+}
+
+// sendTokens signs a short-lived JWT access token for user and returns it with refreshToken and
+// the user's public profile
+func sendTokens(c *fiber.Ctx, user *store.User, refreshToken string) error {
+	// Create and sign a JWT token with user claims
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": user.Username,
+		"user_id":  user.ID,
+		"ver":      user.TokenVersion,
+		"exp":      jwt.NewNumericDate(time.Now().Add(tokens.AccessTTL)),
+	})
+	// The key id lets verification pick the key, and reject tokens of keys that were retired
+	token.Header["kid"] = keyID(secretKey)
+
+	signedToken, err := token.SignedString(secretKey)
+	if err != nil {
+		return handlers.ServerError(c, err)
+	}
+
+	// Return the signed JWT token together with the account, so clients need not decode the token
+	return c.JSON(fiber.Map{
+		"token":         signedToken,
+		"expires_in":    int(tokens.AccessTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"user":          handlers.NewProfile(user),
+	})
 }
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/handlers"
+	"github.com/Vansh3140/Reminder-App/password"
+	"github.com/Vansh3140/Reminder-App/store"
+	jwtware "github.com/gofiber/contrib/jwt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useSigningKeys signs and verifies tokens with key for the duration of a test, accepting the
+// previous keys as well, as SECRET_KEY and SECRET_KEY_PREVIOUS do.
+func useSigningKeys(t *testing.T, key, previous string) {
+	savedKey, savedKeys := secretKey, verificationKeys
+	t.Cleanup(func() { secretKey, verificationKeys = savedKey, savedKeys })
+	secretKey = []byte(key)
+	verificationKeys = loadVerificationKeys(secretKey, previous)
+}
+
+// signToken returns an access token for user 1 signed with key, expiring at exp. Its kid header
+// names the key with the given id, unless kid is empty.
+func signToken(t *testing.T, key, kid string, exp time.Time) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": "alice",
+		"user_id":  1,
+		"exp":      jwt.NewNumericDate(exp),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// newJWTApp returns an app serving GET / behind the JWT middleware of the API.
+func newJWTApp() *fiber.App {
+	app := fiber.New()
+	app.Use(jwtware.New(jwtware.Config{
+		KeyFunc:      jwtKeyFunc,
+		ErrorHandler: jwtErrorHandler,
+	}))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	return app
+}
+
+// authenticate requests GET / from app with token as the bearer token, if any, and returns the
+// status and the code of the error.
+func authenticate(t *testing.T, app *fiber.App, token string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Code string `json:"code"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, body.Code
+}
+
+func TestLoadBcryptCost(t *testing.T) {
+	tests := []struct {
+		raw  string
+		cost int
+		ok   bool
+	}{
+		{"", bcrypt.DefaultCost, true},
+		{"12", 12, true},
+		{"4", bcrypt.MinCost, true},
+		{"3", 0, false},
+		{"32", 0, false},
+		{"twelve", 0, false},
+	}
+	for _, test := range tests {
+		t.Setenv("BCRYPT_COST", test.raw)
+		cost, err := loadBcryptCost()
+		if (err == nil) != test.ok || cost != test.cost {
+			t.Errorf("BCRYPT_COST=%q: got %d, %v", test.raw, cost, err)
+		}
+	}
+}
+
+func TestLoadBool(t *testing.T) {
+	tests := []struct {
+		raw   string
+		value bool
+		ok    bool
+	}{
+		{"", false, true},
+		{"true", true, true},
+		{"1", true, true},
+		{"false", false, true},
+		{"0", false, true},
+		{"yes", false, false},
+	}
+	for _, test := range tests {
+		t.Setenv("LOG_BODIES", test.raw)
+		value, err := loadBool("LOG_BODIES")
+		if (err == nil) != test.ok || value != test.value {
+			t.Errorf("LOG_BODIES=%q: got %t, %v", test.raw, value, err)
+		}
+	}
+}
+
+func TestPasswordsHashedAtConfiguredCost(t *testing.T) {
+	t.Setenv("PASSWORD_HASH", "")
+	t.Setenv("BCRYPT_COST", "5")
+	hasher, err := loadPasswordHasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := hasher.Hash("correct horse 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != 5 {
+		t.Fatalf("got cost %d, %v, want 5", cost, err)
+	}
+	// Hashes made at another cost still verify, and are due to be rehashed
+	old, _ := password.Bcrypt{Cost: bcrypt.MinCost}.Hash("correct horse 1")
+	if rehash, err := password.Verify(hasher, old, "correct horse 1"); err != nil || !rehash {
+		t.Fatalf("hash at cost %d: got rehash %t, %v, want a rehash", bcrypt.MinCost, rehash, err)
+	}
+}
+
+func TestExpiredAndInvalidTokens(t *testing.T) {
+	useSigningKeys(t, "current-key", "")
+	app := newJWTApp()
+	current := keyID([]byte("current-key"))
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		code   string
+	}{
+		{"valid", signToken(t, "current-key", current, time.Now().Add(time.Minute)), 200, ""},
+		{"expired", signToken(t, "current-key", current, time.Now().Add(-time.Minute)), 401, "token_expired"},
+		{"bad signature", signToken(t, "other-key", current, time.Now().Add(time.Minute)), 401, "token_invalid"},
+		// An expired token with a bad signature is invalid, so nothing is told about its expiry
+		{"expired with bad signature", signToken(t, "other-key", current, time.Now().Add(-time.Minute)), 401, "token_invalid"},
+		{"malformed", "not.a.token", 401, "token_invalid"},
+		{"missing", "", 401, "token_invalid"},
+	}
+	for _, test := range tests {
+		if status, code := authenticate(t, app, test.token); status != test.status || code != test.code {
+			t.Errorf("%s token: got %d %q, want %d %q", test.name, status, code, test.status, test.code)
+		}
+	}
+}
+
+// captureLog sends the default logger to a buffer for the duration of a test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	return &buf
+}
+
+func TestPanicsAnswerJSONServerError(t *testing.T) {
+	logged := captureLog(t)
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+	app.Use(handlers.RequestLogger())
+	app.Use(recover.New(recover.Config{
+		EnableStackTrace:  true,
+		StackTraceHandler: handlers.LogPanic,
+	}))
+	app.Get("/panic", func(c *fiber.Ctx) error { panic("index out of range in secret table") })
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 || !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		t.Fatalf("got status %d and content type %q, want a JSON 500", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != "error" || body["message"] != "Internal server error" || body["error_id"] == "" {
+		t.Fatalf("got body %v, want the generic error with an error_id", body)
+	}
+
+	// The panic and its stack trace are only logged
+	log := logged.String()
+	for _, want := range []string{"Recovered from panic", "secret table", "runtime/debug.Stack", body["error_id"]} {
+		if !strings.Contains(log, want) {
+			t.Fatalf("log lacks %q:\n%s", want, log)
+		}
+	}
+
+	// The app keeps serving requests
+	if resp, err := app.Test(httptest.NewRequest("GET", "/", nil), -1); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("request after the panic: got %v, %v", resp, err)
+	}
+}
+
+func TestCompressedListResponses(t *testing.T) {
+	t.Setenv("COMPRESS_LEVEL", "fastest")
+	if _, err := loadCompressLevel(); err == nil {
+		t.Fatal("COMPRESS_LEVEL=fastest: got no error")
+	}
+	t.Setenv("COMPRESS_LEVEL", "")
+	level, err := loadCompressLevel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	st := store.NewMemory()
+	alice, _ := st.Users.Create(ctx, "alice", "hash")
+	for i := 0; i < 30; i++ {
+		event := &store.Event{Name: fmt.Sprintf("event-%d", i), Message: "A reminder long enough to be worth compressing", Date: "2030-01-01T09:00:00Z"}
+		if err := st.Events.Create(ctx, alice, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Use(compress.New(compress.Config{Level: level}))
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": float64(alice)}})
+		return c.Next()
+	})
+	app.Get("/events", func(c *fiber.Ctx) error { return handlers.ListEvents(c, st) })
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	// get requests path, accepting gzip if asked to
+	get := func(path string, gzipped bool) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if gzipped {
+			req.Header.Set(fiber.HeaderAcceptEncoding, "gzip")
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get("/events", true)
+	if resp.Header.Get(fiber.HeaderContentEncoding) != "gzip" || !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		t.Fatalf("got encoding %q and type %q, want gzipped JSON", resp.Header.Get(fiber.HeaderContentEncoding), resp.Header.Get(fiber.HeaderContentType))
+	}
+	body, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Count  int           `json:"count"`
+		Events []store.Event `json:"events"`
+	}
+	if err := json.NewDecoder(body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != 30 || len(list.Events) != 30 {
+		t.Fatalf("decompressed list: got %d events, want 30", len(list.Events))
+	}
+
+	// Clients that do not accept gzip, and tiny responses, are answered as-is
+	if resp := get("/events", false); resp.Header.Get(fiber.HeaderContentEncoding) != "" {
+		t.Fatalf("without Accept-Encoding: got encoding %q", resp.Header.Get(fiber.HeaderContentEncoding))
+	}
+	if resp := get("/", true); resp.Header.Get(fiber.HeaderContentEncoding) != "" {
+		t.Fatalf("tiny response: got encoding %q", resp.Header.Get(fiber.HeaderContentEncoding))
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	useSigningKeys(t, "new-key", "old-key, older-key")
+	app := newJWTApp()
+	exp := time.Now().Add(time.Minute)
+	newKey, oldKey := keyID([]byte("new-key")), keyID([]byte("old-key"))
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"current key", signToken(t, "new-key", newKey, exp), 200},
+		{"previous key", signToken(t, "old-key", oldKey, exp), 200},
+		{"oldest previous key", signToken(t, "older-key", keyID([]byte("older-key")), exp), 200},
+		// Tokens signed before key ids were added are checked against every key
+		{"previous key without kid", signToken(t, "old-key", "", exp), 200},
+		{"unknown kid", signToken(t, "other-key", keyID([]byte("other-key")), exp), 401},
+		{"kid of another key", signToken(t, "old-key", newKey, exp), 401},
+		{"unknown key without kid", signToken(t, "other-key", "", exp), 401},
+	}
+	for _, test := range tests {
+		if status, _ := authenticate(t, app, test.token); status != test.status {
+			t.Errorf("token signed with the %s: got %d, want %d", test.name, status, test.status)
+		}
+	}
+
+	// New tokens are signed with the current key and name it
+	signer := fiber.New()
+	signer.Get("/", func(c *fiber.Ctx) error {
+		return sendTokens(c, &store.User{ID: 1, Username: "alice"}, refreshTokenPrefix+"token")
+	})
+	resp, err := signer.Test(httptest.NewRequest("GET", "/", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(body.Token, func(*jwt.Token) (interface{}, error) { return []byte("new-key"), nil })
+	if err != nil {
+		t.Fatalf("new token is not signed with the current key: %v", err)
+	}
+	if token.Header["kid"] != newKey {
+		t.Fatalf("new token: got kid %v, want %s", token.Header["kid"], newKey)
+	}
+
+	// Once the previous keys are dropped, their tokens are rejected
+	useSigningKeys(t, "new-key", "")
+	if status, _ := authenticate(t, app, signToken(t, "old-key", oldKey, exp)); status != 401 {
+		t.Fatalf("token of a dropped key: got %d, want 401", status)
+	}
+	if status, _ := authenticate(t, app, body.Token); status != 200 {
+		t.Fatalf("new token after dropping the previous keys: got %d, want 200", status)
+	}
+}
+
+// newAccountApp returns an app serving the signup and login endpoints on st. Passwords are hashed
+// at the lowest bcrypt cost for the duration of the test, to keep it fast.
+func newAccountApp(t *testing.T, st *store.Store) *fiber.App {
+	saved := passwordHasher
+	t.Cleanup(func() { passwordHasher = saved })
+	passwordHasher = password.Bcrypt{Cost: bcrypt.MinCost}
+
+	app := fiber.New(fiber.Config{Immutable: true})
+	app.Post("/signup", func(c *fiber.Ctx) error { return signup(c, st) })
+	app.Post("/login", func(c *fiber.Ctx) error { return login(c, st) })
+	return app
+}
+
+// postJSON posts a JSON body to app and returns the response with its decoded body.
+func postJSON(t *testing.T, app *fiber.App, path, body string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var answer map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatalf("%s: decode response: %v", path, err)
+	}
+	return resp, answer
+}
+
+func TestCredentialsWithUnknownFieldsRejected(t *testing.T) {
+	st := store.NewMemory()
+	app := newAccountApp(t, st)
+
+	for _, path := range []string{"/signup", "/login"} {
+		resp, body := postJSON(t, app, path, `{"username":"alice","passwrd":"correct horse 1"}`)
+		if resp.StatusCode != 400 || body["error"] != `Unknown field "passwrd" in request body` {
+			t.Fatalf("%s: got %d %v, want a 400 naming the field", path, resp.StatusCode, body)
+		}
+	}
+	if _, err := st.Users.ByUsername(context.Background(), "alice"); err == nil {
+		t.Fatal("an account was created from a body with an unknown field")
+	}
+}
+
+func TestLoginsAudited(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	app := newAccountApp(t, st)
+	if resp, body := postJSON(t, app, "/signup", `{"username":"alice","password":"correct horse 1"}`); resp.StatusCode != 200 {
+		t.Fatalf("signup: got %d %v", resp.StatusCode, body)
+	}
+	alice, err := st.Users.ByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	postJSON(t, app, "/login", `{"username":"alice","password":"correct horse 1"}`)
+	postJSON(t, app, "/login", `{"username":"alice","password":"wrong horse 1"}`)
+	postJSON(t, app, "/login", `{"username":"mallory","password":"correct horse 1"}`)
+
+	entries, _, err := st.Audit.List(ctx, alice.ID, store.Page{Limit: 10})
+	if err != nil || len(entries) != 2 || entries[0].Action != handlers.AuditLoginFailure || entries[1].Action != handlers.AuditLoginSuccess {
+		t.Fatalf("alice's audit log: got %+v, %v, want a failed and a successful login", entries, err)
+	}
+	// Attempts on unknown usernames are recorded by name, without an account
+	entries, _, err = st.Audit.List(ctx, 0, store.Page{Limit: 10})
+	if err != nil || len(entries) != 1 || entries[0].Action != handlers.AuditLoginFailure || entries[0].Username != "mallory" {
+		t.Fatalf("attempts without an account: got %+v, %v, want mallory's failed login", entries, err)
+	}
+}
+
+func TestLoadSchedulerConfigBoundsAttempts(t *testing.T) {
+	tests := []struct {
+		raw      string
+		attempts int
+		ok       bool
+	}{
+		{"", 5, true},
+		{"1", 1, true},
+		{"20", 20, true},
+		{"0", 0, false},
+		{"21", 0, false},
+		{"100", 0, false},
+		{"five", 0, false},
+	}
+	for _, test := range tests {
+		t.Setenv("NOTIFY_MAX_ATTEMPTS", test.raw)
+		config, err := loadSchedulerConfig()
+		if (err == nil) != test.ok || test.ok && config.MaxAttempts != test.attempts {
+			t.Errorf("NOTIFY_MAX_ATTEMPTS=%q: got %d, %v", test.raw, config.MaxAttempts, err)
+		}
+	}
+}
+
+func TestPasswordsLimitedInBytes(t *testing.T) {
+	useSigningKeys(t, "current-key", "")
+	st := store.NewMemory()
+	app := newAccountApp(t, st)
+	app.Post("/reset-password", func(c *fiber.Ctx) error { return resetPassword(c, st) })
+	app.Post("/account", jwtware.New(jwtware.Config{KeyFunc: jwtKeyFunc}), func(c *fiber.Ctx) error { return updateAccount(c, st) })
+
+	// 30 characters of three bytes each are within 72 characters but beyond the 72 bytes bcrypt
+	// hashes, 23 of them and a digit are not
+	long := strings.Repeat("日", 29) + "1"
+	fits := strings.Repeat("日", 23) + "1"
+
+	// rejected checks that body posted to path fails validation for the password in field
+	rejected := func(path, body, field string) {
+		t.Helper()
+		resp, answer := postJSON(t, app, path, body)
+		if resp.StatusCode != 422 {
+			t.Fatalf("%s: got status %d, want 422; body %v", path, resp.StatusCode, answer)
+		}
+		problems, _ := answer["errors"].([]interface{})
+		if len(problems) != 1 || problems[0].(map[string]interface{})["field"] != field ||
+			problems[0].(map[string]interface{})["message"] != "password must be at most 72 bytes" {
+			t.Fatalf("%s: got errors %v, want the password too long", path, answer["errors"])
+		}
+	}
+
+	rejected("/signup", `{"username":"alice","password":"`+long+`"}`, "password")
+	if resp, answer := postJSON(t, app, "/signup", `{"username":"alice","password":"`+fits+`"}`); resp.StatusCode != 200 {
+		t.Fatalf("signup: got status %d, body %v", resp.StatusCode, answer)
+	}
+	if resp, answer := postJSON(t, app, "/login", `{"username":"alice","password":"`+fits+`"}`); resp.StatusCode != 200 {
+		t.Fatalf("login: got status %d, body %v", resp.StatusCode, answer)
+	}
+
+	user, err := st.Users.ByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.PasswordResets.Create(context.Background(), user.ID, hashToken("reset-token"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	rejected("/reset-password", `{"token":"reset-token","password":"`+long+`"}`, "password")
+
+	req := httptest.NewRequest("POST", "/account", strings.NewReader(`{"current_password":"`+fits+`","new_password":"`+long+`"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, "current-key", keyID([]byte("current-key")), time.Now().Add(time.Hour)))
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var answer struct {
+		Errors []handlers.FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 422 || len(answer.Errors) != 1 || answer.Errors[0].Field != "new_password" {
+		t.Fatalf("account: got status %d, errors %+v, want the new password too long", resp.StatusCode, answer.Errors)
+	}
+}
+
+func TestLoginResponseIncludesUser(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	app := newAccountApp(t, st)
+	_, signedUp := postJSON(t, app, "/signup", `{"username":"Alice","password":"correct horse 1"}`)
+	alice, err := st.Users.ByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users.UpdateSettings(ctx, alice.ID, "alice@example.com", "Europe/Paris", nil); err != nil {
+		t.Fatal(err)
+	}
+	resp, loggedIn := postJSON(t, app, "/login", `{"username":"alice","password":"correct horse 1"}`)
+	if resp.StatusCode != 200 {
+		t.Fatalf("login: got status %d, body %v", resp.StatusCode, loggedIn)
+	}
+
+	for _, test := range []struct {
+		route string
+		body  map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{"signup", signedUp, map[string]interface{}{"id": float64(alice.ID), "username": "alice", "role": store.RoleUser, "email": "", "timezone": "UTC"}},
+		{"login", loggedIn, map[string]interface{}{"id": float64(alice.ID), "username": "alice", "role": store.RoleUser, "email": "alice@example.com", "timezone": "Europe/Paris"}},
+	} {
+		if token, _ := test.body["token"].(string); token == "" {
+			t.Fatalf("%s: got no token in %v", test.route, test.body)
+		}
+		user, _ := test.body["user"].(map[string]interface{})
+		for field, want := range test.want {
+			if user[field] != want {
+				t.Errorf("%s: got user %s %v, want %v", test.route, field, user[field], want)
+			}
+		}
+		for field := range user {
+			if strings.Contains(field, "password") {
+				t.Errorf("%s: user has the field %s", test.route, field)
+			}
+		}
+	}
+}
+
+func TestLoadSchedulerConfigInterval(t *testing.T) {
+	tests := []struct {
+		raw      string
+		interval time.Duration
+		ok       bool
+	}{
+		{"", 30 * time.Second, true},
+		{"1s", time.Second, true},
+		{"5s", 5 * time.Second, true},
+		{"2m", 2 * time.Minute, true},
+		{"500ms", 0, false},
+		{"0s", 0, false},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		t.Setenv("SCHEDULER_INTERVAL", test.raw)
+		config, err := loadSchedulerConfig()
+		if (err == nil) != test.ok || test.ok && config.Interval != test.interval {
+			t.Errorf("SCHEDULER_INTERVAL=%q: got %s, %v", test.raw, config.Interval, err)
+		}
+	}
+}
+
+func TestChangeUsername(t *testing.T) {
+	useSigningKeys(t, "current-key", "")
+	st := store.NewMemory()
+	app := newAccountApp(t, st)
+	auth := jwtware.New(jwtware.Config{KeyFunc: jwtKeyFunc})
+	app.Put("/username", auth, func(c *fiber.Ctx) error { return changeUsername(c, st) })
+	app.Get("/whoami", auth, func(c *fiber.Ctx) error {
+		claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+		return c.SendString(fmt.Sprint(claims["user_id"], " ", claims["username"]))
+	})
+	postJSON(t, app, "/signup", `{"username":"alice","password":"correct horse 1"}`)
+	postJSON(t, app, "/signup", `{"username":"bob","password":"correct horse 1"}`)
+
+	// rename asks to rename alice with token and returns the status and the body of the answer
+	rename := func(token, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest("PUT", "/username", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var answer map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, answer
+	}
+	token := signToken(t, "current-key", keyID([]byte("current-key")), time.Now().Add(time.Hour))
+
+	// Taken names, however spelled, and invalid ones leave the user as it is
+	for body, want := range map[string]int{
+		`{"username":"bob"}`:   409,
+		`{"username":" Bob "}`: 409,
+		`{"username":"a b"}`:   422,
+	} {
+		if status, answer := rename(token, body); status != want {
+			t.Fatalf("%s: got status %d, body %v, want %d", body, status, answer, want)
+		}
+	}
+
+	status, answer := rename(token, `{"username":"Alicia"}`)
+	renamed, _ := answer["token"].(string)
+	if status != 200 || renamed == "" {
+		t.Fatalf("rename: got status %d, body %v, want a new token", status, answer)
+	}
+	if _, err := st.Users.ByUsername(context.Background(), "alicia"); err != nil {
+		t.Fatalf("renamed user: %v", err)
+	}
+	if resp, _ := postJSON(t, app, "/login", `{"username":"alicia","password":"correct horse 1"}`); resp.StatusCode != 200 {
+		t.Fatalf("login with the new name: got status %d", resp.StatusCode)
+	}
+
+	// The new token carries the new name
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+renamed)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "1 alicia" {
+		t.Fatalf("whoami with the new token: got status %d, %q, want 1 alicia", resp.StatusCode, body)
+	}
+}
+
+// countingHasher is a bcrypt hasher counting the hashes it compares passwords against.
+type countingHasher struct {
+	password.Bcrypt
+	compared *int
+}
+
+func (h countingHasher) Compare(hash, plain string) error {
+	*h.compared++
+	return h.Bcrypt.Compare(hash, plain)
+}
+
+func TestLoginUnknownUserLikeWrongPassword(t *testing.T) {
+	st := store.NewMemory()
+	app := newAccountApp(t, st)
+	postJSON(t, app, "/signup", `{"username":"alice","password":"correct horse 1"}`)
+	compared := 0
+	passwordHasher = countingHasher{Bcrypt: password.Bcrypt{Cost: bcrypt.MinCost}, compared: &compared}
+
+	wrongResp, wrong := postJSON(t, app, "/login", `{"username":"alice","password":"wrong horse 1"}`)
+	if compared != 1 {
+		t.Fatalf("wrong password: compared %d hashes, want 1", compared)
+	}
+	unknownResp, unknown := postJSON(t, app, "/login", `{"username":"mallory","password":"correct horse 1"}`)
+	if compared != 2 {
+		t.Fatalf("unknown user: compared %d hashes, want 1 like for a wrong password", compared-1)
+	}
+	if wrongResp.StatusCode != 401 || unknownResp.StatusCode != 401 || fmt.Sprint(wrong) != fmt.Sprint(unknown) {
+		t.Fatalf("got %d %v for a wrong password and %d %v for an unknown user, want the same 401",
+			wrongResp.StatusCode, wrong, unknownResp.StatusCode, unknown)
+	}
+}
+
+func TestRootAndUnknownRoutes(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+	app.Get("/", rootInfo)
+	app.Get("/api/v1/events", func(c *fiber.Ctx) error { return c.SendString("events") })
+	app.Use(notFound)
+
+	// call makes a request to app and returns the response with its decoded JSON body
+	call := func(method, path string) (*http.Response, map[string]string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(method, path, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+			return resp, nil
+		}
+		var body map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return resp, body
+	}
+
+	resp, body := call("GET", "/")
+	if resp.StatusCode != 200 || body["status"] != "ok" || body["name"] != appName || body["version"] != version {
+		t.Fatalf("root: got %d %v, want the name %q and version %q", resp.StatusCode, body, appName, version)
+	}
+
+	for _, test := range []struct{ method, path string }{
+		{"GET", "/nope"},
+		{"GET", "/api/v1/nope"},
+		{"DELETE", "/api/v1/events"},
+		{"POST", "/"},
+	} {
+		resp, body := call(test.method, test.path)
+		want := fmt.Sprintf("No route for %s %s", test.method, test.path)
+		if resp.StatusCode != 404 || body["status"] != "error" || body["message"] != want {
+			t.Errorf("%s %s: got %d %v, want a JSON 404 saying %q", test.method, test.path, resp.StatusCode, body, want)
+		}
+	}
+
+	// The routes registered before it still answer
+	if resp, _ := call("GET", "/api/v1/events"); resp.StatusCode != 200 {
+		t.Fatalf("existing route: got status %d, want 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,102 @@
+// Package metrics holds the Prometheus metrics of the application, exposed on /metrics.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Prefix of the names of the application's metrics
+const namespace = "reminders"
+
+// Registry holds every metric served by Handler: those of this package, the Go runtime, and
+// the process.
+var Registry = prometheus.NewRegistry()
+
+// HTTPRequests counts handled requests by method, route pattern, such as /api/v1/events/:id, and
+// status code. Routes are patterns rather than paths so that the number of series stays bounded.
+var HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "http_requests_total",
+	Help:      "HTTP requests handled, by method, route and status code.",
+}, []string{"method", "route", "status"})
+
+// HTTPDuration observes how long requests took to handle, by method and route pattern.
+var HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "Time taken to handle HTTP requests, by method and route.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route"})
+
+// Outcomes of a delivery attempt counted by Deliveries
+const (
+	OutcomeSent  = "sent"
+	OutcomeRetry = "retry"
+	// OutcomeFailed is counted when a delivery is given up, after its last attempt or because it
+	// can no longer be attempted.
+	OutcomeFailed = "failed"
+)
+
+// Deliveries counts the attempts of the scheduler to deliver reminders, by notification channel
+// and outcome.
+var Deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "deliveries_total",
+	Help:      "Reminder delivery attempts, by channel and outcome (sent, retry, failed).",
+}, []string{"channel", "outcome"})
+
+// Fired counts the events and reminders the scheduler fired, by kind: event or reminder.
+var Fired = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "scheduler_fired_total",
+	Help:      "Events and reminders fired by the scheduler, by kind.",
+}, []string{"kind"})
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, Deliveries, Fired,
+	)
+}
+
+// RegisterDB adds the statistics of a database connection pool, such as open, in-use and idle
+// connections and how long requests waited for one.
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Longest a scrape waits for the count of pending deliveries
+const pendingTimeout = 5 * time.Second
+
+// RegisterPendingDeliveries adds the depth of the scheduler's retry queue, the deliveries waiting
+// for another attempt, as counted by pending when the metrics are scraped. A failed count is
+// logged and reported as -1.
+func RegisterPendingDeliveries(pending func(ctx context.Context) (int, error)) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_pending_deliveries",
+		Help:      "Deliveries waiting for another attempt.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), pendingTimeout)
+		defer cancel()
+		count, err := pending(ctx)
+		if err != nil {
+			slog.Error("Failed to count pending deliveries", slog.Any("error", err))
+			return -1
+		}
+		return float64(count)
+	}))
+}
+
+// Handler serves the metrics of Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
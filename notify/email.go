@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Longest a single email may take to send when the context sets no deadline
+const sendTimeout = time.Minute
+
+// ErrNoAddress is returned for a recipient without an email address.
+var ErrNoAddress = errors.New("recipient has no email address")
+
+// SMTPConfig struct holds the mail server reminders are sent through.
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with the server; both empty means no authentication
+	Username string
+	Password string
+	// From is the sender address of every reminder
+	From string
+}
+
+// Email delivers reminders as plain text emails over SMTP. The connection is upgraded with
+// STARTTLS whenever the server offers it.
+type Email struct {
+	config SMTPConfig
+}
+
+// NewEmail returns an Email notifier sending through the server in config.
+func NewEmail(config SMTPConfig) *Email {
+	return &Email{config: config}
+}
+
+// Channel names the notifier in the delivery history.
+func (e *Email) Channel() string {
+	return store.EmailChannel
+}
+
+// Notify sends one reminder email to the recipient of due.
+func (e *Email) Notify(ctx context.Context, due store.DueEvent) error {
+	if due.Recipient.Email == "" {
+		return ErrNoAddress
+	}
+
+	msg, err := composeEmail(e.config.From, due, time.Now())
+	if err != nil {
+		return err
+	}
+	return e.send(ctx, due.Recipient.Email, msg)
+}
+
+// SendPasswordReset emails a password reset token, valid until expiresAt, to the user named
+// username at address to. With a link, the email points there instead of spelling out the request
+// that redeems the token.
+func (e *Email) SendPasswordReset(ctx context.Context, to, username, token, link string, expiresAt time.Time) error {
+	if to == "" {
+		return ErrNoAddress
+	}
+
+	msg, err := composePasswordReset(e.config.From, to, username, token, link, expiresAt, time.Now())
+	if err != nil {
+		return err
+	}
+	return e.send(ctx, to, msg)
+}
+
+// send delivers msg to a single address, giving up when ctx is done.
+func (e *Email) send(ctx context.Context, to string, msg []byte) error {
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context support, so a deadline bounds the whole conversation
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: e.config.Host}); err != nil {
+			return err
+		}
+	}
+	// PlainAuth refuses to send the password over an unencrypted connection to a remote host
+	if e.config.Username != "" || e.config.Password != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(e.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// composeEmail renders the reminder for due as a MIME message. The date is shown in the
+// recipient's timezone, falling back to UTC when it is unknown.
+func composeEmail(from string, due store.DueEvent, now time.Time) ([]byte, error) {
+	loc, err := time.LoadLocation(due.Recipient.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	date := due.Event.Date
+	if t, err := time.Parse(time.RFC3339, due.Event.Date); err == nil {
+		date = t.In(loc).Format("Monday, 2 January 2006 15:04 MST")
+	}
+
+	var body bytes.Buffer
+	qp := quotedprintable.NewWriter(&body)
+	fmt.Fprintf(qp, "%s\r\n\r\nWhen: %s\r\n", due.Event.Message, date)
+	if due.Event.Priority != "" {
+		fmt.Fprintf(qp, "Priority: %s\r\n", due.Event.Priority)
+	}
+	if due.Event.URL != "" {
+		fmt.Fprintf(qp, "Link: %s\r\n", due.Event.URL)
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	// Event names are user input, so the subject is encoded rather than trusted not to break the header
+	subject := mime.QEncoding.Encode("utf-8", "Reminder: "+due.Event.Name)
+
+	return writeMessage(from, due.Recipient.Email, subject, body.Bytes(), now), nil
+}
+
+// composePasswordReset renders the email carrying a password reset token as a MIME message.
+func composePasswordReset(from, to, username, token, link string, expiresAt, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	qp := quotedprintable.NewWriter(&body)
+	fmt.Fprintf(qp, "A password reset was requested for your account %s.\r\n\r\n", username)
+	if link != "" {
+		fmt.Fprintf(qp, "Choose a new password at:\r\n%s\r\n\r\n", link)
+	} else {
+		fmt.Fprintf(qp, "Your reset token is:\r\n%s\r\n\r\nSend it with your new password to POST /reset-password.\r\n\r\n", token)
+	}
+	fmt.Fprintf(qp, "The token works once and expires at %s. If you did not ask for a reset, ignore this email; your password stays the same.\r\n",
+		expiresAt.UTC().Format("2 January 2006 15:04 MST"))
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	return writeMessage(from, to, "Reset your password", body.Bytes(), now), nil
+}
+
+// writeMessage assembles a plain text MIME message from its encoded subject and its
+// quoted-printable body.
+func writeMessage(from, to, subject string, body []byte, now time.Time) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	msg.Write(body)
+	return msg.Bytes()
+}
@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth scope of the FCM HTTP v1 API
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// Base URL of the FCM HTTP v1 API
+const fcmBaseURL = "https://fcm.googleapis.com/v1/projects/"
+
+// fcmCredentials holds the fields of a Google service account key used to authorize with FCM.
+type fcmCredentials struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// fcm sends notifications to Android, iOS, and web apps through Firebase Cloud Messaging,
+// authorizing with OAuth access tokens obtained for a service account.
+type fcm struct {
+	credentials fcmCredentials
+	key         *rsa.PrivateKey
+	// sendURL is the messages:send endpoint of the project
+	sendURL string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// newFCM returns an fcm client for the project of a JSON service account key.
+func newFCM(credentials []byte) (*fcm, error) {
+	f := &fcm{}
+	if err := json.Unmarshal(credentials, &f.credentials); err != nil {
+		return nil, fmt.Errorf("FCM credentials are not a service account key: %w", err)
+	}
+	if f.credentials.ProjectID == "" || f.credentials.ClientEmail == "" || f.credentials.TokenURI == "" {
+		return nil, errors.New("FCM credentials lack project_id, client_email, or token_uri")
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(f.credentials.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("FCM credentials hold no usable private key: %w", err)
+	}
+	f.key = key
+	f.sendURL = fcmBaseURL + url.PathEscape(f.credentials.ProjectID) + "/messages:send"
+	return f, nil
+}
+
+// token returns a cached access token, or exchanges a signed assertion for a new one when the
+// cached token is about to expire.
+func (f *fcm) token(ctx context.Context, client *http.Client) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.accessToken != "" && now.Add(time.Minute).Before(f.expiresAt) {
+		return f.accessToken, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.credentials.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.credentials.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.credentials.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM token endpoint answered with status %d", resp.StatusCode)
+	}
+
+	var granted struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&granted); err != nil {
+		return "", err
+	}
+	if granted.AccessToken == "" {
+		return "", errors.New("FCM token endpoint granted no access token")
+	}
+
+	f.accessToken = granted.AccessToken
+	f.expiresAt = now.Add(time.Duration(granted.ExpiresIn) * time.Second)
+	return f.accessToken, nil
+}
+
+// send delivers a notification to the registration token of a device. It returns
+// errSubscriptionGone when FCM reports the token as unregistered.
+func (f *fcm) send(ctx context.Context, client *http.Client, device store.Device, message pushMessage) error {
+	token, err := f.token(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	// FCM requires every data value to be a string
+	data := map[string]string{
+		"event_id": strconv.Itoa(message.EventID),
+		"date":     message.Date,
+		"priority": message.Priority,
+	}
+	if message.URL != "" {
+		data["url"] = message.URL
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": device.Endpoint,
+			"notification": map[string]string{
+				"title": message.Title,
+				"body":  message.Body,
+			},
+			"data": data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.sendURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+
+	var failure struct {
+		Error struct {
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errSubscriptionGone
+	}
+	for _, detail := range failure.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return errSubscriptionGone
+		}
+	}
+	// The access token may have been revoked early; fetch a new one on the next attempt
+	if resp.StatusCode == http.StatusUnauthorized {
+		f.mu.Lock()
+		f.accessToken = ""
+		f.mu.Unlock()
+	}
+	return fmt.Errorf("FCM answered with status %d: %s", resp.StatusCode, failure.Error.Message)
+}
@@ -0,0 +1,256 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Longest a single call of a push service may take
+const pushTimeout = 10 * time.Second
+
+// How long push services keep a notification for a device that is offline
+const pushTTL = 24 * time.Hour
+
+// Longest reminder message shown in a notification, in bytes; push payloads are limited to 4 KB
+const maxPushBody = 1024
+
+// Interval between two deletions of expired subscriptions
+const pushCleanupInterval = time.Hour
+
+// errSubscriptionGone is returned by a push service client for a device the service no longer
+// delivers to, e.g. because the user unsubscribed or uninstalled the app.
+var errSubscriptionGone = errors.New("subscription is gone")
+
+// ErrNoDevice is returned when a user has no device left to notify.
+var ErrNoDevice = errors.New("no registered device accepts notifications")
+
+// PushConfig struct holds the credentials of the push services. A service without credentials
+// is disabled, and devices registered for it are not notified.
+type PushConfig struct {
+	// VAPIDPrivateKey is the base64url-encoded raw P-256 private key identifying the server to
+	// Web Push services
+	VAPIDPrivateKey string
+	// VAPIDSubject is a mailto: or https: URL the operators of push services can reach us at
+	VAPIDSubject string
+	// FCMCredentials is the JSON service account key of a Firebase project
+	FCMCredentials []byte
+}
+
+// pushMessage is the notification shown for a reminder.
+type pushMessage struct {
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	URL      string `json:"url,omitempty"`
+	EventID  int    `json:"event_id"`
+	Date     string `json:"date"`
+	Priority string `json:"priority"`
+}
+
+// Push delivers reminders as push notifications to every device the owner of an event
+// registered, through Web Push for browsers and FCM for apps. A delivery succeeds once any device
+// accepted it, so retries never notify a device twice. Devices the push service reports as gone,
+// and subscriptions that expired, are deleted.
+type Push struct {
+	devices store.DeviceStore
+	client  *http.Client
+	webPush *webPush
+	fcm     *fcm
+}
+
+// NewPush returns a Push notifier for the devices in devices, using the services configured in
+// config.
+func NewPush(devices store.DeviceStore, config PushConfig) (*Push, error) {
+	p := &Push{devices: devices, client: &http.Client{Timeout: pushTimeout}}
+
+	var err error
+	if config.VAPIDPrivateKey != "" {
+		if p.webPush, err = newWebPush(config.VAPIDPrivateKey, config.VAPIDSubject); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.FCMCredentials) > 0 {
+		if p.fcm, err = newFCM(config.FCMCredentials); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Channel names the notifier in the delivery history.
+func (p *Push) Channel() string {
+	return store.PushChannel
+}
+
+// Platforms returns the platforms devices may register for.
+func (p *Push) Platforms() []string {
+	var platforms []string
+	if p.webPush != nil {
+		platforms = append(platforms, store.PlatformWebPush)
+	}
+	if p.fcm != nil {
+		platforms = append(platforms, store.PlatformFCM)
+	}
+	return platforms
+}
+
+// VAPIDPublicKey returns the base64url-encoded key browsers subscribe with, or "" when Web Push
+// is disabled.
+func (p *Push) VAPIDPublicKey() string {
+	if p.webPush == nil {
+		return ""
+	}
+	return p.webPush.publicKey
+}
+
+// HasDestination reports whether a user has a device that can currently be notified.
+func (p *Push) HasDestination(ctx context.Context, userID int) (bool, error) {
+	devices, err := p.devices.List(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	for _, device := range devices {
+		if p.supports(device.Platform) && !expired(device, now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Notify sends a notification for due to every device of its owner. It fails if no device
+// accepted it.
+func (p *Push) Notify(ctx context.Context, due store.DueEvent) error {
+	devices, err := p.devices.List(ctx, due.UserID)
+	if err != nil {
+		return err
+	}
+
+	message := pushMessage{
+		Title:    "Reminder: " + due.Event.Name,
+		Body:     truncate(due.Event.Message, maxPushBody),
+		URL:      due.Event.URL,
+		EventID:  due.Event.ID,
+		Date:     due.Event.Date,
+		Priority: due.Event.Priority,
+	}
+
+	now := time.Now()
+	sent := 0
+	var failures []string
+	for _, device := range devices {
+		if !p.supports(device.Platform) {
+			continue
+		}
+		if expired(device, now) {
+			p.forget(ctx, due.UserID, device, "expired")
+			continue
+		}
+
+		err := p.send(ctx, device, message)
+		switch {
+		case errors.Is(err, errSubscriptionGone):
+			p.forget(ctx, due.UserID, device, "gone")
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("device %d: %v", device.ID, err))
+		default:
+			sent++
+		}
+	}
+
+	switch {
+	case sent > 0:
+		if len(failures) > 0 {
+			slog.Warn("Push notification reached only some devices", slog.Int("event_id", due.Event.ID), slog.Int("sent", sent),
+				slog.String("failures", strings.Join(failures, "; ")))
+		}
+		return nil
+	case len(failures) > 0:
+		return fmt.Errorf("%d device(s) failed: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return ErrNoDevice
+}
+
+// send delivers a message to one device through the service of its platform.
+func (p *Push) send(ctx context.Context, device store.Device, message pushMessage) error {
+	if device.Platform == store.PlatformFCM {
+		return p.fcm.send(ctx, p.client, device, message)
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	urgency := "normal"
+	switch message.Priority {
+	case "high", "urgent":
+		urgency = "high"
+	case "low":
+		urgency = "low"
+	}
+	return p.webPush.send(ctx, p.client, device, payload, urgency)
+}
+
+// Run deletes expired subscriptions every pushCleanupInterval until ctx is cancelled.
+func (p *Push) Run(ctx context.Context) {
+	ticker := time.NewTicker(pushCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := p.devices.DeleteExpired(ctx, time.Now())
+			if err != nil {
+				slog.Error("Deleting expired push subscriptions failed", slog.Any("error", err))
+			} else if deleted > 0 {
+				slog.Info("Deleted expired push subscriptions", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
+
+// supports reports whether the service of a platform is configured.
+func (p *Push) supports(platform string) bool {
+	switch platform {
+	case store.PlatformWebPush:
+		return p.webPush != nil
+	case store.PlatformFCM:
+		return p.fcm != nil
+	}
+	return false
+}
+
+// forget deletes a device that can no longer be notified.
+func (p *Push) forget(ctx context.Context, userID int, device store.Device, reason string) {
+	if err := p.devices.Delete(ctx, userID, device.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.Error("Failed to delete device", slog.String("reason", reason), slog.Int("device_id", device.ID), slog.Any("error", err))
+		return
+	}
+	slog.Info("Deleted device", slog.String("reason", reason), slog.Int("device_id", device.ID), slog.Int("user_id", userID))
+}
+
+// expired reports whether the subscription of a device expired at now.
+func expired(device store.Device, now time.Time) bool {
+	return device.ExpiresAt != nil && device.ExpiresAt.Before(now)
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,306 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default address of the Slack Web API
+const slackAPIURL = "https://slack.com/api"
+
+// Longest a single call to Slack may take, including reading the response
+const slackTimeout = 10 * time.Second
+
+// Longest reminder message shown in a Slack message, in bytes; section text is limited to 3000
+// characters
+const maxSlackText = 2000
+
+// Oldest interaction callback accepted, guarding against replayed requests
+const slackMaxSkew = 5 * time.Minute
+
+// Headers of a Slack interaction callback. The signature is "v0=" followed by the hex
+// HMAC-SHA256 of "v0:", the timestamp, a colon, and the body, keyed with the signing secret.
+const (
+	SlackHeaderTimestamp = "X-Slack-Request-Timestamp"
+	SlackHeaderSignature = "X-Slack-Signature"
+)
+
+// Snooze durations offered by the buttons of a reminder, as the Go durations ending their action ids
+var slackSnoozes = []struct {
+	label    string
+	duration string
+}{
+	{"Snooze 15 min", "15m"},
+	{"Snooze 1 hour", "1h"},
+}
+
+// Action ids of the buttons of a reminder; snooze buttons append their duration to the prefix
+const (
+	SlackActionSnooze  = "snooze_"
+	SlackActionDismiss = "dismiss"
+)
+
+// ErrSlackSignature is returned for an interaction callback that does not come from Slack.
+var ErrSlackSignature = errors.New("invalid Slack signature")
+
+// ErrNoSlack is returned when a user has no Slack configuration.
+var ErrNoSlack = errors.New("no Slack configuration")
+
+// SlackConfig struct holds what the Slack notifier shares across users.
+type SlackConfig struct {
+	// SigningSecret verifies the interaction callbacks of the Slack app, and signs the values of
+	// the buttons of a reminder; without it reminders have no buttons
+	SigningSecret string
+	// APIURL is the address of the Slack Web API; empty means Slack's
+	APIURL string
+}
+
+// slackMessage is the body of an incoming webhook call or of chat.postMessage. Text is shown in
+// notifications and by clients that cannot render the blocks.
+type slackMessage struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"`
+	Blocks  []slackBlock `json:"blocks"`
+}
+
+// slackBlock is a Block Kit layout block of a message.
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+	// Elements are the text objects of a context block, or the buttons of an actions block
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// slackText is a text object of Block Kit.
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackButton is a button in an actions block.
+type slackButton struct {
+	Type     string    `json:"type"`
+	Text     slackText `json:"text"`
+	ActionID string    `json:"action_id"`
+	Value    string    `json:"value"`
+	Style    string    `json:"style,omitempty"`
+}
+
+// Slack delivers reminders to the Slack workspace each user configured, through an incoming
+// webhook or as a Slack app posting to a channel. Reminders carry buttons to snooze or dismiss
+// them when the app's signing secret is configured.
+type Slack struct {
+	configs store.SlackStore
+	config  SlackConfig
+	client  *http.Client
+}
+
+// NewSlack returns a Slack notifier for the configurations in configs.
+func NewSlack(configs store.SlackStore, config SlackConfig) *Slack {
+	if config.APIURL == "" {
+		config.APIURL = slackAPIURL
+	}
+	return &Slack{
+		configs: configs,
+		config:  config,
+		client:  &http.Client{Timeout: slackTimeout},
+	}
+}
+
+// Channel names the notifier in the delivery history.
+func (s *Slack) Channel() string {
+	return store.SlackChannel
+}
+
+// HasDestination reports whether a user configured Slack.
+func (s *Slack) HasDestination(ctx context.Context, userID int) (bool, error) {
+	_, err := s.configs.Get(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Notify posts a message for due to the Slack configuration of its owner.
+func (s *Slack) Notify(ctx context.Context, due store.DueEvent) error {
+	config, err := s.configs.Get(ctx, due.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNoSlack
+	}
+	if err != nil {
+		return err
+	}
+
+	message := s.compose(due)
+	if config.Mode() == store.SlackModeWebhook {
+		return s.post(ctx, config.WebhookURL, "", message)
+	}
+	message.Channel = config.Channel
+	return s.post(ctx, s.config.APIURL+"/chat.postMessage", config.BotToken, message)
+}
+
+// Respond replaces the message an interaction came from with text, through the response URL of
+// the interaction.
+func (s *Slack) Respond(ctx context.Context, responseURL, text string) error {
+	return s.post(ctx, responseURL, "", map[string]interface{}{
+		"replace_original": true,
+		"text":             text,
+	})
+}
+
+// Verify checks that an interaction callback comes from Slack: that its signature matches the
+// body, and that it was sent within slackMaxSkew of now.
+func (s *Slack) Verify(timestamp, signature string, body []byte, now time.Time) error {
+	if s.config.SigningSecret == "" {
+		return ErrSlackSignature
+	}
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSlackSignature
+	}
+	if skew := now.Sub(time.Unix(sent, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return ErrSlackSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.config.SigningSecret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	if !hmac.Equal([]byte(signature), []byte("v0="+hex.EncodeToString(mac.Sum(nil)))) {
+		return ErrSlackSignature
+	}
+	return nil
+}
+
+// ActionValue returns the value of a button acting on an event for a user. It is signed, so that
+// buttons in messages not posted by the notifier cannot act on other users' events.
+func (s *Slack) ActionValue(userID, eventID int) string {
+	payload := strconv.Itoa(userID) + ":" + strconv.Itoa(eventID)
+	return payload + ":" + s.sign(payload)
+}
+
+// ParseActionValue returns the user and event of a button value from ActionValue, or
+// ErrSlackSignature if the value was not signed by the notifier.
+func (s *Slack) ParseActionValue(value string) (int, int, error) {
+	i := strings.LastIndex(value, ":")
+	if i < 0 || s.config.SigningSecret == "" || !hmac.Equal([]byte(value[i+1:]), []byte(s.sign(value[:i]))) {
+		return 0, 0, ErrSlackSignature
+	}
+
+	user, event, _ := strings.Cut(value[:i], ":")
+	userID, err := strconv.Atoi(user)
+	if err != nil {
+		return 0, 0, ErrSlackSignature
+	}
+	eventID, err := strconv.Atoi(event)
+	if err != nil {
+		return 0, 0, ErrSlackSignature
+	}
+	return userID, eventID, nil
+}
+
+// sign returns the hex HMAC-SHA256 of a button value, keyed with the signing secret.
+func (s *Slack) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.SigningSecret))
+	mac.Write([]byte("action:" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// compose renders the reminder for due as a message. The date is written so that Slack shows it
+// in the timezone of each reader.
+func (s *Slack) compose(due store.DueEvent) slackMessage {
+	date := due.Event.Date
+	if t, err := time.Parse(time.RFC3339, due.Event.Date); err == nil {
+		date = fmt.Sprintf("<!date^%d^{date_long_pretty} at {time}|%s>", t.Unix(), t.UTC().Format("Monday, 2 January 2006 15:04 MST"))
+	}
+
+	details := "When: " + date
+	if due.Event.Priority != "" {
+		details += " | Priority: " + due.Event.Priority
+	}
+	if due.Event.URL != "" {
+		details += " | <" + due.Event.URL + "|Link>"
+	}
+
+	message := slackMessage{
+		Text: "Reminder: " + due.Event.Name,
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "*Reminder: " + slackEscape(due.Event.Name) + "*\n" +
+				slackEscape(truncate(due.Event.Message, maxSlackText))}},
+			{Type: "context", Elements: []interface{}{slackText{Type: "mrkdwn", Text: details}}},
+		},
+	}
+	if s.config.SigningSecret == "" {
+		return message
+	}
+
+	value := s.ActionValue(due.UserID, due.Event.ID)
+	var buttons []interface{}
+	for _, snooze := range slackSnoozes {
+		buttons = append(buttons, slackButton{Type: "button", Text: slackText{Type: "plain_text", Text: snooze.label},
+			ActionID: SlackActionSnooze + snooze.duration, Value: value})
+	}
+	buttons = append(buttons, slackButton{Type: "button", Text: slackText{Type: "plain_text", Text: "Dismiss"},
+		ActionID: SlackActionDismiss, Value: value, Style: "danger"})
+	message.Blocks = append(message.Blocks, slackBlock{Type: "actions", Elements: buttons})
+	return message
+}
+
+// post sends a JSON body to a Slack URL, authenticated with token unless it is empty. Incoming
+// webhooks answer with a status, the Web API with an ok field.
+func (s *Slack) post(ctx context.Context, url, token string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("slack: status %d %s", resp.StatusCode, strings.TrimSpace(string(answer)))
+	}
+	if token == "" {
+		return nil
+	}
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(answer, &result); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack: %s", result.Error)
+	}
+	return nil
+}
+
+// slackEscape escapes the characters Slack reads as markup in message text.
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
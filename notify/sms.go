@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"strings"
+	"time"
+)
+
+// Longest text of an SMS reminder, in bytes; providers split longer texts into several messages,
+// each billed separately
+const maxSMSText = 320
+
+// ErrNoPhone is returned when a user has no verified phone number.
+var ErrNoPhone = errors.New("no verified phone number")
+
+// ErrUndeliverable is returned by an SMSSender for a number that cannot receive text messages,
+// such as an invalid number, a landline, or one that opted out.
+var ErrUndeliverable = errors.New("phone number cannot receive text messages")
+
+// SMSSender sends text messages through an SMS provider. Twilio implements it.
+type SMSSender interface {
+	// Send texts body to a phone number in E.164 form, such as +14155550123.
+	Send(ctx context.Context, to, body string) error
+}
+
+// SMS delivers reminders as text messages to the phone number each user verified, through an
+// SMSSender. Events only use it when they opt in with SMSNotifications, since messages cost money.
+type SMS struct {
+	phones store.PhoneStore
+	sender SMSSender
+}
+
+// NewSMS returns an SMS notifier texting the phones in phones through sender.
+func NewSMS(phones store.PhoneStore, sender SMSSender) *SMS {
+	return &SMS{phones: phones, sender: sender}
+}
+
+// Channel names the notifier in the delivery history.
+func (s *SMS) Channel() string {
+	return store.SMSChannel
+}
+
+// HasDestination reports whether a user verified a phone number.
+func (s *SMS) HasDestination(ctx context.Context, userID int) (bool, error) {
+	_, err := s.phones.Phone(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Notify texts the reminder for due to the verified phone of its owner.
+func (s *SMS) Notify(ctx context.Context, due store.DueEvent) error {
+	phone, err := s.phones.Phone(ctx, due.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNoPhone
+	}
+	if err != nil {
+		return err
+	}
+	return s.sender.Send(ctx, phone.Number, composeSMS(due))
+}
+
+// composeSMS renders the reminder for due as a text message, with the date in the timezone of
+// its recipient.
+func composeSMS(due store.DueEvent) string {
+	loc, err := time.LoadLocation(due.Recipient.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	date := due.Event.Date
+	if t, err := time.Parse(time.RFC3339, due.Event.Date); err == nil {
+		date = t.In(loc).Format("Mon 2 Jan 15:04 MST")
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Reminder: %s (%s)", due.Event.Name, date)
+	if message := strings.TrimSpace(due.Event.Message); message != "" {
+		fmt.Fprintf(&text, "\n%s", message)
+	}
+	return truncate(text.String(), maxSMSText)
+}
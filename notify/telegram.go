@@ -0,0 +1,308 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default address of the Telegram Bot API
+const telegramAPIURL = "https://api.telegram.org"
+
+// Longest a single Bot API call may take, apart from polling for updates
+const telegramTimeout = 10 * time.Second
+
+// How long a poll for updates waits for a message before the Bot API answers with none
+const telegramPollTimeout = 30 * time.Second
+
+// Delay before polling again after the Bot API could not be reached
+const telegramRetryDelay = 5 * time.Second
+
+// Longest message sent to a chat, in bytes; the Bot API accepts up to 4096 characters
+const maxTelegramText = 4096
+
+// ErrNoChat is returned when a user has no Telegram chat left to notify.
+var ErrNoChat = errors.New("no Telegram chat is linked")
+
+// TelegramConfig struct holds the bot reminders are sent and events created through.
+type TelegramConfig struct {
+	// Token is the token BotFather issued for the bot
+	Token string
+	// APIURL is the address of the Bot API, such as a local Bot API server; empty means Telegram's
+	APIURL string
+}
+
+// BotMessage struct is a text message a user sent to the bot in a private chat. Username is the
+// Telegram username of the sender, which may be empty.
+type BotMessage struct {
+	ChatID   int64
+	Username string
+	Text     string
+}
+
+// BotHandler answers a message sent to the bot. An empty reply sends nothing back.
+type BotHandler func(ctx context.Context, message BotMessage) string
+
+// telegramResponse is the envelope of every Bot API response.
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// telegramError is a call the Bot API rejected.
+type telegramError struct {
+	code        int
+	description string
+}
+
+func (e *telegramError) Error() string {
+	return fmt.Sprintf("telegram: %d %s", e.code, e.description)
+}
+
+// telegramUpdate is an update received by polling, of which only private text messages are used.
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		From struct {
+			Username string `json:"username"`
+		} `json:"from"`
+		Chat struct {
+			ID   int64  `json:"id"`
+			Type string `json:"type"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Telegram delivers reminders as messages to the Telegram chat the owner of an event linked, and
+// runs the bot users link their chat with and create events through. Chats in which the bot was
+// blocked are unlinked. Only one instance may poll a bot for updates at a time.
+type Telegram struct {
+	chats   store.TelegramStore
+	config  TelegramConfig
+	client  *http.Client
+	handler BotHandler
+
+	mu       sync.Mutex
+	username string
+}
+
+// NewTelegram returns a Telegram notifier for the chats in chats, sending through the bot in
+// config. handler answers the messages the bot receives.
+func NewTelegram(chats store.TelegramStore, config TelegramConfig, handler BotHandler) *Telegram {
+	if config.APIURL == "" {
+		config.APIURL = telegramAPIURL
+	}
+	return &Telegram{
+		chats:   chats,
+		config:  config,
+		client:  &http.Client{Timeout: telegramPollTimeout + telegramTimeout},
+		handler: handler,
+	}
+}
+
+// Channel names the notifier in the delivery history.
+func (t *Telegram) Channel() string {
+	return store.TelegramChannel
+}
+
+// BotUsername returns the username of the bot, or "" until Run has looked it up.
+func (t *Telegram) BotUsername() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.username
+}
+
+// HasDestination reports whether a user linked a chat.
+func (t *Telegram) HasDestination(ctx context.Context, userID int) (bool, error) {
+	_, err := t.chats.Chat(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Notify sends a message for due to the chat of its owner.
+func (t *Telegram) Notify(ctx context.Context, due store.DueEvent) error {
+	chat, err := t.chats.Chat(ctx, due.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNoChat
+	}
+	if err != nil {
+		return err
+	}
+
+	err = t.Send(ctx, chat.ChatID, composeTelegram(due))
+	var rejected *telegramError
+	if errors.As(err, &rejected) && rejected.code == http.StatusForbidden {
+		// The user blocked the bot or deleted the chat
+		if err := t.chats.UnlinkChat(ctx, chat.ChatID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.Error("Failed to unlink Telegram chat", slog.Int("user_id", due.UserID), slog.Any("error", err))
+		} else {
+			slog.Info("Unlinked Telegram chat", slog.String("reason", rejected.description), slog.Int("user_id", due.UserID))
+		}
+		return ErrNoChat
+	}
+	return err
+}
+
+// Send sends a plain text message to a chat.
+func (t *Telegram) Send(ctx context.Context, chatID int64, text string) error {
+	ctx, cancel := context.WithTimeout(ctx, telegramTimeout)
+	defer cancel()
+
+	return t.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     truncate(text, maxTelegramText),
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+// Run looks up the username of the bot, then polls for the messages users send it and answers
+// them with the handler until ctx is cancelled.
+func (t *Telegram) Run(ctx context.Context) {
+	for t.BotUsername() == "" {
+		var me struct {
+			Username string `json:"username"`
+		}
+		callCtx, cancel := context.WithTimeout(ctx, telegramTimeout)
+		err := t.call(callCtx, "getMe", map[string]interface{}{}, &me)
+		cancel()
+		if err == nil {
+			t.mu.Lock()
+			t.username = me.Username
+			t.mu.Unlock()
+			slog.Info("Telegram bot is running", slog.String("bot", me.Username))
+			break
+		}
+
+		slog.Error("Looking up the Telegram bot failed", slog.Any("error", err))
+		if !sleep(ctx, telegramRetryDelay) {
+			return
+		}
+	}
+
+	var offset int64
+	for ctx.Err() == nil {
+		var updates []telegramUpdate
+		err := t.call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         int(telegramPollTimeout / time.Second),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Polling Telegram for updates failed", slog.Any("error", err))
+				sleep(ctx, telegramRetryDelay)
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			t.handle(ctx, update)
+		}
+	}
+}
+
+// handle answers a private text message; other updates are ignored.
+func (t *Telegram) handle(ctx context.Context, update telegramUpdate) {
+	m := update.Message
+	if m == nil || m.Chat.Type != "private" || strings.TrimSpace(m.Text) == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telegramTimeout)
+	defer cancel()
+
+	reply := t.handler(ctx, BotMessage{ChatID: m.Chat.ID, Username: m.From.Username, Text: m.Text})
+	if reply == "" {
+		return
+	}
+	if err := t.Send(ctx, m.Chat.ID, reply); err != nil {
+		slog.Error("Answering a Telegram message failed", slog.Int64("chat_id", m.Chat.ID), slog.Any("error", err))
+	}
+}
+
+// call invokes a Bot API method with a JSON body and decodes its result into result, unless nil.
+func (t *Telegram) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.APIURL+"/bot"+t.config.Token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// The URL holds the token, which must not end up in the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("telegram %s: %w", method, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("telegram %s: status %d", method, resp.StatusCode)
+	}
+	if !envelope.OK {
+		return &telegramError{code: envelope.ErrorCode, description: envelope.Description}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// composeTelegram renders the reminder for due as a message. The date is shown in the
+// recipient's timezone, falling back to UTC when it is unknown.
+func composeTelegram(due store.DueEvent) string {
+	loc, err := time.LoadLocation(due.Recipient.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	date := due.Event.Date
+	if t, err := time.Parse(time.RFC3339, due.Event.Date); err == nil {
+		date = t.In(loc).Format("Monday, 2 January 2006 15:04 MST")
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Reminder: %s\n\n%s\n\nWhen: %s", due.Event.Name, due.Event.Message, date)
+	if due.Event.Priority != "" {
+		fmt.Fprintf(&text, "\nPriority: %s", due.Event.Priority)
+	}
+	if due.Event.URL != "" {
+		fmt.Fprintf(&text, "\nLink: %s", due.Event.URL)
+	}
+	return text.String()
+}
+
+// sleep waits for d and reports whether it did, or returns false as soon as ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Default address of the Twilio REST API
+const twilioAPIURL = "https://api.twilio.com"
+
+// Longest a single call to Twilio may take, including reading the response
+const twilioTimeout = 10 * time.Second
+
+// Twilio error codes for numbers that cannot receive text messages: invalid numbers, numbers
+// that are not mobile, unsupported regions, and numbers that replied STOP
+var twilioUndeliverable = map[int]bool{21211: true, 21408: true, 21610: true, 21612: true, 21614: true}
+
+// TwilioConfig struct holds the Twilio account text messages are sent from.
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	// From is the phone number messages are sent from, in E.164 form, or the SID of a messaging
+	// service, which starts with MG
+	From string
+	// APIURL is the address of the Twilio REST API; empty means Twilio's
+	APIURL string
+}
+
+// Twilio sends text messages through the Twilio Programmable Messaging API.
+type Twilio struct {
+	config TwilioConfig
+	client *http.Client
+}
+
+// NewTwilio returns an SMSSender sending through the Twilio account in config.
+func NewTwilio(config TwilioConfig) *Twilio {
+	if config.APIURL == "" {
+		config.APIURL = twilioAPIURL
+	}
+	return &Twilio{
+		config: config,
+		client: &http.Client{Timeout: twilioTimeout},
+	}
+}
+
+// Send texts body to a phone number. Numbers Twilio reports as unable to receive text messages
+// return an error wrapping ErrUndeliverable.
+func (t *Twilio) Send(ctx context.Context, to, body string) error {
+	form := url.Values{"To": {to}, "Body": {body}}
+	if strings.HasPrefix(t.config.From, "MG") {
+		form.Set("MessagingServiceSid", t.config.From)
+	} else {
+		form.Set("From", t.config.From)
+	}
+
+	endpoint := t.config.APIURL + "/2010-04-01/Accounts/" + url.PathEscape(t.config.AccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.config.AccountSID, t.config.AuthToken)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(answer, &result); err != nil || result.Code == 0 {
+		return fmt.Errorf("twilio: status %d %s", resp.StatusCode, strings.TrimSpace(string(answer)))
+	}
+	if twilioUndeliverable[result.Code] {
+		return fmt.Errorf("twilio: %w: %d %s", ErrUndeliverable, result.Code, result.Message)
+	}
+	return fmt.Errorf("twilio: status %d: %d %s", resp.StatusCode, result.Code, result.Message)
+}
@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Longest a single webhook call may take, including reading the response
+const webhookTimeout = 10 * time.Second
+
+// Event type of the payload sent when a reminder fires
+const webhookEventFired = "reminder.fired"
+
+// Headers of a webhook call. The signature is "sha256=" followed by the hex HMAC-SHA256 of the
+// timestamp, a dot, and the body, keyed with the webhook's secret.
+const (
+	HeaderDelivery  = "X-Reminder-Delivery"
+	HeaderTimestamp = "X-Reminder-Timestamp"
+	HeaderSignature = "X-Reminder-Signature"
+)
+
+// webhookPayload is the JSON body POSTed to a webhook.
+type webhookPayload struct {
+	Type       string       `json:"type"`
+	DeliveryID int          `json:"delivery_id"`
+	SentAt     string       `json:"sent_at"`
+	Event      webhookEvent `json:"event"`
+}
+
+// webhookEvent holds the fields of an event a webhook receives.
+type webhookEvent struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Date     string `json:"date"`
+	Message  string `json:"message"`
+	Priority string `json:"priority,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Webhook delivers reminders by POSTing a signed JSON payload to every webhook the owner of the
+// event registered. Every call is logged. A retry of a delivery only calls the webhooks that have
+// not yet accepted it with a 2xx response.
+type Webhook struct {
+	webhooks store.WebhookStore
+	client   *http.Client
+}
+
+// NewWebhook returns a Webhook notifier calling the webhooks in webhooks.
+func NewWebhook(webhooks store.WebhookStore) *Webhook {
+	return &Webhook{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Channel names the notifier in the delivery history.
+func (w *Webhook) Channel() string {
+	return store.WebhookChannel
+}
+
+// HasDestination reports whether a user registered a webhook.
+func (w *Webhook) HasDestination(ctx context.Context, userID int) (bool, error) {
+	webhooks, err := w.webhooks.List(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(webhooks) > 0, nil
+}
+
+// Notify calls the webhooks of the owner of due that have not accepted its delivery yet. It fails
+// if any of them does not answer with a 2xx status.
+func (w *Webhook) Notify(ctx context.Context, due store.DueEvent) error {
+	webhooks, err := w.webhooks.List(ctx, due.UserID)
+	if err != nil {
+		return err
+	}
+	accepted, err := w.webhooks.Accepted(ctx, due.DeliveryID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		Type:       webhookEventFired,
+		DeliveryID: due.DeliveryID,
+		SentAt:     time.Now().UTC().Format(time.RFC3339),
+		Event: webhookEvent{
+			ID:       due.Event.ID,
+			Name:     due.Event.Name,
+			Date:     due.Event.Date,
+			Message:  due.Event.Message,
+			Priority: due.Event.Priority,
+			URL:      due.Event.URL,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, webhook := range webhooks {
+		if accepted[webhook.ID] {
+			continue
+		}
+
+		attempt := &store.WebhookAttempt{WebhookID: webhook.ID, DeliveryID: due.DeliveryID, EventID: due.Event.ID}
+		attempt.StatusCode, err = w.call(ctx, webhook, due.DeliveryID, body)
+		if err == nil && (attempt.StatusCode < 200 || attempt.StatusCode > 299) {
+			err = fmt.Errorf("status %d", attempt.StatusCode)
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+			failures = append(failures, fmt.Sprintf("webhook %d: %v", webhook.ID, err))
+		}
+
+		if err := w.webhooks.RecordAttempt(ctx, attempt); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d webhook(s) failed: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// call POSTs a signed body to a webhook and returns the status it answered with.
+func (w *Webhook) call(ctx context.Context, webhook store.Webhook, deliveryID int, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Reminder-App-Webhook")
+	req.Header.Set(HeaderDelivery, strconv.Itoa(deliveryID))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a bounded part of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of a webhook call with the given timestamp and body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/store"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long a VAPID token is valid; push services reject tokens valid for more than 24 hours
+const vapidTokenTTL = 12 * time.Hour
+
+// Record size announced in the header of an encrypted payload; a reminder always fits one record
+const webPushRecordSize = 4096
+
+// webPush sends notifications to the push services of browsers, following RFC 8030, encrypting
+// them per RFC 8291 and identifying the server per RFC 8292 (VAPID).
+type webPush struct {
+	key *ecdsa.PrivateKey
+	// publicKey is the base64url-encoded uncompressed public key browsers subscribe with
+	publicKey string
+	subject   string
+}
+
+// newWebPush returns a webPush signing with a base64url-encoded raw P-256 private key.
+func newWebPush(privateKey, subject string) (*webPush, error) {
+	raw, err := decodeBase64URL(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID private key is not base64url: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID private key is not a P-256 key: %w", err)
+	}
+	public, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &webPush{key: key, publicKey: base64.RawURLEncoding.EncodeToString(public), subject: subject}, nil
+}
+
+// send delivers an encrypted payload to a subscription. It returns errSubscriptionGone when the
+// push service no longer knows the subscription.
+func (w *webPush) send(ctx context.Context, client *http.Client, device store.Device, payload []byte, urgency string) error {
+	body, err := encryptPayload(device.P256dh, device.Auth, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := w.authorization(device.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, device.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(pushTTL/time.Second)))
+	req.Header.Set("Urgency", urgency)
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errSubscriptionGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("push service answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// authorization returns the VAPID Authorization header for a request to endpoint. The token's
+// audience is the origin of the push service.
+func (w *webPush) authorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidTokenTTL).Unix(),
+		"sub": w.subject,
+	}).SignedString(w.key)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + w.publicKey, nil
+}
+
+// encryptPayload encrypts plaintext for a subscription with the aes128gcm content coding of
+// RFC 8188, keyed as RFC 8291 describes: an ephemeral ECDH key agreed with the subscription's
+// p256dh key, mixed with its auth secret.
+func encryptPayload(p256dh, auth string, plaintext []byte) ([]byte, error) {
+	clientKey, authSecret, err := subscriptionKeys(p256dh, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := serverKey.ECDH(clientKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	// Combine the shared secret with the auth secret into the input keying material
+	prk, err := hkdf.Extract(sha256.New, shared, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(clientKey.Bytes()) + string(serverPublic)
+	ikm, err := hkdf.Expand(sha256.New, prk, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, and the server's public key as key id
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+
+	// A single record, ended by the last-record delimiter
+	record := append(append([]byte{}, plaintext...), 0x02)
+	if len(record)+gcm.Overhead() > webPushRecordSize {
+		return nil, errors.New("push payload is too large")
+	}
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// subscriptionKeys decodes the p256dh public key and auth secret of a Web Push subscription.
+func subscriptionKeys(p256dh, auth string) (*ecdh.PublicKey, []byte, error) {
+	raw, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, nil, errors.New("p256dh key is not base64url")
+	}
+	clientKey, err := ecdh.P256().NewPublicKey(raw)
+	if err != nil {
+		return nil, nil, errors.New("p256dh key is not an uncompressed P-256 public key")
+	}
+
+	authSecret, err := decodeBase64URL(auth)
+	if err != nil || len(authSecret) != 16 {
+		return nil, nil, errors.New("auth secret must be 16 base64url-encoded bytes")
+	}
+	return clientKey, authSecret, nil
+}
+
+// CheckSubscription reports whether the keys of a Web Push subscription are usable.
+func CheckSubscription(p256dh, auth string) error {
+	_, _, err := subscriptionKeys(p256dh, auth)
+	return err
+}
+
+// decodeBase64URL decodes base64url with or without padding, as browsers and key tools differ.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,179 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// ErrMismatch is returned when a password does not match a hash.
+var ErrMismatch = errors.New("password does not match")
+
+// ErrUnknownHash is returned for a hash that no supported algorithm produced.
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher hashes passwords with one algorithm. Hashes start with a prefix naming their algorithm,
+// so hashes of every supported algorithm can be told apart and verified side by side.
+type Hasher interface {
+	// Name identifies the algorithm, as selected by PASSWORD_HASH.
+	Name() string
+	// Hash returns the encoded hash of password, including its salt and parameters.
+	Hash(password string) (string, error)
+	// Owns reports whether hash was produced by this algorithm.
+	Owns(hash string) bool
+	// Compare returns nil if password matches hash, or ErrMismatch.
+	Compare(hash, password string) error
+	// Current reports whether hash was produced with this hasher's parameters.
+	Current(hash string) bool
+}
+
+// New returns the hasher for the algorithm name, "bcrypt" or "argon2id". bcryptCost only
+// applies to bcrypt.
+func New(name string, bcryptCost int) (Hasher, error) {
+	switch name {
+	case "bcrypt":
+		return Bcrypt{Cost: bcryptCost}, nil
+	case "argon2id":
+		return DefaultArgon2id, nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", name)
+	}
+}
+
+// Verify checks password against hash, whichever supported algorithm produced it. rehash reports
+// whether hash should be replaced by one from current, because it uses another algorithm or other
+// parameters; it is only meaningful when the password matched.
+func Verify(current Hasher, hash, password string) (rehash bool, err error) {
+	for _, hasher := range []Hasher{current, Bcrypt{}, DefaultArgon2id} {
+		if !hasher.Owns(hash) {
+			continue
+		}
+		if err := hasher.Compare(hash, password); err != nil {
+			return false, err
+		}
+		return hasher.Name() != current.Name() || !current.Current(hash), nil
+	}
+	return false, ErrUnknownHash
+}
+
+// Bcrypt hashes passwords with bcrypt at the given cost. Only the first 72 bytes of a password are used.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Name() string {
+	return "bcrypt"
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b Bcrypt) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (b Bcrypt) Compare(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	return err
+}
+
+func (b Bcrypt) Current(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == b.Cost
+}
+
+// Argon2id hashes passwords with argon2id. Hashes are encoded in the PHC string format,
+// e.g. "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>", with unpadded base64.
+type Argon2id struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+// DefaultArgon2id uses the parameters recommended by OWASP for argon2id.
+var DefaultArgon2id = Argon2id{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// Prefix of argon2id hashes
+const argon2idPrefix = "$argon2id$"
+
+func (a Argon2id) Name() string {
+	return "argon2id"
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return a.encode(salt, key), nil
+}
+
+// encode formats salt and key with a's parameters as a PHC string.
+func (a Argon2id) encode(salt, key []byte) string {
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeArgon2id parses a PHC string into its parameters, salt, and key.
+func decodeArgon2id(hash string) (Argon2id, []byte, []byte, error) {
+	var params Argon2id
+	var version int
+	parts := strings.Split(strings.TrimPrefix(hash, argon2idPrefix), "$")
+	if !strings.HasPrefix(hash, argon2idPrefix) || len(parts) != 4 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	_, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength, params.KeyLength = len(salt), uint32(len(key))
+	return params, salt, key, nil
+}
+
+func (a Argon2id) Owns(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+func (a Argon2id) Compare(hash, password string) error {
+	// The hash carries its own parameters, so hashes made with older settings still verify
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a Argon2id) Current(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+	return err == nil && params == a
+}
@@ -0,0 +1,113 @@
+package password
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+// Cheap parameters, so the tests do not spend their time hashing
+var (
+	testBcrypt   = Bcrypt{Cost: bcrypt.MinCost}
+	testArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+)
+
+// mustHash returns the hash of password by hasher.
+func mustHash(t *testing.T, hasher Hasher, password string) string {
+	t.Helper()
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatalf("%s: %v", hasher.Name(), err)
+	}
+	return hash
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	for _, hasher := range []Hasher{testBcrypt, testArgon2id} {
+		hash := mustHash(t, hasher, "correct horse 1")
+		if !hasher.Owns(hash) || !hasher.Current(hash) {
+			t.Fatalf("%s: got hash %q, want one of its own with its parameters", hasher.Name(), hash)
+		}
+		if again := mustHash(t, hasher, "correct horse 1"); again == hash {
+			t.Fatalf("%s: hashed the same password twice to %q, want different salts", hasher.Name(), hash)
+		}
+
+		if rehash, err := Verify(hasher, hash, "correct horse 1"); err != nil || rehash {
+			t.Fatalf("%s: right password got rehash %t, %v", hasher.Name(), rehash, err)
+		}
+		if _, err := Verify(hasher, hash, "wrong horse 1"); !errors.Is(err, ErrMismatch) {
+			t.Fatalf("%s: wrong password got %v, want ErrMismatch", hasher.Name(), err)
+		}
+	}
+}
+
+func TestVerifyReportsRehash(t *testing.T) {
+	bcryptHash := mustHash(t, testBcrypt, "correct horse 1")
+	argonHash := mustHash(t, testArgon2id, "correct horse 1")
+	stronger := testArgon2id
+	stronger.Iterations++
+
+	tests := []struct {
+		name    string
+		current Hasher
+		hash    string
+		rehash  bool
+	}{
+		{"same bcrypt cost", testBcrypt, bcryptHash, false},
+		{"other bcrypt cost", Bcrypt{Cost: bcrypt.MinCost + 1}, bcryptHash, true},
+		{"bcrypt to argon2id", testArgon2id, bcryptHash, true},
+		{"same argon2id parameters", testArgon2id, argonHash, false},
+		{"other argon2id parameters", stronger, argonHash, true},
+		{"argon2id to bcrypt", testBcrypt, argonHash, true},
+	}
+	for _, test := range tests {
+		// Hashes of the other algorithm and parameters still verify
+		rehash, err := Verify(test.current, test.hash, "correct horse 1")
+		if err != nil || rehash != test.rehash {
+			t.Errorf("%s: got rehash %t, %v, want %t", test.name, rehash, err, test.rehash)
+		}
+		if _, err := Verify(test.current, test.hash, "wrong horse 1"); !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s: wrong password got %v, want ErrMismatch", test.name, err)
+		}
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	argonHash := mustHash(t, testArgon2id, "correct horse 1")
+	parts := strings.Split(argonHash, "$")
+
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$md5$abc",
+		"$argon2id$",
+		"$argon2id$v=18$" + strings.Join(parts[3:], "$"),
+		"$argon2id$v=19$m=64,t=0,p=1$" + strings.Join(parts[4:], "$"),
+		"$argon2id$v=19$m=64,t=1,p=1$" + parts[4],
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$" + parts[5],
+		"$argon2id$v=19$m=64,t=1,p=1$" + parts[4] + "$",
+	} {
+		if _, err := Verify(testArgon2id, hash, "correct horse 1"); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%q: got %v, want ErrUnknownHash", hash, err)
+		}
+	}
+
+	// A truncated bcrypt hash is rejected, without being taken for a mismatch
+	bcryptHash := mustHash(t, testBcrypt, "correct horse 1")
+	if _, err := Verify(testBcrypt, bcryptHash[:20], "correct horse 1"); err == nil || errors.Is(err, ErrMismatch) {
+		t.Errorf("truncated bcrypt hash: got %v, want an error other than ErrMismatch", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if hasher, err := New("bcrypt", 11); err != nil || hasher != (Bcrypt{Cost: 11}) {
+		t.Fatalf("bcrypt: got %v, %v", hasher, err)
+	}
+	if hasher, err := New("argon2id", 11); err != nil || hasher != DefaultArgon2id {
+		t.Fatalf("argon2id: got %v, %v", hasher, err)
+	}
+	if hasher, err := New("scrypt", 11); err == nil {
+		t.Fatalf("scrypt: got %v, want an error", hasher)
+	}
+}
@@ -0,0 +1,482 @@
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies a rule repeats at
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// Longest accepted INTERVAL
+const maxInterval = 1000
+
+// Occurrences are not generated past this year or this many periods (days, weeks, months, or
+// years), so rules that can never match again end
+const (
+	lastYear   = 9999
+	maxPeriods = 100000
+)
+
+// Weekday codes of BYDAY and WKST, in the order of time.Weekday
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ByDay is an entry of BYDAY: a weekday, optionally restricted to its nth occurrence in the month.
+// A negative N counts from the end of the month; 0 means every such weekday.
+type ByDay struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// Rule is a recurrence rule as defined by RFC 5545, limited to the parts this package supports:
+// FREQ (DAILY, WEEKLY, MONTHLY, or YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH,
+// and WKST. The first occurrence is the start of the series, with which the rule is expanded.
+type Rule struct {
+	Freq     string
+	Interval int
+	// Count limits the series to this many occurrences, including the start; 0 means no limit
+	Count int
+	// Until ends the series after this time; zero means no end. When UntilDate is set, Until is a
+	// date and occurrences on that whole day are included, in the timezone of the series.
+	Until      time.Time
+	UntilDate  bool
+	ByDay      []ByDay
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE". An "RRULE:" prefix is ignored,
+// and "daily", "weekly", "monthly", and "yearly" are accepted as shorthands for plain rules.
+func Parse(value string) (*Rule, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimPrefix(value, "RRULE:")
+	switch value {
+	case Daily, Weekly, Monthly, Yearly:
+		value = "FREQ=" + value
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("rule part %q must have the form NAME=VALUE", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("rule part %s is given twice", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = val
+			default:
+				err = fmt.Errorf("FREQ must be DAILY, WEEKLY, MONTHLY, or YEARLY")
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseNumber(key, val, 1, maxInterval)
+		case "COUNT":
+			rule.Count, err = parseNumber(key, val, 1, 1<<20)
+		case "UNTIL":
+			err = rule.parseUntil(val)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseByMonthDay(val)
+		case "BYMONTH":
+			rule.ByMonth, err = parseByMonth(val)
+		case "WKST":
+			var day ByDay
+			if day, err = parseWeekday(val); err == nil {
+				rule.WeekStart = day.Weekday
+			}
+		default:
+			err = fmt.Errorf("rule part %s is not supported", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rule.check(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// check rejects combinations of parts that RFC 5545 forbids or this package does not expand.
+func (r *Rule) check() error {
+	if r.Freq == "" {
+		return fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("COUNT and UNTIL cannot be combined")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == Weekly {
+		return fmt.Errorf("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	for _, day := range r.ByDay {
+		if day.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return fmt.Errorf("numbered BYDAY entries such as 2MO need FREQ=MONTHLY or YEARLY")
+		}
+	}
+	if len(r.ByDay) > 0 && r.Freq == Yearly && len(r.ByMonth) == 0 {
+		return fmt.Errorf("BYDAY with FREQ=YEARLY is only supported together with BYMONTH")
+	}
+	return nil
+}
+
+// parseNumber reads the integer value of a rule part, which must lie within min and max.
+func parseNumber(key, val string, min, max int) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be a number from %d to %d", key, min, max)
+	}
+	return n, nil
+}
+
+// parseUntil reads UNTIL as a UTC date-time such as 20250131T235959Z or a date such as 20250131.
+func (r *Rule) parseUntil(val string) error {
+	if t, err := time.Parse("20060102T150405Z", val); err == nil {
+		r.Until = t
+		return nil
+	}
+	if t, err := time.Parse("20060102", val); err == nil {
+		r.Until, r.UntilDate = t, true
+		return nil
+	}
+	return fmt.Errorf("UNTIL must be a UTC date-time such as 20250131T235959Z or a date such as 20250131")
+}
+
+// parseWeekday reads a BYDAY entry such as MO, 2TU, or -1FR.
+func parseWeekday(val string) (ByDay, error) {
+	if len(val) < 2 {
+		return ByDay{}, fmt.Errorf("%q is not a weekday such as MO or 2MO", val)
+	}
+	prefix, code := val[:len(val)-2], val[len(val)-2:]
+
+	day := ByDay{Weekday: -1}
+	for i, c := range weekdayCodes {
+		if c == code {
+			day.Weekday = time.Weekday(i)
+		}
+	}
+	if day.Weekday < 0 {
+		return ByDay{}, fmt.Errorf("%q is not a weekday such as MO or 2MO", val)
+	}
+
+	if prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return ByDay{}, fmt.Errorf("%q is not a weekday such as MO or 2MO", val)
+		}
+		day.N = n
+	}
+	return day, nil
+}
+
+// parseByDay reads the comma-separated entries of BYDAY.
+func parseByDay(val string) ([]ByDay, error) {
+	var days []ByDay
+	for _, entry := range strings.Split(val, ",") {
+		day, err := parseWeekday(entry)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// parseByMonthDay reads the comma-separated days of BYMONTHDAY, from 1 to 31 or -31 to -1.
+func parseByMonthDay(val string) ([]int, error) {
+	var days []int
+	for _, entry := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(entry)
+		if err != nil || n == 0 || n < -31 || n > 31 {
+			return nil, fmt.Errorf("BYMONTHDAY entries must be days from 1 to 31 or -31 to -1")
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+// parseByMonth reads the comma-separated months of BYMONTH, from 1 to 12.
+func parseByMonth(val string) ([]time.Month, error) {
+	var months []time.Month
+	for _, entry := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(entry)
+		if err != nil || n < 1 || n > 12 {
+			return nil, fmt.Errorf("BYMONTH entries must be months from 1 to 12")
+		}
+		months = append(months, time.Month(n))
+	}
+	return months, nil
+}
+
+// String returns the rule in canonical form, with its parts in a fixed order and defaults left out.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.UntilDate {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	} else if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayCodes[day.Weekday]
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCodes[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence of the series starting at start that lies after after, or
+// false when the series ends before. Occurrences keep the clock time of start in its location,
+// so a daily 09:00 reminder stays at 09:00 across daylight saving changes.
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	next := r.Occurrences(start, after, 1)
+	if len(next) == 0 {
+		return time.Time{}, false
+	}
+	return next[0], true
+}
+
+// Occurrences returns up to n occurrences of the series starting at start that lie after after,
+// in order.
+func (r *Rule) Occurrences(start, after time.Time, n int) []time.Time {
+	occurrences := []time.Time{}
+	if n <= 0 {
+		return occurrences
+	}
+	r.each(start, func(t time.Time) bool {
+		if t.After(after) {
+			occurrences = append(occurrences, t)
+		}
+		return len(occurrences) < n
+	})
+	return occurrences
+}
+
+// each calls fn with every occurrence of the series starting at start, in order, until fn returns
+// false or the series ends. start itself is always the first occurrence.
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	until := r.Until
+	if r.UntilDate {
+		until = time.Date(until.Year(), until.Month(), until.Day(), 23, 59, 59, 0, start.Location())
+	}
+	ended := func(t time.Time) bool {
+		return !until.IsZero() && t.After(until)
+	}
+
+	if ended(start) || !fn(start) {
+		return
+	}
+	count := 1
+
+	for k := 0; k < maxPeriods && r.periodYear(start, k) <= lastYear; k++ {
+		for _, t := range r.period(start, k) {
+			if !t.After(start) {
+				continue
+			}
+			if ended(t) || (r.Count > 0 && count >= r.Count) {
+				return
+			}
+			count++
+			if !fn(t) {
+				return
+			}
+		}
+	}
+}
+
+// periodYear returns the year in which the kth period of the series starting at start begins.
+func (r *Rule) periodYear(start time.Time, k int) int {
+	step := k * r.Interval
+	switch r.Freq {
+	case Daily:
+		return start.AddDate(0, 0, step).Year()
+	case Weekly:
+		return start.AddDate(0, 0, 7*step).Year()
+	case Monthly:
+		return start.Year() + (int(start.Month())-1+step)/12
+	default:
+		return start.Year() + step
+	}
+}
+
+// period returns the candidate occurrences of the kth period of the series starting at start,
+// which is a day, week, month, or year depending on the frequency, in order.
+func (r *Rule) period(start time.Time, k int) []time.Time {
+	step := k * r.Interval
+	y, m, d := start.Date()
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := r.at(start, y, m, d+step)
+		if r.matchesMonth(day.Month()) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+	case Weekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		for i := 0; i < 7; i++ {
+			day := r.at(start, y, m, d-offset+7*step+i)
+			weekdays := r.ByDay
+			if len(weekdays) == 0 {
+				weekdays = []ByDay{{Weekday: start.Weekday()}}
+			}
+			if r.matchesMonth(day.Month()) && hasWeekday(weekdays, day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		first := time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchesMonth(first.Month()) {
+			days = r.monthDays(start, first.Year(), first.Month())
+		}
+	case Yearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		sorted := append([]time.Month(nil), months...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		for _, month := range sorted {
+			days = append(days, r.monthDays(start, y+step, month)...)
+		}
+	}
+	return days
+}
+
+// monthDays returns the candidate occurrences within one month, in order. Without BYMONTHDAY and
+// BYDAY the series falls on the day of the month of its start, and months without it are skipped.
+func (r *Rule) monthDays(start time.Time, y int, m time.Month) []time.Time {
+	length := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	var days []time.Time
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if start.Day() <= length {
+			days = append(days, r.at(start, y, m, start.Day()))
+		}
+		return days
+	}
+
+	for d := 1; d <= length; d++ {
+		day := r.at(start, y, m, d)
+		if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(day) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !matchesNthWeekday(r.ByDay, d, length, day.Weekday()) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// at returns the given day at the clock time of start, in its location. Days past the end of the
+// month roll over into the next one, as with time.Date.
+func (r *Rule) at(start time.Time, y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+}
+
+// matchesMonth reports whether month is allowed by BYMONTH.
+func (r *Rule) matchesMonth(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMonthDay reports whether the day of the month of t is allowed by BYMONTHDAY.
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || length+1+d == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday reports whether the weekday of t is allowed by BYDAY.
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	return len(r.ByDay) == 0 || hasWeekday(r.ByDay, t.Weekday())
+}
+
+// hasWeekday reports whether days includes weekday, ignoring any numbers.
+func hasWeekday(days []ByDay, weekday time.Weekday) bool {
+	for _, day := range days {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNthWeekday reports whether day d of a month with length days, falling on weekday, is
+// allowed by the BYDAY entries days.
+func matchesNthWeekday(days []ByDay, d, length int, weekday time.Weekday) bool {
+	for _, day := range days {
+		if day.Weekday != weekday {
+			continue
+		}
+		switch {
+		case day.N == 0:
+			return true
+		case day.N > 0 && (d-1)/7+1 == day.N:
+			return true
+		case day.N < 0 && (length-d)/7+1 == -day.N:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"sync"
+	"testing"
+	"time"
+)
+
+// firingTimes records the times on a clock at which the events a scheduler fires are handed to
+// its listeners.
+type firingTimes struct {
+	clock Clock
+
+	mu    sync.Mutex
+	times map[int][]time.Time
+}
+
+// listen subscribes to the events scheduler fires.
+func (f *firingTimes) listen(scheduler *Scheduler) {
+	scheduler.Subscribe(func(userID int, event store.Event) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.times[event.ID] = append(f.times[event.ID], f.clock.Now())
+	})
+}
+
+// check fails the test unless event fired exactly at want.
+func (f *firingTimes) check(t *testing.T, eventID int, want ...time.Time) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	got := f.times[eventID]
+	if len(got) != len(want) {
+		t.Fatalf("event %d fired at %v, want %v", eventID, got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("event %d fired at %v, want %v", eventID, got, want)
+		}
+	}
+}
+
+func TestEventAndReminderFireAtSimulatedTimes(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		clock := NewFakeClock(testStart)
+		scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+		fired := &firingTimes{clock: clock, times: map[int][]time.Time{}}
+		fired.listen(scheduler)
+
+		alice := createUser(t, st, "alice")
+		event := createEvent(t, st, alice, "standup", testStart.Add(30*time.Minute))
+		if _, err := st.Reminders.Add(context.Background(), alice, event.ID, 10*time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		advance(t, scheduler, clock, 19*time.Minute)
+		fired.check(t, event.ID)
+		advance(t, scheduler, clock, time.Minute)
+		fired.check(t, event.ID, testStart.Add(20*time.Minute))
+		advance(t, scheduler, clock, time.Hour)
+		fired.check(t, event.ID, testStart.Add(20*time.Minute), testStart.Add(30*time.Minute))
+	})
+}
+
+func TestRecurringEventFiresAtEveryOccurrence(t *testing.T) {
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+	fired := &firingTimes{clock: clock, times: map[int][]time.Time{}}
+	fired.listen(scheduler)
+
+	alice := createUser(t, st, "alice")
+	daily := "FREQ=DAILY"
+	event := &store.Event{Name: "standup", Message: "Daily standup", Date: testStart.Add(time.Hour).Format(time.RFC3339), Recurrence: &daily}
+	if err := st.Events.Create(context.Background(), alice, event); err != nil {
+		t.Fatal(err)
+	}
+
+	advance(t, scheduler, clock, 3*24*time.Hour)
+	day := 24 * time.Hour
+	fired.check(t, event.ID, testStart.Add(time.Hour), testStart.Add(day+time.Hour), testStart.Add(2*day+time.Hour))
+}
+
+func TestMissedEventsFireWithinGrace(t *testing.T) {
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	config := testConfig(clock)
+	config.MissedGrace = time.Hour
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, config)
+	fired := &firingTimes{clock: clock, times: map[int][]time.Time{}}
+	fired.listen(scheduler)
+
+	alice := createUser(t, st, "alice")
+	recent := createEvent(t, st, alice, "recent", testStart.Add(2*time.Hour))
+	stale := createEvent(t, st, alice, "stale", testStart.Add(time.Hour))
+
+	// No tick runs while the clock jumps, as when every scheduler was down
+	clock.Set(testStart.Add(150 * time.Minute))
+	if err := scheduler.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fired.check(t, recent.ID, testStart.Add(150*time.Minute))
+	fired.check(t, stale.ID)
+}
+
+func TestCompletedEventDoesNotFire(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		clock := NewFakeClock(testStart)
+		scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+		fired := &firingTimes{clock: clock, times: map[int][]time.Time{}}
+		fired.listen(scheduler)
+
+		alice := createUser(t, st, "alice")
+		done := createEvent(t, st, alice, "done", testStart.Add(10*time.Minute))
+		pending := createEvent(t, st, alice, "pending", testStart.Add(10*time.Minute))
+		_, err := st.Events.Update(context.Background(), alice, "done", func(event *store.Event) error {
+			completed := testStart
+			event.CompletedAt = &completed
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		advance(t, scheduler, clock, time.Hour)
+		fired.check(t, done.ID)
+		fired.check(t, pending.ID, testStart.Add(10*time.Minute))
+	})
+}
+
+func TestShortIntervalFiresOnceOnTime(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		clock := NewFakeClock(testStart)
+		config := testConfig(clock)
+		config.Interval = time.Second
+		scheduler := New(st.Events, st.Reminders, st.Deliveries, config)
+		fired := &firingTimes{clock: clock, times: map[int][]time.Time{}}
+		fired.listen(scheduler)
+
+		alice := createUser(t, st, "alice")
+		soon := createEvent(t, st, alice, "standup", testStart.Add(3*time.Second))
+		later := createEvent(t, st, alice, "review", testStart.Add(7*time.Second))
+
+		// Ticking every second, each event fires on the tick at its date, and ticks repeated at
+		// the same time do not fire it again
+		for i := 0; i < 10; i++ {
+			clock.Advance(time.Second)
+			for repeat := 0; repeat < 2; repeat++ {
+				if err := scheduler.Step(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		fired.check(t, soon.ID, testStart.Add(3*time.Second))
+		fired.check(t, later.ID, testStart.Add(7*time.Second))
+	})
+}
+
+// blockingNotifier holds every delivery until release is closed, signalling started when the first
+// one begins.
+type blockingNotifier struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (n *blockingNotifier) Channel() string { return "blocking" }
+
+func (n *blockingNotifier) Notify(ctx context.Context, due store.DueEvent) error {
+	n.once.Do(func() { close(n.started) })
+	<-n.release
+	return nil
+}
+
+func TestSlowTickDoesNotOverlap(t *testing.T) {
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	config := testConfig(clock)
+	config.Interval = time.Second
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, config)
+	fired := &firingTimes{clock: clock, times: map[int][]time.Time{}}
+	fired.listen(scheduler)
+	notifier := &blockingNotifier{started: make(chan struct{}), release: make(chan struct{})}
+	scheduler.AddNotifier(notifier)
+
+	alice := createUser(t, st, "alice")
+	event := createEvent(t, st, alice, "standup", testStart.Add(time.Second))
+	clock.Advance(time.Second)
+
+	// A second tick starting while the first one still delivers waits for it, then finds the
+	// event fired
+	errs := make(chan error, 2)
+	go func() { errs <- scheduler.Step(context.Background()) }()
+	<-notifier.started
+	go func() { errs <- scheduler.Step(context.Background()) }()
+	close(notifier.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	fired.check(t, event.ID, testStart.Add(time.Second))
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/store"
+	"log/slog"
+	"time"
+)
+
+// PurgeConfig struct holds the timing of the purge of old events.
+type PurgeConfig struct {
+	// Interval between two purges
+	Interval time.Duration
+	// Retention is how long after its date an event that fired or was completed is kept; zero
+	// keeps them all
+	Retention time.Duration
+	// TrashRetention is how long a deleted event stays in the trash; zero keeps them all
+	TrashRetention time.Duration
+	// BatchSize caps how many events a single delete removes, so no statement holds locks for long
+	BatchSize int
+	// Clock tells the time at every purge; nil means the system clock
+	Clock Clock
+}
+
+// Purger periodically deletes events that fired or were completed more than the retention
+// period ago, and empties the trash of events deleted more than the trash retention period ago.
+// Users who opted out keep all their events that fired or were completed, but not those in the
+// trash. The delivery history is kept.
+type Purger struct {
+	events store.EventStore
+	config PurgeConfig
+	clock  Clock
+}
+
+// NewPurger returns a Purger deleting old events from events.
+func NewPurger(events store.EventStore, config PurgeConfig) *Purger {
+	return &Purger{events: events, config: config, clock: orSystem(config.Clock)}
+}
+
+// Run purges old events every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.Purge(ctx, p.clock.Now()); err != nil {
+				slog.Error("Event purge failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Purge deletes the events dated more than the retention period before now that fired or were
+// completed, and those deleted more than the trash retention period before now, BatchSize events
+// at a time, and returns how many were deleted.
+func (p *Purger) Purge(ctx context.Context, now time.Time) (int64, error) {
+	var purged int64
+	if p.config.Retention > 0 {
+		before := now.Add(-p.config.Retention)
+		deleted, err := p.batches(ctx, p.events.Purge, before)
+		purged += deleted
+		if deleted > 0 {
+			slog.Info("Purged events", slog.Int64("count", deleted), slog.Time("before", before.UTC()))
+		}
+		if err != nil {
+			return purged, err
+		}
+	}
+	if p.config.TrashRetention > 0 {
+		before := now.Add(-p.config.TrashRetention)
+		deleted, err := p.batches(ctx, p.events.PurgeTrash, before)
+		purged += deleted
+		if deleted > 0 {
+			slog.Info("Emptied trash", slog.Int64("count", deleted), slog.Time("before", before.UTC()))
+		}
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// batches calls purge with before until it deletes fewer than BatchSize events, and returns how
+// many it deleted in total.
+func (p *Purger) batches(ctx context.Context, purge func(context.Context, time.Time, int) (int64, error), before time.Time) (int64, error) {
+	var purged int64
+	for {
+		deleted, err := purge(ctx, before, p.config.BatchSize)
+		purged += deleted
+		if err != nil || deleted < int64(p.config.BatchSize) {
+			return purged, err
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/store"
+	"testing"
+	"time"
+)
+
+func TestPurgerDeletesOldFiredEvents(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := context.Background()
+		clock := NewFakeClock(testStart)
+		scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+		// A batch size of one makes every purge take several batches
+		purger := NewPurger(st.Events, PurgeConfig{Retention: 24 * time.Hour, BatchSize: 1, Clock: clock})
+
+		alice := createUser(t, st, "alice")
+		createEvent(t, st, alice, "standup", testStart.Add(time.Minute))
+		createEvent(t, st, alice, "review", testStart.Add(2*time.Minute))
+		daily := "FREQ=DAILY"
+		recurring := &store.Event{Name: "water", Message: "Water the plants", Date: testStart.Add(time.Minute).Format(time.RFC3339), Recurrence: &daily}
+		if err := st.Events.Create(ctx, alice, recurring); err != nil {
+			t.Fatal(err)
+		}
+		advance(t, scheduler, clock, 5*time.Minute)
+		createEvent(t, st, alice, "retro", testStart.Add(30*time.Hour))
+
+		// Within the retention nothing goes
+		clock.Advance(23 * time.Hour)
+		if purged, err := purger.Purge(ctx, clock.Now()); err != nil || purged != 0 {
+			t.Fatalf("within retention: got %d purged, %v, want none", purged, err)
+		}
+
+		// Past it the events that fired go, the recurring event moved on to its next date and the
+		// one due later stay
+		clock.Advance(time.Hour)
+		if purged, err := purger.Purge(ctx, clock.Now()); err != nil || purged != 2 {
+			t.Fatalf("past retention: got %d purged, %v, want 2", purged, err)
+		}
+		for name, gone := range map[string]bool{"standup": true, "review": true, "water": false, "retro": false} {
+			if _, err := st.Events.Get(ctx, alice, name); errors.Is(err, store.ErrNotFound) != gone {
+				t.Errorf("%s: got %v, want gone %t", name, err, gone)
+			}
+		}
+	})
+}
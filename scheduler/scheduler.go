@@ -0,0 +1,660 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/metrics"
+	"github.com/Vansh3140/Reminder-App/store"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Listener is called for every event that fires, once with the id of the user owning it and once
+// with the id of each user it is shared with. Listeners are best effort; use a Notifier when
+// delivery must be tracked and retried.
+type Listener func(userID int, event store.Event)
+
+// Notifier delivers fired events through one channel, such as email. Every call is recorded
+// as a delivery attempt, and failed attempts are retried.
+type Notifier interface {
+	// Channel names the notifier in the delivery history, e.g. "email".
+	Channel() string
+	// Notify delivers a due event to its recipient. Additional recipients of an event are notified
+	// with separate calls whose recipient only has an email address and the owner's timezone. Users
+	// the event is shared with are notified with separate calls addressed to them as if they owned
+	// the event, with their own UserID and Recipient.
+	Notify(ctx context.Context, due store.DueEvent) error
+}
+
+// OwnerNotifier is a Notifier delivering to destinations the owner of an event configured, such as
+// webhooks, rather than to a recipient. It is called once for the owner and once for each user the
+// event is shared with, without the additional recipients, and not at all for users without a
+// destination.
+type OwnerNotifier interface {
+	Notifier
+	// HasDestination reports whether a user configured anywhere to deliver to.
+	HasDestination(ctx context.Context, userID int) (bool, error)
+}
+
+// Config struct holds the timing of the scheduler.
+type Config struct {
+	// Interval between two checks for due events and deliveries
+	Interval time.Duration
+	// MaxAttempts is how often a delivery is attempted, including the first attempt
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles after every failed attempt, up
+	// to a day
+	RetryBackoff time.Duration
+	// BatchSize caps how many due events are loaded and processed at once
+	BatchSize int
+	// LeaseTimeout is how long a claim on an event or delivery is held before another instance
+	// may take it over, e.g. because the claiming instance crashed
+	LeaseTimeout time.Duration
+	// UrgentRepeat is how long after firing an urgent event fires again, over and over until it is
+	// dismissed or completed; zero fires urgent events once like any other
+	UrgentRepeat time.Duration
+	// MissedGrace is how late an event or reminder that was missed, e.g. while no scheduler ran,
+	// still fires; zero fires them however late
+	MissedGrace time.Duration
+	// Clock tells the time at every tick; nil means the system clock
+	Clock Clock
+}
+
+// Scheduler periodically looks for events whose date has passed and hands them to its listeners
+// and notifiers. Several instances may run against the same database: each claims the events it
+// processes, so every event fires once. Deliveries through notifiers stay pending until they
+// succeed or run out of attempts.
+type Scheduler struct {
+	events     store.EventStore
+	reminders  store.ReminderStore
+	deliveries store.DeliveryStore
+	config     Config
+	owner      string
+	clock      Clock
+
+	mu        sync.Mutex
+	listeners []Listener
+	notifiers map[string]Notifier
+
+	// State of Run reported by Health, guarded by healthMu rather than mu, which ticks hold while
+	// they run
+	healthMu sync.Mutex
+	running  bool
+	lastTick time.Time
+}
+
+// New returns a Scheduler that fires the events in events and their reminders in reminders, and
+// records deliveries in deliveries. Events and reminders that came due before the scheduler was
+// created, e.g. during a restart, fire on its first tick, unless they are older than MissedGrace.
+func New(events store.EventStore, reminders store.ReminderStore, deliveries store.DeliveryStore, config Config) *Scheduler {
+	clock := orSystem(config.Clock)
+	return &Scheduler{
+		events:     events,
+		reminders:  reminders,
+		deliveries: deliveries,
+		config:     config,
+		owner:      newOwner(),
+		clock:      clock,
+		notifiers:  map[string]Notifier{},
+	}
+}
+
+// newOwner returns a name identifying this scheduler's claims, unique across instances.
+func newOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "scheduler"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Subscribe registers a listener for fired events.
+func (s *Scheduler) Subscribe(listener Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// AddNotifier registers a notifier. Fired events are delivered through every registered notifier,
+// unless the event selects specific channels.
+func (s *Scheduler) AddNotifier(notifier Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifiers[notifier.Channel()] = notifier
+}
+
+// Channels returns the channel names of the registered notifiers.
+func (s *Scheduler) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channels := make([]string, 0, len(s.notifiers))
+	for channel := range s.notifiers {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Run checks for due events every interval until ctx is cancelled, so events fire at most one
+// interval late. Ticks never overlap: when a tick takes longer than the interval, the ticks missed
+// meanwhile are dropped and the next one starts right away. The interval is measured in real time;
+// each tick reads the scheduler's clock. Tests drive the scheduler with Step instead.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	s.setRunning(true)
+	defer s.setRunning(false)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Read the clock instead of using the tick's time, which is stale after a slow tick
+			if err := s.Step(ctx); err != nil {
+				slog.Error("Scheduler tick failed", slog.Any("error", err))
+				continue
+			}
+			s.healthMu.Lock()
+			s.lastTick = time.Now()
+			s.healthMu.Unlock()
+		}
+	}
+}
+
+// setRunning records whether Run is running. Starting counts as a successful tick, so a scheduler
+// that just started is healthy until its first ticks are due.
+func (s *Scheduler) setRunning(running bool) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.running = running
+	if running {
+		s.lastTick = time.Now()
+	}
+}
+
+// Intervals that may pass without a successful tick before Health reports the scheduler as stalled
+const stalledIntervals = 3
+
+// Health returns nil while Run is running and has completed a tick without error within the last
+// three intervals, and otherwise an error describing the problem, e.g. for a readiness probe. Ticks
+// fail while the database is unreachable; a tick running for longer than that is stuck, e.g. on a
+// notifier that does not time out.
+func (s *Scheduler) Health() error {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	if !s.running {
+		return errors.New("scheduler is not running")
+	}
+	if since := time.Since(s.lastTick); since > stalledIntervals*s.config.Interval {
+		return fmt.Errorf("no successful scheduler tick for %s", since.Round(time.Second))
+	}
+	return nil
+}
+
+// Step runs a tick at the time the scheduler's clock tells.
+func (s *Scheduler) Step(ctx context.Context) error {
+	return s.Tick(ctx, s.clock.Now())
+}
+
+// Tick retries the deliveries due at now and then fires the events dated at or before now that
+// have not fired yet, followed by the reminders due ahead of them, BatchSize at a time. Events and
+// reminders are claimed before they fire; if this instance stops before marking them fired, another
+// instance fires them once the lease expires. The leases restart before every event of a batch, so
+// slow notifiers do not let the claims on the rest of the batch expire. Fired events and reminders are marked in the store,
+// so consecutive ticks with overlapping windows fire each once, however short the interval.
+// Concurrent calls run one after the other.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.retry(ctx, now); err != nil {
+		return err
+	}
+	if err := s.fireEvents(ctx, now); err != nil {
+		return err
+	}
+	return s.fireReminders(ctx, now)
+}
+
+// missedSince returns the time after which events and reminders due at now must lie to fire: the
+// zero time, which bounds nothing, unless MissedGrace is set. Whether they fired already is
+// recorded on them, so no bound is needed to fire each once.
+func (s *Scheduler) missedSince(now time.Time) time.Time {
+	if s.config.MissedGrace <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-s.config.MissedGrace)
+}
+
+// fireEvents fires the events due at now and moves the recurring ones to their next occurrence.
+func (s *Scheduler) fireEvents(ctx context.Context, now time.Time) error {
+	for {
+		// Each batch comes with its recipients, so firing it takes a single query
+		due, err := s.events.ClaimDue(ctx, s.owner, s.missedSince(now), now, s.config.LeaseTimeout, s.config.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int, len(due))
+		for i, d := range due {
+			if i > 0 {
+				s.renewClaims(ctx, s.events.RenewClaims)
+			}
+			s.fire(ctx, d, now)
+			ids[i] = d.Event.ID
+		}
+		if err := s.events.MarkFired(ctx, s.owner, ids, now); err != nil {
+			return err
+		}
+		metrics.Fired.WithLabelValues("event").Add(float64(len(due)))
+		for _, d := range due {
+			if d.Event.Priority == store.PriorityUrgent && s.config.UrgentRepeat > 0 {
+				s.escalate(ctx, d, now)
+			}
+			if d.Event.Recurrence != nil && *d.Event.Recurrence != "" {
+				s.advance(ctx, d, now)
+			}
+		}
+
+		if len(due) < s.config.BatchSize {
+			return nil
+		}
+	}
+}
+
+// fireReminders fires the reminders due at now. An event with several reminders due at once fires
+// once for each of them.
+func (s *Scheduler) fireReminders(ctx context.Context, now time.Time) error {
+	for {
+		due, err := s.reminders.ClaimDue(ctx, s.owner, s.missedSince(now), now, s.config.LeaseTimeout, s.config.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int, len(due))
+		for i, d := range due {
+			if i > 0 {
+				s.renewClaims(ctx, s.reminders.RenewClaims)
+			}
+			s.fire(ctx, d, now)
+			ids[i] = d.ReminderID
+		}
+		if err := s.reminders.MarkFired(ctx, s.owner, ids, now); err != nil {
+			return err
+		}
+		metrics.Fired.WithLabelValues("reminder").Add(float64(len(due)))
+
+		if len(due) < s.config.BatchSize {
+			return nil
+		}
+	}
+}
+
+// renewClaims restarts the leases on the claims of this instance with renew, before firing the next
+// due event of a batch. Notifiers run while the claims are held, and without the renewal a batch
+// delivered slower than LeaseTimeout would be claimed and fired again by another instance.
+func (s *Scheduler) renewClaims(ctx context.Context, renew func(ctx context.Context, owner string, now time.Time) error) {
+	if err := renew(ctx, s.owner, s.clock.Now()); err != nil {
+		slog.Error("Failed to renew claims", slog.Any("error", err))
+	}
+}
+
+// fire hands a due event to every listener, for its owner and each user it is shared with, and
+// delivers it through the notifiers.
+func (s *Scheduler) fire(ctx context.Context, d store.DueEvent, now time.Time) {
+	for _, listener := range s.listeners {
+		listener(d.UserID, d.Event)
+		for _, participant := range d.Participants {
+			listener(participant.UserID, d.Event)
+		}
+	}
+	s.deliver(ctx, d, now, false)
+}
+
+// Resend delivers an event through its notifiers right away, whether or not it fired, and returns
+// the deliveries recorded, marked as manual. Failed deliveries are retried as usual. Listeners are
+// not called, since the event does not fire again.
+func (s *Scheduler) Resend(ctx context.Context, d store.DueEvent) []store.Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := s.deliver(ctx, d, s.clock.Now(), true)
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries
+}
+
+// deliver starts a delivery through every notifier selected by the event, to the owner, to each
+// additional recipient, and to each user the event is shared with, and returns the deliveries
+// recorded. All of them belong to the owner. Users who turned a channel off are not delivered to
+// through it, and nothing is delivered for owners whose account was disabled.
+func (s *Scheduler) deliver(ctx context.Context, d store.DueEvent, now time.Time, manual bool) []store.Delivery {
+	deliveries := []store.Delivery{}
+	if d.Recipient.Disabled {
+		return deliveries
+	}
+	for _, notifier := range s.notifiers {
+		if !selects(d.Event, notifier.Channel()) {
+			continue
+		}
+
+		// An empty recipient stands for the owner and a username for a user the event is shared
+		// with; the addresses of additional recipients always hold an @, which usernames do not
+		var users []string
+		if d.Recipient.Receives(notifier.Channel()) {
+			users = append(users, "")
+		}
+		for _, participant := range d.Participants {
+			if participant.Recipient.Receives(notifier.Channel()) {
+				users = append(users, participant.Recipient.Username)
+			}
+		}
+		recipients := append(append([]string{}, users...), d.Event.Recipients...)
+		if owner, ok := notifier.(OwnerNotifier); ok {
+			recipients = recipients[:0]
+			for _, user := range users {
+				userID := addressedTo(d, user).UserID
+				// When the lookup fails the delivery is recorded anyway, so it is retried
+				has, err := owner.HasDestination(ctx, userID)
+				if err != nil {
+					slog.Error("Failed to look up destinations", slog.Int("user_id", userID), slog.String("channel", notifier.Channel()), slog.Any("error", err))
+				} else if !has {
+					continue
+				}
+				recipients = append(recipients, user)
+			}
+		}
+
+		for _, recipient := range recipients {
+			delivery := &store.Delivery{
+				UserID:    d.UserID,
+				EventID:   d.Event.ID,
+				EventName: d.Event.Name,
+				Channel:   notifier.Channel(),
+				Recipient: recipient,
+				Manual:    manual,
+				Status:    store.DeliveryPending,
+			}
+			if err := s.deliveries.Create(ctx, delivery); err != nil {
+				slog.Error("Failed to record delivery", slog.Int("event_id", d.Event.ID), slog.String("channel", notifier.Channel()), slog.Any("error", err))
+				continue
+			}
+			s.attempt(ctx, delivery, notifier, addressedTo(d, recipient), now)
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries
+}
+
+// errNotAdvanced aborts the update in advance when an event is to stay as it is.
+var errNotAdvanced = errors.New("event not advanced")
+
+// advance moves a recurring event that fired to its next occurrence after now, which makes it fire
+// again. Occurrences missed while no scheduler ran are skipped. An event whose date was changed
+// since it fired, or whose series has ended, is left as it is.
+func (s *Scheduler) advance(ctx context.Context, d store.DueEvent, now time.Time) {
+	// The rule is expanded in the owner's timezone, so occurrences keep their local clock time
+	loc, err := time.LoadLocation(d.Recipient.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	_, err = s.events.Update(ctx, d.UserID, d.Event.Name, func(event *store.Event) error {
+		if event.Date != d.Event.Date {
+			return errNotAdvanced
+		}
+		next := store.Occurrences(event, loc, now, 1)
+		if len(next) == 0 {
+			return errNotAdvanced
+		}
+		event.Date = next[0].Format(time.RFC3339)
+		return nil
+	})
+	// A deleted or renamed event is not advanced either
+	if err != nil && !errors.Is(err, errNotAdvanced) && !errors.Is(err, store.ErrNotFound) {
+		slog.Error("Failed to move recurring event to its next occurrence", slog.Int("event_id", d.Event.ID), slog.Any("error", err))
+	}
+}
+
+// errNotEscalated aborts the update in escalate when an event is not to fire again.
+var errNotEscalated = errors.New("event not escalated")
+
+// escalate snoozes an urgent event that fired for UrgentRepeat, so that it fires again until the
+// user dismisses it, which clears the snooze. An event that was dismissed, snoozed, or completed
+// since it was claimed, or is no longer urgent, is left as it is.
+func (s *Scheduler) escalate(ctx context.Context, d store.DueEvent, now time.Time) {
+	_, err := s.events.Update(ctx, d.UserID, d.Event.Name, func(event *store.Event) error {
+		if event.Priority != store.PriorityUrgent || event.CompletedAt != nil || event.SnoozedUntil != nil ||
+			!sameTime(event.DismissedAt, d.Event.DismissedAt) {
+			return errNotEscalated
+		}
+		// Whole seconds, matching the precision of the stored DATETIME
+		until := now.UTC().Add(s.config.UrgentRepeat).Truncate(time.Second)
+		event.SnoozedUntil = &until
+		return nil
+	})
+	if err != nil && !errors.Is(err, errNotEscalated) && !errors.Is(err, store.ErrNotFound) {
+		slog.Error("Failed to repeat urgent event", slog.Int("event_id", d.Event.ID), slog.Any("error", err))
+	}
+}
+
+// sameTime reports whether a and b are both nil or the same instant.
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// addressedTo returns d addressed to the user the event is shared with named by recipient, as if
+// they owned it, or to an additional recipient, or d itself for an empty recipient.
+func addressedTo(d store.DueEvent, recipient string) store.DueEvent {
+	if recipient == "" {
+		return d
+	}
+	for _, participant := range d.Participants {
+		if participant.Recipient.Username == recipient {
+			d.UserID, d.Recipient = participant.UserID, participant.Recipient
+			return d
+		}
+	}
+	d.Recipient = store.Recipient{Email: recipient, Timezone: d.Recipient.Timezone}
+	return d
+}
+
+// hasRecipient reports whether recipient is still to be notified of d: an additional recipient of
+// the event or a user it is shared with. The owner always is.
+func hasRecipient(d store.DueEvent, recipient string) bool {
+	if recipient == "" {
+		return true
+	}
+	for _, email := range d.Event.Recipients {
+		if email == recipient {
+			return true
+		}
+	}
+	for _, participant := range d.Participants {
+		if participant.Recipient.Username == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// selects reports whether event is to be delivered through channel. Events without a
+// channel selection are delivered through every channel, except email when they turned it off
+// and SMS unless they turned it on.
+func selects(event store.Event, channel string) bool {
+	if channel == store.EmailChannel && event.EmailNotifications != nil && !*event.EmailNotifications {
+		return false
+	}
+	if channel == store.SMSChannel && (event.SMSNotifications == nil || !*event.SMSNotifications) {
+		return false
+	}
+	if len(event.Channels) == 0 {
+		return true
+	}
+	for _, selected := range event.Channels {
+		if selected == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// retry claims and attempts the pending deliveries whose next attempt is due at now.
+func (s *Scheduler) retry(ctx context.Context, now time.Time) error {
+	pending, err := s.deliveries.ClaimRetryable(ctx, s.owner, now, s.config.LeaseTimeout)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	// Load the current state of every event involved, with its recipient, in one query;
+	// events may have changed since their first attempt
+	ids := make([]int, 0, len(pending))
+	seen := map[int]bool{}
+	for _, delivery := range pending {
+		if !seen[delivery.EventID] {
+			seen[delivery.EventID] = true
+			ids = append(ids, delivery.EventID)
+		}
+	}
+	due, err := s.events.DueByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]store.DueEvent, len(due))
+	for _, d := range due {
+		byID[d.Event.ID] = d
+	}
+
+	for i := range pending {
+		delivery := &pending[i]
+
+		notifier, ok := s.notifiers[delivery.Channel]
+		if !ok {
+			s.fail(ctx, delivery, fmt.Errorf("notifier %q is no longer configured", delivery.Channel))
+			continue
+		}
+
+		d, ok := byID[delivery.EventID]
+		if !ok || d.UserID != delivery.UserID {
+			s.fail(ctx, delivery, errors.New("event no longer exists"))
+			continue
+		}
+		if !hasRecipient(d, delivery.Recipient) {
+			s.fail(ctx, delivery, errors.New("recipient was removed from the event"))
+			continue
+		}
+		addressed := addressedTo(d, delivery.Recipient)
+		if d.Recipient.Disabled || addressed.Recipient.Disabled {
+			s.fail(ctx, delivery, errors.New("account was disabled"))
+			continue
+		}
+		if !addressed.Recipient.Receives(delivery.Channel) {
+			s.fail(ctx, delivery, errors.New("recipient turned the channel off"))
+			continue
+		}
+		if d.Event.CompletedAt != nil {
+			s.fail(ctx, delivery, errors.New("event was completed"))
+			continue
+		}
+		if d.Event.DismissedAt != nil && d.Event.DismissedAt.After(delivery.CreatedAt) {
+			s.fail(ctx, delivery, errors.New("reminder was dismissed"))
+			continue
+		}
+
+		s.attempt(ctx, delivery, notifier, addressed, now)
+	}
+	return nil
+}
+
+// attempt delivers a due event through notifier once and records the outcome, both in the log of
+// attempts and on the delivery. After a failure the delivery is scheduled for a retry with
+// exponential backoff until MaxAttempts is reached.
+func (s *Scheduler) attempt(ctx context.Context, delivery *store.Delivery, notifier Notifier, d store.DueEvent, now time.Time) {
+	d.DeliveryID = delivery.ID
+	err := notifier.Notify(ctx, d)
+	delivery.Attempts++
+
+	logged := &store.DeliveryAttempt{
+		UserID:     delivery.UserID,
+		DeliveryID: delivery.ID,
+		EventID:    delivery.EventID,
+		Channel:    delivery.Channel,
+		Recipient:  delivery.Recipient,
+		Status:     store.DeliverySent,
+	}
+	if err != nil {
+		logged.Status = store.DeliveryFailed
+		logged.Error = err.Error()
+	}
+	if err := s.deliveries.RecordAttempt(ctx, logged); err != nil {
+		slog.Error("Failed to log delivery attempt", slog.Int("delivery_id", delivery.ID), slog.Any("error", err))
+	}
+
+	switch {
+	case err == nil:
+		metrics.Deliveries.WithLabelValues(delivery.Channel, metrics.OutcomeSent).Inc()
+		delivery.Status = store.DeliverySent
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= s.config.MaxAttempts:
+		metrics.Deliveries.WithLabelValues(delivery.Channel, metrics.OutcomeFailed).Inc()
+		slog.Warn("Giving up on delivery", slog.Int("delivery_id", delivery.ID), slog.String("channel", delivery.Channel),
+			slog.Int("attempts", delivery.Attempts), slog.Any("error", err))
+		delivery.Status = store.DeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		metrics.Deliveries.WithLabelValues(delivery.Channel, metrics.OutcomeRetry).Inc()
+		next := now.Add(s.retryDelay(delivery.Attempts)).UTC()
+		slog.Warn("Delivery failed, retrying", slog.Int("delivery_id", delivery.ID), slog.String("channel", delivery.Channel),
+			slog.Int("attempt", delivery.Attempts), slog.Int("max_attempts", s.config.MaxAttempts), slog.Time("retry_at", next), slog.Any("error", err))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := s.deliveries.Update(ctx, delivery); err != nil {
+		slog.Error("Failed to record outcome of delivery", slog.Int("delivery_id", delivery.ID), slog.Any("error", err))
+	}
+}
+
+// Longest wait between two delivery attempts, however often the delivery failed, unless
+// RetryBackoff alone is longer
+const maxRetryDelay = 24 * time.Hour
+
+// retryDelay returns how long to wait before retrying a delivery that failed attempts times:
+// RetryBackoff, doubled after every further failure until it reaches maxRetryDelay.
+func (s *Scheduler) retryDelay(attempts int) time.Duration {
+	delay := s.config.RetryBackoff
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay && s.config.RetryBackoff < maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// fail gives up on a delivery that can no longer be attempted.
+func (s *Scheduler) fail(ctx context.Context, delivery *store.Delivery, reason error) {
+	metrics.Deliveries.WithLabelValues(delivery.Channel, metrics.OutcomeFailed).Inc()
+	delivery.Status = store.DeliveryFailed
+	delivery.LastError = reason.Error()
+	delivery.NextAttemptAt = nil
+	if err := s.deliveries.Update(ctx, delivery); err != nil {
+		slog.Error("Failed to record outcome of delivery", slog.Int("delivery_id", delivery.ID), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,521 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/database"
+	"github.com/Vansh3140/Reminder-App/store"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Time the fake clocks of the tests start at
+var testStart = time.Date(2030, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// testConfig returns the configuration of a scheduler under test, ticking on a fake clock.
+func testConfig(clock Clock) Config {
+	return Config{
+		Interval:     time.Minute,
+		MaxAttempts:  3,
+		RetryBackoff: time.Minute,
+		BatchSize:    10,
+		LeaseTimeout: time.Minute,
+		Clock:        clock,
+	}
+}
+
+// eachStore runs test as a subtest against the in-memory store and the SQL store on a SQLite file,
+// for the tests of claims, which each store implements on its own.
+func eachStore(t *testing.T, test func(t *testing.T, st *store.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, store.NewMemory())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		t.Setenv("DB_DRIVER", "sqlite")
+		t.Setenv("DB_CREDS", filepath.Join(t.TempDir(), "reminders.db"))
+		db, err := database.Connect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		test(t, store.NewSQL(db))
+	})
+}
+
+// createUser stores a user and returns its id.
+func createUser(t *testing.T, s *store.Store, username string) int {
+	t.Helper()
+	id, err := s.Users.Create(context.Background(), username, "hash")
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return id
+}
+
+// createEvent stores an event of a user dated at date.
+func createEvent(t *testing.T, s *store.Store, userID int, name string, date time.Time) *store.Event {
+	t.Helper()
+	event := &store.Event{Name: name, Message: "Message of " + name, Date: date.UTC().Format(time.RFC3339)}
+	if err := s.Events.Create(context.Background(), userID, event); err != nil {
+		t.Fatalf("create event %s: %v", name, err)
+	}
+	return event
+}
+
+// advance moves clock forward one minute at a time for d, running a tick of scheduler after every
+// minute.
+func advance(t *testing.T, scheduler *Scheduler, clock *FakeClock, d time.Duration) {
+	t.Helper()
+	for end := clock.Now().Add(d); clock.Now().Before(end); {
+		clock.Advance(time.Minute)
+		if err := scheduler.Step(context.Background()); err != nil {
+			t.Fatalf("tick at %s: %v", clock.Now(), err)
+		}
+	}
+}
+
+// flakyNotifier fails the first failures deliveries and sends the others, recording when it was
+// called.
+type flakyNotifier struct {
+	clock    Clock
+	failures int
+
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func (n *flakyNotifier) Channel() string { return "flaky" }
+
+func (n *flakyNotifier) Notify(ctx context.Context, due store.DueEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls = append(n.calls, n.clock.Now())
+	if len(n.calls) <= n.failures {
+		return errors.New("server unavailable")
+	}
+	return nil
+}
+
+// deliveryOf returns the only delivery of an event.
+func deliveryOf(t *testing.T, s *store.Store, userID, eventID int) store.Delivery {
+	t.Helper()
+	deliveries, err := s.Deliveries.ForEvent(context.Background(), userID, eventID)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("deliveries of event %d: got %+v, %v, want one", eventID, deliveries, err)
+	}
+	return deliveries[0]
+}
+
+func TestFailedDeliveryIsRetriedUntilSent(t *testing.T) {
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+	notifier := &flakyNotifier{clock: clock, failures: 2}
+	scheduler.AddNotifier(notifier)
+
+	alice := createUser(t, st, "alice")
+	event := createEvent(t, st, alice, "standup", testStart.Add(time.Minute))
+
+	advance(t, scheduler, clock, time.Minute)
+	delivery := deliveryOf(t, st, alice, event.ID)
+	if delivery.Status != store.DeliveryPending || delivery.Attempts != 1 || delivery.LastError != "server unavailable" {
+		t.Fatalf("after the first attempt: got %+v, want a pending delivery", delivery)
+	}
+
+	advance(t, scheduler, clock, 10*time.Minute)
+	delivery = deliveryOf(t, st, alice, event.ID)
+	if delivery.Status != store.DeliverySent || delivery.Attempts != 3 || delivery.LastError != "" || delivery.NextAttemptAt != nil {
+		t.Fatalf("after the retries: got %+v, want a sent delivery", delivery)
+	}
+
+	// The backoff doubles after every failed attempt
+	want := []time.Time{testStart.Add(time.Minute), testStart.Add(2 * time.Minute), testStart.Add(4 * time.Minute)}
+	if len(notifier.calls) != len(want) {
+		t.Fatalf("got %d attempts at %v, want %d", len(notifier.calls), notifier.calls, len(want))
+	}
+	for i := range want {
+		if !notifier.calls[i].Equal(want[i]) {
+			t.Fatalf("attempt %d at %s, want %s", i+1, notifier.calls[i], want[i])
+		}
+	}
+
+	attempts, _, err := st.Deliveries.Attempts(context.Background(), alice, event.ID, store.Page{Limit: 10})
+	if err != nil || len(attempts) != 3 {
+		t.Fatalf("attempt log: got %+v, %v", attempts, err)
+	}
+	// Newest first
+	statuses := []string{attempts[0].Status, attempts[1].Status, attempts[2].Status}
+	if statuses[0] != store.DeliverySent || statuses[1] != store.DeliveryFailed || statuses[2] != store.DeliveryFailed {
+		t.Fatalf("attempt log statuses: got %v", statuses)
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+	notifier := &flakyNotifier{clock: clock, failures: 100}
+	scheduler.AddNotifier(notifier)
+
+	alice := createUser(t, st, "alice")
+	event := createEvent(t, st, alice, "standup", testStart.Add(time.Minute))
+
+	advance(t, scheduler, clock, time.Hour)
+	delivery := deliveryOf(t, st, alice, event.ID)
+	if delivery.Status != store.DeliveryFailed || delivery.Attempts != 3 || delivery.LastError != "server unavailable" || delivery.NextAttemptAt != nil {
+		t.Fatalf("got %+v, want a failed delivery after 3 attempts", delivery)
+	}
+	if len(notifier.calls) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(notifier.calls))
+	}
+	if pending, err := st.Deliveries.Pending(context.Background()); err != nil || pending != 0 {
+		t.Fatalf("got %d pending deliveries, %v, want none", pending, err)
+	}
+}
+
+// firings counts how often each event fired, across schedulers.
+type firings struct {
+	mu    sync.Mutex
+	count map[int]int
+}
+
+// listen subscribes to the events scheduler fires.
+func (f *firings) listen(scheduler *Scheduler) {
+	scheduler.Subscribe(func(userID int, event store.Event) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.count[event.ID]++
+	})
+}
+
+func TestContendingSchedulersFireEachEventOnce(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		clock := NewFakeClock(testStart)
+		config := testConfig(clock)
+		config.BatchSize = 3
+		fired := &firings{count: map[int]int{}}
+		schedulers := []*Scheduler{
+			New(st.Events, st.Reminders, st.Deliveries, config),
+			New(st.Events, st.Reminders, st.Deliveries, config),
+		}
+		for _, scheduler := range schedulers {
+			fired.listen(scheduler)
+		}
+
+		alice := createUser(t, st, "alice")
+		var ids []int
+		for i := 0; i < 20; i++ {
+			ids = append(ids, createEvent(t, st, alice, "event-"+string(rune('a'+i)), testStart.Add(time.Minute)).ID)
+		}
+
+		// Both schedulers tick at the same time, twice
+		clock.Advance(time.Minute)
+		for round := 0; round < 2; round++ {
+			var wg sync.WaitGroup
+			errs := make(chan error, len(schedulers))
+			for _, scheduler := range schedulers {
+				wg.Add(1)
+				go func(scheduler *Scheduler) {
+					defer wg.Done()
+					errs <- scheduler.Step(context.Background())
+				}(scheduler)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("tick: %v", err)
+				}
+			}
+		}
+
+		for _, id := range ids {
+			if fired.count[id] != 1 {
+				t.Fatalf("event %d fired %d times, want once; all: %v", id, fired.count[id], fired.count)
+			}
+		}
+	})
+}
+
+func TestClaimOfCrashedSchedulerExpires(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := context.Background()
+		clock := NewFakeClock(testStart)
+		config := testConfig(clock)
+		alice := createUser(t, st, "alice")
+		event := createEvent(t, st, alice, "standup", testStart.Add(time.Minute))
+
+		// Another instance claims the event and stops before firing it
+		clock.Advance(time.Minute)
+		claimed, err := st.Events.ClaimDue(ctx, "crashed", time.Time{}, clock.Now(), config.LeaseTimeout, config.BatchSize)
+		if err != nil || len(claimed) != 1 || claimed[0].Event.ID != event.ID {
+			t.Fatalf("ClaimDue: got %+v, %v", claimed, err)
+		}
+		again, err := st.Events.ClaimDue(ctx, "other", time.Time{}, clock.Now(), config.LeaseTimeout, config.BatchSize)
+		if err != nil || len(again) != 0 {
+			t.Fatalf("ClaimDue of a claimed event: got %+v, %v, want none", again, err)
+		}
+
+		scheduler := New(st.Events, st.Reminders, st.Deliveries, config)
+		fired := &firings{count: map[int]int{}}
+		fired.listen(scheduler)
+
+		// The event stays claimed for the lease, then this scheduler takes it over
+		if err := scheduler.Step(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if fired.count[event.ID] != 0 {
+			t.Fatal("event claimed by another instance fired before the lease expired")
+		}
+		advance(t, scheduler, clock, 2*config.LeaseTimeout)
+		if fired.count[event.ID] != 1 {
+			t.Fatalf("event fired %d times after the lease expired, want once", fired.count[event.ID])
+		}
+	})
+}
+
+// slowNotifier takes step of the fake clock for every delivery, running during while it is busy.
+type slowNotifier struct {
+	clock  *FakeClock
+	step   time.Duration
+	during func()
+}
+
+func (n *slowNotifier) Channel() string { return "slow" }
+
+func (n *slowNotifier) Notify(ctx context.Context, due store.DueEvent) error {
+	n.clock.Advance(n.step)
+	n.during()
+	return nil
+}
+
+func TestClaimsOutlastSlowBatch(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		clock := NewFakeClock(testStart)
+		config := testConfig(clock)
+		config.BatchSize = 3
+		fired := &firings{count: map[int]int{}}
+		slow := New(st.Events, st.Reminders, st.Deliveries, config)
+		other := New(st.Events, st.Reminders, st.Deliveries, config)
+		fired.listen(slow)
+		fired.listen(other)
+
+		// Every delivery takes two thirds of the lease, so the batch takes longer than the lease
+		// while another instance ticks after each delivery
+		slow.AddNotifier(&slowNotifier{clock: clock, step: 2 * config.LeaseTimeout / 3, during: func() {
+			if err := other.Step(context.Background()); err != nil {
+				t.Errorf("tick of the other instance: %v", err)
+			}
+		}})
+
+		alice := createUser(t, st, "alice")
+		var ids []int
+		for _, name := range []string{"standup", "review", "retro"} {
+			ids = append(ids, createEvent(t, st, alice, name, testStart.Add(time.Minute)).ID)
+		}
+
+		clock.Advance(time.Minute)
+		if err := slow.Step(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := clock.Now().Sub(testStart.Add(time.Minute)); elapsed <= config.LeaseTimeout {
+			t.Fatalf("batch took %s, want longer than the lease", elapsed)
+		}
+		for _, id := range ids {
+			if fired.count[id] != 1 {
+				t.Fatalf("event %d fired %d times, want once; all: %v", id, fired.count[id], fired.count)
+			}
+		}
+	})
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	config := testConfig(clock)
+	config.MaxAttempts = 100
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, config)
+
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{11, 1024 * time.Minute},
+		{12, maxRetryDelay},
+		// Shifting the backoff this far would overflow
+		{64, maxRetryDelay},
+		{99, maxRetryDelay},
+	}
+	for _, test := range tests {
+		if delay := scheduler.retryDelay(test.attempts); delay != test.delay {
+			t.Errorf("after %d attempts: got %s, want %s", test.attempts, delay, test.delay)
+		}
+	}
+
+	// A delivery failing for the 70th time is retried a day later
+	notifier := &flakyNotifier{clock: clock, failures: 1}
+	delivery := &store.Delivery{ID: 1, Channel: notifier.Channel(), Status: store.DeliveryPending, Attempts: 69}
+	scheduler.attempt(context.Background(), delivery, notifier, store.DueEvent{}, testStart)
+	if delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(testStart.Add(maxRetryDelay)) {
+		t.Fatalf("got next attempt at %v, want %s", delivery.NextAttemptAt, testStart.Add(maxRetryDelay))
+	}
+
+	// A backoff configured above the cap is kept
+	config.RetryBackoff = 48 * time.Hour
+	scheduler = New(st.Events, st.Reminders, st.Deliveries, config)
+	if delay := scheduler.retryDelay(3); delay != 48*time.Hour {
+		t.Fatalf("backoff of 48h: got %s, want 48h", delay)
+	}
+}
+
+// channelNotifier sends every delivery of its channel, recording the events it was called for.
+type channelNotifier struct {
+	channel string
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (n *channelNotifier) Channel() string { return n.channel }
+
+func (n *channelNotifier) Notify(ctx context.Context, due store.DueEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, due.Event.Name)
+	return nil
+}
+
+func TestEventChannelsSelectNotifiers(t *testing.T) {
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+	email := &channelNotifier{channel: "email"}
+	webhook := &channelNotifier{channel: "webhook"}
+	slack := &channelNotifier{channel: "slack"}
+	for _, notifier := range []*channelNotifier{email, webhook, slack} {
+		scheduler.AddNotifier(notifier)
+	}
+
+	alice := createUser(t, st, "alice")
+	selected := &store.Event{Name: "standup", Message: "Daily standup", Date: testStart.Add(time.Minute).Format(time.RFC3339),
+		Channels: []string{"email", "webhook"}}
+	if err := st.Events.Create(context.Background(), alice, selected); err != nil {
+		t.Fatal(err)
+	}
+	createEvent(t, st, alice, "review", testStart.Add(time.Minute))
+	advance(t, scheduler, clock, time.Minute)
+
+	// The event selecting two channels goes out through both of them, the other one through all
+	for _, test := range []struct {
+		notifier *channelNotifier
+		want     []string
+	}{
+		{email, []string{"review", "standup"}},
+		{webhook, []string{"review", "standup"}},
+		{slack, []string{"review"}},
+	} {
+		sort.Strings(test.notifier.events)
+		if !reflect.DeepEqual(test.notifier.events, test.want) {
+			t.Errorf("%s: got events %v, want %v", test.notifier.channel, test.notifier.events, test.want)
+		}
+	}
+}
+
+// addressNotifier sends every delivery, recording the address it went to.
+type addressNotifier struct {
+	mu        sync.Mutex
+	addresses []string
+}
+
+func (n *addressNotifier) Channel() string { return "email" }
+
+func (n *addressNotifier) Notify(ctx context.Context, due store.DueEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.addresses = append(n.addresses, due.Recipient.Email)
+	return nil
+}
+
+func TestRecipientsEachGetADelivery(t *testing.T) {
+	eachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := context.Background()
+		clock := NewFakeClock(testStart)
+		scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+		notifier := &addressNotifier{}
+		scheduler.AddNotifier(notifier)
+
+		alice := createUser(t, st, "alice")
+		if err := st.Users.UpdateSettings(ctx, alice, "alice@example.com", "UTC", nil); err != nil {
+			t.Fatal(err)
+		}
+		event := &store.Event{Name: "dinner", Message: "Family dinner", Date: testStart.Add(time.Minute).Format(time.RFC3339),
+			Recipients: []string{"bob@example.com", "carol@example.com", "dave@example.com"}}
+		if err := st.Events.Create(ctx, alice, event); err != nil {
+			t.Fatal(err)
+		}
+		advance(t, scheduler, clock, time.Minute)
+
+		// The owner is notified, then each of the three recipients
+		want := []string{"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"}
+		if !reflect.DeepEqual(notifier.addresses, want) {
+			t.Fatalf("got notified %v, want %v", notifier.addresses, want)
+		}
+		deliveries, err := st.Deliveries.ForEvent(ctx, alice, event.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var recipients []string
+		for _, delivery := range deliveries {
+			if delivery.Status != store.DeliverySent {
+				t.Errorf("delivery to %q: got status %s, want sent", delivery.Recipient, delivery.Status)
+			}
+			recipients = append(recipients, delivery.Recipient)
+		}
+		if want := []string{"", "bob@example.com", "carol@example.com", "dave@example.com"}; !reflect.DeepEqual(recipients, want) {
+			t.Fatalf("got deliveries to %q, want one for the owner and one per recipient", recipients)
+		}
+	})
+}
+
+func TestResendDeliversNow(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	clock := NewFakeClock(testStart)
+	scheduler := New(st.Events, st.Reminders, st.Deliveries, testConfig(clock))
+	notifier := &channelNotifier{channel: "email"}
+	scheduler.AddNotifier(notifier)
+
+	alice := createUser(t, st, "alice")
+	event := createEvent(t, st, alice, "standup", testStart.Add(time.Hour))
+	due, err := st.Events.DueByIDs(ctx, []int{event.ID})
+	if err != nil || len(due) != 1 {
+		t.Fatalf("got %+v, %v, want the event", due, err)
+	}
+
+	// The notification goes out once, right away, and is recorded as manual
+	deliveries := scheduler.Resend(ctx, due[0])
+	if !reflect.DeepEqual(notifier.events, []string{"standup"}) {
+		t.Fatalf("got notified of %v, want standup once", notifier.events)
+	}
+	history, err := st.Deliveries.ForEvent(ctx, alice, event.ID)
+	if err != nil || len(deliveries) != 1 || len(history) != 1 || history[0].ID != deliveries[0].ID ||
+		!history[0].Manual || history[0].Status != store.DeliverySent {
+		t.Fatalf("got deliveries %+v and history %+v, %v, want one sent manual delivery", deliveries, history, err)
+	}
+
+	// The schedule is unaffected: the event still fires at its date
+	if firedAt, err := st.Events.FiredAt(ctx, alice, event.ID); err != nil || firedAt != nil {
+		t.Fatalf("got fired at %v, %v, want the event not fired", firedAt, err)
+	}
+	advance(t, scheduler, clock, time.Hour)
+	history, err = st.Deliveries.ForEvent(ctx, alice, event.ID)
+	if err != nil || len(notifier.events) != 2 || len(history) != 2 || history[1].Manual {
+		t.Fatalf("got history %+v, %v, want a scheduled delivery after the manual one", history, err)
+	}
+}
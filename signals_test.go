@@ -0,0 +1,48 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSuspendDoesNotShutDown(t *testing.T) {
+	// Catching SIGTSTP keeps it from suspending the test binary
+	suspend := make(chan os.Signal, 1)
+	signal.Notify(suspend, syscall.SIGTSTP)
+	defer signal.Stop(suspend)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, shutdownSignals...)
+	defer signal.Stop(stop)
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTSTP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-suspend:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGTSTP was not delivered")
+	}
+	select {
+	case sig := <-stop:
+		t.Fatalf("SIGTSTP shut the server down with %v", sig)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// SIGTERM still does
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sig := <-stop:
+		if sig != syscall.SIGTERM {
+			t.Fatalf("got %v, want SIGTERM", sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGTERM did not shut the server down")
+	}
+}
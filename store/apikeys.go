@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+// APIKey struct describes a long-lived API key. Only a hash of the key is stored;
+// Prefix keeps the first characters so users can tell their keys apart.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// APIKeyStore persists API keys. Keys are identified by the hash of their secret.
+type APIKeyStore interface {
+	// Create stores a new key for a user and returns it.
+	Create(ctx context.Context, userID int, name, prefix, hash string) (*APIKey, error)
+	// List returns the active keys of a user, oldest first.
+	List(ctx context.Context, userID int) ([]APIKey, error)
+	// Revoke deactivates a key of the user, or returns ErrNotFound.
+	Revoke(ctx context.Context, userID, id int) error
+	// UserIDByHash returns the owner of an active key and records its use, or returns ErrNotFound.
+	// Keys of disabled accounts are not active.
+	UserIDByHash(ctx context.Context, hash string) (int, error)
+}
+
+// sqlAPIKeys implements APIKeyStore on the api_keys table.
+type sqlAPIKeys struct {
+	db *database.DB
+}
+
+func (s *sqlAPIKeys) Create(ctx context.Context, userID int, name, prefix, hash string) (*APIKey, error) {
+	now := time.Now().UTC()
+	id, err := s.db.InsertID(ctx, "INSERT INTO api_keys (user_id, name, prefix, key_hash, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, name, prefix, hash, now)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &APIKey{ID: int(id), Name: name, Prefix: prefix, CreatedAt: now}, nil
+}
+
+func (s *sqlAPIKeys) List(ctx context.Context, userID int) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, prefix, created_at, last_used_at FROM api_keys WHERE user_id = ? AND revoked_at IS NULL ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			key.LastUsedAt = &lastUsed.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *sqlAPIKeys) Revoke(ctx context.Context, userID, id int) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL", time.Now().UTC(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlAPIKeys) UserIDByHash(ctx context.Context, hash string) (int, error) {
+	var id, userID int
+	err := s.db.QueryRowContext(ctx, "SELECT api_keys.id, api_keys.user_id FROM api_keys JOIN users ON users.id = api_keys.user_id"+
+		" WHERE api_keys.key_hash = ? AND api_keys.revoked_at IS NULL AND users.disabled_at IS NULL", hash).Scan(&id, &userID)
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	// Usage tracking is best effort and must not block authentication
+	s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now().UTC(), id)
+	return userID, nil
+}
+
+// memoryAPIKey is an API key together with its owner, hash, and revocation state.
+type memoryAPIKey struct {
+	userID  int
+	hash    string
+	revoked bool
+	key     APIKey
+}
+
+// memoryAPIKeys implements APIKeyStore in memory.
+type memoryAPIKeys struct {
+	mu     sync.Mutex
+	keys   map[int]*memoryAPIKey
+	lastID int
+	// users tells whether the owner of a key is disabled
+	users *memoryUsers
+}
+
+func (s *memoryAPIKeys) Create(ctx context.Context, userID int, name, prefix, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.keys {
+		if stored.hash == hash {
+			return nil, ErrDuplicate
+		}
+	}
+
+	s.lastID++
+	key := APIKey{ID: s.lastID, Name: name, Prefix: prefix, CreatedAt: time.Now().UTC()}
+	s.keys[key.ID] = &memoryAPIKey{userID: userID, hash: hash, key: key}
+	return &key, nil
+}
+
+func (s *memoryAPIKeys) List(ctx context.Context, userID int) ([]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []APIKey{}
+	for _, stored := range s.keys {
+		if stored.userID == userID && !stored.revoked {
+			keys = append(keys, stored.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (s *memoryAPIKeys) Revoke(ctx context.Context, userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.keys[id]
+	if !ok || stored.userID != userID || stored.revoked {
+		return ErrNotFound
+	}
+	stored.revoked = true
+	return nil
+}
+
+func (s *memoryAPIKeys) UserIDByHash(ctx context.Context, hash string) (int, error) {
+	userID, err := s.activeKey(hash)
+	if err != nil {
+		return 0, err
+	}
+
+	// Looked up without holding the lock of the keys, which deleting a user takes while holding
+	// the lock of the users
+	user, err := s.users.ByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.DisabledAt != nil {
+		return 0, ErrNotFound
+	}
+	return userID, nil
+}
+
+// activeKey returns the owner of a key that was not revoked and records its use.
+func (s *memoryAPIKeys) activeKey(hash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.keys {
+		if stored.hash == hash && !stored.revoked {
+			now := time.Now().UTC()
+			stored.key.LastUsedAt = &now
+			return stored.userID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// deleteUser removes the keys of a deleted user.
+func (s *memoryAPIKeys) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stored := range s.keys {
+		if stored.userID == userID {
+			delete(s.keys, id)
+		}
+	}
+}
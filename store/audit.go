@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuditEntry struct describes one recorded account action. UserID is 0 when the action could
+// not be tied to an account, such as a login attempt for an unknown username.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Username  string    `json:"username"`
+	Action    string    `json:"action"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditStore persists the audit log. Entries are never modified once recorded.
+type AuditStore interface {
+	// Record appends an entry to the log, setting its ID and CreatedAt.
+	Record(ctx context.Context, entry *AuditEntry) error
+	// List returns one page of a user's entries and whether more follow. Offset pages are
+	// ordered newest first; keyset pages by id.
+	List(ctx context.Context, userID int, page Page) ([]AuditEntry, bool, error)
+	// Count returns how many entries a user has.
+	Count(ctx context.Context, userID int) (int, error)
+}
+
+// sqlAudit implements AuditStore on the audit_log table.
+type sqlAudit struct {
+	db *database.DB
+}
+
+func (s *sqlAudit) Record(ctx context.Context, entry *AuditEntry) error {
+	entry.CreatedAt = time.Now().UTC()
+
+	// Entries without an account are stored with a NULL user_id
+	var userID sql.NullInt64
+	if entry.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(entry.UserID), Valid: true}
+	}
+
+	id, err := s.db.InsertID(ctx, "INSERT INTO audit_log (user_id, username, action, ip, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, entry.Username, entry.Action, entry.IP, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+	return nil
+}
+
+func (s *sqlAudit) List(ctx context.Context, userID int, page Page) ([]AuditEntry, bool, error) {
+	// Fetch one row more than requested to learn whether another page follows
+	var rows *database.Rows
+	var err error
+	if page.Keyset {
+		rows, err = s.db.QueryContext(ctx, "SELECT id, username, action, ip, created_at FROM audit_log WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?", userID, page.AfterID, page.Limit+1)
+	} else {
+		rows, err = s.db.QueryContext(ctx, "SELECT id, username, action, ip, created_at FROM audit_log WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?", userID, page.Limit+1, page.Offset)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		entry := AuditEntry{UserID: userID}
+		if err := rows.Scan(&entry.ID, &entry.Username, &entry.Action, &entry.IP, &entry.CreatedAt); err != nil {
+			return nil, false, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(entries) > page.Limit {
+		return entries[:page.Limit], true, nil
+	}
+	return entries, false, nil
+}
+
+func (s *sqlAudit) Count(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+// memoryAudit implements AuditStore in memory.
+type memoryAudit struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func (s *memoryAudit) Record(ctx context.Context, entry *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = len(s.entries) + 1
+	entry.CreatedAt = time.Now().UTC()
+	s.entries = append(s.entries, *entry)
+	return nil
+}
+
+func (s *memoryAudit) List(ctx context.Context, userID int, page Page) ([]AuditEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []AuditEntry{}
+	for _, entry := range s.entries {
+		if entry.UserID == userID && (!page.Keyset || entry.ID > page.AfterID) {
+			entries = append(entries, entry)
+		}
+	}
+	if !page.Keyset {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+		if page.Offset >= len(entries) {
+			return []AuditEntry{}, false, nil
+		}
+		entries = entries[page.Offset:]
+	}
+
+	if len(entries) > page.Limit {
+		return entries[:page.Limit], true, nil
+	}
+	return entries, false, nil
+}
+
+func (s *memoryAudit) Count(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, entry := range s.entries {
+		if entry.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deleteUser removes the entries of a deleted user.
+func (s *memoryAudit) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.entries[:0]
+	for _, entry := range s.entries {
+		if entry.UserID != userID {
+			kept = append(kept, entry)
+		}
+	}
+	s.entries = kept
+}
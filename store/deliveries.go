@@ -0,0 +1,486 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Delivery statuses. A delivery stays pending until it succeeds or runs out of attempts.
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// Delivery struct records the attempts to notify a user of a fired event through one channel.
+// NextAttemptAt is set while a failed delivery waits to be retried.
+type Delivery struct {
+	ID        int    `json:"id"`
+	UserID    int    `json:"-"`
+	EventID   int    `json:"event_id"`
+	EventName string `json:"event_name"`
+	Channel   string `json:"channel"`
+	// Recipient is the address of an additional recipient of the event, the username of a user the
+	// event is shared with, or empty for its owner
+	Recipient string `json:"recipient"`
+	// Manual is set for deliveries of a notification resent on request rather than by the schedule
+	Manual        bool       `json:"manual"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// DeliveryAttempt struct records one attempt of a delivery: whether the notifier sent it, and why
+// it failed otherwise. Status is DeliverySent or DeliveryFailed.
+type DeliveryAttempt struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"-"`
+	DeliveryID  int       `json:"delivery_id"`
+	EventID     int       `json:"event_id"`
+	Channel     string    `json:"channel"`
+	Recipient   string    `json:"recipient"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// DeliveryStore persists the delivery history of fired events and the log of their attempts.
+type DeliveryStore interface {
+	// Create stores a new delivery, setting its ID and timestamps.
+	Create(ctx context.Context, delivery *Delivery) error
+	// Update saves the status, attempts, last error, and next attempt time of a delivery and
+	// releases any claim on it.
+	Update(ctx context.Context, delivery *Delivery) error
+	// ClaimRetryable claims the pending deliveries of all users whose next attempt is due at now
+	// for owner and returns them. A claim postpones the next attempt by lease, so the delivery
+	// returns to the pool if owner does not record an outcome with Update in time.
+	ClaimRetryable(ctx context.Context, owner string, now time.Time, lease time.Duration) ([]Delivery, error)
+	// List returns one page of a user's deliveries, newest first, optionally only those with
+	// the given status, and whether more follow. Keyset pages are ordered by id.
+	List(ctx context.Context, userID int, status string, page Page) ([]Delivery, bool, error)
+	// Count returns how many deliveries a user has, optionally only those with the given status.
+	Count(ctx context.Context, userID int, status string) (int, error)
+	// ForEvent returns the deliveries of one of a user's events in id order.
+	ForEvent(ctx context.Context, userID, eventID int) ([]Delivery, error)
+	// Pending returns how many deliveries of all users are pending, i.e. waiting for a retry.
+	Pending(ctx context.Context) (int, error)
+	// RecordAttempt stores an attempt of a delivery, setting its ID and AttemptedAt.
+	RecordAttempt(ctx context.Context, attempt *DeliveryAttempt) error
+	// Attempts returns one page of the attempts of the deliveries of one of a user's events, newest
+	// first, and whether more follow. Keyset pages are ordered by id.
+	Attempts(ctx context.Context, userID, eventID int, page Page) ([]DeliveryAttempt, bool, error)
+	// CountAttempts returns how many attempts of the deliveries of one of a user's events are logged.
+	CountAttempts(ctx context.Context, userID, eventID int) (int, error)
+}
+
+// Columns selected for a delivery, in the order scanDelivery reads them
+const deliveryColumns = "id, user_id, event_id, event_name, channel, recipient, manual, status, attempts, last_error, next_attempt_at, created_at, updated_at"
+
+// scanDelivery reads a row selected with deliveryColumns into delivery.
+func scanDelivery(row rowScanner, delivery *Delivery) error {
+	var nextAttempt sql.NullTime
+	err := row.Scan(&delivery.ID, &delivery.UserID, &delivery.EventID, &delivery.EventName, &delivery.Channel, &delivery.Recipient, &delivery.Manual, &delivery.Status,
+		&delivery.Attempts, &delivery.LastError, &nextAttempt, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	delivery.NextAttemptAt = nil
+	if nextAttempt.Valid {
+		delivery.NextAttemptAt = &nextAttempt.Time
+	}
+	return nil
+}
+
+// Columns selected for a delivery attempt, in the order scanDeliveryAttempt reads them
+const deliveryAttemptColumns = "id, user_id, delivery_id, event_id, channel, recipient, status, error, attempted_at"
+
+// scanDeliveryAttempt reads a row selected with deliveryAttemptColumns into attempt.
+func scanDeliveryAttempt(row rowScanner, attempt *DeliveryAttempt) error {
+	return row.Scan(&attempt.ID, &attempt.UserID, &attempt.DeliveryID, &attempt.EventID, &attempt.Channel, &attempt.Recipient, &attempt.Status,
+		&attempt.Error, &attempt.AttemptedAt)
+}
+
+// collectDeliveries scans all rows into a slice and closes them.
+func collectDeliveries(rows *database.Rows) ([]Delivery, error) {
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var delivery Delivery
+		if err := scanDelivery(rows, &delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// sqlDeliveries implements DeliveryStore on the deliveries table.
+type sqlDeliveries struct {
+	db *database.DB
+}
+
+func (s *sqlDeliveries) Create(ctx context.Context, delivery *Delivery) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	id, err := s.db.InsertID(ctx, `INSERT INTO deliveries (user_id, event_id, event_name, channel, recipient, manual, status, attempts, last_error, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		delivery.UserID, delivery.EventID, delivery.EventName, delivery.Channel, delivery.Recipient, delivery.Manual, delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextAttemptAt, now, now)
+	if err != nil {
+		return err
+	}
+	delivery.ID = int(id)
+	delivery.CreatedAt, delivery.UpdatedAt = now, now
+	return nil
+}
+
+func (s *sqlDeliveries) Update(ctx context.Context, delivery *Delivery) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err := s.db.ExecContext(ctx, "UPDATE deliveries SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = ?, claimed_by = NULL WHERE id = ?",
+		delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextAttemptAt, now, delivery.ID)
+	if err != nil {
+		return err
+	}
+	delivery.UpdatedAt = now
+	return nil
+}
+
+func (s *sqlDeliveries) ClaimRetryable(ctx context.Context, owner string, now time.Time, lease time.Duration) ([]Delivery, error) {
+	// A single UPDATE takes the claim atomically, so concurrent schedulers never claim the same row
+	_, err := s.db.ExecContext(ctx, "UPDATE deliveries SET claimed_by = ?, next_attempt_at = ? WHERE status = ? AND next_attempt_at <= ?",
+		owner, now.Add(lease).UTC(), DeliveryPending, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM deliveries WHERE claimed_by = ? AND status = ? ORDER BY id",
+		owner, DeliveryPending)
+	if err != nil {
+		return nil, err
+	}
+	return collectDeliveries(rows)
+}
+
+// deliveryWhere returns the WHERE condition and arguments selecting a user's deliveries,
+// optionally only those with the given status.
+func deliveryWhere(userID int, status string) (string, []interface{}) {
+	if status == "" {
+		return "user_id = ?", []interface{}{userID}
+	}
+	return "user_id = ? AND status = ?", []interface{}{userID, status}
+}
+
+func (s *sqlDeliveries) List(ctx context.Context, userID int, status string, page Page) ([]Delivery, bool, error) {
+	where, args := deliveryWhere(userID, status)
+
+	// Fetch one row more than requested to learn whether another page follows
+	var rows *database.Rows
+	var err error
+	if page.Keyset {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM deliveries WHERE "+where+" AND id > ? ORDER BY id LIMIT ?",
+			append(args, page.AfterID, page.Limit+1)...)
+	} else {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM deliveries WHERE "+where+" ORDER BY id DESC LIMIT ? OFFSET ?",
+			append(args, page.Limit+1, page.Offset)...)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	deliveries, err := collectDeliveries(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(deliveries) > page.Limit {
+		return deliveries[:page.Limit], true, nil
+	}
+	return deliveries, false, nil
+}
+
+func (s *sqlDeliveries) ForEvent(ctx context.Context, userID, eventID int) ([]Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM deliveries WHERE user_id = ? AND event_id = ? ORDER BY id", userID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	return collectDeliveries(rows)
+}
+
+func (s *sqlDeliveries) Count(ctx context.Context, userID int, status string) (int, error) {
+	where, args := deliveryWhere(userID, status)
+
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM deliveries WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+func (s *sqlDeliveries) Pending(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM deliveries WHERE status = ?", DeliveryPending).Scan(&count)
+	return count, err
+}
+
+func (s *sqlDeliveries) RecordAttempt(ctx context.Context, attempt *DeliveryAttempt) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	id, err := s.db.InsertID(ctx, "INSERT INTO delivery_attempts (user_id, delivery_id, event_id, channel, recipient, status, error, attempted_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		attempt.UserID, attempt.DeliveryID, attempt.EventID, attempt.Channel, attempt.Recipient, attempt.Status, attempt.Error, now)
+	if err != nil {
+		return err
+	}
+	attempt.ID = int(id)
+	attempt.AttemptedAt = now
+	return nil
+}
+
+func (s *sqlDeliveries) Attempts(ctx context.Context, userID, eventID int, page Page) ([]DeliveryAttempt, bool, error) {
+	// Fetch one row more than requested to learn whether another page follows
+	var rows *database.Rows
+	var err error
+	if page.Keyset {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+deliveryAttemptColumns+" FROM delivery_attempts WHERE event_id = ? AND user_id = ? AND id > ? ORDER BY id LIMIT ?",
+			eventID, userID, page.AfterID, page.Limit+1)
+	} else {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+deliveryAttemptColumns+" FROM delivery_attempts WHERE event_id = ? AND user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+			eventID, userID, page.Limit+1, page.Offset)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	attempts := []DeliveryAttempt{}
+	for rows.Next() {
+		var attempt DeliveryAttempt
+		if err := scanDeliveryAttempt(rows, &attempt); err != nil {
+			return nil, false, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(attempts) > page.Limit {
+		return attempts[:page.Limit], true, nil
+	}
+	return attempts, false, nil
+}
+
+func (s *sqlDeliveries) CountAttempts(ctx context.Context, userID, eventID int) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM delivery_attempts WHERE event_id = ? AND user_id = ?", eventID, userID).Scan(&count)
+	return count, err
+}
+
+// memoryDeliveries implements DeliveryStore in memory.
+type memoryDeliveries struct {
+	mu         sync.Mutex
+	deliveries []Delivery
+	claimedBy  map[int]string
+	attempts   []DeliveryAttempt
+	// lastAttemptID is the id of the latest attempt
+	lastAttemptID int
+}
+
+func (s *memoryDeliveries) Create(ctx context.Context, delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	delivery.ID = len(s.deliveries) + 1
+	delivery.CreatedAt, delivery.UpdatedAt = now, now
+	s.deliveries = append(s.deliveries, *delivery)
+	return nil
+}
+
+func (s *memoryDeliveries) Update(ctx context.Context, delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if delivery.ID < 1 || delivery.ID > len(s.deliveries) {
+		return ErrNotFound
+	}
+
+	stored := &s.deliveries[delivery.ID-1]
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.LastError = delivery.LastError
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.UpdatedAt = time.Now().UTC()
+	delivery.UpdatedAt = stored.UpdatedAt
+	delete(s.claimedBy, delivery.ID)
+	return nil
+}
+
+func (s *memoryDeliveries) ClaimRetryable(ctx context.Context, owner string, now time.Time, lease time.Duration) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.claimedBy == nil {
+		s.claimedBy = map[int]string{}
+	}
+
+	claimed := []Delivery{}
+	for i := range s.deliveries {
+		delivery := &s.deliveries[i]
+		if delivery.Status != DeliveryPending {
+			continue
+		}
+		if delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) {
+			until := now.Add(lease).UTC()
+			delivery.NextAttemptAt = &until
+			s.claimedBy[delivery.ID] = owner
+		}
+		if s.claimedBy[delivery.ID] == owner {
+			claimed = append(claimed, *delivery)
+		}
+	}
+	return claimed, nil
+}
+
+// matching returns the deliveries of a user, optionally only those with the given status, in id order.
+// The lock must be held.
+func (s *memoryDeliveries) matching(userID int, status string) []Delivery {
+	deliveries := []Delivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.UserID == userID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries
+}
+
+func (s *memoryDeliveries) List(ctx context.Context, userID int, status string, page Page) ([]Delivery, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := s.matching(userID, status)
+	if page.Keyset {
+		start := sort.Search(len(deliveries), func(i int) bool { return deliveries[i].ID > page.AfterID })
+		deliveries = deliveries[start:]
+	} else {
+		sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+		if page.Offset >= len(deliveries) {
+			return []Delivery{}, false, nil
+		}
+		deliveries = deliveries[page.Offset:]
+	}
+
+	if len(deliveries) > page.Limit {
+		return deliveries[:page.Limit], true, nil
+	}
+	return deliveries, false, nil
+}
+
+func (s *memoryDeliveries) Count(ctx context.Context, userID int, status string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.matching(userID, status)), nil
+}
+
+func (s *memoryDeliveries) Pending(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, delivery := range s.deliveries {
+		if delivery.Status == DeliveryPending {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryDeliveries) ForEvent(ctx context.Context, userID, eventID int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := []Delivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.UserID == userID && delivery.EventID == eventID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *memoryDeliveries) RecordAttempt(ctx context.Context, attempt *DeliveryAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Deleting a user removes attempts, so ids continue from the last one rather than the count
+	s.lastAttemptID++
+	attempt.ID = s.lastAttemptID
+	attempt.AttemptedAt = time.Now().UTC()
+	s.attempts = append(s.attempts, *attempt)
+	return nil
+}
+
+func (s *memoryDeliveries) Attempts(ctx context.Context, userID, eventID int, page Page) ([]DeliveryAttempt, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := []DeliveryAttempt{}
+	for _, attempt := range s.attempts {
+		if attempt.UserID == userID && attempt.EventID == eventID && (!page.Keyset || attempt.ID > page.AfterID) {
+			attempts = append(attempts, attempt)
+		}
+	}
+	if !page.Keyset {
+		sort.Slice(attempts, func(i, j int) bool { return attempts[i].ID > attempts[j].ID })
+		if page.Offset >= len(attempts) {
+			return []DeliveryAttempt{}, false, nil
+		}
+		attempts = attempts[page.Offset:]
+	}
+
+	if len(attempts) > page.Limit {
+		return attempts[:page.Limit], true, nil
+	}
+	return attempts, false, nil
+}
+
+func (s *memoryDeliveries) CountAttempts(ctx context.Context, userID, eventID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, attempt := range s.attempts {
+		if attempt.UserID == userID && attempt.EventID == eventID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deleteUser removes the delivery history and attempts of a deleted user.
+func (s *memoryDeliveries) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.deliveries[:0]
+	for _, delivery := range s.deliveries {
+		if delivery.UserID != userID {
+			kept = append(kept, delivery)
+		} else {
+			delete(s.claimedBy, delivery.ID)
+		}
+	}
+	s.deliveries = kept
+
+	keptAttempts := s.attempts[:0]
+	for _, attempt := range s.attempts {
+		if attempt.UserID != userID {
+			keptAttempts = append(keptAttempts, attempt)
+		}
+	}
+	s.attempts = keptAttempts
+}
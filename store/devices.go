@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Platforms a device receives push notifications through
+const (
+	PlatformWebPush = "webpush"
+	PlatformFCM     = "fcm"
+)
+
+// Device struct describes a browser or app a user registered for push notifications.
+// Endpoint is the push service URL of a Web Push subscription, or the registration token of an
+// FCM device. P256dh and Auth are the keys a Web Push payload is encrypted with.
+type Device struct {
+	ID        int        `json:"id"`
+	Platform  string     `json:"platform"`
+	Endpoint  string     `json:"endpoint"`
+	P256dh    string     `json:"-"`
+	Auth      string     `json:"-"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// DeviceStore persists the devices users registered for push notifications. A device is identified
+// by its endpoint, so registering it again updates it.
+type DeviceStore interface {
+	// Register stores a device of a user, setting its ID and CreatedAt. A device already registered
+	// with the same endpoint, possibly by another user, is taken over and its keys and expiry updated.
+	Register(ctx context.Context, userID int, device *Device) error
+	// List returns the devices of a user with their keys, oldest first.
+	List(ctx context.Context, userID int) ([]Device, error)
+	// Delete removes one of a user's devices, or returns ErrNotFound.
+	Delete(ctx context.Context, userID, id int) error
+	// DeleteExpired removes the devices whose subscription expired before now and returns how many.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// endpointHash returns the hex-encoded SHA-256 hash devices are looked up by. Endpoints are too long
+// for a unique index.
+func endpointHash(endpoint string) string {
+	sum := sha256.Sum256([]byte(endpoint))
+	return hex.EncodeToString(sum[:])
+}
+
+// sqlDevices implements DeviceStore on the devices table.
+type sqlDevices struct {
+	db *database.DB
+}
+
+func (s *sqlDevices) Register(ctx context.Context, userID int, device *Device) error {
+	var expiresAt *time.Time
+	if device.ExpiresAt != nil {
+		t := device.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+
+	hash := endpointHash(device.Endpoint)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO devices (user_id, platform, endpoint, endpoint_hash, p256dh, auth, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`+s.db.Dialect.Upsert("endpoint_hash", "user_id", "platform", "p256dh", "auth", "expires_at"),
+		userID, device.Platform, device.Endpoint, hash, device.P256dh, device.Auth, expiresAt, time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		return mapError(err)
+	}
+
+	return s.db.QueryRowContext(ctx, "SELECT id, created_at FROM devices WHERE endpoint_hash = ?", hash).Scan(&device.ID, &device.CreatedAt)
+}
+
+func (s *sqlDevices) List(ctx context.Context, userID int) ([]Device, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, platform, endpoint, p256dh, auth, expires_at, created_at FROM devices WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []Device{}
+	for rows.Next() {
+		var device Device
+		if err := rows.Scan(&device.ID, &device.Platform, &device.Endpoint, &device.P256dh, &device.Auth, &device.ExpiresAt, &device.CreatedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+func (s *sqlDevices) Delete(ctx context.Context, userID, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM devices WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlDevices) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM devices WHERE expires_at < ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// memoryDevice is a device together with its owner.
+type memoryDevice struct {
+	userID int
+	device Device
+}
+
+// memoryDevices implements DeviceStore in memory, keyed by endpoint.
+type memoryDevices struct {
+	mu      sync.Mutex
+	devices map[string]*memoryDevice
+	lastID  int
+}
+
+func (s *memoryDevices) Register(ctx context.Context, userID int, device *Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.devices[device.Endpoint]
+	if !ok {
+		s.lastID++
+		stored = &memoryDevice{device: Device{ID: s.lastID, CreatedAt: time.Now().UTC()}}
+		s.devices[device.Endpoint] = stored
+	}
+	device.ID = stored.device.ID
+	device.CreatedAt = stored.device.CreatedAt
+
+	stored.userID = userID
+	stored.device = *device
+	return nil
+}
+
+func (s *memoryDevices) List(ctx context.Context, userID int) ([]Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices := []Device{}
+	for _, stored := range s.devices {
+		if stored.userID == userID {
+			devices = append(devices, stored.device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	return devices, nil
+}
+
+func (s *memoryDevices) Delete(ctx context.Context, userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for endpoint, stored := range s.devices {
+		if stored.device.ID == id && stored.userID == userID {
+			delete(s.devices, endpoint)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryDevices) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for endpoint, stored := range s.devices {
+		if stored.device.ExpiresAt != nil && stored.device.ExpiresAt.Before(now) {
+			delete(s.devices, endpoint)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// deleteUser removes the devices of a deleted user.
+func (s *memoryDevices) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for endpoint, stored := range s.devices {
+		if stored.userID == userID {
+			delete(s.devices, endpoint)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/database"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingConnector opens connections to a SQLite file that count the statements run on them.
+type countingConnector struct {
+	driver     driver.Driver
+	dsn        string
+	statements int64
+}
+
+func (c *countingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, counter: c}, nil
+}
+
+func (c *countingConnector) Driver() driver.Driver { return c.driver }
+
+// count returns how many statements ran so far.
+func (c *countingConnector) count() int64 { return atomic.LoadInt64(&c.statements) }
+
+// countingConn is a connection of countingConnector, passing statements on to SQLite.
+type countingConn struct {
+	driver.Conn
+	counter *countingConnector
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt64(&c.counter.statements, 1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	atomic.AddInt64(&c.counter.statements, 1)
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+// openCountingStore returns a SQL store on a new SQLite database whose statements are counted.
+func openCountingStore(tb testing.TB) (*Store, *countingConnector) {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "reminders.db")
+
+	// Connect creates the schema
+	tb.Setenv("DB_DRIVER", "sqlite")
+	tb.Setenv("DB_CREDS", path)
+	db, err := database.Connect()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db.Close()
+
+	pool, err := sql.Open("sqlite", "")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	connector := &countingConnector{driver: pool.Driver(), dsn: "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_txlock=immediate"}
+	pool.Close()
+
+	counted := sql.OpenDB(connector)
+	tb.Cleanup(func() { counted.Close() })
+	return NewSQL(database.Wrap(counted, database.SQLite)), connector
+}
+
+// createDueEvents stores count events of a user named after prefix, all due by 2030-01-02.
+func createDueEvents(tb testing.TB, s *Store, userID int, prefix string, count int) {
+	tb.Helper()
+	for i := 0; i < count; i++ {
+		event := &Event{Name: fmt.Sprintf("%s-%d", prefix, i), Message: "Due", Date: "2030-01-01T09:00:00Z"}
+		if err := s.Events.Create(context.Background(), userID, event); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestClaimDueReturnsRecipients(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		if err := s.Users.UpdateSettings(ctx, alice, "alice@example.com", "Europe/Paris", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Users.UpdateSettings(ctx, bob, "bob@example.com", "", nil); err != nil {
+			t.Fatal(err)
+		}
+		owners := map[int]string{}
+		for _, user := range []struct {
+			id    int
+			email string
+		}{{alice, "alice@example.com"}, {bob, "bob@example.com"}, {alice, "alice@example.com"}} {
+			event := &Event{Name: fmt.Sprintf("event-%d", len(owners)), Message: "Due", Date: "2030-01-01T09:00:00Z"}
+			if err := s.Events.Create(ctx, user.id, event); err != nil {
+				t.Fatal(err)
+			}
+			owners[event.ID] = user.email
+		}
+
+		now := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+		due, err := s.Events.ClaimDue(ctx, "test", time.Time{}, now, time.Minute, 10)
+		if err != nil || len(due) != 3 {
+			t.Fatalf("got %d due events, %v, want 3", len(due), err)
+		}
+		for _, d := range due {
+			if d.Recipient.Email != owners[d.Event.ID] || d.UserID == alice && (d.Recipient.Username != "alice" || d.Recipient.Timezone != "Europe/Paris") {
+				t.Fatalf("event %d: got recipient %+v of user %d, want %s", d.Event.ID, d.Recipient, d.UserID, owners[d.Event.ID])
+			}
+		}
+	})
+}
+
+func TestClaimDueQueriesDoNotGrowWithEvents(t *testing.T) {
+	s, counter := openCountingStore(t)
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	// claim claims the due events and returns how many were claimed and the statements it took
+	claim := func(now time.Time) (int, int64) {
+		t.Helper()
+		before := counter.count()
+		due, err := s.Events.ClaimDue(ctx, "test", time.Time{}, now, time.Minute, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(due), counter.count() - before
+	}
+
+	createDueEvents(t, s, alice, "single", 1)
+	now := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	claimed, one := claim(now)
+	if claimed != 1 {
+		t.Fatalf("claimed %d events, want 1", claimed)
+	}
+
+	// With many more due events of several users the claim takes as many statements
+	createDueEvents(t, s, alice, "many", 30)
+	createDueEvents(t, s, bob, "many", 30)
+	claimed, many := claim(now.Add(time.Hour))
+	if claimed != 61 || many != one {
+		t.Fatalf("claimed %d events in %d statements, want 61 in %d like a single event", claimed, many, one)
+	}
+}
+
+func BenchmarkClaimDue(b *testing.B) {
+	for _, count := range []int{10, 100} {
+		b.Run(fmt.Sprintf("%d events", count), func(b *testing.B) {
+			s, counter := openCountingStore(b)
+			ctx := context.Background()
+			alice := createUser(b, s, "alice")
+			createDueEvents(b, s, alice, "event", count)
+
+			now := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+			before := counter.count()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Every claim comes after the lease of the previous one ran out
+				now = now.Add(2 * time.Minute)
+				if _, err := s.Events.ClaimDue(ctx, "bench", time.Time{}, now, time.Minute, count); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(counter.count()-before)/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func BenchmarkListEvents(b *testing.B) {
+	for _, backend := range testBackends {
+		b.Run(backend.name, func(b *testing.B) {
+			s := backend.open(b)
+			ctx := context.Background()
+			alice := createUser(b, s, "alice")
+			for i := 0; i < 1000; i++ {
+				event := &Event{Name: fmt.Sprintf("event-%d", i), Message: "Listed", Priority: "normal",
+					Date: time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour).Format(time.RFC3339)}
+				if err := s.Events.Create(ctx, alice, event); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// A page from the middle of the list, sorted by priority and date as by default
+				if _, _, err := s.Events.List(ctx, alice, EventFilter{}, Page{Limit: 50, Offset: 500}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sync"
+	"time"
+)
+
+// CalendarFeed struct describes the read-only iCalendar feed of a user's events. Calendar apps
+// fetch it with a secret token in its URL; only a hash of the token is stored.
+type CalendarFeed struct {
+	CreatedAt     time.Time  `json:"created_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+}
+
+// CalendarFeedStore persists the calendar feeds of users, at most one per user.
+type CalendarFeedStore interface {
+	// Get returns the feed of a user, or ErrNotFound if they have none.
+	Get(ctx context.Context, userID int) (*CalendarFeed, error)
+	// Rotate gives a user a feed with a new token hash, replacing their previous feed, and returns it.
+	Rotate(ctx context.Context, userID int, hash string) (*CalendarFeed, error)
+	// Delete removes the feed of a user, or returns ErrNotFound if they have none.
+	Delete(ctx context.Context, userID int) error
+	// UserIDByHash returns the owner of the feed with a token hash and records the fetch, or
+	// returns ErrNotFound.
+	UserIDByHash(ctx context.Context, hash string) (int, error)
+}
+
+// sqlCalendarFeeds implements CalendarFeedStore on the calendar_feeds table.
+type sqlCalendarFeeds struct {
+	db *database.DB
+}
+
+func (s *sqlCalendarFeeds) Get(ctx context.Context, userID int) (*CalendarFeed, error) {
+	feed := new(CalendarFeed)
+	var lastFetched sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT created_at, last_fetched_at FROM calendar_feeds WHERE user_id = ?", userID).Scan(&feed.CreatedAt, &lastFetched)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if lastFetched.Valid {
+		feed.LastFetchedAt = &lastFetched.Time
+	}
+	return feed, nil
+}
+
+func (s *sqlCalendarFeeds) Rotate(ctx context.Context, userID int, hash string) (*CalendarFeed, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err := s.db.ExecContext(ctx, "INSERT INTO calendar_feeds (user_id, token_hash, created_at, last_fetched_at) VALUES (?, ?, ?, NULL)"+
+		s.db.Dialect.Upsert("user_id", "token_hash", "created_at", "last_fetched_at"),
+		userID, hash, now)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &CalendarFeed{CreatedAt: now}, nil
+}
+
+func (s *sqlCalendarFeeds) Delete(ctx context.Context, userID int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM calendar_feeds WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlCalendarFeeds) UserIDByHash(ctx context.Context, hash string) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM calendar_feeds WHERE token_hash = ?", hash).Scan(&userID)
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	// Fetch tracking is best effort and must not fail the feed
+	s.db.ExecContext(ctx, "UPDATE calendar_feeds SET last_fetched_at = ? WHERE user_id = ?", time.Now().UTC(), userID)
+	return userID, nil
+}
+
+// memoryCalendarFeed is a calendar feed together with its token hash.
+type memoryCalendarFeed struct {
+	hash string
+	feed CalendarFeed
+}
+
+// memoryCalendarFeeds implements CalendarFeedStore in memory.
+type memoryCalendarFeeds struct {
+	mu    sync.Mutex
+	feeds map[int]*memoryCalendarFeed
+}
+
+func (s *memoryCalendarFeeds) Get(ctx context.Context, userID int) (*CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.feeds[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	feed := stored.feed
+	return &feed, nil
+}
+
+func (s *memoryCalendarFeeds) Rotate(ctx context.Context, userID int, hash string) (*CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed := CalendarFeed{CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	s.feeds[userID] = &memoryCalendarFeed{hash: hash, feed: feed}
+	return &feed, nil
+}
+
+func (s *memoryCalendarFeeds) Delete(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.feeds[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.feeds, userID)
+	return nil
+}
+
+func (s *memoryCalendarFeeds) UserIDByHash(ctx context.Context, hash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, stored := range s.feeds {
+		if stored.hash == hash {
+			now := time.Now().UTC()
+			stored.feed.LastFetchedAt = &now
+			return userID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// deleteUser removes the feed of a deleted user.
+func (s *memoryCalendarFeeds) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.feeds, userID)
+}
@@ -0,0 +1,50 @@
+//go:build integration
+
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// The integration tests run the store tests against a database server as well, the one named by
+// TEST_DB_CREDS, in the format of DB_CREDS, on the TEST_DB_DRIVER server, MySQL by default. With
+// a MySQL container:
+//
+//	docker run -d -p 3306:3306 -e MYSQL_ROOT_PASSWORD=test -e MYSQL_DATABASE=reminders mysql:8
+//	TEST_DB_CREDS='root:test@tcp(localhost:3306)/reminders' go test -tags integration ./store/
+//
+// Every test starts by deleting all users of the database, so it must not hold data worth keeping.
+func init() {
+	testBackends = append(testBackends, testBackend{name: "server", open: openServerStore})
+}
+
+// openServerStore returns a SQL store on the test database server, emptied of users and
+// everything they own.
+func openServerStore(t testing.TB) *Store {
+	creds := os.Getenv("TEST_DB_CREDS")
+	if creds == "" {
+		t.Skip("TEST_DB_CREDS is not set")
+	}
+	t.Setenv("DB_DRIVER", os.Getenv("TEST_DB_DRIVER"))
+	t.Setenv("DB_CREDS", creds)
+	t.Setenv("DB_TLS_MODE", "disable")
+	s := connectStore(t)
+
+	ctx := context.Background()
+	for {
+		users, _, err := s.Users.List(ctx, "", Page{Limit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) == 0 {
+			return s
+		}
+		for _, user := range users {
+			if err := s.Users.Delete(ctx, user.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
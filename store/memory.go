@@ -0,0 +1,964 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sort rank of each priority, most urgent first, mirroring priorityOrder
+var priorityRank = map[string]int{"urgent": 0, "high": 1, "normal": 2, "low": 3}
+
+// NewMemory returns a Store that keeps everything in memory. It is safe for concurrent use
+// and intended for tests and local experiments; data is lost when the process exits.
+func NewMemory() *Store {
+	m := &memory{
+		users:  map[int]*User{},
+		events: map[int]*memoryEvent{},
+		orgs:   map[int]Organization{},
+	}
+	users := &memoryUsers{m}
+	apiKeys := &memoryAPIKeys{keys: map[int]*memoryAPIKey{}, users: users}
+	audit := &memoryAudit{}
+	deliveries := &memoryDeliveries{}
+	templates := &memoryTemplates{templates: map[int]*memoryTemplate{}}
+	refreshTokens := &memoryRefreshTokens{tokens: map[string]*memoryRefreshToken{}}
+	webhooks := &memoryWebhooks{webhooks: map[int]*memoryWebhook{}}
+	devices := &memoryDevices{devices: map[string]*memoryDevice{}}
+	feeds := &memoryCalendarFeeds{feeds: map[int]*memoryCalendarFeed{}}
+	telegram := &memoryTelegram{chats: map[int]TelegramChat{}, codes: map[string]memoryTelegramCode{}}
+	slack := &memorySlack{configs: map[int]SlackConfig{}}
+	phones := &memoryPhones{phones: map[int]Phone{}, verifications: map[int]memoryVerification{}}
+	resets := &memoryPasswordResets{
+		tokens:        map[string]*memoryPasswordReset{},
+		users:         users,
+		refreshTokens: refreshTokens,
+	}
+	m.userCleanups = []func(int){
+		apiKeys.deleteUser, audit.deleteUser, deliveries.deleteUser, templates.deleteUser, refreshTokens.deleteUser,
+		webhooks.deleteUser, devices.deleteUser, feeds.deleteUser, resets.deleteUser, telegram.deleteUser,
+		slack.deleteUser, phones.deleteUser,
+	}
+
+	return &Store{
+		Users:          users,
+		Events:         &memoryEvents{m},
+		APIKeys:        apiKeys,
+		Audit:          audit,
+		Deliveries:     deliveries,
+		Orgs:           &memoryOrgs{m},
+		Templates:      templates,
+		RefreshTokens:  refreshTokens,
+		Webhooks:       webhooks,
+		Devices:        devices,
+		Reminders:      &memoryReminders{m},
+		CalendarFeeds:  feeds,
+		PasswordResets: resets,
+		Tags:           &memoryTags{m},
+		Shares:         &memoryShares{m},
+		Telegram:       telegram,
+		Slack:          slack,
+		Phones:         phones,
+		Stats:          &memoryStats{memory: m, deliveries: deliveries},
+	}
+}
+
+// memory holds the shared state of the in-memory stores behind a single lock.
+type memory struct {
+	mu          sync.Mutex
+	users       map[int]*User
+	events      map[int]*memoryEvent
+	lastUserID  int
+	lastEventID int
+	orgs        map[int]Organization
+	lastOrgID   int
+	// lastReminderID is the id of the latest reminder of any event
+	lastReminderID int
+	// userCleanups remove what the other in-memory stores hold for a deleted user, as the foreign
+	// keys of the SQL store do
+	userCleanups []func(userID int)
+}
+
+// memoryEvent is an event together with the id of the user owning it, the organization sharing
+// it (0 for none), its scheduler state, its reminders, and the users it is shared with.
+type memoryEvent struct {
+	userID    int
+	orgID     int
+	event     Event
+	firedAt   *time.Time
+	lockedBy  string
+	lockedAt  time.Time
+	reminders []*memoryReminder
+	// shares holds the users the event is shared with, by user id
+	shares map[int]memoryShare
+}
+
+// memoryUsers implements UserStore on top of memory.
+type memoryUsers struct {
+	*memory
+}
+
+func (s *memoryUsers) Create(ctx context.Context, username, passwordHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			return 0, ErrDuplicate
+		}
+	}
+
+	s.lastUserID++
+	s.users[s.lastUserID] = &User{ID: s.lastUserID, Username: username, PasswordHash: passwordHash, Timezone: "UTC", Role: RoleUser}
+	return s.lastUserID, nil
+}
+
+func (s *memoryUsers) ByID(ctx context.Context, id int) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *memoryUsers) ByUsername(ctx context.Context, username string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUsers) UpdateSettings(ctx context.Context, id int, email, timezone string, keepEvents *bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if email != "" {
+		user.Email = email
+	}
+	if timezone != "" {
+		user.Timezone = timezone
+	}
+	if keepEvents != nil {
+		user.KeepEvents = *keepEvents
+	}
+	return nil
+}
+
+func (s *memoryUsers) UpdateChannels(ctx context.Context, id int, disabled []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.DisabledChannels = append([]string{}, disabled...)
+	return nil
+}
+
+func (s *memoryUsers) UpdateUsername(ctx context.Context, id int, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Username == username && user.ID != id {
+			return ErrDuplicate
+		}
+	}
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Username = username
+	return nil
+}
+
+func (s *memoryUsers) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+func (s *memoryUsers) RevokeTokens(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.TokenVersion++
+	return nil
+}
+
+func (s *memoryUsers) List(ctx context.Context, search string, page Page) ([]User, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := s.matchingUsers(search)
+	if page.Keyset {
+		start := sort.Search(len(users), func(i int) bool { return users[i].ID > page.AfterID })
+		users = users[start:]
+	} else {
+		if page.Offset >= len(users) {
+			return []User{}, false, nil
+		}
+		users = users[page.Offset:]
+	}
+
+	if len(users) > page.Limit {
+		return users[:page.Limit], true, nil
+	}
+	return users, false, nil
+}
+
+func (s *memoryUsers) Count(ctx context.Context, search string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.matchingUsers(search)), nil
+}
+
+// matchingUsers returns copies of the users whose username contains search, in id order. The
+// lock must be held.
+func (s *memoryUsers) matchingUsers(search string) []User {
+	users := []User{}
+	for _, user := range s.users {
+		if search == "" || containsFold(user.Username, search) {
+			users = append(users, *user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+func (s *memoryUsers) SetRole(ctx context.Context, id int, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+func (s *memoryUsers) SetDisabled(ctx context.Context, id int, disabledAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.DisabledAt = nil
+	if disabledAt != nil {
+		at := disabledAt.UTC()
+		user.DisabledAt = &at
+	}
+	return nil
+}
+
+func (s *memoryUsers) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	if _, ok := s.users[id]; !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(s.users, id)
+	for eventID, stored := range s.events {
+		if stored.userID == id {
+			delete(s.events, eventID)
+		}
+		delete(stored.shares, id)
+	}
+	s.mu.Unlock()
+
+	// The other stores take their own locks, some of them before this one
+	for _, cleanup := range s.userCleanups {
+		cleanup(id)
+	}
+	return nil
+}
+
+// memoryEvents implements EventStore on top of memory.
+type memoryEvents struct {
+	*memory
+}
+
+// owns reports whether a stored event is one a user can see, in the trash or not: one of their
+// organization, or their own when they belong to none. The lock must be held.
+func (s *memoryEvents) owns(userID int, stored *memoryEvent) bool {
+	if user, ok := s.users[userID]; ok && user.OrgID != 0 {
+		return stored.orgID == user.OrgID
+	}
+	return stored.orgID == 0 && stored.userID == userID
+}
+
+// visible reports whether a user can see a stored event outside the trash. The lock must be held.
+func (s *memoryEvents) visible(userID int, stored *memoryEvent) bool {
+	return stored.event.DeletedAt == nil && s.owns(userID, stored)
+}
+
+// dropTrashed permanently deletes the event named name in the trash of a user, if any, so that
+// another event can take the name. The lock must be held.
+func (s *memoryEvents) dropTrashed(userID int, name string) {
+	for id, stored := range s.events {
+		if stored.event.DeletedAt != nil && s.owns(userID, stored) && stored.event.Name == name {
+			delete(s.events, id)
+		}
+	}
+}
+
+// trash moves a stored event to the trash. The lock must be held.
+func (stored *memoryEvent) trash() {
+	// DeletedAt holds whole seconds, like the deleted_at column of the SQL store
+	now := eventTimestamp()
+	deletedAt := now.Truncate(time.Second)
+	stored.event.DeletedAt, stored.event.UpdatedAt = &deletedAt, now
+}
+
+// find returns the stored event with the given name visible to a user, or nil. The lock must be held.
+func (s *memoryEvents) find(userID int, name string) *memoryEvent {
+	for _, stored := range s.events {
+		if s.visible(userID, stored) && stored.event.Name == name {
+			return stored
+		}
+	}
+	return nil
+}
+
+// owned returns copies of all events visible to a user in id order. The lock must be held.
+func (s *memoryEvents) owned(userID int) []Event {
+	events := []Event{}
+	for _, stored := range s.events {
+		if s.visible(userID, stored) {
+			events = append(events, stored.event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+// matching returns copies of the events of a user that match filter in id order. The lock must be held.
+func (s *memoryEvents) matching(userID int, filter EventFilter) []Event {
+	events := []Event{}
+	for _, event := range s.owned(userID) {
+		if filter.CompletedOnly && event.CompletedAt == nil {
+			continue
+		}
+		if !filter.CompletedOnly && !filter.IncludeCompleted && event.CompletedAt != nil {
+			continue
+		}
+		if !filter.AsOf.IsZero() && event.CreatedAt.After(filter.AsOf) {
+			continue
+		}
+		if !filter.From.IsZero() || !filter.To.IsZero() {
+			date, err := time.Parse(time.RFC3339, event.Date)
+			if err != nil || (!filter.From.IsZero() && date.Before(filter.From)) || (!filter.To.IsZero() && date.After(filter.To)) {
+				continue
+			}
+		}
+		if filter.Search != "" && !containsFold(event.Name, filter.Search) && !containsFold(event.Message, filter.Search) {
+			continue
+		}
+		if filter.Tag != "" && !containsString(event.Tags, filter.Tag) {
+			continue
+		}
+		if len(filter.Priorities) > 0 && !containsString(filter.Priorities, event.Priority) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// matchesRecurrence reports whether a recurrence rule, in canonical form, passes the Recurrence of an
+// AdminEventFilter.
+func matchesRecurrence(rule, recurrence string) bool {
+	switch recurrence {
+	case "":
+		return true
+	case RecurrenceNone:
+		return rule == ""
+	case RecurrenceAny:
+		return rule != ""
+	default:
+		return rule == "FREQ="+recurrence || strings.HasPrefix(rule, "FREQ="+recurrence+";")
+	}
+}
+
+// containsFold reports whether substr is within s, ignoring case like the MySQL collation.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, other := range list {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryEvents) Create(ctx context.Context, userID int, event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.create(userID, event)
+}
+
+func (s *memoryEvents) Duplicate(ctx context.Context, userID, sourceID int, event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.create(userID, event); err != nil {
+		return err
+	}
+	source, ok := s.events[sourceID]
+	if !ok {
+		return nil
+	}
+	stored := s.events[event.ID]
+	for _, r := range source.reminders {
+		s.lastReminderID++
+		reminder := &memoryReminder{
+			reminder: Reminder{ID: s.lastReminderID, EventID: event.ID, Before: r.reminder.Before, CreatedAt: reminderTimestamp()},
+			before:   r.before,
+		}
+		reminder.arm(stored.event.Date)
+		stored.reminders = append(stored.reminders, reminder)
+	}
+	return nil
+}
+
+func (s *memoryEvents) CreateMany(ctx context.Context, userID int, events []*Event) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures := make([]error, len(events))
+	for i, event := range events {
+		failures[i] = s.create(userID, event)
+	}
+	return failures, nil
+}
+
+// create stores a new event of a user, or returns ErrDuplicate if the name is taken. The lock
+// must be held.
+func (s *memoryEvents) create(userID int, event *Event) error {
+	if s.find(userID, event.Name) != nil {
+		return ErrDuplicate
+	}
+	s.dropTrashed(userID, event.Name)
+
+	s.lastEventID++
+	event.ID = s.lastEventID
+	setEventDefaults(event)
+	event.CreatedAt = eventTimestamp()
+	event.UpdatedAt = event.CreatedAt
+	stored := &memoryEvent{userID: userID, event: *event}
+	if user, ok := s.users[userID]; ok {
+		stored.orgID = user.OrgID
+	}
+	s.events[event.ID] = stored
+	return nil
+}
+
+func (s *memoryEvents) Get(ctx context.Context, userID int, name string) (*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.find(userID, name)
+	if stored == nil {
+		return nil, ErrNotFound
+	}
+	event := stored.event
+	return &event, nil
+}
+
+func (s *memoryEvents) NameByID(ctx context.Context, userID, id int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[id]
+	if !ok || !s.visible(userID, stored) {
+		return "", ErrNotFound
+	}
+	return stored.event.Name, nil
+}
+
+func (s *memoryEvents) Update(ctx context.Context, userID int, name string, apply func(*Event) error) (*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.find(userID, name)
+	if stored == nil {
+		return nil, ErrNotFound
+	}
+
+	// Work on a copy so a failed apply leaves the stored event untouched
+	event := stored.event
+	if err := apply(&event); err != nil {
+		return nil, err
+	}
+	setEventDefaults(&event)
+	event.CreatedAt, event.UpdatedAt = stored.event.CreatedAt, eventTimestamp()
+	if other := s.find(userID, event.Name); other != nil && other != stored {
+		return nil, ErrDuplicate
+	}
+	if event.Name != stored.event.Name {
+		s.dropTrashed(userID, event.Name)
+	}
+
+	// A rescheduled event fires again at its new date, and so do its reminders
+	dateChanged := event.Date != stored.event.Date
+	stored.event = event
+	if dateChanged {
+		stored.firedAt = nil
+		stored.armReminders()
+	}
+	return &event, nil
+}
+
+func (s *memoryEvents) Delete(ctx context.Context, userID int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.find(userID, name)
+	if stored == nil {
+		return ErrNotFound
+	}
+	stored.trash()
+	return nil
+}
+
+func (s *memoryEvents) DeleteIf(ctx context.Context, userID int, name string, check func(*Event) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.find(userID, name)
+	if stored == nil {
+		return ErrNotFound
+	}
+	event := stored.event
+	if err := check(&event); err != nil {
+		return err
+	}
+	stored.trash()
+	return nil
+}
+
+func (s *memoryEvents) DeleteMany(ctx context.Context, userID int, names []string) (int64, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	notFound := []string{}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		stored := s.find(userID, name)
+		if stored == nil {
+			notFound = append(notFound, name)
+			continue
+		}
+		stored.trash()
+		deleted++
+	}
+	return deleted, notFound, nil
+}
+
+func (s *memoryEvents) Trash(ctx context.Context, userID int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []Event{}
+	for _, stored := range s.events {
+		if stored.event.DeletedAt != nil && s.owns(userID, stored) {
+			events = append(events, stored.event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].DeletedAt.Equal(*events[j].DeletedAt) {
+			return events[i].DeletedAt.After(*events[j].DeletedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+	return events, nil
+}
+
+func (s *memoryEvents) Restore(ctx context.Context, userID, id int) (*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[id]
+	if !ok || stored.event.DeletedAt == nil || !s.owns(userID, stored) {
+		return nil, ErrNotFound
+	}
+	stored.event.DeletedAt, stored.event.UpdatedAt = nil, eventTimestamp()
+	event := stored.event
+	return &event, nil
+}
+
+func (s *memoryEvents) Erase(ctx context.Context, id int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	delete(s.events, id)
+	return stored.userID, nil
+}
+
+func (s *memoryEvents) PurgeTrash(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Delete in id order so a limited purge removes the same events as the SQL store
+	ids := make([]int, 0, len(s.events))
+	for id, stored := range s.events {
+		if stored.event.DeletedAt != nil && stored.event.DeletedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		delete(s.events, id)
+	}
+	return int64(len(ids)), nil
+}
+
+func (s *memoryEvents) List(ctx context.Context, userID int, filter EventFilter, page Page) ([]Event, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.matching(userID, filter)
+	if page.Keyset {
+		start := sort.Search(len(events), func(i int) bool { return events[i].ID > page.AfterID })
+		events = events[start:]
+	} else {
+		sort.SliceStable(events, func(i, j int) bool {
+			if filter.CompletedOnly {
+				if !events[i].CompletedAt.Equal(*events[j].CompletedAt) {
+					return events[i].CompletedAt.After(*events[j].CompletedAt)
+				}
+				return events[i].ID > events[j].ID
+			}
+			if !filter.ByDate && priorityRank[events[i].Priority] != priorityRank[events[j].Priority] {
+				return priorityRank[events[i].Priority] < priorityRank[events[j].Priority]
+			}
+			return events[i].Date < events[j].Date
+		})
+		if page.Offset >= len(events) {
+			return []Event{}, false, nil
+		}
+		events = events[page.Offset:]
+	}
+
+	if len(events) > page.Limit {
+		return events[:page.Limit], true, nil
+	}
+	return events, false, nil
+}
+
+func (s *memoryEvents) Count(ctx context.Context, userID int, filter EventFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.matching(userID, filter)), nil
+}
+
+func (s *memoryEvents) ListAll(ctx context.Context, filter AdminEventFilter, page Page) ([]OwnedEvent, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.matchingAll(filter)
+	if page.Keyset {
+		start := sort.Search(len(events), func(i int) bool { return events[i].ID > page.AfterID })
+		events = events[start:]
+	} else {
+		if page.Offset >= len(events) {
+			return []OwnedEvent{}, false, nil
+		}
+		events = events[page.Offset:]
+	}
+
+	if len(events) > page.Limit {
+		return events[:page.Limit], true, nil
+	}
+	return events, false, nil
+}
+
+func (s *memoryEvents) CountAll(ctx context.Context, filter AdminEventFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.matchingAll(filter)), nil
+}
+
+// matchingAll returns the events of all users outside the trash matching filter in id order, with
+// their owners. The lock must be held.
+func (s *memoryEvents) matchingAll(filter AdminEventFilter) []OwnedEvent {
+	events := []OwnedEvent{}
+	for _, stored := range s.events {
+		owner, ok := s.users[stored.userID]
+		if !ok || stored.event.DeletedAt != nil || (filter.Owner != "" && owner.Username != filter.Owner) {
+			continue
+		}
+		if !filter.From.IsZero() || !filter.To.IsZero() {
+			date, err := time.Parse(time.RFC3339, stored.event.Date)
+			if err != nil || (!filter.From.IsZero() && date.Before(filter.From)) || (!filter.To.IsZero() && date.After(filter.To)) {
+				continue
+			}
+		}
+		if !matchesRecurrence(recurrenceRule(&stored.event), filter.Recurrence) {
+			continue
+		}
+		events = append(events, OwnedEvent{Event: stored.event, Owner: owner.Username})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+func (s *memoryEvents) GetMany(ctx context.Context, userID int, names []string) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	events := []Event{}
+	for _, event := range s.owned(userID) {
+		if wanted[event.Name] {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryEvents) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := before.UTC().Format(time.RFC3339)
+	var purged int64
+	// Delete in id order so a limited purge removes the same events as the SQL store
+	ids := make([]int, 0, len(s.events))
+	for id := range s.events {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if purged == int64(limit) {
+			break
+		}
+		stored := s.events[id]
+		if user, ok := s.users[stored.userID]; ok && user.KeepEvents {
+			continue
+		}
+		if stored.event.Date < cutoff && (stored.firedAt != nil || stored.event.CompletedAt != nil) && stored.event.SnoozedUntil == nil {
+			delete(s.events, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *memoryEvents) ListByDatePrefix(ctx context.Context, userID int, prefix string) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []Event{}
+	for _, event := range s.owned(userID) {
+		if strings.HasPrefix(event.Date, prefix) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date < events[j].Date })
+	return events, nil
+}
+
+func (s *memoryEvents) Reschedule(ctx context.Context, userID int, dateFor func(*Event) (string, bool)) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rescheduled := []Event{}
+	for _, event := range s.owned(userID) {
+		date, ok := dateFor(&event)
+		if !ok {
+			continue
+		}
+
+		// A rescheduled event fires again at its new date
+		stored := s.events[event.ID]
+		if date != stored.event.Date {
+			// A recurring event's series starts over at its new date
+			stored.firedAt = nil
+			stored.event.SeriesStart, stored.event.UpdatedAt = date, eventTimestamp()
+			stored.event.Date = date
+			stored.armReminders()
+		}
+		event.Date, event.SeriesStart, event.UpdatedAt = date, stored.event.SeriesStart, stored.event.UpdatedAt
+		rescheduled = append(rescheduled, event)
+	}
+	return rescheduled, nil
+}
+
+func (s *memoryEvents) Each(ctx context.Context, userID int, fn func(*Event) error) error {
+	// Iterate over a snapshot so fn may call back into the store
+	s.mu.Lock()
+	events := s.owned(userID)
+	s.mu.Unlock()
+
+	for i := range events {
+		if err := fn(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// due returns a stored event together with its recipient and participants. The lock must be held.
+func (s *memoryEvents) due(stored *memoryEvent) DueEvent {
+	d := DueEvent{UserID: stored.userID, Event: stored.event, Participants: s.participants(stored)}
+	if user, ok := s.users[stored.userID]; ok {
+		d.Recipient = user.recipient()
+	}
+	return d
+}
+
+func (s *memoryEvents) ClaimDue(ctx context.Context, owner string, after, now time.Time, lease time.Duration, limit int) ([]DueEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now = now.UTC()
+	from, to := after.UTC().Format(time.RFC3339), now.Format(time.RFC3339)
+
+	// Visit candidates in id order so the claimed batch matches the SQL store
+	candidates := []*memoryEvent{}
+	for _, stored := range s.events {
+		event := stored.event
+		if event.CompletedAt != nil || event.DeletedAt != nil {
+			continue
+		}
+		dismissed := event.DismissedAt != nil && event.DismissedAt.UTC().Format(time.RFC3339) >= event.Date
+		dated := stored.firedAt == nil && event.SnoozedUntil == nil && event.Date > from && event.Date <= to && !dismissed
+		if dated || (event.SnoozedUntil != nil && !event.SnoozedUntil.After(now)) {
+			candidates = append(candidates, stored)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].event.ID < candidates[j].event.ID })
+
+	claimed := 0
+	for _, stored := range candidates {
+		if claimed == limit {
+			break
+		}
+		if stored.lockedBy == "" || stored.lockedAt.Before(now.Add(-lease)) {
+			stored.lockedBy, stored.lockedAt = owner, now
+			claimed++
+		}
+	}
+
+	due := []DueEvent{}
+	for _, stored := range s.events {
+		if stored.lockedBy == owner && (stored.firedAt == nil || stored.event.SnoozedUntil != nil) {
+			due = append(due, s.due(stored))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Event.ID < due[j].Event.ID })
+	return due, nil
+}
+
+func (s *memoryEvents) MarkFired(ctx context.Context, owner string, ids []int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	firedAt := now.UTC().Truncate(time.Second)
+	for _, id := range ids {
+		if stored, ok := s.events[id]; ok && stored.lockedBy == owner {
+			if stored.event.Date <= now.UTC().Format(time.RFC3339) {
+				stored.firedAt = &firedAt
+			}
+			if stored.event.SnoozedUntil != nil && !stored.event.SnoozedUntil.After(now) {
+				stored.event.SnoozedUntil = nil
+			}
+			stored.lockedBy = ""
+		}
+	}
+	return nil
+}
+
+func (s *memoryEvents) RenewClaims(ctx context.Context, owner string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.events {
+		if stored.lockedBy == owner {
+			stored.lockedAt = now
+		}
+	}
+	return nil
+}
+
+func (s *memoryEvents) FiredAt(ctx context.Context, userID, eventID int) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[eventID]
+	if !ok || !s.visible(userID, stored) {
+		return nil, ErrNotFound
+	}
+	return stored.firedAt, nil
+}
+
+func (s *memoryEvents) DueByIDs(ctx context.Context, ids []int) ([]DueEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []DueEvent{}
+	for _, id := range ids {
+		if stored, ok := s.events[id]; ok && stored.event.DeletedAt == nil {
+			due = append(due, s.due(stored))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Event.ID < due[j].Event.ID })
+	return due, nil
+}
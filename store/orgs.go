@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"time"
+)
+
+// ErrInOrganization is returned when a user who already belongs to an organization creates or
+// joins another one.
+var ErrInOrganization = errors.New("user already belongs to an organization")
+
+// Organization struct describes a group of users sharing their events. Members join with the
+// invite code.
+type Organization struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	InviteCode string    `json:"invite_code"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Members lists the usernames of the members in alphabetical order
+	Members []string `json:"members"`
+}
+
+// OrgStore persists organizations. A user belongs to at most one organization. Joining one moves
+// all of the user's events into it, where every member can see and change them.
+type OrgStore interface {
+	// Create stores a new organization with the user as its first member. It returns
+	// ErrInOrganization if the user already belongs to one.
+	Create(ctx context.Context, userID int, name, inviteCode string) (*Organization, error)
+	// ForUser returns the organization of a user, or ErrNotFound if they belong to none.
+	ForUser(ctx context.Context, userID int) (*Organization, error)
+	// Join adds the user to the organization with the invite code. It returns ErrNotFound for an
+	// unknown code, ErrInOrganization if the user already belongs to one, and ErrDuplicate if one
+	// of the user's event names is already used in the organization; the user is then not added.
+	Join(ctx context.Context, userID int, inviteCode string) (*Organization, error)
+}
+
+// sqlOrgs implements OrgStore on the organizations table and the org_id columns of users and events.
+type sqlOrgs struct {
+	db *database.DB
+}
+
+func (s *sqlOrgs) Create(ctx context.Context, userID int, name, inviteCode string) (*Organization, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	var orgID int
+
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		id, err := tx.InsertID(ctx, "INSERT INTO organizations (name, invite_code, created_at) VALUES (?, ?, ?)", name, inviteCode, now)
+		if err != nil {
+			return mapError(err)
+		}
+		orgID = int(id)
+		return addMember(ctx, tx, orgID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ForUser(ctx, userID)
+}
+
+func (s *sqlOrgs) ForUser(ctx context.Context, userID int) (*Organization, error) {
+	org := new(Organization)
+	err := s.db.QueryRowContext(ctx, "SELECT organizations.id, name, invite_code, created_at FROM organizations"+
+		" JOIN users ON users.org_id = organizations.id WHERE users.id = ?", userID).Scan(&org.ID, &org.Name, &org.InviteCode, &org.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT username FROM users WHERE org_id = ? ORDER BY username", org.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	org.Members = []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		org.Members = append(org.Members, username)
+	}
+	return org, rows.Err()
+}
+
+func (s *sqlOrgs) Join(ctx context.Context, userID int, inviteCode string) (*Organization, error) {
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		var orgID int
+		err := tx.QueryRowContext(ctx, "SELECT id FROM organizations WHERE invite_code = ?", inviteCode).Scan(&orgID)
+		if err != nil {
+			return mapError(err)
+		}
+		return addMember(ctx, tx, orgID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ForUser(ctx, userID)
+}
+
+// addMember makes the user a member of the organization and moves their events into it.
+func addMember(ctx context.Context, tx *database.Tx, orgID, userID int) error {
+	// Lock the user row so concurrent joins of the same user are serialized
+	var current sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT org_id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&current); err != nil {
+		return mapError(err)
+	}
+	if current.Valid {
+		return ErrInOrganization
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET org_id = ? WHERE id = ?", orgID, userID); err != nil {
+		return err
+	}
+	// Events in the trash give up their name to events of the other side, the user's first. MySQL
+	// only reads the table a DELETE changes through a derived table.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM events WHERE user_id = ? AND org_id IS NULL AND deleted_at IS NOT NULL"+
+		" AND name IN (SELECT name FROM (SELECT name FROM events WHERE org_id = ?) AS taken)", userID, orgID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM events WHERE org_id = ? AND deleted_at IS NOT NULL"+
+		" AND name IN (SELECT name FROM (SELECT name FROM events WHERE user_id = ? AND org_id IS NULL) AS joining)", orgID, userID); err != nil {
+		return err
+	}
+	// The unique index on (org_id, name) rejects names already used in the organization
+	_, err := tx.ExecContext(ctx, "UPDATE events SET org_id = ? WHERE user_id = ? AND org_id IS NULL", orgID, userID)
+	return mapError(err)
+}
+
+// memoryOrgs implements OrgStore on top of memory.
+type memoryOrgs struct {
+	*memory
+}
+
+func (s *memoryOrgs) Create(ctx context.Context, userID int, name, inviteCode string) (*Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, org := range s.orgs {
+		if org.InviteCode == inviteCode {
+			return nil, ErrDuplicate
+		}
+	}
+	if err := s.checkMember(userID, 0); err != nil {
+		return nil, err
+	}
+
+	s.lastOrgID++
+	org := Organization{ID: s.lastOrgID, Name: name, InviteCode: inviteCode, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	s.orgs[org.ID] = org
+	s.addMember(org.ID, userID)
+	return s.withMembers(org.ID), nil
+}
+
+func (s *memoryOrgs) ForUser(ctx context.Context, userID int) (*Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || user.OrgID == 0 {
+		return nil, ErrNotFound
+	}
+	return s.withMembers(user.OrgID), nil
+}
+
+func (s *memoryOrgs) Join(ctx context.Context, userID int, inviteCode string) (*Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orgID := 0
+	for id, org := range s.orgs {
+		if org.InviteCode == inviteCode {
+			orgID = id
+		}
+	}
+	if orgID == 0 {
+		return nil, ErrNotFound
+	}
+	if err := s.checkMember(userID, orgID); err != nil {
+		return nil, err
+	}
+
+	s.addMember(orgID, userID)
+	return s.withMembers(orgID), nil
+}
+
+// checkMember reports whether the user can join the organization with id orgID, or create a new
+// one when orgID is 0. The lock must be held.
+func (s *memoryOrgs) checkMember(userID, orgID int) error {
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.OrgID != 0 {
+		return ErrInOrganization
+	}
+	if orgID == 0 {
+		return nil
+	}
+
+	// Names must stay unique within the organization, as with the unique index in the SQL store;
+	// addMember makes way for events outside the trash
+	taken := map[string]bool{}
+	for _, stored := range s.events {
+		if stored.orgID == orgID && stored.event.DeletedAt == nil {
+			taken[stored.event.Name] = true
+		}
+	}
+	for _, stored := range s.events {
+		if stored.userID == userID && stored.orgID == 0 && stored.event.DeletedAt == nil && taken[stored.event.Name] {
+			return ErrDuplicate
+		}
+	}
+	return nil
+}
+
+// addMember makes the user a member and moves their events into the organization. Events in the
+// trash give up their name to events of the other side, the user's first. The lock must be held.
+func (s *memoryOrgs) addMember(orgID, userID int) {
+	taken := map[string]bool{}
+	for _, stored := range s.events {
+		if stored.orgID == orgID {
+			taken[stored.event.Name] = true
+		}
+	}
+	joining := map[string]bool{}
+	for id, stored := range s.events {
+		if stored.userID != userID || stored.orgID != 0 {
+			continue
+		}
+		if stored.event.DeletedAt != nil && taken[stored.event.Name] {
+			delete(s.events, id)
+			continue
+		}
+		joining[stored.event.Name] = true
+	}
+	for id, stored := range s.events {
+		if stored.orgID == orgID && stored.event.DeletedAt != nil && joining[stored.event.Name] {
+			delete(s.events, id)
+		}
+	}
+
+	s.users[userID].OrgID = orgID
+	for _, stored := range s.events {
+		if stored.userID == userID && stored.orgID == 0 {
+			stored.orgID = orgID
+		}
+	}
+}
+
+// withMembers returns a copy of an organization with its members. The lock must be held.
+func (s *memoryOrgs) withMembers(orgID int) *Organization {
+	org := s.orgs[orgID]
+	org.Members = []string{}
+	for _, user := range s.users {
+		if user.OrgID == orgID {
+			org.Members = append(org.Members, user.Username)
+		}
+	}
+	sort.Strings(org.Members)
+	return &org
+}
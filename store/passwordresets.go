@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sync"
+	"time"
+)
+
+// PasswordResetStore persists the tokens emailed to users who forgot their password. Tokens are
+// identified by the hash of their secret, expire after a while, and each one works once.
+type PasswordResetStore interface {
+	// Create stores a new token of a user, valid until expiresAt.
+	Create(ctx context.Context, userID int, hash string, expiresAt time.Time) error
+	// Redeem uses up the active token with hash, along with every other unused token of its user,
+	// replaces the password hash of the user with passwordHash, and revokes the user's refresh
+	// and access tokens. It returns the user, or ErrNotFound if the token is unknown, used, or expired at now.
+	Redeem(ctx context.Context, hash string, now time.Time, passwordHash string) (int, error)
+}
+
+// sqlPasswordResets implements PasswordResetStore on the password_resets table.
+type sqlPasswordResets struct {
+	db *database.DB
+}
+
+func (s *sqlPasswordResets) Create(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO password_resets (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		userID, hash, time.Now().UTC(), expiresAt.UTC())
+	return mapError(err)
+}
+
+func (s *sqlPasswordResets) Redeem(ctx context.Context, hash string, now time.Time, passwordHash string) (int, error) {
+	var userID int
+
+	// Lock the token so two concurrent resets cannot both redeem it
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		err := tx.QueryRowContext(ctx, "SELECT user_id FROM password_resets WHERE token_hash = ? AND used_at IS NULL AND expires_at > ? FOR UPDATE",
+			hash, now.UTC()).Scan(&userID)
+		if err != nil {
+			return err
+		}
+
+		// Older emails stop working too, so a reset cannot be undone with one of them
+		if _, err := tx.ExecContext(ctx, "UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now.UTC(), userID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET password = ?, token_version = token_version + 1 WHERE id = ?", passwordHash, userID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now.UTC(), userID)
+		return err
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return userID, nil
+}
+
+// memoryPasswordReset is a password reset token together with its owner and state.
+type memoryPasswordReset struct {
+	userID    int
+	expiresAt time.Time
+	used      bool
+}
+
+// memoryPasswordResets implements PasswordResetStore in memory, changing passwords in users and
+// revoking tokens in refreshTokens.
+type memoryPasswordResets struct {
+	mu            sync.Mutex
+	tokens        map[string]*memoryPasswordReset
+	users         *memoryUsers
+	refreshTokens *memoryRefreshTokens
+}
+
+func (s *memoryPasswordResets) Create(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[hash]; ok {
+		return ErrDuplicate
+	}
+	s.tokens[hash] = &memoryPasswordReset{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *memoryPasswordResets) Redeem(ctx context.Context, hash string, now time.Time, passwordHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tokens[hash]
+	if !ok || stored.used || !stored.expiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+	if err := s.users.UpdatePassword(ctx, stored.userID, passwordHash); err != nil {
+		return 0, err
+	}
+	if err := s.users.RevokeTokens(ctx, stored.userID); err != nil {
+		return 0, err
+	}
+
+	for _, token := range s.tokens {
+		if token.userID == stored.userID {
+			token.used = true
+		}
+	}
+	s.refreshTokens.RevokeAll(ctx, stored.userID)
+	return stored.userID, nil
+}
+
+// deleteUser removes the tokens of a deleted user.
+func (s *memoryPasswordResets) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, stored := range s.tokens {
+		if stored.userID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+}
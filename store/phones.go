@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sync"
+	"time"
+)
+
+// ErrCodeMismatch is returned when a verification code does not match the one sent.
+var ErrCodeMismatch = errors.New("verification code does not match")
+
+// Phone struct describes the phone number a user verified to receive SMS reminders at, in E.164
+// form such as +14155550123.
+type Phone struct {
+	Number     string    `json:"number"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// PhoneStore persists the verified phone numbers of users, at most one per user, and the pending
+// verifications of new numbers. A verification is identified by the hash of the code texted to the
+// number, expires after a while, and allows a limited number of wrong codes. The number a user
+// verified before stays in use until a new one is verified.
+type PhoneStore interface {
+	// StartVerification stores a verification of number for a user, whose code hashes to hash and
+	// is valid until expiresAt, replacing any verification the user started before.
+	StartVerification(ctx context.Context, userID int, number, hash string, expiresAt time.Time) error
+	// Confirm completes the verification of a user whose code hashes to hash, making its number the
+	// user's phone and setting its VerifiedAt. It returns ErrNotFound if the user has no verification
+	// active at now, and ErrCodeMismatch for a wrong code, which uses up an attempt; the verification
+	// is dropped once maxAttempts wrong codes were tried.
+	Confirm(ctx context.Context, userID int, hash string, now time.Time, maxAttempts int) (*Phone, error)
+	// Phone returns the verified phone of a user, or ErrNotFound if they have none.
+	Phone(ctx context.Context, userID int) (*Phone, error)
+	// Delete removes the phone of a user along with any pending verification, or returns ErrNotFound
+	// if they have neither.
+	Delete(ctx context.Context, userID int) error
+}
+
+// sqlPhones implements PhoneStore on the phone_numbers and phone_verifications tables.
+type sqlPhones struct {
+	db *database.DB
+}
+
+func (s *sqlPhones) StartVerification(ctx context.Context, userID int, number, hash string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO phone_verifications (user_id, number, code_hash, expires_at, attempts) VALUES (?, ?, ?, ?, 0)"+
+		s.db.Dialect.Upsert("user_id", "number", "code_hash", "expires_at", "attempts"),
+		userID, number, hash, expiresAt.UTC())
+	return mapError(err)
+}
+
+func (s *sqlPhones) Confirm(ctx context.Context, userID int, hash string, now time.Time, maxAttempts int) (*Phone, error) {
+	phone := &Phone{VerifiedAt: now.UTC().Truncate(time.Second)}
+	mismatch := false
+
+	// Lock the verification so concurrent guesses cannot exceed the attempts
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		var codeHash string
+		var attempts int
+		err := tx.QueryRowContext(ctx, "SELECT number, code_hash, attempts FROM phone_verifications WHERE user_id = ? AND expires_at > ? FOR UPDATE",
+			userID, now.UTC()).Scan(&phone.Number, &codeHash, &attempts)
+		if err != nil {
+			return err
+		}
+
+		// A wrong code is recorded rather than rolled back
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(codeHash)) != 1 {
+			mismatch = true
+			if attempts+1 >= maxAttempts {
+				_, err = tx.ExecContext(ctx, "DELETE FROM phone_verifications WHERE user_id = ?", userID)
+			} else {
+				_, err = tx.ExecContext(ctx, "UPDATE phone_verifications SET attempts = ? WHERE user_id = ?", attempts+1, userID)
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM phone_verifications WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO phone_numbers (user_id, number, verified_at) VALUES (?, ?, ?)"+
+			s.db.Dialect.Upsert("user_id", "number", "verified_at"),
+			userID, phone.Number, phone.VerifiedAt)
+		return err
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if mismatch {
+		return nil, ErrCodeMismatch
+	}
+	return phone, nil
+}
+
+func (s *sqlPhones) Phone(ctx context.Context, userID int) (*Phone, error) {
+	phone := new(Phone)
+	err := s.db.QueryRowContext(ctx, "SELECT number, verified_at FROM phone_numbers WHERE user_id = ?", userID).
+		Scan(&phone.Number, &phone.VerifiedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return phone, nil
+}
+
+func (s *sqlPhones) Delete(ctx context.Context, userID int) error {
+	var deleted int64
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		for _, table := range []string{"phone_numbers", "phone_verifications"} {
+			result, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			deleted += rowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// memoryVerification is a pending phone verification.
+type memoryVerification struct {
+	number    string
+	hash      string
+	expiresAt time.Time
+	attempts  int
+}
+
+// memoryPhones implements PhoneStore in memory.
+type memoryPhones struct {
+	mu            sync.Mutex
+	phones        map[int]Phone
+	verifications map[int]memoryVerification
+}
+
+func (s *memoryPhones) StartVerification(ctx context.Context, userID int, number, hash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.verifications[userID] = memoryVerification{number: number, hash: hash, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *memoryPhones) Confirm(ctx context.Context, userID int, hash string, now time.Time, maxAttempts int) (*Phone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verification, ok := s.verifications[userID]
+	if !ok || !verification.expiresAt.After(now) {
+		return nil, ErrNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(verification.hash)) != 1 {
+		verification.attempts++
+		if verification.attempts >= maxAttempts {
+			delete(s.verifications, userID)
+		} else {
+			s.verifications[userID] = verification
+		}
+		return nil, ErrCodeMismatch
+	}
+
+	delete(s.verifications, userID)
+	phone := Phone{Number: verification.number, VerifiedAt: now.UTC().Truncate(time.Second)}
+	s.phones[userID] = phone
+	return &phone, nil
+}
+
+func (s *memoryPhones) Phone(ctx context.Context, userID int) (*Phone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	phone, ok := s.phones[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &phone, nil
+}
+
+func (s *memoryPhones) Delete(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, hasPhone := s.phones[userID]
+	_, hasVerification := s.verifications[userID]
+	if !hasPhone && !hasVerification {
+		return ErrNotFound
+	}
+	delete(s.phones, userID)
+	delete(s.verifications, userID)
+	return nil
+}
+
+// deleteUser removes the phone and pending verification of a deleted user.
+func (s *memoryPhones) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.phones, userID)
+	delete(s.verifications, userID)
+}
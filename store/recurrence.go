@@ -0,0 +1,44 @@
+package store
+
+import (
+	"github.com/Vansh3140/Reminder-App/rrule"
+	"time"
+)
+
+// Occurrences returns up to n dates of event after after, in order. A recurring event's rule is
+// expanded from the start of its series in loc, so occurrences keep their local clock time; a
+// one-off event has at most its own date. Completed events have no further occurrences.
+func Occurrences(event *Event, loc *time.Location, after time.Time, n int) []time.Time {
+	occurrences := []time.Time{}
+	if event.CompletedAt != nil || n <= 0 {
+		return occurrences
+	}
+
+	date, err := time.Parse(time.RFC3339, event.Date)
+	if err != nil {
+		return occurrences
+	}
+
+	rule, err := rrule.Parse(recurrenceRule(event))
+	if recurrenceRule(event) == "" || err != nil {
+		if date.After(after) {
+			occurrences = append(occurrences, date)
+		}
+		return occurrences
+	}
+
+	start := date
+	if event.SeriesStart != "" {
+		if start, err = time.Parse(time.RFC3339, event.SeriesStart); err != nil {
+			start = date
+		}
+	}
+	// Occurrences before the current date have already fired
+	if date.Add(-time.Second).After(after) {
+		after = date.Add(-time.Second)
+	}
+	for _, t := range rule.Occurrences(start.In(loc), after, n) {
+		occurrences = append(occurrences, t.UTC())
+	}
+	return occurrences
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sync"
+	"time"
+)
+
+// RefreshTokenStore persists the refresh tokens that renew short-lived access tokens. Tokens are
+// identified by the hash of their secret, and each one is used once: refreshing replaces it.
+type RefreshTokenStore interface {
+	// Create stores a new token of a user, valid until expiresAt.
+	Create(ctx context.Context, userID int, hash string, expiresAt time.Time) error
+	// Rotate revokes the active token with oldHash and stores newHash for the same user in its place,
+	// valid until expiresAt. It returns the user, or ErrNotFound if the token is unknown, revoked,
+	// or expired at now.
+	Rotate(ctx context.Context, oldHash, newHash string, now, expiresAt time.Time) (int, error)
+	// Revoke deactivates an active token and returns its user, or returns ErrNotFound.
+	Revoke(ctx context.Context, hash string) (int, error)
+	// RevokeAll deactivates every active token of a user, ending all their sessions.
+	RevokeAll(ctx context.Context, userID int) error
+}
+
+// sqlRefreshTokens implements RefreshTokenStore on the refresh_tokens table.
+type sqlRefreshTokens struct {
+	db *database.DB
+}
+
+func (s *sqlRefreshTokens) Create(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO refresh_tokens (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		userID, hash, time.Now().UTC(), expiresAt.UTC())
+	return mapError(err)
+}
+
+func (s *sqlRefreshTokens) Rotate(ctx context.Context, oldHash, newHash string, now, expiresAt time.Time) (int, error) {
+	var userID int
+
+	// Lock the old token so two concurrent refreshes cannot both redeem it
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		var id int
+		err := tx.QueryRowContext(ctx, "SELECT id, user_id FROM refresh_tokens WHERE token_hash = ? AND revoked_at IS NULL AND expires_at > ? FOR UPDATE",
+			oldHash, now.UTC()).Scan(&id, &userID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE id = ?", now.UTC(), id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO refresh_tokens (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+			userID, newHash, now.UTC(), expiresAt.UTC())
+		return err
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return userID, nil
+}
+
+func (s *sqlRefreshTokens) Revoke(ctx context.Context, hash string) (int, error) {
+	var userID int
+
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		var id int
+		err := tx.QueryRowContext(ctx, "SELECT id, user_id FROM refresh_tokens WHERE token_hash = ? AND revoked_at IS NULL FOR UPDATE", hash).Scan(&id, &userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE id = ?", time.Now().UTC(), id)
+		return err
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return userID, nil
+}
+
+func (s *sqlRefreshTokens) RevokeAll(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now().UTC(), userID)
+	return err
+}
+
+// memoryRefreshToken is a refresh token together with its owner and state.
+type memoryRefreshToken struct {
+	userID    int
+	expiresAt time.Time
+	revoked   bool
+}
+
+// memoryRefreshTokens implements RefreshTokenStore in memory.
+type memoryRefreshTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*memoryRefreshToken
+}
+
+func (s *memoryRefreshTokens) Create(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[hash]; ok {
+		return ErrDuplicate
+	}
+	s.tokens[hash] = &memoryRefreshToken{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *memoryRefreshTokens) Rotate(ctx context.Context, oldHash, newHash string, now, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tokens[oldHash]
+	if !ok || stored.revoked || !stored.expiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+	if _, ok := s.tokens[newHash]; ok {
+		return 0, ErrDuplicate
+	}
+	stored.revoked = true
+	s.tokens[newHash] = &memoryRefreshToken{userID: stored.userID, expiresAt: expiresAt}
+	return stored.userID, nil
+}
+
+func (s *memoryRefreshTokens) Revoke(ctx context.Context, hash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tokens[hash]
+	if !ok || stored.revoked {
+		return 0, ErrNotFound
+	}
+	stored.revoked = true
+	return stored.userID, nil
+}
+
+func (s *memoryRefreshTokens) RevokeAll(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.tokens {
+		if stored.userID == userID {
+			stored.revoked = true
+		}
+	}
+	return nil
+}
+
+// deleteUser removes the tokens of a deleted user.
+func (s *memoryRefreshTokens) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, stored := range s.tokens {
+		if stored.userID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reminder struct describes an additional time an event fires at, Before its date, such as a day
+// ahead. FireAt is when it fires for the event's current date, and FiredAt when it did, or nil.
+type Reminder struct {
+	ID        int        `json:"id"`
+	EventID   int        `json:"event_id"`
+	Before    string     `json:"before"`
+	FireAt    time.Time  `json:"fire_at"`
+	FiredAt   *time.Time `json:"fired_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ReminderStore persists the reminders of events. Reminders follow the visibility of their event,
+// and are deleted with it. Changing the date of an event makes its reminders fire again; a
+// reminder whose time has already passed when it is added or its event's date changes does not fire.
+type ReminderStore interface {
+	// List returns the reminders of one of a user's events, the earliest first, or ErrNotFound
+	// for an unknown event.
+	List(ctx context.Context, userID, eventID int) ([]Reminder, error)
+	// Add stores a reminder firing before ahead of one of a user's events. It returns ErrNotFound
+	// for an unknown event and ErrDuplicate if the event has a reminder at that offset already.
+	Add(ctx context.Context, userID, eventID int, before time.Duration) (*Reminder, error)
+	// Update moves a reminder to another offset, after which it fires again. It returns
+	// ErrNotFound for an unknown reminder and ErrDuplicate if the offset is taken.
+	Update(ctx context.Context, userID, eventID, id int, before time.Duration) (*Reminder, error)
+	// Delete removes a reminder, or returns ErrNotFound.
+	Delete(ctx context.Context, userID, eventID, id int) error
+	// ClaimDue atomically claims up to limit reminders of all users for owner and returns their
+	// events in reminder id order, each with its recipient and ReminderID set. Only reminders of
+	// uncompleted events outside the trash are claimed that have not fired yet, were not dismissed, and whose time
+	// lies after after, unless it is the zero time, and at or before now. Reminders another owner holds a claim on younger than
+	// lease at now are skipped. Claimed reminders must be released with MarkFired.
+	ClaimDue(ctx context.Context, owner string, after, now time.Time, lease time.Duration, limit int) ([]DueEvent, error)
+	// MarkFired records that the reminders with the given ids, claimed by owner, fired at now and
+	// releases the claim.
+	MarkFired(ctx context.Context, owner string, ids []int, now time.Time) error
+	// RenewClaims restarts the lease of every claim owner holds on reminders at now, for an owner
+	// still busy firing the reminders it claimed.
+	RenewClaims(ctx context.Context, owner string, now time.Time) error
+}
+
+// formatOffset renders how long before its event a reminder fires as a Go duration, without the
+// trailing zero units Duration.String adds: 24h rather than 24h0m0s.
+func formatOffset(before time.Duration) string {
+	s := before.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// reminderTimestamp returns the current time at the precision of the DATETIME columns.
+func reminderTimestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Columns of the event_reminders table selected for a reminder, in the order scanReminder reads them
+const reminderColumns = "event_reminders.id, event_reminders.event_id, event_reminders.offset_seconds, event_reminders.fire_at, event_reminders.fired_at, event_reminders.created_at"
+
+// scanReminder reads a row selected with reminderColumns into reminder.
+func scanReminder(row rowScanner, reminder *Reminder) error {
+	var offset int
+	var firedAt sql.NullTime
+	if err := row.Scan(&reminder.ID, &reminder.EventID, &offset, &reminder.FireAt, &firedAt, &reminder.CreatedAt); err != nil {
+		return err
+	}
+
+	reminder.Before = formatOffset(time.Duration(offset) * time.Second)
+	reminder.FiredAt = nil
+	if firedAt.Valid {
+		reminder.FiredAt = &firedAt.Time
+	}
+	return nil
+}
+
+// armReminders recomputes when the reminders of an event fire after its date changed, and lets
+// them fire again. Claims on them are dropped, so a reminder fired for the old date is not
+// recorded as fired for the new one.
+func armReminders(ctx context.Context, tx *database.Tx, eventID int, date time.Time) error {
+	_, err := tx.ExecContext(ctx, "UPDATE event_reminders SET fire_at = "+tx.Dialect.SubtractSeconds("?", "offset_seconds")+", armed_at = ?,"+
+		" fired_at = NULL, locked_by = NULL, locked_at = NULL WHERE event_id = ?", date, reminderTimestamp(), eventID)
+	return err
+}
+
+// copyReminders gives the event with id to a reminder at the offset of each reminder of the event
+// with id from, to fire ahead of date.
+func copyReminders(ctx context.Context, tx *database.Tx, from, to int, date time.Time) error {
+	now := reminderTimestamp()
+	_, err := tx.ExecContext(ctx, "INSERT INTO event_reminders (event_id, offset_seconds, fire_at, armed_at, created_at)"+
+		" SELECT ?, offset_seconds, "+tx.Dialect.SubtractSeconds("?", "offset_seconds")+", ?, ? FROM event_reminders WHERE event_id = ?",
+		to, date, now, now, from)
+	return err
+}
+
+// sqlReminders implements ReminderStore on the event_reminders table.
+type sqlReminders struct {
+	db *database.DB
+}
+
+// get returns one reminder of an event visible to a user, or ErrNotFound.
+func (s *sqlReminders) get(ctx context.Context, userID, eventID, id int) (*Reminder, error) {
+	reminder := new(Reminder)
+	err := scanReminder(s.db.QueryRowContext(ctx, "SELECT "+reminderColumns+" FROM event_reminders JOIN events ON events.id = event_reminders.event_id"+
+		" WHERE event_reminders.id = ? AND event_reminders.event_id = ? AND "+eventScope,
+		id, eventID, userID, userID), reminder)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return reminder, nil
+}
+
+func (s *sqlReminders) List(ctx context.Context, userID, eventID int) ([]Reminder, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "SELECT id FROM events WHERE id = ? AND "+eventScope, eventID, userID, userID).Scan(&id)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+reminderColumns+" FROM event_reminders WHERE event_id = ? ORDER BY offset_seconds DESC", eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []Reminder{}
+	for rows.Next() {
+		var reminder Reminder
+		if err := scanReminder(rows, &reminder); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+func (s *sqlReminders) Add(ctx context.Context, userID, eventID int, before time.Duration) (*Reminder, error) {
+	now := reminderTimestamp()
+	offset := int(before / time.Second)
+
+	var id int64
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		// Lock the event so its date cannot change before the reminder is stored
+		var date time.Time
+		err := tx.QueryRowContext(ctx, "SELECT date FROM events WHERE id = ? AND "+eventScope+" FOR UPDATE", eventID, userID, userID).Scan(&date)
+		if err != nil {
+			return err
+		}
+
+		id, err = tx.InsertID(ctx, "INSERT INTO event_reminders (event_id, offset_seconds, fire_at, armed_at, created_at) VALUES (?, ?, ?, ?, ?)",
+			eventID, offset, date.Add(-before), now, now)
+		return err
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return s.get(ctx, userID, eventID, int(id))
+}
+
+func (s *sqlReminders) Update(ctx context.Context, userID, eventID, id int, before time.Duration) (*Reminder, error) {
+	offset := int(before / time.Second)
+	_, err := s.db.ExecContext(ctx, "UPDATE event_reminders SET offset_seconds = ?,"+
+		" fire_at = "+s.db.Dialect.SubtractSeconds("(SELECT date FROM events WHERE events.id = event_reminders.event_id)", "?")+", armed_at = ?,"+
+		" fired_at = NULL, locked_by = NULL, locked_at = NULL"+
+		" WHERE id = ? AND event_id = ? AND offset_seconds <> ? AND event_id IN (SELECT id FROM events WHERE "+eventScope+")",
+		offset, offset, reminderTimestamp(), id, eventID, offset, userID, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	// A reminder kept at its offset is left as it is, so it is looked up rather than counted
+	return s.get(ctx, userID, eventID, id)
+}
+
+func (s *sqlReminders) Delete(ctx context.Context, userID, eventID, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM event_reminders"+
+		" WHERE id = ? AND event_id = ? AND event_id IN (SELECT id FROM events WHERE "+eventScope+")", id, eventID, userID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlReminders) ClaimDue(ctx context.Context, owner string, after, now time.Time, lease time.Duration, limit int) ([]DueEvent, error) {
+	now = now.UTC()
+	lower, args := lowerBound("fire_at", after)
+
+	// A single UPDATE takes the claim atomically, so concurrent schedulers never claim the same row
+	_, err := s.db.ExecContext(ctx, "UPDATE event_reminders SET locked_by = ?, locked_at = ? WHERE "+s.db.Dialect.FirstRows("event_reminders",
+		"fired_at IS NULL AND (locked_by IS NULL OR locked_at < ?)"+lower+" AND fire_at > armed_at AND fire_at <= ?"+
+			" AND EXISTS (SELECT 1 FROM events WHERE events.id = event_reminders.event_id AND events.completed_at IS NULL AND events.deleted_at IS NULL"+
+			" AND (events.dismissed_at IS NULL OR events.dismissed_at < event_reminders.fire_at))"),
+		append(append([]interface{}{owner, now, now.Add(-lease)}, args...), now, limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT event_reminders.id, "+dueColumns+", "+qualifiedEventColumns+" FROM event_reminders"+
+		" JOIN events ON events.id = event_reminders.event_id JOIN users ON users.id = events.user_id"+
+		" WHERE event_reminders.locked_by = ? AND event_reminders.fired_at IS NULL ORDER BY event_reminders.id", owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := []DueEvent{}
+	for rows.Next() {
+		var d DueEvent
+		if err := scanDueEvent(rows, &d, &d.ReminderID); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// The rows are done, which frees their connection for the next query
+	if err := addParticipants(ctx, s.db, due); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+func (s *sqlReminders) MarkFired(ctx context.Context, owner string, ids []int, now time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders, args := idPlaceholders(ids)
+	args = append([]interface{}{now.UTC(), owner}, args...)
+	_, err := s.db.ExecContext(ctx, "UPDATE event_reminders SET fired_at = ?, locked_by = NULL, locked_at = NULL"+
+		" WHERE locked_by = ? AND id IN ("+placeholders+")", args...)
+	return err
+}
+
+func (s *sqlReminders) RenewClaims(ctx context.Context, owner string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE event_reminders SET locked_at = ? WHERE locked_by = ?", now.UTC(), owner)
+	return err
+}
+
+// memoryReminder is a reminder together with its scheduler state.
+type memoryReminder struct {
+	reminder Reminder
+	before   time.Duration
+	armedAt  time.Time
+	lockedBy string
+	lockedAt time.Time
+}
+
+// arm recomputes when a reminder fires for the event date, and lets it fire again.
+func (r *memoryReminder) arm(date string) {
+	t, _ := time.Parse(time.RFC3339, date)
+	r.reminder.FireAt = t.UTC().Add(-r.before)
+	r.reminder.FiredAt = nil
+	r.armedAt = reminderTimestamp()
+	r.lockedBy = ""
+}
+
+// armReminders lets the reminders of a stored event fire again for its current date.
+func (stored *memoryEvent) armReminders() {
+	for _, r := range stored.reminders {
+		r.arm(stored.event.Date)
+	}
+}
+
+// memoryReminders implements ReminderStore on top of memory, keeping reminders with their events.
+type memoryReminders struct {
+	*memory
+}
+
+// event returns a stored event visible to a user, or nil. The lock must be held.
+func (s *memoryReminders) event(userID, eventID int) *memoryEvent {
+	stored, ok := s.events[eventID]
+	if !ok || !(&memoryEvents{s.memory}).visible(userID, stored) {
+		return nil
+	}
+	return stored
+}
+
+// find returns one reminder of an event visible to a user, or nil. The lock must be held.
+func (s *memoryReminders) find(userID, eventID, id int) *memoryReminder {
+	stored := s.event(userID, eventID)
+	if stored == nil {
+		return nil
+	}
+	for _, r := range stored.reminders {
+		if r.reminder.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *memoryReminders) List(ctx context.Context, userID, eventID int) ([]Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.event(userID, eventID)
+	if stored == nil {
+		return nil, ErrNotFound
+	}
+
+	reminders := []Reminder{}
+	for _, r := range stored.reminders {
+		reminders = append(reminders, r.reminder)
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].FireAt.Before(reminders[j].FireAt) })
+	return reminders, nil
+}
+
+func (s *memoryReminders) Add(ctx context.Context, userID, eventID int, before time.Duration) (*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.event(userID, eventID)
+	if stored == nil {
+		return nil, ErrNotFound
+	}
+	for _, r := range stored.reminders {
+		if r.before == before {
+			return nil, ErrDuplicate
+		}
+	}
+
+	s.lastReminderID++
+	r := &memoryReminder{
+		reminder: Reminder{ID: s.lastReminderID, EventID: eventID, Before: formatOffset(before), CreatedAt: reminderTimestamp()},
+		before:   before,
+	}
+	r.arm(stored.event.Date)
+	stored.reminders = append(stored.reminders, r)
+	reminder := r.reminder
+	return &reminder, nil
+}
+
+func (s *memoryReminders) Update(ctx context.Context, userID, eventID, id int, before time.Duration) (*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.find(userID, eventID, id)
+	if r == nil {
+		return nil, ErrNotFound
+	}
+	if r.before == before {
+		reminder := r.reminder
+		return &reminder, nil
+	}
+	for _, other := range s.events[eventID].reminders {
+		if other.before == before {
+			return nil, ErrDuplicate
+		}
+	}
+
+	r.before = before
+	r.reminder.Before = formatOffset(before)
+	r.arm(s.events[eventID].event.Date)
+	reminder := r.reminder
+	return &reminder, nil
+}
+
+func (s *memoryReminders) Delete(ctx context.Context, userID, eventID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(userID, eventID, id) == nil {
+		return ErrNotFound
+	}
+	stored := s.events[eventID]
+	kept := stored.reminders[:0]
+	for _, r := range stored.reminders {
+		if r.reminder.ID != id {
+			kept = append(kept, r)
+		}
+	}
+	stored.reminders = kept
+	return nil
+}
+
+func (s *memoryReminders) ClaimDue(ctx context.Context, owner string, after, now time.Time, lease time.Duration, limit int) ([]DueEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now = now.UTC()
+
+	// Visit candidates in id order so the claimed batch matches the SQL store
+	candidates := []*memoryReminder{}
+	for _, stored := range s.events {
+		event := stored.event
+		if event.CompletedAt != nil || event.DeletedAt != nil {
+			continue
+		}
+		for _, r := range stored.reminders {
+			fireAt := r.reminder.FireAt
+			dismissed := event.DismissedAt != nil && !event.DismissedAt.Before(fireAt)
+			if r.reminder.FiredAt == nil && fireAt.After(after) && fireAt.After(r.armedAt) && !fireAt.After(now) && !dismissed {
+				candidates = append(candidates, r)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].reminder.ID < candidates[j].reminder.ID })
+
+	claimed := 0
+	for _, r := range candidates {
+		if claimed == limit {
+			break
+		}
+		if r.lockedBy == "" || r.lockedAt.Before(now.Add(-lease)) {
+			r.lockedBy, r.lockedAt = owner, now
+			claimed++
+		}
+	}
+
+	events := &memoryEvents{s.memory}
+	due := []DueEvent{}
+	for _, stored := range s.events {
+		for _, r := range stored.reminders {
+			if r.lockedBy == owner && r.reminder.FiredAt == nil {
+				d := events.due(stored)
+				d.ReminderID = r.reminder.ID
+				due = append(due, d)
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ReminderID < due[j].ReminderID })
+	return due, nil
+}
+
+func (s *memoryReminders) MarkFired(ctx context.Context, owner string, ids []int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fired := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		fired[id] = true
+	}
+	firedAt := now.UTC().Truncate(time.Second)
+	for _, stored := range s.events {
+		for _, r := range stored.reminders {
+			if fired[r.reminder.ID] && r.lockedBy == owner {
+				r.reminder.FiredAt = &firedAt
+				r.lockedBy = ""
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryReminders) RenewClaims(ctx context.Context, owner string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.events {
+		for _, r := range stored.reminders {
+			if r.lockedBy == owner {
+				r.lockedAt = now.UTC()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"time"
+)
+
+// Permissions of the users an event is shared with
+const (
+	// PermissionRead lets a user see an event and its reminders
+	PermissionRead = "read"
+	// PermissionEdit also lets a user change an event, snooze, dismiss, or complete it, and manage
+	// its reminders
+	PermissionEdit = "edit"
+)
+
+// Share struct describes a user an event is shared with and what they may do with it.
+type Share struct {
+	Username   string    `json:"username"`
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SharedEvent struct is an event shared with a user, together with the username of its owner and
+// the permission the user was given.
+type SharedEvent struct {
+	Event
+	Owner      string `json:"owner"`
+	Permission string `json:"permission"`
+}
+
+// Participant is a user an event is shared with, as the scheduler notifies them.
+type Participant struct {
+	UserID    int
+	Recipient Recipient
+}
+
+// ShareStore persists the users events are shared with. Any user who can see an event, its owner
+// or a member of its organization, manages its shares. Shares are deleted with their event or the
+// user they were given to; an event in the trash is not shared until it is restored.
+type ShareStore interface {
+	// Share shares the event with the given id with another user, or changes the permission of a
+	// user it is already shared with, and returns the share. It returns ErrNotFound if the owner
+	// can see no such event.
+	Share(ctx context.Context, ownerID, eventID, userID int, permission string) (*Share, error)
+	// List returns the shares of an event in username order, or ErrNotFound if the owner can see
+	// no such event.
+	List(ctx context.Context, ownerID, eventID int) ([]Share, error)
+	// Unshare stops sharing an event with a user. It returns ErrNotFound if the owner can see no
+	// such event or it is not shared with the user.
+	Unshare(ctx context.Context, ownerID, eventID, userID int) error
+	// Access returns the id of the user owning an event shared with userID, whose events hold it,
+	// and the permission userID was given. It returns ErrNotFound if the event is not shared with
+	// userID or is in the trash.
+	Access(ctx context.Context, userID, eventID int) (int, string, error)
+	// SharedWith returns the events shared with a user, outside the trash, in id order.
+	SharedWith(ctx context.Context, userID int) ([]SharedEvent, error)
+}
+
+// sqlShares implements ShareStore on the event_shares table.
+type sqlShares struct {
+	db *database.DB
+}
+
+func (s *sqlShares) Share(ctx context.Context, ownerID, eventID, userID int, permission string) (*Share, error) {
+	share := &Share{Permission: permission}
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		if err := lockShared(ctx, tx, ownerID, eventID); err != nil {
+			return err
+		}
+		// Sharing again changes the permission and keeps when the event was first shared
+		if _, err := tx.ExecContext(ctx, "INSERT INTO event_shares (event_id, user_id, permission, created_at) VALUES (?, ?, ?, ?)"+
+			s.db.Dialect.Upsert("event_id, user_id", "permission"),
+			eventID, userID, permission, time.Now().UTC().Truncate(time.Second)); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, "SELECT users.username, event_shares.created_at FROM event_shares JOIN users ON users.id = event_shares.user_id"+
+			" WHERE event_shares.event_id = ? AND event_shares.user_id = ?", eventID, userID).Scan(&share.Username, &share.CreatedAt)
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return share, nil
+}
+
+func (s *sqlShares) List(ctx context.Context, ownerID, eventID int) ([]Share, error) {
+	var shares []Share
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		if err := lockShared(ctx, tx, ownerID, eventID); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, "SELECT users.username, event_shares.permission, event_shares.created_at FROM event_shares"+
+			" JOIN users ON users.id = event_shares.user_id WHERE event_shares.event_id = ? ORDER BY users.username", eventID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		shares = []Share{}
+		for rows.Next() {
+			var share Share
+			if err := rows.Scan(&share.Username, &share.Permission, &share.CreatedAt); err != nil {
+				return err
+			}
+			shares = append(shares, share)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return shares, nil
+}
+
+func (s *sqlShares) Unshare(ctx context.Context, ownerID, eventID, userID int) error {
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		if err := lockShared(ctx, tx, ownerID, eventID); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, "DELETE FROM event_shares WHERE event_id = ? AND user_id = ?", eventID, userID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return mapError(err)
+}
+
+func (s *sqlShares) Access(ctx context.Context, userID, eventID int) (int, string, error) {
+	var ownerID int
+	var permission string
+	err := s.db.QueryRowContext(ctx, "SELECT events.user_id, event_shares.permission FROM event_shares JOIN events ON events.id = event_shares.event_id"+
+		" WHERE event_shares.event_id = ? AND event_shares.user_id = ? AND events.deleted_at IS NULL", eventID, userID).Scan(&ownerID, &permission)
+	if err != nil {
+		return 0, "", mapError(err)
+	}
+	return ownerID, permission, nil
+}
+
+func (s *sqlShares) SharedWith(ctx context.Context, userID int) ([]SharedEvent, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT users.username, event_shares.permission, "+qualifiedEventColumns+" FROM event_shares"+
+		" JOIN events ON events.id = event_shares.event_id JOIN users ON users.id = events.user_id"+
+		" WHERE event_shares.user_id = ? AND events.deleted_at IS NULL ORDER BY events.id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []SharedEvent{}
+	for rows.Next() {
+		var shared SharedEvent
+		if err := scanEvent(rows, &shared.Event, &shared.Owner, &shared.Permission); err != nil {
+			return nil, err
+		}
+		events = append(events, shared)
+	}
+	return events, rows.Err()
+}
+
+// lockShared locks the event with the given id a user can see until tx ends, so that its shares do
+// not change concurrently, or returns ErrNotFound if the user can see no such event.
+func lockShared(ctx context.Context, tx *database.Tx, ownerID, eventID int) error {
+	var id int
+	return tx.QueryRowContext(ctx, "SELECT id FROM events WHERE id = ? AND "+eventScope+" FOR UPDATE", eventID, ownerID, ownerID).Scan(&id)
+}
+
+// addParticipants fills in the Participants of due events: the users each event is shared with.
+func addParticipants(ctx context.Context, db *database.DB, due []DueEvent) error {
+	if len(due) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(due))
+	for i, d := range due {
+		ids[i] = d.Event.ID
+	}
+	placeholders, args := idPlaceholders(ids)
+	rows, err := db.QueryContext(ctx, "SELECT event_shares.event_id, users.id, users.username, users.email, users.timezone, users.disabled_channels, users.disabled_at FROM event_shares"+
+		" JOIN users ON users.id = event_shares.user_id WHERE event_shares.event_id IN ("+placeholders+") ORDER BY users.username", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	participants := map[int][]Participant{}
+	for rows.Next() {
+		var eventID int
+		var p Participant
+		var disabledChannels string
+		var disabledAt sql.NullTime
+		if err := rows.Scan(&eventID, &p.UserID, &p.Recipient.Username, &p.Recipient.Email, &p.Recipient.Timezone, &disabledChannels, &disabledAt); err != nil {
+			return err
+		}
+		p.Recipient.DisabledChannels = splitChannels(disabledChannels)
+		p.Recipient.Disabled = disabledAt.Valid
+		participants[eventID] = append(participants[eventID], p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range due {
+		due[i].Participants = participants[due[i].Event.ID]
+	}
+	return nil
+}
+
+// memoryShare is the share of an event with one user.
+type memoryShare struct {
+	permission string
+	createdAt  time.Time
+}
+
+// memoryShares implements ShareStore on the events in memory.
+type memoryShares struct {
+	*memory
+}
+
+func (s *memoryShares) Share(ctx context.Context, ownerID, eventID, userID int, permission string) (*Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[eventID]
+	user, known := s.users[userID]
+	if !ok || !(&memoryEvents{s.memory}).visible(ownerID, stored) || !known {
+		return nil, ErrNotFound
+	}
+
+	if stored.shares == nil {
+		stored.shares = map[int]memoryShare{}
+	}
+	share, ok := stored.shares[userID]
+	if !ok {
+		share.createdAt = time.Now().UTC().Truncate(time.Second)
+	}
+	share.permission = permission
+	stored.shares[userID] = share
+	return &Share{Username: user.Username, Permission: permission, CreatedAt: share.createdAt}, nil
+}
+
+func (s *memoryShares) List(ctx context.Context, ownerID, eventID int) ([]Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[eventID]
+	if !ok || !(&memoryEvents{s.memory}).visible(ownerID, stored) {
+		return nil, ErrNotFound
+	}
+
+	shares := []Share{}
+	for userID, share := range stored.shares {
+		if user, ok := s.users[userID]; ok {
+			shares = append(shares, Share{Username: user.Username, Permission: share.permission, CreatedAt: share.createdAt})
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Username < shares[j].Username })
+	return shares, nil
+}
+
+func (s *memoryShares) Unshare(ctx context.Context, ownerID, eventID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[eventID]
+	if !ok || !(&memoryEvents{s.memory}).visible(ownerID, stored) {
+		return ErrNotFound
+	}
+	if _, ok := stored.shares[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(stored.shares, userID)
+	return nil
+}
+
+func (s *memoryShares) Access(ctx context.Context, userID, eventID int) (int, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[eventID]
+	if !ok || stored.event.DeletedAt != nil {
+		return 0, "", ErrNotFound
+	}
+	share, ok := stored.shares[userID]
+	if !ok {
+		return 0, "", ErrNotFound
+	}
+	return stored.userID, share.permission, nil
+}
+
+func (s *memoryShares) SharedWith(ctx context.Context, userID int) ([]SharedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []SharedEvent{}
+	for _, stored := range s.events {
+		share, ok := stored.shares[userID]
+		if !ok || stored.event.DeletedAt != nil {
+			continue
+		}
+		shared := SharedEvent{Event: stored.event, Permission: share.permission}
+		if owner, ok := s.users[stored.userID]; ok {
+			shared.Owner = owner.Username
+		}
+		events = append(events, shared)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// participants returns the users a stored event is shared with in username order. The lock must
+// be held.
+func (s *memory) participants(stored *memoryEvent) []Participant {
+	var participants []Participant
+	for userID := range stored.shares {
+		if user, ok := s.users[userID]; ok {
+			participants = append(participants, Participant{
+				UserID:    userID,
+				Recipient: user.recipient(),
+			})
+		}
+	}
+	sort.Slice(participants, func(i, j int) bool { return participants[i].Recipient.Username < participants[j].Recipient.Username })
+	return participants
+}
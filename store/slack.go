@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sync"
+	"time"
+)
+
+// Ways reminders reach Slack
+const (
+	SlackModeWebhook = "webhook"
+	SlackModeApp     = "app"
+)
+
+// SlackConfig struct describes where a user's reminders are posted in Slack: either an incoming
+// webhook, or a channel a Slack app posts to with its bot token. The webhook URL and the token are
+// secrets and never returned.
+type SlackConfig struct {
+	WebhookURL string    `json:"-"`
+	BotToken   string    `json:"-"`
+	Channel    string    `json:"channel"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Mode returns SlackModeWebhook for a configuration posting through an incoming webhook, and
+// SlackModeApp for one posting through a Slack app.
+func (c *SlackConfig) Mode() string {
+	if c.WebhookURL != "" {
+		return SlackModeWebhook
+	}
+	return SlackModeApp
+}
+
+// SlackStore persists the Slack configurations of users, at most one per user.
+type SlackStore interface {
+	// Get returns the configuration of a user, or ErrNotFound if they have none.
+	Get(ctx context.Context, userID int) (*SlackConfig, error)
+	// Set stores the configuration of a user, replacing the one they had, and sets its UpdatedAt.
+	Set(ctx context.Context, userID int, config *SlackConfig) error
+	// Delete removes the configuration of a user, or returns ErrNotFound if they have none.
+	Delete(ctx context.Context, userID int) error
+}
+
+// sqlSlack implements SlackStore on the slack_configs table.
+type sqlSlack struct {
+	db *database.DB
+}
+
+func (s *sqlSlack) Get(ctx context.Context, userID int) (*SlackConfig, error) {
+	config := new(SlackConfig)
+	err := s.db.QueryRowContext(ctx, "SELECT webhook_url, bot_token, channel, updated_at FROM slack_configs WHERE user_id = ?", userID).
+		Scan(&config.WebhookURL, &config.BotToken, &config.Channel, &config.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return config, nil
+}
+
+func (s *sqlSlack) Set(ctx context.Context, userID int, config *SlackConfig) error {
+	updatedAt := time.Now().UTC().Truncate(time.Second)
+	_, err := s.db.ExecContext(ctx, "INSERT INTO slack_configs (user_id, webhook_url, bot_token, channel, updated_at) VALUES (?, ?, ?, ?, ?)"+
+		s.db.Dialect.Upsert("user_id", "webhook_url", "bot_token", "channel", "updated_at"),
+		userID, config.WebhookURL, config.BotToken, config.Channel, updatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+	config.UpdatedAt = updatedAt
+	return nil
+}
+
+func (s *sqlSlack) Delete(ctx context.Context, userID int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM slack_configs WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// memorySlack implements SlackStore in memory.
+type memorySlack struct {
+	mu      sync.Mutex
+	configs map[int]SlackConfig
+}
+
+func (s *memorySlack) Get(ctx context.Context, userID int) (*SlackConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, ok := s.configs[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &config, nil
+}
+
+func (s *memorySlack) Set(ctx context.Context, userID int, config *SlackConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	s.configs[userID] = *config
+	return nil
+}
+
+func (s *memorySlack) Delete(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.configs[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.configs, userID)
+	return nil
+}
+
+// deleteUser removes the configuration of a deleted user.
+func (s *memorySlack) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.configs, userID)
+}
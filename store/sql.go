@@ -0,0 +1,1132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Columns of the events table selected for an event
+const eventTableColumns = "id, name, message, date, priority, url, channels, email_notifications, sms_notifications, recurrence, series_start, completed_at, snoozed_until, dismissed_at, deleted_at, created_at, updated_at"
+
+// Subquery selecting the recipients of an event as a JSON array, or NULL when it has none
+const recipientsColumn = "(SELECT JSON_ARRAYAGG(email) FROM event_recipients WHERE event_recipients.event_id = events.id)"
+
+// Subquery selecting the tags of an event as a JSON array, or NULL when it has none
+const tagsColumn = "(SELECT JSON_ARRAYAGG(tag) FROM event_tags WHERE event_tags.event_id = events.id)"
+
+// Columns selected for an event, in the order scanEvent reads them
+const eventColumns = eventTableColumns + ", " + recipientsColumn + ", " + tagsColumn
+
+// eventColumns qualified with the events table, for queries joining other tables
+var qualifiedEventColumns = "events." + strings.ReplaceAll(eventTableColumns, ", ", ", events.") + ", " + recipientsColumn + ", " + tagsColumn
+
+// Columns selected before qualifiedEventColumns when loading due events, in the order scanDueEvent reads them
+const dueColumns = "events.user_id, users.username, users.email, users.timezone, users.disabled_channels, users.disabled_at"
+
+// ORDER BY expression sorting events from the most to the least urgent priority
+const priorityOrder = "CASE priority WHEN 'urgent' THEN 1 WHEN 'high' THEN 2 WHEN 'normal' THEN 3 WHEN 'low' THEN 4 ELSE 0 END"
+
+// rowScanner is implemented by *database.Row, *database.TxRow and *database.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEvent reads a row selected with eventColumns into event. Columns selected before
+// eventColumns are read into leading.
+func scanEvent(row rowScanner, event *Event, leading ...interface{}) error {
+	var channels, recurrence string
+	var emailNotifications, smsNotifications bool
+	var date, seriesStart time.Time
+	var completedAt, snoozedUntil, dismissedAt, deletedAt sql.NullTime
+	var recipients, tags sql.NullString
+	dest := append(leading, &event.ID, &event.Name, &event.Message, &date, &event.Priority, &event.URL, &channels, &emailNotifications,
+		&smsNotifications, &recurrence, &seriesStart, &completedAt, &snoozedUntil, &dismissedAt, &deletedAt, &event.CreatedAt, &event.UpdatedAt, &recipients, &tags)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	event.Date = formatDate(date)
+	event.SeriesStart = formatDate(seriesStart)
+	event.Channels = splitChannels(channels)
+	event.EmailNotifications = &emailNotifications
+	event.SMSNotifications = &smsNotifications
+	event.Recurrence = &recurrence
+
+	event.Recipients = []string{}
+	if recipients.Valid {
+		if err := json.Unmarshal([]byte(recipients.String), &event.Recipients); err != nil {
+			return err
+		}
+		sort.Strings(event.Recipients)
+	}
+	event.Tags = []string{}
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &event.Tags); err != nil {
+			return err
+		}
+		sort.Strings(event.Tags)
+	}
+
+	event.CompletedAt = nil
+	if completedAt.Valid {
+		event.CompletedAt = &completedAt.Time
+	}
+	event.SnoozedUntil = nil
+	if snoozedUntil.Valid {
+		event.SnoozedUntil = &snoozedUntil.Time
+	}
+	event.DismissedAt = nil
+	if dismissedAt.Valid {
+		event.DismissedAt = &dismissedAt.Time
+	}
+	event.DeletedAt = nil
+	if deletedAt.Valid {
+		event.DeletedAt = &deletedAt.Time
+	}
+	return nil
+}
+
+// scanDueEvent reads a row selected with dueColumns and qualifiedEventColumns into due. Columns
+// selected before dueColumns are read into leading.
+func scanDueEvent(row rowScanner, due *DueEvent, leading ...interface{}) error {
+	var disabledChannels string
+	var disabledAt sql.NullTime
+	dest := append(leading, &due.UserID, &due.Recipient.Username, &due.Recipient.Email, &due.Recipient.Timezone, &disabledChannels, &disabledAt)
+	if err := scanEvent(row, &due.Event, dest...); err != nil {
+		return err
+	}
+	due.Recipient.DisabledChannels = splitChannels(disabledChannels)
+	due.Recipient.Disabled = disabledAt.Valid
+	return nil
+}
+
+// parseDate converts an event date, RFC3339 in the API, for the DATETIME columns, which hold UTC.
+func parseDate(date string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid event date %q: %w", date, err)
+	}
+	return t.UTC(), nil
+}
+
+// formatDate converts a DATETIME column back to an RFC3339 event date.
+func formatDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// joinChannels encodes channel names for the comma-separated channels column.
+func joinChannels(channels []string) string {
+	return strings.Join(channels, ",")
+}
+
+// splitChannels decodes the channels column; an empty column yields an empty slice.
+func splitChannels(channels string) []string {
+	if channels == "" {
+		return []string{}
+	}
+	return strings.Split(channels, ",")
+}
+
+// setRecipients replaces the recipients of an event.
+func setRecipients(ctx context.Context, tx *database.Tx, eventID int, recipients []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM event_recipients WHERE event_id = ?", eventID); err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, 2*len(recipients))
+	for _, email := range recipients {
+		args = append(args, eventID, email)
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO event_recipients (event_id, email) VALUES "+
+		strings.TrimSuffix(strings.Repeat("(?, ?), ", len(recipients)), ", "), args...)
+	return err
+}
+
+// setTags replaces the tags of an event.
+func setTags(ctx context.Context, tx *database.Tx, eventID int, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM event_tags WHERE event_id = ?", eventID); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, 2*len(tags))
+	for _, tag := range tags {
+		args = append(args, eventID, tag)
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO event_tags (event_id, tag) VALUES "+
+		strings.TrimSuffix(strings.Repeat("(?, ?), ", len(tags)), ", "), args...)
+	return err
+}
+
+// sameStrings reports whether two lists, such as the recipients or tags of an event, are equal.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mapError translates driver errors into the store's sentinel errors.
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if database.IsDuplicate(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// NewSQL returns a Store backed by the given database, of any dialect. Statements are retried
+// once when the connection is lost, see database.DB.
+func NewSQL(db *database.DB) *Store {
+	return &Store{
+		Users:          &sqlUsers{db: db},
+		Events:         &sqlEvents{db: db},
+		APIKeys:        &sqlAPIKeys{db: db},
+		Audit:          &sqlAudit{db: db},
+		Deliveries:     &sqlDeliveries{db: db},
+		Orgs:           &sqlOrgs{db: db},
+		Templates:      &sqlTemplates{db: db},
+		RefreshTokens:  &sqlRefreshTokens{db: db},
+		Webhooks:       &sqlWebhooks{db: db},
+		Devices:        &sqlDevices{db: db},
+		Reminders:      &sqlReminders{db: db},
+		CalendarFeeds:  &sqlCalendarFeeds{db: db},
+		PasswordResets: &sqlPasswordResets{db: db},
+		Tags:           &sqlTags{db: db},
+		Shares:         &sqlShares{db: db},
+		Telegram:       &sqlTelegram{db: db},
+		Slack:          &sqlSlack{db: db},
+		Phones:         &sqlPhones{db: db},
+		Stats:          &sqlStats{db: db},
+	}
+}
+
+// Columns of the users table read into a User, in the order of sqlUsers.one
+const userColumns = "id, username, password, email, timezone, keep_events, org_id, disabled_channels, token_version, role, disabled_at"
+
+// sqlUsers implements UserStore on the users table.
+type sqlUsers struct {
+	db *database.DB
+}
+
+func (s *sqlUsers) Create(ctx context.Context, username, passwordHash string) (int, error) {
+	id, err := s.db.InsertID(ctx, "INSERT INTO users (username, password) VALUES (?, ?)", username, passwordHash)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return int(id), nil
+}
+
+func (s *sqlUsers) ByID(ctx context.Context, id int) (*User, error) {
+	return s.one(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", id)
+}
+
+func (s *sqlUsers) ByUsername(ctx context.Context, username string) (*User, error) {
+	return s.one(ctx, "SELECT "+userColumns+" FROM users WHERE username = ?", username)
+}
+
+// one runs a query selecting a single user row.
+func (s *sqlUsers) one(ctx context.Context, query string, args ...interface{}) (*User, error) {
+	user := new(User)
+	if err := scanUser(s.db.QueryRowContext(ctx, query, args...), user); err != nil {
+		return nil, mapError(err)
+	}
+	return user, nil
+}
+
+// scanUser reads a row selected with userColumns into user.
+func scanUser(row rowScanner, user *User) error {
+	var orgID sql.NullInt64
+	var disabledChannels string
+	var disabledAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Email, &user.Timezone, &user.KeepEvents, &orgID,
+		&disabledChannels, &user.TokenVersion, &user.Role, &disabledAt)
+	if err != nil {
+		return err
+	}
+	user.OrgID = int(orgID.Int64)
+	user.DisabledChannels = splitChannels(disabledChannels)
+	user.DisabledAt = nil
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+	return nil
+}
+
+func (s *sqlUsers) UpdateSettings(ctx context.Context, id int, email, timezone string, keepEvents *bool) error {
+	// Only the provided fields are written
+	var sets []string
+	var args []interface{}
+	if email != "" {
+		sets = append(sets, "email = ?")
+		args = append(args, email)
+	}
+	if timezone != "" {
+		sets = append(sets, "timezone = ?")
+		args = append(args, timezone)
+	}
+	if keepEvents != nil {
+		sets = append(sets, "keep_events = ?")
+		args = append(args, *keepEvents)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	args = append(args, id)
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	return err
+}
+
+func (s *sqlUsers) UpdateChannels(ctx context.Context, id int, disabled []string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET disabled_channels = ? WHERE id = ?", joinChannels(disabled), id)
+	return err
+}
+
+func (s *sqlUsers) UpdateUsername(ctx context.Context, id int, username string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET username = ? WHERE id = ?", username, id)
+	return mapError(err)
+}
+
+func (s *sqlUsers) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", passwordHash, id)
+	return err
+}
+
+func (s *sqlUsers) RevokeTokens(ctx context.Context, id int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET token_version = token_version + 1 WHERE id = ?", id)
+	return err
+}
+
+// userSearchWhere returns the WHERE condition and arguments selecting the users whose username
+// contains search, or all users when it is empty.
+func userSearchWhere(dialect database.Dialect, search string) (string, []interface{}) {
+	if search == "" {
+		return "1 = 1", nil
+	}
+	return dialect.Like("username"), []interface{}{"%" + escapeLike(search) + "%"}
+}
+
+func (s *sqlUsers) List(ctx context.Context, search string, page Page) ([]User, bool, error) {
+	where, args := userSearchWhere(s.db.Dialect, search)
+
+	// Fetch one row more than requested to learn whether another page follows
+	var rows *database.Rows
+	var err error
+	if page.Keyset {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE "+where+" AND id > ? ORDER BY id LIMIT ?",
+			append(args, page.AfterID, page.Limit+1)...)
+	} else {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE "+where+" ORDER BY id LIMIT ? OFFSET ?",
+			append(args, page.Limit+1, page.Offset)...)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, false, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(users) > page.Limit {
+		return users[:page.Limit], true, nil
+	}
+	return users, false, nil
+}
+
+func (s *sqlUsers) Count(ctx context.Context, search string) (int, error) {
+	where, args := userSearchWhere(s.db.Dialect, search)
+
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+func (s *sqlUsers) SetRole(ctx context.Context, id int, role string) error {
+	return s.updateOne(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id)
+}
+
+func (s *sqlUsers) SetDisabled(ctx context.Context, id int, disabledAt *time.Time) error {
+	var at interface{}
+	if disabledAt != nil {
+		at = disabledAt.UTC()
+	}
+	return s.updateOne(ctx, "UPDATE users SET disabled_at = ? WHERE id = ?", at, id)
+}
+
+// updateOne runs an UPDATE of a single user, returning ErrNotFound if there is no such user.
+func (s *sqlUsers) updateOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		// MySQL counts only changed rows, so a user already in the requested state lands here too
+		var exists int
+		return mapError(s.db.QueryRowContext(ctx, "SELECT 1 FROM users WHERE id = ?", args[len(args)-1]).Scan(&exists))
+	}
+	return nil
+}
+
+func (s *sqlUsers) Delete(ctx context.Context, id int) error {
+	// Everything else a user owns goes with the user through ON DELETE CASCADE
+	return database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM templates WHERE user_id = ?", id); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// sqlEvents implements EventStore on the events table.
+type sqlEvents struct {
+	db *database.DB
+}
+
+func (s *sqlEvents) Create(ctx context.Context, userID int, event *Event) error {
+	// The event, its recipients and its tags are stored together
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		return insertEvent(ctx, tx, userID, event)
+	})
+	return mapError(err)
+}
+
+func (s *sqlEvents) Duplicate(ctx context.Context, userID, sourceID int, event *Event) error {
+	// The copy and its reminders are stored together
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		if err := insertEvent(ctx, tx, userID, event); err != nil {
+			return err
+		}
+		date, err := parseDate(event.Date)
+		if err != nil {
+			return err
+		}
+		return copyReminders(ctx, tx, sourceID, event.ID, date)
+	})
+	return mapError(err)
+}
+
+func (s *sqlEvents) CreateMany(ctx context.Context, userID int, events []*Event) ([]error, error) {
+	failures := make([]error, len(events))
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		for i, event := range events {
+			// A taken name fails only its own statement, so the transaction goes on
+			err := tx.Savepoint(ctx, func() error {
+				return insertEvent(ctx, tx, userID, event)
+			})
+			if errors.Is(mapError(err), ErrDuplicate) {
+				failures[i] = ErrDuplicate
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return failures, nil
+}
+
+// insertEvent stores a new event of a user with its recipients and tags in tx, setting its ID and timestamps.
+func insertEvent(ctx context.Context, tx *database.Tx, userID int, event *Event) error {
+	setEventDefaults(event)
+	date, err := parseDate(event.Date)
+	if err != nil {
+		return err
+	}
+	seriesStart, err := parseDate(event.SeriesStart)
+	if err != nil {
+		return err
+	}
+	now := eventTimestamp()
+	if err := dropTrashed(ctx, tx, userID, event.Name); err != nil {
+		return err
+	}
+
+	// Events of organization members are shared with the organization
+	id, err := tx.InsertID(ctx, "INSERT INTO events (name, message, date, priority, url, channels, email_notifications, sms_notifications, recurrence, series_start, user_id, org_id, created_at, updated_at)"+
+		" VALUES(?,?,?,?,?,?,?,?,?,?,?,(SELECT org_id FROM users WHERE id = ?),?,?)",
+		event.Name, event.Message, date, event.Priority, event.URL, joinChannels(event.Channels), *event.EmailNotifications, *event.SMSNotifications, *event.Recurrence, seriesStart,
+		userID, userID, now, now)
+	if err != nil {
+		return err
+	}
+	if err := setRecipients(ctx, tx, int(id), event.Recipients); err != nil {
+		return err
+	}
+	if err := setTags(ctx, tx, int(id), event.Tags); err != nil {
+		return err
+	}
+
+	event.ID = int(id)
+	event.CreatedAt, event.UpdatedAt = now, now
+	return nil
+}
+
+func (s *sqlEvents) Get(ctx context.Context, userID int, name string) (*Event, error) {
+	event := new(Event)
+	err := scanEvent(s.db.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE name = ? AND "+eventScope, name, userID, userID), event)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return event, nil
+}
+
+func (s *sqlEvents) NameByID(ctx context.Context, userID, id int) (string, error) {
+	var name string
+	err := s.db.QueryRowContext(ctx, "SELECT name FROM events WHERE id = ? AND "+eventScope, id, userID, userID).Scan(&name)
+	if err != nil {
+		return "", mapError(err)
+	}
+	return name, nil
+}
+
+func (s *sqlEvents) Erase(ctx context.Context, id int) (int, error) {
+	var userID int
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		if err := tx.QueryRowContext(ctx, "SELECT user_id FROM events WHERE id = ? FOR UPDATE", id).Scan(&userID); err != nil {
+			return err
+		}
+		// Tags, shares, and reminders go with the event through ON DELETE CASCADE
+		_, err := tx.ExecContext(ctx, "DELETE FROM events WHERE id = ?", id)
+		return err
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return userID, nil
+}
+
+func (s *sqlEvents) Update(ctx context.Context, userID int, name string, apply func(*Event) error) (*Event, error) {
+	event := new(Event)
+
+	// Read and rewrite the event in one transaction so concurrent updates cannot interleave
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		// Fetch the current details of the event, locking the row until the update commits
+		err := scanEvent(tx.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE name = ? AND "+eventScope+" FOR UPDATE", name, userID, userID), event)
+		if err != nil {
+			return err
+		}
+
+		previousDate, previousRecipients, previousTags, createdAt := event.Date, event.Recipients, event.Tags, event.CreatedAt
+		if err := apply(event); err != nil {
+			return err
+		}
+		setEventDefaults(event)
+		event.CreatedAt, event.UpdatedAt = createdAt, eventTimestamp()
+		date, err := parseDate(event.Date)
+		if err != nil {
+			return err
+		}
+		seriesStart, err := parseDate(event.SeriesStart)
+		if err != nil {
+			return err
+		}
+		if event.Name != name {
+			if err := dropTrashed(ctx, tx, userID, event.Name); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE events SET name = ?, message = ?, date = ?, priority = ?, url = ?, channels = ?, email_notifications = ?,"+
+			" sms_notifications = ?, recurrence = ?, series_start = ?, completed_at = ?, snoozed_until = ?, dismissed_at = ?, updated_at = ? WHERE id = ?",
+			event.Name, event.Message, date, event.Priority, event.URL, joinChannels(event.Channels), *event.EmailNotifications,
+			*event.SMSNotifications, *event.Recurrence, seriesStart, event.CompletedAt, event.SnoozedUntil, event.DismissedAt, event.UpdatedAt, event.ID)
+		if err != nil {
+			return err
+		}
+		if !sameStrings(event.Recipients, previousRecipients) {
+			if err := setRecipients(ctx, tx, event.ID, event.Recipients); err != nil {
+				return err
+			}
+		}
+		if !sameStrings(event.Tags, previousTags) {
+			if err := setTags(ctx, tx, event.ID, event.Tags); err != nil {
+				return err
+			}
+		}
+		if event.Date == previousDate {
+			return nil
+		}
+
+		// A rescheduled event fires again at its new date, and so do its reminders
+		if _, err := tx.ExecContext(ctx, "UPDATE events SET fired_at = NULL WHERE id = ?", event.ID); err != nil {
+			return err
+		}
+		return armReminders(ctx, tx, event.ID, date)
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return event, nil
+}
+
+// dropTrashed permanently deletes the event named name in the trash of a user, if any, so that
+// another event can take the name.
+func dropTrashed(ctx context.Context, tx *database.Tx, userID int, name string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM events WHERE name = ? AND "+trashScope, name, userID, userID)
+	return err
+}
+
+func (s *sqlEvents) Delete(ctx context.Context, userID int, name string) error {
+	// deleted_at holds whole seconds, like the other DATETIME columns
+	now := eventTimestamp()
+	result, err := s.db.ExecContext(ctx, "UPDATE events SET deleted_at = ?, updated_at = ? WHERE name = ? AND "+eventScope, now.Truncate(time.Second), now, name, userID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlEvents) DeleteIf(ctx context.Context, userID int, name string, check func(*Event) error) error {
+	// Lock the event while it is checked, so it cannot change before it is deleted
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		event := new(Event)
+		err := scanEvent(tx.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE name = ? AND "+eventScope+" FOR UPDATE", name, userID, userID), event)
+		if err != nil {
+			return err
+		}
+		if err := check(event); err != nil {
+			return err
+		}
+
+		now := eventTimestamp()
+		_, err = tx.ExecContext(ctx, "UPDATE events SET deleted_at = ?, updated_at = ? WHERE id = ?", now.Truncate(time.Second), now, event.ID)
+		return err
+	})
+	return mapError(err)
+}
+
+func (s *sqlEvents) DeleteMany(ctx context.Context, userID int, names []string) (int64, []string, error) {
+	var deleted int64
+	notFound := []string{}
+
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		now := eventTimestamp()
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			// Skip repeated names so they are not reported as missing after the first delete
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			// Run through the transaction rather than a prepared statement, so the times are bound
+			// for the dialect
+			result, err := tx.ExecContext(ctx, "UPDATE events SET deleted_at = ?, updated_at = ? WHERE name = ? AND "+eventScope,
+				now.Truncate(time.Second), now, name, userID, userID)
+			if err != nil {
+				return err
+			}
+
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if rowsAffected == 0 {
+				notFound = append(notFound, name)
+			}
+			deleted += rowsAffected
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return deleted, notFound, nil
+}
+
+func (s *sqlEvents) Trash(ctx context.Context, userID int) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+eventColumns+" FROM events WHERE "+trashScope+" ORDER BY deleted_at DESC, id DESC", userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return collectEvents(rows)
+}
+
+func (s *sqlEvents) Restore(ctx context.Context, userID, id int) (*Event, error) {
+	event := new(Event)
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		err := scanEvent(tx.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE id = ? AND "+trashScope+" FOR UPDATE", id, userID, userID), event)
+		if err != nil {
+			return err
+		}
+
+		event.DeletedAt, event.UpdatedAt = nil, eventTimestamp()
+		_, err = tx.ExecContext(ctx, "UPDATE events SET deleted_at = NULL, updated_at = ? WHERE id = ?", event.UpdatedAt, event.ID)
+		return err
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return event, nil
+}
+
+func (s *sqlEvents) PurgeTrash(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM events WHERE "+s.db.Dialect.FirstRows("events", "deleted_at < ?"), before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ownerScope is the condition selecting the events a user can see: those of their organization, or
+// their own when they belong to none. Both placeholders take the user's id.
+const ownerScope = "(org_id <=> (SELECT org_id FROM users WHERE id = ?) AND (org_id IS NOT NULL OR user_id = ?))"
+
+// eventScope is ownerScope without the events in the trash; trashScope selects only those. Both
+// placeholders take the user's id.
+const (
+	eventScope = ownerScope + " AND deleted_at IS NULL"
+	trashScope = ownerScope + " AND deleted_at IS NOT NULL"
+)
+
+// eventFilterWhere returns the WHERE condition selecting a user's events that match filter, for a
+// database of dialect, together with the arguments for its placeholders.
+func eventFilterWhere(dialect database.Dialect, userID int, filter EventFilter) (string, []interface{}) {
+	where, args := eventScope, []interface{}{userID, userID}
+	if filter.CompletedOnly {
+		where += " AND completed_at IS NOT NULL"
+	} else if !filter.IncludeCompleted {
+		where += " AND completed_at IS NULL"
+	}
+	if !filter.AsOf.IsZero() {
+		where += " AND created_at <= ?"
+		args = append(args, filter.AsOf.UTC())
+	}
+	if !filter.From.IsZero() {
+		where += " AND date >= ?"
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where += " AND date <= ?"
+		args = append(args, filter.To.UTC())
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		where += " AND (" + dialect.Like("name") + " OR " + dialect.Like("message") + ")"
+		args = append(args, pattern, pattern)
+	}
+	if filter.Tag != "" {
+		where += " AND id IN (SELECT event_id FROM event_tags WHERE tag = ?)"
+		args = append(args, filter.Tag)
+	}
+	if len(filter.Priorities) > 0 {
+		where += " AND priority IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(filter.Priorities)), ", ") + ")"
+		for _, priority := range filter.Priorities {
+			args = append(args, priority)
+		}
+	}
+	return where, args
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns and the backslash, LIKE's default escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike returns s as a LIKE pattern matching s literally.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func (s *sqlEvents) List(ctx context.Context, userID int, filter EventFilter, page Page) ([]Event, bool, error) {
+	where, args := eventFilterWhere(s.db.Dialect, userID, filter)
+
+	// Fetch one row more than requested to learn whether another page follows
+	var rows *database.Rows
+	var err error
+	if page.Keyset {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+eventColumns+" FROM events WHERE "+where+" AND id > ? ORDER BY id LIMIT ?",
+			append(args, page.AfterID, page.Limit+1)...)
+	} else {
+		order := priorityOrder + ", date, id"
+		if filter.CompletedOnly {
+			order = "completed_at DESC, id DESC"
+		} else if filter.ByDate {
+			order = "date, id"
+		}
+		rows, err = s.db.QueryContext(ctx, "SELECT "+eventColumns+" FROM events WHERE "+where+" ORDER BY "+order+" LIMIT ? OFFSET ?",
+			append(args, page.Limit+1, page.Offset)...)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	events, err := collectEvents(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(events) > page.Limit {
+		return events[:page.Limit], true, nil
+	}
+	return events, false, nil
+}
+
+func (s *sqlEvents) Count(ctx context.Context, userID int, filter EventFilter) (int, error) {
+	var count int
+	where, args := eventFilterWhere(s.db.Dialect, userID, filter)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM events WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+// adminEventWhere returns the WHERE clause of events joined with their owners in users selecting
+// those outside the trash matching filter, and its arguments.
+func adminEventWhere(filter AdminEventFilter) (string, []interface{}) {
+	where, args := "events.deleted_at IS NULL", []interface{}{}
+	if filter.Owner != "" {
+		where += " AND users.username = ?"
+		args = append(args, filter.Owner)
+	}
+	if !filter.From.IsZero() {
+		where += " AND events.date >= ?"
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where += " AND events.date <= ?"
+		args = append(args, filter.To.UTC())
+	}
+	switch filter.Recurrence {
+	case "":
+	case RecurrenceNone:
+		where += " AND events.recurrence = ''"
+	case RecurrenceAny:
+		where += " AND events.recurrence <> ''"
+	default:
+		// Rules are stored in canonical form, starting with their frequency
+		where += " AND (events.recurrence = ? OR events.recurrence LIKE ?)"
+		args = append(args, "FREQ="+filter.Recurrence, "FREQ="+filter.Recurrence+";%")
+	}
+	return where, args
+}
+
+func (s *sqlEvents) ListAll(ctx context.Context, filter AdminEventFilter, page Page) ([]OwnedEvent, bool, error) {
+	where, args := adminEventWhere(filter)
+
+	// Fetch one row more than requested to learn whether another page follows
+	var rows *database.Rows
+	var err error
+	if page.Keyset {
+		rows, err = s.db.QueryContext(ctx, "SELECT users.username, "+qualifiedEventColumns+" FROM events JOIN users ON users.id = events.user_id"+
+			" WHERE "+where+" AND events.id > ? ORDER BY events.id LIMIT ?", append(args, page.AfterID, page.Limit+1)...)
+	} else {
+		rows, err = s.db.QueryContext(ctx, "SELECT users.username, "+qualifiedEventColumns+" FROM events JOIN users ON users.id = events.user_id"+
+			" WHERE "+where+" ORDER BY events.id LIMIT ? OFFSET ?", append(args, page.Limit+1, page.Offset)...)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	events := []OwnedEvent{}
+	for rows.Next() {
+		var owned OwnedEvent
+		if err := scanEvent(rows, &owned.Event, &owned.Owner); err != nil {
+			return nil, false, err
+		}
+		events = append(events, owned)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(events) > page.Limit {
+		return events[:page.Limit], true, nil
+	}
+	return events, false, nil
+}
+
+func (s *sqlEvents) CountAll(ctx context.Context, filter AdminEventFilter) (int, error) {
+	var count int
+	where, args := adminEventWhere(filter)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM events JOIN users ON users.id = events.user_id WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+func (s *sqlEvents) GetMany(ctx context.Context, userID int, names []string) ([]Event, error) {
+	if len(names) == 0 {
+		return []Event{}, nil
+	}
+
+	args := make([]interface{}, 0, len(names)+2)
+	args = append(args, userID, userID)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	rows, err := s.db.QueryContext(ctx, "SELECT "+eventColumns+" FROM events WHERE "+eventScope+" AND name IN ("+placeholders+") ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectEvents(rows)
+}
+
+func (s *sqlEvents) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM events WHERE "+s.db.Dialect.FirstRows("events",
+		"date < ? AND (fired_at IS NOT NULL OR completed_at IS NOT NULL) AND snoozed_until IS NULL"+
+			" AND user_id NOT IN (SELECT id FROM users WHERE keep_events)"),
+		before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *sqlEvents) ListByDatePrefix(ctx context.Context, userID int, prefix string) ([]Event, error) {
+	// The prefix applies to the date as the API shows it, in RFC3339
+	rows, err := s.db.QueryContext(ctx, "SELECT "+eventColumns+" FROM events WHERE "+eventScope+" AND "+s.db.Dialect.FormatRFC3339("date")+" LIKE ? ORDER BY date",
+		userID, userID, prefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	return collectEvents(rows)
+}
+
+func (s *sqlEvents) Reschedule(ctx context.Context, userID int, dateFor func(*Event) (string, bool)) ([]Event, error) {
+	rescheduled := []Event{}
+
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		// Lock the user's events until the new dates commit
+		rows, err := tx.QueryContext(ctx, "SELECT "+eventColumns+" FROM events WHERE "+eventScope+" ORDER BY id FOR UPDATE", userID, userID)
+		if err != nil {
+			return err
+		}
+		events, err := collectEvents(rows)
+		if err != nil {
+			return err
+		}
+
+		for i := range events {
+			date, ok := dateFor(&events[i])
+			if !ok {
+				continue
+			}
+
+			// A rescheduled event fires again at its new date
+			if date != events[i].Date {
+				column, err := parseDate(date)
+				if err != nil {
+					return err
+				}
+				// A recurring event's series starts over at its new date
+				events[i].SeriesStart, events[i].UpdatedAt = date, eventTimestamp()
+				if _, err := tx.ExecContext(ctx, "UPDATE events SET date = ?, series_start = ?, fired_at = NULL, updated_at = ? WHERE id = ?", column, column, events[i].UpdatedAt, events[i].ID); err != nil {
+					return err
+				}
+				if err := armReminders(ctx, tx, events[i].ID, column); err != nil {
+					return err
+				}
+			}
+			events[i].Date = date
+			rescheduled = append(rescheduled, events[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rescheduled, nil
+}
+
+func (s *sqlEvents) Each(ctx context.Context, userID int, fn func(*Event) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+eventColumns+" FROM events WHERE "+eventScope+" ORDER BY id", userID, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event Event
+		if err := scanEvent(rows, &event); err != nil {
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqlEvents) ClaimDue(ctx context.Context, owner string, after, now time.Time, lease time.Duration, limit int) ([]DueEvent, error) {
+	now = now.UTC()
+	lower, args := lowerBound("date", after)
+
+	// A single UPDATE takes the claim atomically, so concurrent schedulers never claim the same row
+	_, err := s.db.ExecContext(ctx, "UPDATE events SET locked_by = ?, locked_at = ? WHERE "+s.db.Dialect.FirstRows("events",
+		"completed_at IS NULL AND deleted_at IS NULL AND (locked_by IS NULL OR locked_at < ?)"+
+			" AND ((fired_at IS NULL AND snoozed_until IS NULL"+lower+" AND date <= ? AND (dismissed_at IS NULL OR dismissed_at < date))"+
+			" OR snoozed_until <= ?)"),
+		append(append([]interface{}{owner, now, now.Add(-lease)}, args...), now, now, limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+dueColumns+", "+qualifiedEventColumns+" FROM events JOIN users ON users.id = events.user_id"+
+		" WHERE events.locked_by = ? AND (events.fired_at IS NULL OR events.snoozed_until IS NOT NULL) ORDER BY events.id", owner)
+	if err != nil {
+		return nil, err
+	}
+	due, err := collectDueEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := addParticipants(ctx, s.db, due); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+func (s *sqlEvents) MarkFired(ctx context.Context, owner string, ids []int, now time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders, args := idPlaceholders(ids)
+	args = append([]interface{}{now.UTC(), now.UTC(), now.UTC(), owner}, args...)
+	_, err := s.db.ExecContext(ctx, "UPDATE events SET fired_at = CASE WHEN date <= ? THEN ? ELSE fired_at END,"+
+		" snoozed_until = CASE WHEN snoozed_until <= ? THEN NULL ELSE snoozed_until END, locked_by = NULL, locked_at = NULL"+
+		" WHERE locked_by = ? AND id IN ("+placeholders+")", args...)
+	return err
+}
+
+func (s *sqlEvents) RenewClaims(ctx context.Context, owner string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE events SET locked_at = ? WHERE locked_by = ?", now.UTC(), owner)
+	return err
+}
+
+// lowerBound returns the condition, starting with AND, and its arguments keeping only rows whose
+// column lies after after, or nothing when after is the zero time.
+func lowerBound(column string, after time.Time) (string, []interface{}) {
+	if after.IsZero() {
+		return "", nil
+	}
+	return " AND " + column + " > ?", []interface{}{after.UTC()}
+}
+
+// idPlaceholders returns the placeholders and arguments for an IN clause over ids.
+func idPlaceholders(ids []int) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+func (s *sqlEvents) FiredAt(ctx context.Context, userID, eventID int) (*time.Time, error) {
+	var firedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT fired_at FROM events WHERE id = ? AND "+eventScope, eventID, userID, userID).Scan(&firedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if !firedAt.Valid {
+		return nil, nil
+	}
+	return &firedAt.Time, nil
+}
+
+func (s *sqlEvents) DueByIDs(ctx context.Context, ids []int) ([]DueEvent, error) {
+	if len(ids) == 0 {
+		return []DueEvent{}, nil
+	}
+
+	placeholders, args := idPlaceholders(ids)
+	rows, err := s.db.QueryContext(ctx, "SELECT "+dueColumns+", "+qualifiedEventColumns+" FROM events JOIN users ON users.id = events.user_id"+
+		" WHERE events.id IN ("+placeholders+") AND events.deleted_at IS NULL ORDER BY events.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	due, err := collectDueEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := addParticipants(ctx, s.db, due); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// collectDueEvents scans all rows into a slice and closes them.
+func collectDueEvents(rows *database.Rows) ([]DueEvent, error) {
+	defer rows.Close()
+
+	due := []DueEvent{}
+	for rows.Next() {
+		var d DueEvent
+		if err := scanDueEvent(rows, &d); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// collectEvents scans all rows into a slice and closes them.
+func collectEvents(rows *database.Rows) ([]Event, error) {
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/database"
+	"time"
+)
+
+// Stats struct summarizes the state of the whole system for admins.
+type Stats struct {
+	Users         int `json:"users"`
+	Admins        int `json:"admins"`
+	DisabledUsers int `json:"disabled_users"`
+	// Events counts the events of all users, including TrashedEvents, those in the trash
+	Events        int `json:"events"`
+	TrashedEvents int `json:"trashed_events"`
+	// PendingDeliveries counts the deliveries waiting for a retry
+	PendingDeliveries int `json:"pending_deliveries"`
+	// RecentDeliveries counts the deliveries started since a given time by status
+	RecentDeliveries map[string]int `json:"recent_deliveries"`
+}
+
+// StatsStore computes statistics over the data of all users.
+type StatsStore interface {
+	// Stats returns the current statistics, with the deliveries started at or after since.
+	Stats(ctx context.Context, since time.Time) (*Stats, error)
+}
+
+// sqlStats implements StatsStore with aggregate queries over the SQL tables.
+type sqlStats struct {
+	db *database.DB
+}
+
+func (s *sqlStats) Stats(ctx context.Context, since time.Time) (*Stats, error) {
+	stats := &Stats{RecentDeliveries: map[string]int{DeliveryPending: 0, DeliverySent: 0, DeliveryFailed: 0}}
+
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(CASE WHEN role = ? THEN 1 ELSE 0 END), 0),"+
+		" COALESCE(SUM(CASE WHEN disabled_at IS NOT NULL THEN 1 ELSE 0 END), 0) FROM users", RoleAdmin).
+		Scan(&stats.Users, &stats.Admins, &stats.DisabledUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(CASE WHEN deleted_at IS NOT NULL THEN 1 ELSE 0 END), 0) FROM events").
+		Scan(&stats.Events, &stats.TrashedEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM deliveries WHERE status = ?", DeliveryPending).Scan(&stats.PendingDeliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM deliveries WHERE created_at >= ? GROUP BY status", since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		stats.RecentDeliveries[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// memoryStats implements StatsStore over the in-memory stores.
+type memoryStats struct {
+	*memory
+	deliveries *memoryDeliveries
+}
+
+func (s *memoryStats) Stats(ctx context.Context, since time.Time) (*Stats, error) {
+	stats := &Stats{RecentDeliveries: map[string]int{DeliveryPending: 0, DeliverySent: 0, DeliveryFailed: 0}}
+
+	s.mu.Lock()
+	for _, user := range s.users {
+		stats.Users++
+		if user.Role == RoleAdmin {
+			stats.Admins++
+		}
+		if user.DisabledAt != nil {
+			stats.DisabledUsers++
+		}
+	}
+	for _, stored := range s.events {
+		stats.Events++
+		if stored.event.DeletedAt != nil {
+			stats.TrashedEvents++
+		}
+	}
+	s.mu.Unlock()
+
+	s.deliveries.mu.Lock()
+	defer s.deliveries.mu.Unlock()
+	for _, delivery := range s.deliveries.deliveries {
+		if delivery.Status == DeliveryPending {
+			stats.PendingDeliveries++
+		}
+		if !delivery.CreatedAt.Before(since) {
+			stats.RecentDeliveries[delivery.Status]++
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,397 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/Vansh3140/Reminder-App/database"
+	"time"
+)
+
+// ErrNotFound is returned when the requested user or event does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicate is returned when a write would violate a uniqueness constraint,
+// such as an existing username or an event name already used by the same user.
+var ErrDuplicate = errors.New("record already exists")
+
+// ErrUnavailable is returned, wrapped, when the database cannot be reached, even after a retry.
+// Callers can tell it apart from other failures without depending on the database package.
+var ErrUnavailable = database.ErrUnavailable
+
+// Event struct defines the structure of an event.
+// The form tags let the same struct be parsed from x-www-form-urlencoded bodies; the validate
+// tags hold the rules checked by the handlers, whose custom rules are registered there.
+type Event struct {
+	// ID is assigned by the store and never changes; values in request bodies are ignored
+	ID   int    `json:"id" form:"-"`
+	Name string `json:"name" form:"name" validate:"required,eventname"`
+	// Date is RFC3339 in UTC once the handlers have normalized it; the SQL store keeps it in a
+	// date column
+	Date     string `json:"date" form:"date" validate:"required,eventdate"`
+	Message  string `json:"message" form:"message" validate:"required,max=65535"`
+	Priority string `json:"priority" form:"priority" validate:"omitempty,oneof=low normal high urgent"`
+	URL      string `json:"url" form:"url" validate:"omitempty,max=2048,http_url"`
+	// Channels selects the notification channels the event is delivered through; empty means all of them
+	Channels []string `json:"channels" form:"channels" validate:"unique,dive,channel"`
+	// Recipients are email addresses notified of the event in addition to its owner, in alphabetical order
+	Recipients []string `json:"recipients" form:"recipients" validate:"max=10,unique,dive,address"`
+	// Tags organize events, such as work or bills, in alphabetical order. The handlers lowercase
+	// them, so tags differing only in case are the same tag.
+	Tags []string `json:"tags" form:"tags" validate:"max=20,unique,dive,tag"`
+	// EmailNotifications turns delivery through the email channel on or off; it is always set on
+	// stored events, and nil in an update keeps the current setting
+	EmailNotifications *bool `json:"email_notifications" form:"email_notifications"`
+	// SMSNotifications opts the event in to delivery through the SMS channel, which is off by
+	// default; it is always set on stored events, and nil in an update keeps the current setting
+	SMSNotifications *bool `json:"sms_notifications" form:"sms_notifications"`
+	// Recurrence is an RFC 5545 recurrence rule, such as FREQ=WEEKLY;BYDAY=MO, after which the event
+	// repeats; empty for a one-off event. It is always set on stored events, and nil in an update
+	// keeps the current rule.
+	Recurrence *string `json:"recurrence" form:"recurrence" validate:"omitempty,max=255,recurrence"`
+	// SeriesStart is the date of the first occurrence of a recurring event, which the rule is
+	// expanded from while Date moves on to the next occurrence. The store sets it to Date when empty.
+	SeriesStart string `json:"-" form:"-"`
+	// CompletedAt is set once the event has been marked as done; it is never read from request bodies
+	CompletedAt *time.Time `json:"completed_at" form:"-"`
+	// SnoozedUntil is when a snoozed reminder fires again; while it is set the event does not fire at
+	// its date. DismissedAt is when the user last dismissed the reminder, which keeps it from firing
+	// for dates before then. Neither is ever read from request bodies.
+	SnoozedUntil *time.Time `json:"snoozed_until" form:"-"`
+	DismissedAt  *time.Time `json:"dismissed_at" form:"-"`
+	// DeletedAt is when the event was moved to the trash, from which it can be restored until it is
+	// purged; it is nil for every other event and never read from request bodies
+	DeletedAt *time.Time `json:"deleted_at" form:"-"`
+	// CreatedAt and UpdatedAt are maintained by the store; values in request bodies are ignored
+	CreatedAt time.Time `json:"created_at" form:"-"`
+	UpdatedAt time.Time `json:"updated_at" form:"-"`
+}
+
+// Channel of the email notifier, which events may turn off with EmailNotifications
+const EmailChannel = "email"
+
+// Channel of the webhook notifier, which calls the webhooks of the event's owner
+const WebhookChannel = "webhook"
+
+// Channel of the push notifier, which notifies the devices of the event's owner
+const PushChannel = "push"
+
+// Channel of the Telegram notifier, which messages the Telegram chat of the event's owner
+const TelegramChannel = "telegram"
+
+// Channel of the Slack notifier, which posts to the Slack workspace of the event's owner
+const SlackChannel = "slack"
+
+// Channel of the SMS notifier, which texts the verified phone of the event's owner; events opt in
+// with SMSNotifications
+const SMSChannel = "sms"
+
+// Most urgent event priority, whose reminders the scheduler repeats until they are dismissed
+const PriorityUrgent = "urgent"
+
+// emailEnabled reports whether email notifications are on for event; they are unless turned off.
+func emailEnabled(event *Event) bool {
+	return event.EmailNotifications == nil || *event.EmailNotifications
+}
+
+// recurrenceRule returns the recurrence rule of event, empty when it does not recur.
+func recurrenceRule(event *Event) string {
+	if event.Recurrence == nil {
+		return ""
+	}
+	return *event.Recurrence
+}
+
+// smsEnabled reports whether event opted in to SMS notifications; they are off unless turned on.
+func smsEnabled(event *Event) bool {
+	return event.SMSNotifications != nil && *event.SMSNotifications
+}
+
+// setEventDefaults fills in the fields an event being stored may leave unset: email notifications
+// are on, SMS notifications off, the event does not recur, and its series starts at its date.
+func setEventDefaults(event *Event) {
+	enabled, sms, rule := emailEnabled(event), smsEnabled(event), recurrenceRule(event)
+	event.EmailNotifications, event.SMSNotifications, event.Recurrence = &enabled, &sms, &rule
+	if event.SeriesStart == "" {
+		event.SeriesStart = event.Date
+	}
+}
+
+// eventTimestamp returns the current time for CreatedAt and UpdatedAt, in UTC and truncated to the
+// microsecond precision of the created_at and updated_at columns.
+func eventTimestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Recipient holds the contact details of the user owning a due event.
+type Recipient struct {
+	Username string
+	Email    string
+	Timezone string
+	// DisabledChannels are the notification channels the user turned off
+	DisabledChannels []string
+	// Disabled is set when an admin has disabled the account of the user
+	Disabled bool
+}
+
+// Receives reports whether the recipient accepts reminders through channel, which they do unless
+// they turned it off or their account is disabled.
+func (r Recipient) Receives(channel string) bool {
+	if r.Disabled {
+		return false
+	}
+	for _, disabled := range r.DisabledChannels {
+		if disabled == channel {
+			return false
+		}
+	}
+	return true
+}
+
+// DueEvent is an event together with the user owning it, as returned to the scheduler.
+type DueEvent struct {
+	UserID    int
+	Recipient Recipient
+	Event     Event
+	// Participants are the users the event is shared with, in username order
+	Participants []Participant
+	// DeliveryID is the delivery a notifier is attempting; it stays the same across retries, so
+	// notifiers can avoid notifying a destination twice
+	DeliveryID int
+	// ReminderID is the reminder the event fired for ahead of its date, or 0 when it fired at its date
+	ReminderID int
+}
+
+// User struct defines a stored user account, including the password hash.
+type User struct {
+	ID           int
+	Username     string
+	PasswordHash string
+	Email        string
+	Timezone     string
+	// KeepEvents opts the user out of the purge of old events
+	KeepEvents bool
+	// OrgID is the organization the user belongs to, or 0
+	OrgID int
+	// DisabledChannels are the notification channels the user turned off; reminders reach them
+	// through every other channel
+	DisabledChannels []string
+	// TokenVersion is signed into the access tokens of the user; raising it invalidates those issued before
+	TokenVersion int
+	// Role is RoleUser, or RoleAdmin for users who may use the admin API
+	Role string
+	// DisabledAt is set while an admin has disabled the account
+	DisabledAt *time.Time
+}
+
+// Roles of users
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// recipient returns the contact details of user as the recipient of a due event.
+func (user *User) recipient() Recipient {
+	return Recipient{Username: user.Username, Email: user.Email, Timezone: user.Timezone, DisabledChannels: user.DisabledChannels,
+		Disabled: user.DisabledAt != nil}
+}
+
+// Page describes which slice of a list to return. When Keyset is set, rows with an id
+// greater than AfterID are returned in id order; otherwise Offset rows are skipped.
+type Page struct {
+	Limit   int
+	Offset  int
+	AfterID int
+	Keyset  bool
+}
+
+// EventFilter narrows the events returned by a list.
+type EventFilter struct {
+	// IncludeCompleted also returns events that have been marked as completed
+	IncludeCompleted bool
+	// CompletedOnly returns only the events marked as completed, overriding IncludeCompleted, and
+	// sorts offset pages by completion time, most recent first
+	CompletedOnly bool
+	// AsOf, when set, leaves out events created after it, so that pages fetched while events are
+	// being added stay consistent with the first one
+	AsOf time.Time
+	// From and To, when set, leave out events dated before From or after To
+	From time.Time
+	To   time.Time
+	// Search, when set, leaves out events whose name and message both do not contain it, ignoring case
+	Search string
+	// Tag, when set, leaves out events without that tag
+	Tag string
+	// Priorities, when set, leaves out events whose priority is not among them
+	Priorities []string
+	// ByDate sorts offset pages by date alone instead of by priority first; keyset pages are
+	// always sorted by id
+	ByDate bool
+}
+
+// Values of AdminEventFilter.Recurrence besides the frequencies of recurrence rules
+const (
+	RecurrenceNone = "none"
+	RecurrenceAny  = "any"
+)
+
+// AdminEventFilter narrows the events of all users listed by admins.
+type AdminEventFilter struct {
+	// Owner, when set, leaves out the events of users with another username
+	Owner string
+	// From and To, when set, leave out events dated before From or after To
+	From time.Time
+	To   time.Time
+	// Recurrence, when set, leaves out events recurring otherwise: RecurrenceNone keeps one-off
+	// events, RecurrenceAny recurring events, and a frequency such as WEEKLY events recurring at it
+	Recurrence string
+}
+
+// OwnedEvent struct is an event together with the username of its owner, as admins list them.
+type OwnedEvent struct {
+	Event
+	Owner string `json:"owner"`
+}
+
+// UserStore persists user accounts.
+type UserStore interface {
+	// Create stores a new user and returns its id, or ErrDuplicate if the username is taken.
+	Create(ctx context.Context, username, passwordHash string) (int, error)
+	// ByID returns the user with the given id, or ErrNotFound.
+	ByID(ctx context.Context, id int) (*User, error)
+	// ByUsername returns the user with the given username, or ErrNotFound.
+	ByUsername(ctx context.Context, username string) (*User, error)
+	// UpdateSettings changes the email, timezone, and purge opt-out of a user; empty values and a
+	// nil keepEvents are left unchanged.
+	UpdateSettings(ctx context.Context, id int, email, timezone string, keepEvents *bool) error
+	// UpdateChannels replaces the notification channels a user turned off.
+	UpdateChannels(ctx context.Context, id int, disabled []string) error
+	// UpdateUsername renames a user, or returns ErrDuplicate if the username is taken.
+	UpdateUsername(ctx context.Context, id int, username string) error
+	// UpdatePassword replaces the password hash of a user.
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	// RevokeTokens raises the token version of a user, invalidating every access token issued to
+	// them so far.
+	RevokeTokens(ctx context.Context, id int) error
+	// List returns one page of all users, in id order, optionally only those whose username contains
+	// search, ignoring case, and whether more follow.
+	List(ctx context.Context, search string, page Page) ([]User, bool, error)
+	// Count returns how many users there are, optionally only those whose username contains search.
+	Count(ctx context.Context, search string) (int, error)
+	// SetRole changes the role of a user, or returns ErrNotFound.
+	SetRole(ctx context.Context, id int, role string) error
+	// SetDisabled disables a user as of disabledAt, or enables them again when it is nil. It returns
+	// ErrNotFound if there is no such user.
+	SetDisabled(ctx context.Context, id int, disabledAt *time.Time) error
+	// Delete removes a user together with everything they own: their events, including those
+	// shared with their organization, API keys, tokens, templates, webhooks, devices, calendar
+	// feed, delivery history, and audit log. It returns ErrNotFound if there is no such user.
+	Delete(ctx context.Context, id int) error
+}
+
+// EventStore persists events. Every method is scoped to the owning user. Deleted events go to the
+// trash, where only Trash, Restore, and the purges see them; a new event taking the name of one in
+// the trash deletes it for good.
+type EventStore interface {
+	// Create stores a new event and sets its ID and timestamps, or returns ErrDuplicate if the name is taken.
+	Create(ctx context.Context, userID int, event *Event) error
+	// CreateMany stores several new events in one transaction, setting the ID and timestamps of each
+	// event stored. The returned errors line up with events: ErrDuplicate for an event whose name
+	// is taken, by an existing event or one earlier in events, and nil for one stored. Any other
+	// failure stores none of the events.
+	CreateMany(ctx context.Context, userID int, events []*Event) ([]error, error)
+	// Duplicate stores event as a new event of the user like Create, together with a reminder at
+	// the offset of each reminder of the event with id sourceID, in one transaction.
+	Duplicate(ctx context.Context, userID, sourceID int, event *Event) error
+	// Get returns the named event, or ErrNotFound.
+	Get(ctx context.Context, userID int, name string) (*Event, error)
+	// NameByID returns the current name of one of the user's events, or ErrNotFound.
+	NameByID(ctx context.Context, userID, id int) (string, error)
+	// Erase deletes the event with the given id for good, whoever owns it and whether or not it is
+	// in the trash, and returns its owner, or ErrNotFound. It is meant for admins removing abusive
+	// content.
+	Erase(ctx context.Context, id int) (int, error)
+	// ListAll returns one page of the events of all users outside the trash matching filter, in id
+	// order and each with the username of its owner, and whether more follow. It is meant for admins.
+	ListAll(ctx context.Context, filter AdminEventFilter, page Page) ([]OwnedEvent, bool, error)
+	// CountAll returns how many events of all users outside the trash match filter.
+	CountAll(ctx context.Context, filter AdminEventFilter) (int, error)
+	// Update loads the named event, lets apply modify it, and saves the result atomically with a new UpdatedAt.
+	// It returns ErrNotFound if the event does not exist and stops with apply's error if it fails.
+	Update(ctx context.Context, userID int, name string, apply func(*Event) error) (*Event, error)
+	// Delete moves the named event to the trash, or returns ErrNotFound.
+	Delete(ctx context.Context, userID int, name string) error
+	// DeleteIf moves the named event to the trash if check accepts its current state; it returns
+	// ErrNotFound if the event does not exist and check's error, leaving the event in place, if it fails.
+	DeleteIf(ctx context.Context, userID int, name string, check func(*Event) error) error
+	// DeleteMany moves the named events to the trash atomically, returning how many were deleted
+	// and which names did not match an event.
+	DeleteMany(ctx context.Context, userID int, names []string) (int64, []string, error)
+	// Trash returns the events in the trash a user can see, most recently deleted first.
+	Trash(ctx context.Context, userID int) ([]Event, error)
+	// Restore takes an event of the user out of the trash by id and returns it, or ErrNotFound if
+	// the trash holds no such event.
+	Restore(ctx context.Context, userID, id int) (*Event, error)
+	// PurgeTrash permanently deletes up to limit events moved to the trash before the given time,
+	// and returns how many were deleted.
+	PurgeTrash(ctx context.Context, before time.Time, limit int) (int64, error)
+	// List returns one page of the events matching filter and whether more follow. Offset pages
+	// are ordered by priority, date, and id unless filter says otherwise; keyset pages by id.
+	List(ctx context.Context, userID int, filter EventFilter, page Page) ([]Event, bool, error)
+	// Count returns how many events match filter.
+	Count(ctx context.Context, userID int, filter EventFilter) (int, error)
+	// GetMany returns the events with the given names in id order; names without an event are skipped.
+	GetMany(ctx context.Context, userID int, names []string) ([]Event, error)
+	// Purge deletes up to limit events dated before the given time that have fired or were completed,
+	// except those of users who opted out, and returns how many were deleted.
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+	// ListByDatePrefix returns the events whose stored date starts with prefix, ordered by date.
+	ListByDatePrefix(ctx context.Context, userID int, prefix string) ([]Event, error)
+	// Reschedule sets new dates on several events of a user in one transaction. dateFor is called
+	// with each of the user's events in id order and returns the event's new date, or false to
+	// leave it unchanged. It returns the rescheduled events with their new dates.
+	Reschedule(ctx context.Context, userID int, dateFor func(*Event) (string, bool)) ([]Event, error)
+	// Each calls fn for every event in id order without loading them all at once.
+	Each(ctx context.Context, userID int, fn func(*Event) error) error
+	// ClaimDue atomically claims up to limit events of all users for owner and returns them in id
+	// order, each with its recipient. Only uncompleted events outside the trash are claimed: those that have not fired
+	// yet, are not snoozed, and whose date lies after after, unless it is the zero time, and at or
+	// before now and was not dismissed, as well as those whose snooze ended at or before now. Events another owner holds a
+	// claim on younger than lease at now are skipped. Claimed events must be released with MarkFired.
+	ClaimDue(ctx context.Context, owner string, after, now time.Time, lease time.Duration, limit int) ([]DueEvent, error)
+	// MarkFired records that the events with the given ids, claimed by owner, fired at now and
+	// releases the claim. Events dated after now only fired because their snooze ended, so they still
+	// fire at their date. Ended snoozes are cleared. Changing the date of an event makes it fire again.
+	MarkFired(ctx context.Context, owner string, ids []int, now time.Time) error
+	// RenewClaims restarts the lease of every claim owner holds on events at now, for an owner still
+	// busy firing the events it claimed.
+	RenewClaims(ctx context.Context, owner string, now time.Time) error
+	// FiredAt returns when the scheduler last fired an event, or nil if it has not fired at its
+	// current date. It returns ErrNotFound if the user has no event with that id.
+	FiredAt(ctx context.Context, userID, eventID int) (*time.Time, error)
+	// DueByIDs returns the events with the given ids together with their recipients, in id order.
+	// Ids that do not match an event, or match one in the trash, are left out.
+	DueByIDs(ctx context.Context, ids []int) ([]DueEvent, error)
+}
+
+// Store bundles the repositories the handlers depend on.
+type Store struct {
+	Users          UserStore
+	Events         EventStore
+	APIKeys        APIKeyStore
+	Audit          AuditStore
+	Deliveries     DeliveryStore
+	Orgs           OrgStore
+	Templates      TemplateStore
+	RefreshTokens  RefreshTokenStore
+	Webhooks       WebhookStore
+	Devices        DeviceStore
+	Reminders      ReminderStore
+	CalendarFeeds  CalendarFeedStore
+	PasswordResets PasswordResetStore
+	Tags           TagStore
+	Shares         ShareStore
+	Telegram       TelegramStore
+	Slack          SlackStore
+	Phones         PhoneStore
+	Stats          StatsStore
+}
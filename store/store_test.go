@@ -0,0 +1,671 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Vansh3140/Reminder-App/database"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testBackend opens an empty store of one implementation for a test.
+type testBackend struct {
+	name string
+	open func(t testing.TB) *Store
+}
+
+// testBackends are the stores every test runs against: the in-memory store, and the SQL store on
+// a SQLite file. The integration build tag adds a database server.
+var testBackends = []testBackend{
+	{name: "memory", open: func(t testing.TB) *Store { return NewMemory() }},
+	{name: "sqlite", open: openSQLiteStore},
+}
+
+// openSQLiteStore returns a SQL store on a new SQLite database in a temporary file.
+func openSQLiteStore(t testing.TB) *Store {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_CREDS", filepath.Join(t.TempDir(), "reminders.db"))
+	return connectStore(t)
+}
+
+// connectStore returns a SQL store on the database configured in the environment, as the app
+// connects to it.
+func connectStore(t testing.TB) *Store {
+	t.Helper()
+	db, err := database.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewSQL(db)
+}
+
+// eachStore runs test as a subtest against every backend.
+func eachStore(t *testing.T, test func(t *testing.T, s *Store)) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+// createUser stores a user and returns its id.
+func createUser(t testing.TB, s *Store, username string) int {
+	t.Helper()
+	id, err := s.Users.Create(context.Background(), username, "hash")
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return id
+}
+
+// createEvent stores an event of a user dated the given number of days after 2030-01-01.
+func createEvent(t *testing.T, s *Store, userID int, name string, day int) *Event {
+	t.Helper()
+	event := &Event{
+		Name:    name,
+		Message: "Message of " + name,
+		Date:    time.Date(2030, 1, 1+day, 9, 0, 0, 0, time.UTC).Format(time.RFC3339),
+	}
+	if err := s.Events.Create(context.Background(), userID, event); err != nil {
+		t.Fatalf("create event %s: %v", name, err)
+	}
+	return event
+}
+
+func TestUsers(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		id := createUser(t, s, "alice")
+
+		if _, err := s.Users.Create(ctx, "alice", "hash"); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("creating a taken username: got %v, want ErrDuplicate", err)
+		}
+
+		user, err := s.Users.ByUsername(ctx, "alice")
+		if err != nil || user.ID != id || user.Role != RoleUser {
+			t.Fatalf("ByUsername: got %+v, %v", user, err)
+		}
+		if _, err := s.Users.ByID(ctx, id+1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ByID of an unknown user: got %v, want ErrNotFound", err)
+		}
+
+		if err := s.Users.Delete(ctx, id); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Users.ByUsername(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ByUsername after Delete: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestEventLifecycle(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		created := createEvent(t, s, alice, "standup", 0)
+		if created.ID == 0 || created.CreatedAt.IsZero() {
+			t.Fatalf("Create did not set the id and timestamps: %+v", created)
+		}
+		if err := s.Events.Create(ctx, alice, &Event{Name: "standup", Message: "again", Date: created.Date}); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("creating a taken name: got %v, want ErrDuplicate", err)
+		}
+		// Names are unique per user
+		createEvent(t, s, bob, "standup", 1)
+
+		event, err := s.Events.Get(ctx, alice, "standup")
+		if err != nil || event.ID != created.ID || event.Message != "Message of standup" || event.Date != created.Date {
+			t.Fatalf("Get: got %+v, %v", event, err)
+		}
+
+		updated, err := s.Events.Update(ctx, alice, "standup", func(e *Event) error {
+			e.Message = "Moved"
+			return nil
+		})
+		if err != nil || updated.Message != "Moved" {
+			t.Fatalf("Update: got %+v, %v", updated, err)
+		}
+		if _, err := s.Events.Update(ctx, alice, "missing", func(*Event) error { return nil }); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Update of an unknown event: got %v, want ErrNotFound", err)
+		}
+
+		if err := s.Events.Delete(ctx, alice, "standup"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Events.Get(ctx, alice, "standup"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get after Delete: got %v, want ErrNotFound", err)
+		}
+		if other, err := s.Events.Get(ctx, bob, "standup"); err != nil || other.Message != "Message of standup" {
+			t.Fatalf("deleting an event touched the event of another user: got %+v, %v", other, err)
+		}
+
+		trash, err := s.Events.Trash(ctx, alice)
+		if err != nil || len(trash) != 1 || trash[0].ID != created.ID || trash[0].DeletedAt == nil {
+			t.Fatalf("Trash: got %+v, %v", trash, err)
+		}
+		restored, err := s.Events.Restore(ctx, alice, created.ID)
+		if err != nil || restored.Name != "standup" || restored.DeletedAt != nil {
+			t.Fatalf("Restore: got %+v, %v", restored, err)
+		}
+	})
+}
+
+func TestEventListPages(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		var ids []int
+		for i, name := range []string{"one", "two", "three", "four", "five"} {
+			ids = append(ids, createEvent(t, s, alice, name, i).ID)
+		}
+		createEvent(t, s, bob, "other", 0)
+
+		filter := EventFilter{ByDate: true}
+		events, hasMore, err := s.Events.List(ctx, alice, filter, Page{Limit: 2, Offset: 2})
+		if err != nil || !hasMore || len(events) != 2 || events[0].Name != "three" || events[1].Name != "four" {
+			t.Fatalf("offset page: got %+v, %t, %v", events, hasMore, err)
+		}
+
+		events, hasMore, err = s.Events.List(ctx, alice, filter, Page{Limit: 3, AfterID: ids[1], Keyset: true})
+		if err != nil || hasMore || len(events) != 3 || events[0].ID != ids[2] || events[2].ID != ids[4] {
+			t.Fatalf("keyset page: got %+v, %t, %v", events, hasMore, err)
+		}
+
+		filter.From = time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+		filter.To = time.Date(2030, 1, 4, 0, 0, 0, 0, time.UTC)
+		if count, err := s.Events.Count(ctx, alice, filter); err != nil || count != 2 {
+			t.Fatalf("Count in a date range: got %d, %v, want 2", count, err)
+		}
+	})
+}
+
+func TestDeleteMany(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		for i, name := range []string{"one", "two", "three"} {
+			createEvent(t, s, alice, name, i)
+		}
+		createEvent(t, s, bob, "four", 0)
+
+		// Duplicates count once, and the events of other users are not found
+		deleted, notFound, err := s.Events.DeleteMany(ctx, alice, []string{"one", "three", "one", "four", "missing"})
+		if err != nil || deleted != 2 || len(notFound) != 2 || notFound[0] != "four" || notFound[1] != "missing" {
+			t.Fatalf("got %d, %v, %v, want 2 deleted and four and missing not found", deleted, notFound, err)
+		}
+
+		for name, want := range map[string]error{"one": ErrNotFound, "two": nil, "three": ErrNotFound} {
+			if _, err := s.Events.Get(ctx, alice, name); !errors.Is(err, want) {
+				t.Fatalf("Get %s after DeleteMany: got %v, want %v", name, err, want)
+			}
+		}
+		if _, err := s.Events.Get(ctx, bob, "four"); err != nil {
+			t.Fatalf("DeleteMany deleted the event of another user: %v", err)
+		}
+	})
+}
+
+func TestEventListSortedByPriority(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		for i, event := range []struct{ name, priority string }{
+			{"chores", "low"},
+			{"sooner", "high"},
+			{"standup", "normal"},
+			{"deadline", "urgent"},
+			{"later", "high"},
+		} {
+			created := &Event{Name: event.name, Message: "Message of " + event.name, Priority: event.priority,
+				Date: time.Date(2030, 1, 1+i, 9, 0, 0, 0, time.UTC).Format(time.RFC3339)}
+			if err := s.Events.Create(ctx, alice, created); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		events, _, err := s.Events.List(ctx, alice, EventFilter{}, Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, event := range events {
+			names = append(names, event.Name)
+		}
+		if got := fmt.Sprint(names); got != "[deadline sooner later standup chores]" {
+			t.Fatalf("got %s, want the most urgent first and then by date", got)
+		}
+	})
+}
+
+func TestEventListDateRange(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		for day := 1; day <= 4; day++ {
+			createEvent(t, s, alice, fmt.Sprintf("day-%d", day), day)
+		}
+
+		// at returns 9:00 UTC on a day of January 2030, offset by d
+		at := func(day int, d time.Duration) time.Time {
+			return time.Date(2030, 1, day, 9, 0, 0, 0, time.UTC).Add(d)
+		}
+		paris := time.FixedZone("Paris", 3600)
+		for _, test := range []struct {
+			name   string
+			filter EventFilter
+			want   string
+		}{
+			{"inclusive", EventFilter{From: at(3, 0), To: at(4, 0)}, "[day-2 day-3]"},
+			{"other offset", EventFilter{From: at(3, 0).In(paris), To: at(4, 0).In(paris)}, "[day-2 day-3]"},
+			{"from only", EventFilter{From: at(4, -time.Nanosecond)}, "[day-3 day-4]"},
+			{"to only", EventFilter{To: at(3, time.Nanosecond)}, "[day-1 day-2]"},
+			{"fraction after a date", EventFilter{From: at(3, time.Millisecond), To: at(5, 0)}, "[day-3 day-4]"},
+			{"empty", EventFilter{From: at(3, time.Second), To: at(4, -time.Second)}, "[]"},
+		} {
+			test.filter.ByDate = true
+			events, _, err := s.Events.List(ctx, alice, test.filter, Page{Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, event := range events {
+				names = append(names, event.Name)
+			}
+			if got := fmt.Sprint(names); got != test.want {
+				t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+			}
+			if count, err := s.Events.Count(ctx, alice, test.filter); err != nil || count != len(events) {
+				t.Errorf("%s: got count %d, %v, want %d", test.name, count, err, len(events))
+			}
+		}
+	})
+}
+
+func TestReschedule(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		createEvent(t, s, alice, "one", 0)
+		createEvent(t, s, alice, "two", 1)
+		createEvent(t, s, bob, "one", 0)
+
+		// Only the events of the user are visited, and only those given a date change
+		var visited []string
+		rescheduled, err := s.Events.Reschedule(ctx, alice, func(event *Event) (string, bool) {
+			visited = append(visited, event.Name)
+			if event.Name != "one" {
+				return "", false
+			}
+			return "2030-03-01T09:00:00Z", true
+		})
+		if err != nil || len(rescheduled) != 1 || rescheduled[0].Name != "one" || rescheduled[0].Date != "2030-03-01T09:00:00Z" {
+			t.Fatalf("got %+v, %v, want one rescheduled", rescheduled, err)
+		}
+		if len(visited) != 2 {
+			t.Fatalf("visited %v, want the two events of alice", visited)
+		}
+
+		for _, test := range []struct {
+			userID     int
+			name, date string
+		}{
+			{alice, "one", "2030-03-01T09:00:00Z"},
+			{alice, "two", "2030-01-02T09:00:00Z"},
+			{bob, "one", "2030-01-01T09:00:00Z"},
+		} {
+			if event, err := s.Events.Get(ctx, test.userID, test.name); err != nil || event.Date != test.date {
+				t.Fatalf("event %s of user %d: got %+v, %v, want date %s", test.name, test.userID, event, err, test.date)
+			}
+		}
+	})
+}
+
+func TestEventTimestampsAndAsOf(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		first := createEvent(t, s, alice, "first", 0)
+		if first.CreatedAt.IsZero() || !first.UpdatedAt.Equal(first.CreatedAt) {
+			t.Fatalf("created: got created_at %v, updated_at %v", first.CreatedAt, first.UpdatedAt)
+		}
+		stored, err := s.Events.Get(ctx, alice, "first")
+		if err != nil || !stored.CreatedAt.Equal(first.CreatedAt) {
+			t.Fatalf("Get: got %+v, %v, want created_at %v", stored, err, first.CreatedAt)
+		}
+
+		// Events created after AsOf are left out of the listing and its count
+		time.Sleep(time.Millisecond)
+		createEvent(t, s, alice, "second", 1)
+		filter := EventFilter{AsOf: first.CreatedAt}
+		events, _, err := s.Events.List(ctx, alice, filter, Page{Limit: 10})
+		if err != nil || len(events) != 1 || events[0].Name != "first" {
+			t.Fatalf("List as of the first event: got %+v, %v, want the first event only", events, err)
+		}
+		if count, err := s.Events.Count(ctx, alice, filter); err != nil || count != 1 {
+			t.Fatalf("Count as of the first event: got %d, %v, want 1", count, err)
+		}
+		if events, _, err := s.Events.List(ctx, alice, EventFilter{}, Page{Limit: 10}); err != nil || len(events) != 2 {
+			t.Fatalf("List: got %+v, %v, want both events", events, err)
+		}
+	})
+}
+
+func TestGetMany(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		createEvent(t, s, alice, "one", 0)
+		createEvent(t, s, alice, "two", 1)
+		createEvent(t, s, bob, "three", 2)
+
+		events, err := s.Events.GetMany(context.Background(), alice, []string{"two", "three", "missing", "one", "two"})
+		if err != nil || len(events) != 2 || events[0].Name != "one" || events[1].Name != "two" {
+			t.Fatalf("got %+v, %v, want one and two in id order", events, err)
+		}
+	})
+}
+
+func TestPurge(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		keep := true
+		if err := s.Users.UpdateSettings(ctx, bob, "", "UTC", &keep); err != nil {
+			t.Fatal(err)
+		}
+
+		// Events of the first days fire; the one created afterwards never does
+		fired := createEvent(t, s, alice, "fired", 0)
+		completed := createEvent(t, s, alice, "completed", 1)
+		recent := createEvent(t, s, alice, "recent", 20)
+		kept := createEvent(t, s, bob, "kept", 0)
+		now := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+		due, err := s.Events.ClaimDue(ctx, "test", time.Time{}, now, time.Minute, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, len(due))
+		for i, d := range due {
+			ids[i] = d.Event.ID
+		}
+		if err := s.Events.MarkFired(ctx, "test", ids, now); err != nil {
+			t.Fatal(err)
+		}
+		unfired := createEvent(t, s, alice, "unfired", 2)
+		if _, err := s.Events.Update(ctx, alice, "completed", func(event *Event) error {
+			event.CompletedAt = &now
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Retention of ten days from January 11th keeps the recent event and those not done yet
+		purged, err := s.Events.Purge(ctx, time.Date(2030, 1, 11, 0, 0, 0, 0, time.UTC), 100)
+		if err != nil || purged != 2 {
+			t.Fatalf("got %d purged, %v, want 2", purged, err)
+		}
+		for _, test := range []struct {
+			event *Event
+			owner int
+			gone  bool
+		}{
+			{fired, alice, true},
+			{completed, alice, true},
+			{recent, alice, false},
+			{unfired, alice, false},
+			{kept, bob, false},
+		} {
+			_, err := s.Events.Get(ctx, test.owner, test.event.Name)
+			if gone := errors.Is(err, ErrNotFound); gone != test.gone || err != nil && !gone {
+				t.Errorf("%s after Purge: got %v, want gone %t", test.event.Name, err, test.gone)
+			}
+		}
+	})
+}
+
+func TestPurgeTrash(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		createEvent(t, s, alice, "deleted", 0)
+		createEvent(t, s, alice, "kept", 1)
+		if err := s.Events.Delete(ctx, alice, "deleted"); err != nil {
+			t.Fatal(err)
+		}
+		trash, err := s.Events.Trash(ctx, alice)
+		if err != nil || len(trash) != 1 || trash[0].DeletedAt == nil {
+			t.Fatalf("trash: got %+v, %v, want the deleted event", trash, err)
+		}
+		deletedAt := *trash[0].DeletedAt
+
+		// Within the retention the event stays in the trash
+		if purged, err := s.Events.PurgeTrash(ctx, deletedAt, 100); err != nil || purged != 0 {
+			t.Fatalf("within retention: got %d purged, %v, want none", purged, err)
+		}
+		if trash, err := s.Events.Trash(ctx, alice); err != nil || len(trash) != 1 {
+			t.Fatalf("within retention: got trash %+v, %v, want the deleted event", trash, err)
+		}
+
+		// Past it the event is gone for good, and events outside the trash remain
+		if purged, err := s.Events.PurgeTrash(ctx, deletedAt.Add(time.Second), 100); err != nil || purged != 1 {
+			t.Fatalf("past retention: got %d purged, %v, want 1", purged, err)
+		}
+		if trash, err := s.Events.Trash(ctx, alice); err != nil || len(trash) != 0 {
+			t.Fatalf("past retention: got trash %+v, %v, want it empty", trash, err)
+		}
+		if _, err := s.Events.Restore(ctx, alice, trash[0].ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Restore of a purged event: got %v, want ErrNotFound", err)
+		}
+		if _, err := s.Events.Get(ctx, alice, "kept"); err != nil {
+			t.Fatalf("kept: got %v", err)
+		}
+	})
+}
+
+func TestOrganizationsIsolateEvents(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		carol := createUser(t, s, "carol")
+		dave := createUser(t, s, "dave")
+		standup := createEvent(t, s, alice, "standup", 0)
+		createEvent(t, s, carol, "dentist", 1)
+		createEvent(t, s, dave, "retro", 2)
+
+		org, err := s.Orgs.Create(ctx, alice, "Acme", "acme-code")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Orgs.Join(ctx, bob, org.InviteCode); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Orgs.Create(ctx, dave, "Globex", "globex-code"); err != nil {
+			t.Fatal(err)
+		}
+
+		// list returns the names of the events a user sees
+		list := func(userID int) []string {
+			t.Helper()
+			events, _, err := s.Events.List(ctx, userID, EventFilter{}, Page{Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, event := range events {
+				names = append(names, event.Name)
+			}
+			return names
+		}
+
+		// The members share the events of their organization
+		if names := list(bob); len(names) != 1 || names[0] != "standup" {
+			t.Fatalf("bob: got %v, want the standup of alice", names)
+		}
+		if _, err := s.Events.Update(ctx, bob, "standup", func(event *Event) error {
+			event.Message = "Standup moved"
+			return nil
+		}); err != nil {
+			t.Fatalf("bob update: %v", err)
+		}
+
+		// The others find none of them
+		for _, user := range []int{carol, dave} {
+			if names := list(user); len(names) != 1 || names[0] == "standup" {
+				t.Fatalf("user %d: got %v, want only their own event", user, names)
+			}
+			if _, err := s.Events.Get(ctx, user, "standup"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("user %d get: got %v, want ErrNotFound", user, err)
+			}
+			if _, err := s.Events.NameByID(ctx, user, standup.ID); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("user %d by id: got %v, want ErrNotFound", user, err)
+			}
+			if _, err := s.Events.Update(ctx, user, "standup", func(event *Event) error {
+				event.Message = "Taken over"
+				return nil
+			}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("user %d update: got %v, want ErrNotFound", user, err)
+			}
+			if err := s.Events.Delete(ctx, user, "standup"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("user %d delete: got %v, want ErrNotFound", user, err)
+			}
+		}
+		if event, err := s.Events.Get(ctx, alice, "standup"); err != nil || event.Message != "Standup moved" {
+			t.Fatalf("alice: got %+v, %v, want the message bob set", event, err)
+		}
+
+		// Nor do the members reach the events outside their organization
+		for _, name := range []string{"dentist", "retro"} {
+			if err := s.Events.Delete(ctx, alice, name); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("alice delete %s: got %v, want ErrNotFound", name, err)
+			}
+		}
+	})
+}
+
+func TestTemplates(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		template := &Template{NamePattern: "standup-{date}", Message: "Daily standup", Priority: "high",
+			URL: "https://meet.example.com/standup", Channels: []string{"email", "webhook"},
+			Recipients: []string{"\"Smith, Bob\" <bob@example.com>", "carol@example.com"}}
+		if err := s.Templates.Create(ctx, alice, template); err != nil {
+			t.Fatal(err)
+		}
+		if template.ID == 0 || template.CreatedAt.IsZero() {
+			t.Fatalf("got %+v, want an id and creation time", template)
+		}
+		second := &Template{NamePattern: "review", Message: "Weekly review", Priority: "normal", Channels: []string{}}
+		if err := s.Templates.Create(ctx, alice, second); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Templates.Get(ctx, alice, template.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.NamePattern != template.NamePattern || got.Message != template.Message || got.URL != template.URL ||
+			fmt.Sprint(got.Channels) != fmt.Sprint(template.Channels) || fmt.Sprint(got.Recipients) != fmt.Sprint(template.Recipients) {
+			t.Fatalf("got %+v, want %+v", got, template)
+		}
+
+		// Templates belong to their user
+		if _, err := s.Templates.Get(ctx, bob, template.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("bob: got %v, want ErrNotFound", err)
+		}
+		templates, err := s.Templates.List(ctx, alice)
+		if err != nil || len(templates) != 2 || templates[0].ID != template.ID || templates[1].ID != second.ID {
+			t.Fatalf("alice: got %+v, %v, want both templates oldest first", templates, err)
+		}
+		if templates, err := s.Templates.List(ctx, bob); err != nil || len(templates) != 0 {
+			t.Fatalf("bob: got %+v, %v, want none", templates, err)
+		}
+	})
+}
+
+func TestDeleteIf(t *testing.T) {
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		standup := createEvent(t, s, alice, "standup", 0)
+
+		// A failing check leaves the event in place and is returned as is
+		stale := errors.New("stale")
+		var checked *Event
+		err := s.Events.DeleteIf(ctx, alice, "standup", func(event *Event) error {
+			checked = event
+			return stale
+		})
+		if !errors.Is(err, stale) {
+			t.Fatalf("failing check: got %v, want its error", err)
+		}
+		if checked == nil || checked.ID != standup.ID || !checked.UpdatedAt.Equal(standup.UpdatedAt) {
+			t.Fatalf("failing check: checked %+v, want the stored event", checked)
+		}
+		if _, err := s.Events.Get(ctx, alice, "standup"); err != nil {
+			t.Fatalf("failing check: got %v, want the event kept", err)
+		}
+
+		// The check only sees the user's own events
+		if err := s.Events.DeleteIf(ctx, bob, "standup", func(*Event) error { return nil }); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("bob: got %v, want ErrNotFound", err)
+		}
+
+		if err := s.Events.DeleteIf(ctx, alice, "standup", func(*Event) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Events.Get(ctx, alice, "standup"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("passing check: got %v, want the event deleted", err)
+		}
+	})
+}
+
+func TestUnicodeTextRoundTrips(t *testing.T) {
+	// Emoji lie outside the Basic Multilingual Plane, which MySQL's three-byte utf8 cannot store
+	const message = "Mum's birthday 🎂🎉 — 誕生日おめでとう, День рождения, café, 👩‍👩‍👧 done ✅"
+	eachStore(t, func(t *testing.T, s *Store) {
+		ctx := context.Background()
+		alice := createUser(t, s, "alice")
+		event := &Event{Name: "birthday", Message: message, Date: "2030-01-01T09:00:00Z", Tags: []string{"família", "🎂"}}
+		if err := s.Events.Create(ctx, alice, event); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Events.Get(ctx, alice, "birthday")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Message != message || fmt.Sprint(got.Tags) != fmt.Sprint(event.Tags) {
+			t.Fatalf("got message %q and tags %q, want %q and %q", got.Message, got.Tags, message, event.Tags)
+		}
+
+		// The text is searched as stored
+		events, _, err := s.Events.List(ctx, alice, EventFilter{Search: "🎂🎉"}, Page{Limit: 10})
+		if err != nil || len(events) != 1 || events[0].Message != message {
+			t.Fatalf("search: got %+v, %v, want the event", events, err)
+		}
+
+		updated := message + " 🥳"
+		if _, err := s.Events.Update(ctx, alice, "birthday", func(event *Event) error {
+			event.Message = updated
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.Events.Get(ctx, alice, "birthday"); err != nil || got.Message != updated {
+			t.Fatalf("after update: got %+v, %v, want message %q", got, err, updated)
+		}
+	})
+}
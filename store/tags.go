@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"strings"
+)
+
+// Tag struct describes a tag in use on the events a user can see, with how many events carry it.
+type Tag struct {
+	Name   string `json:"name"`
+	Events int    `json:"events"`
+}
+
+// TagStore manages the tags of events across all the events a user can see. Tags are set on the
+// events themselves, through EventStore; a tag exists as long as an event carries it. Renaming or
+// deleting a tag changes the UpdatedAt of every event carrying it.
+type TagStore interface {
+	// List returns the tags of the events a user can see, in alphabetical order.
+	List(ctx context.Context, userID int) ([]Tag, error)
+	// Rename replaces tag with newTag on every event carrying it, merging it into newTag on events
+	// that carry both, and returns how many events carried tag. It returns ErrNotFound if none did.
+	Rename(ctx context.Context, userID int, tag, newTag string) (int, error)
+	// Delete removes tag from every event carrying it and returns how many did, or ErrNotFound if
+	// none did.
+	Delete(ctx context.Context, userID int, tag string) (int, error)
+}
+
+// sqlTags implements TagStore on the event_tags table.
+type sqlTags struct {
+	db *database.DB
+}
+
+func (s *sqlTags) List(ctx context.Context, userID int) ([]Tag, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT tag, COUNT(*) FROM event_tags WHERE event_id IN (SELECT id FROM events WHERE "+eventScope+")"+
+		" GROUP BY tag ORDER BY tag", userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Name, &tag.Events); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *sqlTags) Rename(ctx context.Context, userID int, tag, newTag string) (int, error) {
+	var tagged []int
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		var err error
+		if tagged, err = lockTagged(ctx, tx, userID, tag); err != nil {
+			return err
+		}
+
+		// Both tags are dropped and newTag added back, so events carrying both end up with it once
+		placeholders, args := idPlaceholders(tagged)
+		if _, err := tx.ExecContext(ctx, "DELETE FROM event_tags WHERE tag IN (?, ?) AND event_id IN ("+placeholders+")",
+			append([]interface{}{tag, newTag}, args...)...); err != nil {
+			return err
+		}
+		values := make([]interface{}, 0, 2*len(tagged))
+		for _, id := range tagged {
+			values = append(values, id, newTag)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO event_tags (event_id, tag) VALUES "+
+			strings.TrimSuffix(strings.Repeat("(?, ?), ", len(tagged)), ", "), values...); err != nil {
+			return err
+		}
+		return touchEvents(ctx, tx, tagged)
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return len(tagged), nil
+}
+
+func (s *sqlTags) Delete(ctx context.Context, userID int, tag string) (int, error) {
+	var tagged []int
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		var err error
+		if tagged, err = lockTagged(ctx, tx, userID, tag); err != nil {
+			return err
+		}
+
+		placeholders, args := idPlaceholders(tagged)
+		if _, err := tx.ExecContext(ctx, "DELETE FROM event_tags WHERE tag = ? AND event_id IN ("+placeholders+")",
+			append([]interface{}{tag}, args...)...); err != nil {
+			return err
+		}
+		return touchEvents(ctx, tx, tagged)
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return len(tagged), nil
+}
+
+// lockTagged returns the ids of the events a user can see that carry tag, locking them until tx
+// ends, or ErrNotFound if there are none.
+func lockTagged(ctx context.Context, tx *database.Tx, userID int, tag string) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM events WHERE "+eventScope+" AND id IN (SELECT event_id FROM event_tags WHERE tag = ?)"+
+		" ORDER BY id FOR UPDATE", userID, userID, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrNotFound
+	}
+	return ids, nil
+}
+
+// touchEvents sets a new UpdatedAt on the events with the given ids, whose tags changed.
+func touchEvents(ctx context.Context, tx *database.Tx, ids []int) error {
+	placeholders, args := idPlaceholders(ids)
+	_, err := tx.ExecContext(ctx, "UPDATE events SET updated_at = ? WHERE id IN ("+placeholders+")",
+		append([]interface{}{eventTimestamp()}, args...)...)
+	return err
+}
+
+// memoryTags implements TagStore on the events in memory.
+type memoryTags struct {
+	*memory
+}
+
+func (s *memoryTags) List(ctx context.Context, userID int) ([]Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, event := range (&memoryEvents{s.memory}).owned(userID) {
+		for _, tag := range event.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, Tag{Name: name, Events: count})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (s *memoryTags) Rename(ctx context.Context, userID int, tag, newTag string) (int, error) {
+	return s.retag(userID, tag, func(tags []string) []string {
+		renamed := []string{newTag}
+		for _, other := range tags {
+			if other != tag && other != newTag {
+				renamed = append(renamed, other)
+			}
+		}
+		sort.Strings(renamed)
+		return renamed
+	})
+}
+
+func (s *memoryTags) Delete(ctx context.Context, userID int, tag string) (int, error) {
+	return s.retag(userID, tag, func(tags []string) []string {
+		remaining := []string{}
+		for _, other := range tags {
+			if other != tag {
+				remaining = append(remaining, other)
+			}
+		}
+		return remaining
+	})
+}
+
+// retag replaces the tags of every event a user can see that carries tag with the result of
+// change, and returns how many events there were, or ErrNotFound if there were none.
+func (s *memoryTags) retag(userID int, tag string, change func([]string) []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := &memoryEvents{s.memory}
+	changed := 0
+	for _, stored := range s.events {
+		if !events.visible(userID, stored) || !containsString(stored.event.Tags, tag) {
+			continue
+		}
+		// change returns a new slice, so copies of the event handed out earlier keep their tags
+		stored.event.Tags = change(stored.event.Tags)
+		stored.event.UpdatedAt = eventTimestamp()
+		changed++
+	}
+	if changed == 0 {
+		return 0, ErrNotFound
+	}
+	return changed, nil
+}
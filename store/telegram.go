@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sync"
+	"time"
+)
+
+// TelegramChat struct describes the Telegram chat a user linked to receive reminders in and create
+// events from. Username is the Telegram username of the person chatting, which may be empty.
+type TelegramChat struct {
+	ChatID   int64     `json:"chat_id"`
+	Username string    `json:"username"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// TelegramStore persists the Telegram chats users linked, at most one per user and one user per
+// chat, and the one-time codes chats are linked with. Codes are identified by their hash, expire
+// after a while, and each one works once.
+type TelegramStore interface {
+	// CreateCode stores a new link code of a user, valid until expiresAt, replacing any code the
+	// user was given before.
+	CreateCode(ctx context.Context, userID int, hash string, expiresAt time.Time) error
+	// Link uses up the active code with hash and links chat to its user, setting LinkedAt. The chat
+	// the user linked before, and any other user chat was linked to, are unlinked. It returns the
+	// user, or ErrNotFound if the code is unknown, used, or expired at now.
+	Link(ctx context.Context, hash string, chat *TelegramChat, now time.Time) (int, error)
+	// Chat returns the chat a user linked, or ErrNotFound if they have none.
+	Chat(ctx context.Context, userID int) (*TelegramChat, error)
+	// UserByChat returns the user a chat is linked to, or ErrNotFound.
+	UserByChat(ctx context.Context, chatID int64) (int, error)
+	// Unlink removes the chat of a user, or returns ErrNotFound if they have none.
+	Unlink(ctx context.Context, userID int) error
+	// UnlinkChat removes a chat from whichever user linked it, e.g. once the bot was blocked in
+	// it, or returns ErrNotFound if it is not linked.
+	UnlinkChat(ctx context.Context, chatID int64) error
+}
+
+// sqlTelegram implements TelegramStore on the telegram_chats and telegram_link_codes tables.
+type sqlTelegram struct {
+	db *database.DB
+}
+
+func (s *sqlTelegram) CreateCode(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO telegram_link_codes (user_id, code_hash, expires_at) VALUES (?, ?, ?)"+
+		s.db.Dialect.Upsert("user_id", "code_hash", "expires_at"),
+		userID, hash, expiresAt.UTC())
+	return mapError(err)
+}
+
+func (s *sqlTelegram) Link(ctx context.Context, hash string, chat *TelegramChat, now time.Time) (int, error) {
+	var userID int
+	linkedAt := now.UTC().Truncate(time.Second)
+
+	// Lock the code so two chats cannot both be linked with it
+	err := database.WithTx(ctx, s.db, func(tx *database.Tx) error {
+		err := tx.QueryRowContext(ctx, "SELECT user_id FROM telegram_link_codes WHERE code_hash = ? AND expires_at > ? FOR UPDATE",
+			hash, now.UTC()).Scan(&userID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM telegram_link_codes WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM telegram_chats WHERE user_id = ? OR chat_id = ?", userID, chat.ChatID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO telegram_chats (user_id, chat_id, username, linked_at) VALUES (?, ?, ?, ?)",
+			userID, chat.ChatID, chat.Username, linkedAt)
+		return err
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	chat.LinkedAt = linkedAt
+	return userID, nil
+}
+
+func (s *sqlTelegram) Chat(ctx context.Context, userID int) (*TelegramChat, error) {
+	chat := new(TelegramChat)
+	err := s.db.QueryRowContext(ctx, "SELECT chat_id, username, linked_at FROM telegram_chats WHERE user_id = ?", userID).
+		Scan(&chat.ChatID, &chat.Username, &chat.LinkedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return chat, nil
+}
+
+func (s *sqlTelegram) UserByChat(ctx context.Context, chatID int64) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM telegram_chats WHERE chat_id = ?", chatID).Scan(&userID)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return userID, nil
+}
+
+func (s *sqlTelegram) Unlink(ctx context.Context, userID int) error {
+	return s.delete(ctx, "user_id", int64(userID))
+}
+
+func (s *sqlTelegram) UnlinkChat(ctx context.Context, chatID int64) error {
+	return s.delete(ctx, "chat_id", chatID)
+}
+
+// delete removes the chat whose column holds value, or returns ErrNotFound.
+func (s *sqlTelegram) delete(ctx context.Context, column string, value int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM telegram_chats WHERE "+column+" = ?", value)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// memoryTelegramCode is a link code together with its owner and expiry.
+type memoryTelegramCode struct {
+	userID    int
+	expiresAt time.Time
+}
+
+// memoryTelegram implements TelegramStore in memory.
+type memoryTelegram struct {
+	mu    sync.Mutex
+	chats map[int]TelegramChat
+	codes map[string]memoryTelegramCode
+}
+
+func (s *memoryTelegram) CreateCode(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.codes[hash]; ok {
+		return ErrDuplicate
+	}
+	s.dropCodes(userID)
+	s.codes[hash] = memoryTelegramCode{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *memoryTelegram) Link(ctx context.Context, hash string, chat *TelegramChat, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.codes[hash]
+	if !ok || !code.expiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+	s.dropCodes(code.userID)
+
+	for userID, linked := range s.chats {
+		if linked.ChatID == chat.ChatID {
+			delete(s.chats, userID)
+		}
+	}
+	chat.LinkedAt = now.UTC().Truncate(time.Second)
+	s.chats[code.userID] = *chat
+	return code.userID, nil
+}
+
+func (s *memoryTelegram) Chat(ctx context.Context, userID int) (*TelegramChat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &chat, nil
+}
+
+func (s *memoryTelegram) UserByChat(ctx context.Context, chatID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, chat := range s.chats {
+		if chat.ChatID == chatID {
+			return userID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (s *memoryTelegram) Unlink(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.chats, userID)
+	return nil
+}
+
+func (s *memoryTelegram) UnlinkChat(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, chat := range s.chats {
+		if chat.ChatID == chatID {
+			delete(s.chats, userID)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// dropCodes removes the link codes of a user. The lock must be held.
+func (s *memoryTelegram) dropCodes(userID int) {
+	for hash, code := range s.codes {
+		if code.userID == userID {
+			delete(s.codes, hash)
+		}
+	}
+}
+
+// deleteUser removes the chat and link codes of a deleted user.
+func (s *memoryTelegram) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.chats, userID)
+	s.dropCodes(userID)
+}
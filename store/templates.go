@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Template struct holds the defaults of a kind of event a user creates often. Events are created
+// from it with a date, and named after NamePattern unless a name is given.
+type Template struct {
+	ID int `json:"id" form:"-"`
+	// NamePattern names the events created from the template; {date} stands for their date as YYYY-MM-DD
+	NamePattern string   `json:"name_pattern" form:"name_pattern" validate:"required,namepattern"`
+	Message     string   `json:"message" form:"message" validate:"required,max=65535"`
+	Priority    string   `json:"priority" form:"priority" validate:"omitempty,oneof=low normal high urgent"`
+	URL         string   `json:"url" form:"url" validate:"omitempty,max=2048,http_url"`
+	Channels    []string `json:"channels" form:"channels" validate:"unique,dive,channel"`
+	Recipients  []string `json:"recipients" form:"recipients" validate:"max=10,unique,dive,address"`
+	// CreatedAt is maintained by the store; values in request bodies are ignored
+	CreatedAt time.Time `json:"created_at" form:"-"`
+}
+
+// TemplateStore persists the event templates of users.
+type TemplateStore interface {
+	// Create stores a new template for a user, setting its ID and CreatedAt.
+	Create(ctx context.Context, userID int, template *Template) error
+	// List returns the templates of a user, oldest first.
+	List(ctx context.Context, userID int) ([]Template, error)
+	// Get returns one of a user's templates, or ErrNotFound.
+	Get(ctx context.Context, userID, id int) (*Template, error)
+}
+
+// Columns selected for a template, in the order scanTemplate reads them
+const templateColumns = "id, name_pattern, message, priority, url, channels, recipients, created_at"
+
+// scanTemplate reads a row selected with templateColumns into template.
+func scanTemplate(row rowScanner, template *Template) error {
+	var channels, recipients string
+	err := row.Scan(&template.ID, &template.NamePattern, &template.Message, &template.Priority, &template.URL,
+		&channels, &recipients, &template.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	template.Channels = splitChannels(channels)
+	return json.Unmarshal([]byte(recipients), &template.Recipients)
+}
+
+// sqlTemplates implements TemplateStore on the templates table.
+type sqlTemplates struct {
+	db *database.DB
+}
+
+func (s *sqlTemplates) Create(ctx context.Context, userID int, template *Template) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	// Addresses may contain commas, so recipients are stored as a JSON array
+	recipients, err := json.Marshal(template.Recipients)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.db.InsertID(ctx, "INSERT INTO templates (user_id, name_pattern, message, priority, url, channels, recipients, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		userID, template.NamePattern, template.Message, template.Priority, template.URL, joinChannels(template.Channels), string(recipients), now)
+	if err != nil {
+		return mapError(err)
+	}
+	template.ID = int(id)
+	template.CreatedAt = now
+	return nil
+}
+
+func (s *sqlTemplates) List(ctx context.Context, userID int) ([]Template, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+templateColumns+" FROM templates WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		var template Template
+		if err := scanTemplate(rows, &template); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+func (s *sqlTemplates) Get(ctx context.Context, userID, id int) (*Template, error) {
+	template := new(Template)
+	err := scanTemplate(s.db.QueryRowContext(ctx, "SELECT "+templateColumns+" FROM templates WHERE id = ? AND user_id = ?", id, userID), template)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return template, nil
+}
+
+// memoryTemplate is a template together with the user owning it.
+type memoryTemplate struct {
+	userID   int
+	template Template
+}
+
+// memoryTemplates implements TemplateStore in memory.
+type memoryTemplates struct {
+	mu        sync.Mutex
+	templates map[int]*memoryTemplate
+	lastID    int
+}
+
+func (s *memoryTemplates) Create(ctx context.Context, userID int, template *Template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	template.ID = s.lastID
+	template.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	s.templates[template.ID] = &memoryTemplate{userID: userID, template: *template}
+	return nil
+}
+
+func (s *memoryTemplates) List(ctx context.Context, userID int) ([]Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	templates := []Template{}
+	for _, stored := range s.templates {
+		if stored.userID == userID {
+			templates = append(templates, stored.template)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	return templates, nil
+}
+
+func (s *memoryTemplates) Get(ctx context.Context, userID, id int) (*Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.templates[id]
+	if !ok || stored.userID != userID {
+		return nil, ErrNotFound
+	}
+	template := stored.template
+	return &template, nil
+}
+
+// deleteUser removes the templates of a deleted user.
+func (s *memoryTemplates) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stored := range s.templates {
+		if stored.userID == userID {
+			delete(s.templates, id)
+		}
+	}
+}
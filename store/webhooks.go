@@ -0,0 +1,328 @@
+package store
+
+import (
+	"context"
+	"github.com/Vansh3140/Reminder-App/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Webhook struct describes a URL a user registered to be called when their reminders fire.
+// Secret signs the calls; it is only shown to the user when the webhook is created.
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookAttempt struct records one call of a webhook. StatusCode is the HTTP status the endpoint
+// answered with, or 0 if it could not be reached.
+type WebhookAttempt struct {
+	ID         int       `json:"id"`
+	WebhookID  int       `json:"webhook_id"`
+	DeliveryID int       `json:"delivery_id"`
+	EventID    int       `json:"event_id"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookStore persists the webhooks of users and the log of their calls.
+type WebhookStore interface {
+	// Create stores a new webhook of a user and returns it.
+	Create(ctx context.Context, userID int, url, secret string) (*Webhook, error)
+	// List returns the webhooks of a user with their secrets, oldest first.
+	List(ctx context.Context, userID int) ([]Webhook, error)
+	// Delete removes one of a user's webhooks and its log, or returns ErrNotFound.
+	Delete(ctx context.Context, userID, id int) error
+	// RecordAttempt stores a call of a webhook, setting its ID and CreatedAt.
+	RecordAttempt(ctx context.Context, attempt *WebhookAttempt) error
+	// Attempts returns one page of the calls of one of a user's webhooks, newest first, and whether
+	// more follow. Keyset pages are ordered by id. It returns ErrNotFound for an unknown webhook.
+	Attempts(ctx context.Context, userID, webhookID int, page Page) ([]WebhookAttempt, bool, error)
+	// CountAttempts returns how many calls of a webhook are logged.
+	CountAttempts(ctx context.Context, webhookID int) (int, error)
+	// Accepted returns the ids of the webhooks that answered a call for a delivery with a 2xx status.
+	Accepted(ctx context.Context, deliveryID int) (map[int]bool, error)
+}
+
+// Columns selected for a webhook attempt, in the order scanWebhookAttempt reads them
+const webhookAttemptColumns = "id, webhook_id, delivery_id, event_id, status_code, error, created_at"
+
+// scanWebhookAttempt reads a row selected with webhookAttemptColumns into attempt.
+func scanWebhookAttempt(row rowScanner, attempt *WebhookAttempt) error {
+	return row.Scan(&attempt.ID, &attempt.WebhookID, &attempt.DeliveryID, &attempt.EventID, &attempt.StatusCode, &attempt.Error, &attempt.CreatedAt)
+}
+
+// sqlWebhooks implements WebhookStore on the webhooks and webhook_attempts tables.
+type sqlWebhooks struct {
+	db *database.DB
+}
+
+func (s *sqlWebhooks) Create(ctx context.Context, userID int, url, secret string) (*Webhook, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	id, err := s.db.InsertID(ctx, "INSERT INTO webhooks (user_id, url, secret, created_at) VALUES (?, ?, ?, ?)", userID, url, secret, now)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &Webhook{ID: int(id), URL: url, Secret: secret, CreatedAt: now}, nil
+}
+
+func (s *sqlWebhooks) List(ctx context.Context, userID int) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, url, secret, created_at FROM webhooks WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *sqlWebhooks) Delete(ctx context.Context, userID, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlWebhooks) RecordAttempt(ctx context.Context, attempt *WebhookAttempt) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	id, err := s.db.InsertID(ctx, "INSERT INTO webhook_attempts (webhook_id, delivery_id, event_id, status_code, error, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		attempt.WebhookID, attempt.DeliveryID, attempt.EventID, attempt.StatusCode, attempt.Error, now)
+	if err != nil {
+		return err
+	}
+	attempt.ID = int(id)
+	attempt.CreatedAt = now
+	return nil
+}
+
+func (s *sqlWebhooks) Attempts(ctx context.Context, userID, webhookID int, page Page) ([]WebhookAttempt, bool, error) {
+	var owner int
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM webhooks WHERE id = ? AND user_id = ?", webhookID, userID).Scan(&owner)
+	if err != nil {
+		return nil, false, mapError(err)
+	}
+
+	// Fetch one row more than requested to learn whether another page follows
+	var rows *database.Rows
+	if page.Keyset {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+webhookAttemptColumns+" FROM webhook_attempts WHERE webhook_id = ? AND id > ? ORDER BY id LIMIT ?",
+			webhookID, page.AfterID, page.Limit+1)
+	} else {
+		rows, err = s.db.QueryContext(ctx, "SELECT "+webhookAttemptColumns+" FROM webhook_attempts WHERE webhook_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+			webhookID, page.Limit+1, page.Offset)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	attempts := []WebhookAttempt{}
+	for rows.Next() {
+		var attempt WebhookAttempt
+		if err := scanWebhookAttempt(rows, &attempt); err != nil {
+			return nil, false, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(attempts) > page.Limit {
+		return attempts[:page.Limit], true, nil
+	}
+	return attempts, false, nil
+}
+
+func (s *sqlWebhooks) CountAttempts(ctx context.Context, webhookID int) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_attempts WHERE webhook_id = ?", webhookID).Scan(&count)
+	return count, err
+}
+
+func (s *sqlWebhooks) Accepted(ctx context.Context, deliveryID int) (map[int]bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT webhook_id FROM webhook_attempts WHERE delivery_id = ? AND status_code BETWEEN 200 AND 299", deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accepted := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		accepted[id] = true
+	}
+	return accepted, rows.Err()
+}
+
+// memoryWebhook is a webhook together with its owner.
+type memoryWebhook struct {
+	userID  int
+	webhook Webhook
+}
+
+// memoryWebhooks implements WebhookStore in memory.
+type memoryWebhooks struct {
+	mu       sync.Mutex
+	webhooks map[int]*memoryWebhook
+	attempts []WebhookAttempt
+	lastID   int
+	// lastAttemptID is the id of the latest attempt
+	lastAttemptID int
+}
+
+func (s *memoryWebhooks) Create(ctx context.Context, userID int, url, secret string) (*Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	webhook := Webhook{ID: s.lastID, URL: url, Secret: secret, CreatedAt: time.Now().UTC()}
+	s.webhooks[webhook.ID] = &memoryWebhook{userID: userID, webhook: webhook}
+	return &webhook, nil
+}
+
+func (s *memoryWebhooks) List(ctx context.Context, userID int) ([]Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks := []Webhook{}
+	for _, stored := range s.webhooks {
+		if stored.userID == userID {
+			webhooks = append(webhooks, stored.webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (s *memoryWebhooks) Delete(ctx context.Context, userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.webhooks[id]
+	if !ok || stored.userID != userID {
+		return ErrNotFound
+	}
+	delete(s.webhooks, id)
+
+	kept := s.attempts[:0]
+	for _, attempt := range s.attempts {
+		if attempt.WebhookID != id {
+			kept = append(kept, attempt)
+		}
+	}
+	s.attempts = kept
+	return nil
+}
+
+func (s *memoryWebhooks) RecordAttempt(ctx context.Context, attempt *WebhookAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Deleting a webhook removes attempts, so ids continue from the last one rather than the count
+	s.lastAttemptID++
+	attempt.ID = s.lastAttemptID
+	attempt.CreatedAt = time.Now().UTC()
+	s.attempts = append(s.attempts, *attempt)
+	return nil
+}
+
+func (s *memoryWebhooks) Attempts(ctx context.Context, userID, webhookID int, page Page) ([]WebhookAttempt, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.webhooks[webhookID]
+	if !ok || stored.userID != userID {
+		return nil, false, ErrNotFound
+	}
+
+	attempts := []WebhookAttempt{}
+	for _, attempt := range s.attempts {
+		if attempt.WebhookID == webhookID && (!page.Keyset || attempt.ID > page.AfterID) {
+			attempts = append(attempts, attempt)
+		}
+	}
+	if !page.Keyset {
+		sort.Slice(attempts, func(i, j int) bool { return attempts[i].ID > attempts[j].ID })
+		if page.Offset >= len(attempts) {
+			return []WebhookAttempt{}, false, nil
+		}
+		attempts = attempts[page.Offset:]
+	}
+
+	if len(attempts) > page.Limit {
+		return attempts[:page.Limit], true, nil
+	}
+	return attempts, false, nil
+}
+
+func (s *memoryWebhooks) CountAttempts(ctx context.Context, webhookID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, attempt := range s.attempts {
+		if attempt.WebhookID == webhookID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryWebhooks) Accepted(ctx context.Context, deliveryID int) (map[int]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accepted := map[int]bool{}
+	for _, attempt := range s.attempts {
+		if attempt.DeliveryID == deliveryID && attempt.StatusCode >= 200 && attempt.StatusCode <= 299 {
+			accepted[attempt.WebhookID] = true
+		}
+	}
+	return accepted, nil
+}
+
+// deleteUser removes the webhooks of a deleted user along with their attempts.
+func (s *memoryWebhooks) deleteUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := map[int]bool{}
+	for id, stored := range s.webhooks {
+		if stored.userID == userID {
+			delete(s.webhooks, id)
+			deleted[id] = true
+		}
+	}
+	kept := s.attempts[:0]
+	for _, attempt := range s.attempts {
+		if !deleted[attempt.WebhookID] {
+			kept = append(kept, attempt)
+		}
+	}
+	s.attempts = kept
+}